@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	firebase "firebase.google.com/go"
+	"firebase.google.com/go/auth"
+	"github.com/gorilla/mux"
+)
+
+// Firebase auth provider, client signs in with FirebaseUI and posts ID token to /sessionLogin
+type FirebaseAuthProvider struct {
+	su         *SessionUtil
+	authClient *auth.Client
+}
+
+func newFirebaseAuthProvider(su *SessionUtil, fireapp *firebase.App) (*FirebaseAuthProvider, error) {
+	authClient, err := fireapp.Auth(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("firebase.Auth: %w", err)
+	}
+
+	return &FirebaseAuthProvider{su, authClient}, nil
+}
+
+func (p *FirebaseAuthProvider) name() string {
+	return "firebase"
+}
+
+func (p *FirebaseAuthProvider) registerHandlers(r *mux.Router) {
+	r.Methods("POST").Path("/sessionLogin").Handler(appHandler(p.sessionLogin))
+}
+
+func (p *FirebaseAuthProvider) sessionLogin(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	// Get the tokens sent by the client
+	idToken := r.FormValue("idToken")
+
+	// Decode the IDToken
+	decoded, err := p.authClient.VerifyIDToken(ctx, idToken)
+	if err != nil {
+		str := err.Error()
+		http.Error(w, str, http.StatusUnauthorized)
+		return err
+	}
+	time_now := time.Now().Unix()
+	claimed_auth_time := int64(decoded.Claims["auth_time"].(float64))
+	// Return error if the sign-in is older than 5 minutes.
+	if time_now-claimed_auth_time > 5*60 {
+		err = errors.New(fmt.Sprintf("Recent sign-in required, claimed_auth_time=%v, time_now=%v", time_now, claimed_auth_time))
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	// Check if user exists in DB, add record otherwise
+	u, err := p.getUserRecord(ctx, decoded.UID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	err = p.su.startSession(w, r, p.name(), u)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	w.Write([]byte(`{"status": "success"}`))
+	return nil
+}
+
+func (p *FirebaseAuthProvider) getUserRecord(ctx context.Context, userId string) (*AuthUserRecord, error) {
+	u, err := p.authClient.GetUser(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get user record: %w", err)
+	}
+
+	return &AuthUserRecord{
+		UID:           u.UID,
+		DisplayName:   u.DisplayName,
+		Email:         u.Email,
+		PhoneNumber:   u.PhoneNumber,
+		EmailVerified: u.EmailVerified,
+	}, nil
+}
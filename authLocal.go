@@ -0,0 +1,257 @@
+package main
+
+import (
+	assist_db "assist/db"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const verifyEmailPurpose = "verifyEmail"
+const verifyEmailExpiresIn = 48 * time.Hour
+const minPasswordLength = 8
+
+// Local auth provider, users sign up with email and password which are kept
+// in the app DB (password is hashed with bcrypt)
+type LocalAuthProvider struct {
+	su     *SessionUtil
+	db     assist_db.Store
+	mailer *Mailer
+	// compared against when there is no such user, so that response time does
+	// not reveal registered emails
+	dummyHash []byte
+}
+
+type verifyEmailToken struct {
+	Email string `json:"email"`
+}
+
+func newLocalAuthProvider(su *SessionUtil, db assist_db.Store, mailer *Mailer) *LocalAuthProvider {
+	dummyHash, err := bcrypt.GenerateFromPassword([]byte(randomToken(16)), bcrypt.DefaultCost)
+	if err != nil {
+		log.Fatalf("Failed to init local auth provider: %v", err)
+	}
+
+	return &LocalAuthProvider{su, db, mailer, dummyHash}
+}
+
+func (p *LocalAuthProvider) name() string {
+	return "local"
+}
+
+func (p *LocalAuthProvider) registerHandlers(r *mux.Router) {
+	r.Methods("POST").Path("/auth/local/signup").Handler(appHandler(p.signup))
+	r.Methods("POST").Path("/auth/local/login").Handler(appHandler(p.login))
+	r.Methods("GET").Path("/auth/local/verify").Handler(appHandler(p.verifyEmail))
+	r.Methods("POST").Path("/auth/local/verify").Handler(appHandler(p.resendVerification))
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func (p *LocalAuthProvider) signup(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	email := normalizeEmail(r.FormValue("email"))
+	password := r.FormValue("password")
+	displayName := strings.TrimSpace(r.FormValue("displayName"))
+
+	if !strings.Contains(email, "@") || displayName == "" {
+		err := errors.New("Email and name are required")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+	if len(password) < minPasswordLength {
+		err := fmt.Errorf("Password should be at least %v characters long", minPasswordLength)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		err = fmt.Errorf("Failed to hash password: %w", err)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	u := &AuthUserRecord{
+		UID:         randomToken(15),
+		DisplayName: displayName,
+		Email:       email,
+	}
+
+	err = p.db.CreateCredentials(ctx, &assist_db.Credentials{
+		UserId:       u.UID,
+		Email:        email,
+		PasswordHash: string(hash),
+	})
+	if status.Code(err) == codes.AlreadyExists {
+		// responds as for new user to not disclose registered emails, owner
+		// of the address is notified instead
+		if err = p.sendAccountExists(r, email); err != nil {
+			log.Println(err.Error())
+		}
+		w.Write([]byte(`{"status": "verify"}`))
+		return nil
+	}
+	if err != nil {
+		err = fmt.Errorf("Failed to create user: %w", err)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	// session is started only after the email is verified, name is kept till then
+	err = p.db.UpdateUserInfoFromAuth(ctx, u.UID, &assist_db.UserInfo{
+		DisplayName: u.DisplayName,
+		Email:       u.Email,
+	})
+	if err != nil {
+		err = fmt.Errorf("Failed to create user: %w", err)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	if err = p.sendVerification(r, email); err != nil {
+		log.Println(err.Error())
+	}
+
+	w.Write([]byte(`{"status": "verify"}`))
+	return nil
+}
+
+func (p *LocalAuthProvider) login(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	email := normalizeEmail(r.FormValue("email"))
+	password := r.FormValue("password")
+
+	hash := p.dummyHash
+	c, err := p.db.GetCredentials(ctx, email)
+	if err == nil {
+		hash = []byte(c.PasswordHash)
+	}
+
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || c == nil {
+		err = errors.New("Invalid email or password")
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	if !c.EmailVerified {
+		err = errors.New("Email is not verified, please follow the link sent to " + email)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return err
+	}
+
+	u, err := p.getUserRecord(ctx, c.UserId)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	err = p.su.startSession(w, r, p.name(), u)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	w.Write([]byte(`{"status": "success"}`))
+	return nil
+}
+
+func (p *LocalAuthProvider) sendVerification(r *http.Request, email string) error {
+	token, err := p.su.sign(verifyEmailPurpose, &verifyEmailToken{email}, verifyEmailExpiresIn)
+	if err != nil {
+		return fmt.Errorf("Failed to create email verification token: %w", err)
+	}
+
+	link := p.su.baseURL(r) + "/auth/local/verify?token=" + url.QueryEscape(token)
+
+	return p.mailer.send([]string{email}, "Verify your email for Assist",
+		"Please follow the link below to verify your email:\r\n\r\n"+link+"\r\n\r\n"+
+			"If you did not sign up for Assist, just ignore this email.\r\n")
+}
+
+func (p *LocalAuthProvider) sendAccountExists(r *http.Request, email string) error {
+	link := p.su.baseURL(r) + "/login"
+
+	return p.mailer.send([]string{email}, "Your Assist account",
+		"Someone tried to sign up for Assist with this email, but an account already exists. "+
+			"You can log in here:\r\n\r\n"+link+"\r\n\r\n"+
+			"If it was not you, just ignore this email.\r\n")
+}
+
+func (p *LocalAuthProvider) verifyEmail(w http.ResponseWriter, r *http.Request) error {
+	token := &verifyEmailToken{}
+	err := p.su.verify(verifyEmailPurpose, r.FormValue("token"), token)
+	if err != nil {
+		err = fmt.Errorf("Invalid email verification link: %w", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	err = p.db.SetEmailVerified(r.Context(), token.Email)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	http.Redirect(w, r, "/login", http.StatusFound)
+	return nil
+}
+
+// sends verification email again, responds with success even if there is no
+// such email to not disclose registered users
+func (p *LocalAuthProvider) resendVerification(w http.ResponseWriter, r *http.Request) error {
+	email := normalizeEmail(r.FormValue("email"))
+
+	c, err := p.db.GetCredentials(r.Context(), email)
+	if err == nil && !c.EmailVerified {
+		if err = p.sendVerification(r, email); err != nil {
+			log.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+	}
+
+	w.Write([]byte(`{"status": "success"}`))
+	return nil
+}
+
+func (p *LocalAuthProvider) getUserRecord(ctx context.Context, userId string) (*AuthUserRecord, error) {
+	c, err := p.db.GetCredentialsByUserId(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get user record: %w", err)
+	}
+
+	u := &AuthUserRecord{
+		UID:           userId,
+		Email:         c.Email,
+		EmailVerified: c.EmailVerified,
+	}
+
+	// name and phone are maintained by user in the app
+	if ui, err := p.db.GetUserInfo(ctx, userId); err == nil {
+		u.DisplayName = ui.DisplayName
+		u.PhoneNumber = ui.PhoneNumber
+	}
+
+	return u, nil
+}
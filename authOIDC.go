@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/oauth2"
+)
+
+const oidcStateCookie = "oidcState"
+const oidcStateExpiresIn = 10 * time.Minute
+
+// Generic OpenID Connect provider (authorization code flow), configured with
+// OIDC_ISSUER, OIDC_CLIENT_ID, OIDC_CLIENT_SECRET and optional OIDC_REDIRECT_URL
+// (defaults to <app url>/auth/oidc/callback)
+type OIDCAuthProvider struct {
+	su          *SessionUtil
+	issuer      string
+	config      oauth2.Config
+	redirectURL string
+}
+
+type oidcState struct {
+	State string `json:"state"`
+	Nonce string `json:"nonce"`
+}
+
+// aud claim might be either string or array of strings
+type oidcAudience []string
+
+func (a *oidcAudience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = oidcAudience{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+func (a oidcAudience) contains(clientId string) bool {
+	for _, s := range a {
+		if s == clientId {
+			return true
+		}
+	}
+	return false
+}
+
+type oidcClaims struct {
+	Issuer        string       `json:"iss"`
+	Subject       string       `json:"sub"`
+	Audience      oidcAudience `json:"aud"`
+	Expires       int64        `json:"exp"`
+	Nonce         string       `json:"nonce"`
+	Name          string       `json:"name"`
+	Email         string       `json:"email"`
+	EmailVerified *bool        `json:"email_verified"`
+	PhoneNumber   string       `json:"phone_number"`
+}
+
+// name of the identity provider shown on login page
+func oidcName() string {
+	if name := os.Getenv("OIDC_NAME"); name != "" {
+		return name
+	}
+	return "Single Sign-On"
+}
+
+func newOIDCAuthProvider(su *SessionUtil) (*OIDCAuthProvider, error) {
+	issuer := os.Getenv("OIDC_ISSUER")
+	clientId := os.Getenv("OIDC_CLIENT_ID")
+	if issuer == "" || clientId == "" {
+		return nil, errors.New("OIDC_ISSUER and OIDC_CLIENT_ID should be set to use oidc auth provider")
+	}
+
+	// discover provider endpoints
+	res, err := http.Get(strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get OIDC provider configuration: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Failed to get OIDC provider configuration: %v", res.Status)
+	}
+
+	discovery := &struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+	}{}
+	if err = json.NewDecoder(res.Body).Decode(discovery); err != nil {
+		return nil, fmt.Errorf("Failed to parse OIDC provider configuration: %w", err)
+	}
+	if discovery.Issuer != issuer {
+		return nil, fmt.Errorf("OIDC provider reports issuer %v, expected %v", discovery.Issuer, issuer)
+	}
+	// token signature is not checked, so the token endpoint should be
+	// authenticated by TLS (see parseIDToken)
+	if !su.dev && (!strings.HasPrefix(issuer, "https://") || !strings.HasPrefix(discovery.TokenEndpoint, "https://")) {
+		return nil, fmt.Errorf("OIDC issuer and token endpoint should use https, got %v and %v", issuer, discovery.TokenEndpoint)
+	}
+
+	return &OIDCAuthProvider{
+		su:     su,
+		issuer: issuer,
+		config: oauth2.Config{
+			ClientID:     clientId,
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  discovery.AuthorizationEndpoint,
+				TokenURL: discovery.TokenEndpoint,
+			},
+			Scopes: []string{"openid", "profile", "email"},
+		},
+		redirectURL: os.Getenv("OIDC_REDIRECT_URL"),
+	}, nil
+}
+
+func (p *OIDCAuthProvider) name() string {
+	return "oidc"
+}
+
+func (p *OIDCAuthProvider) registerHandlers(r *mux.Router) {
+	r.Methods("GET").Path("/auth/oidc/login").Handler(appHandler(p.login))
+	r.Methods("GET").Path("/auth/oidc/callback").Handler(appHandler(p.callback))
+}
+
+func (p *OIDCAuthProvider) oauth2Config(r *http.Request) *oauth2.Config {
+	config := p.config
+	config.RedirectURL = p.redirectURL
+	if config.RedirectURL == "" {
+		config.RedirectURL = p.su.baseURL(r) + "/auth/oidc/callback"
+	}
+	return &config
+}
+
+func (p *OIDCAuthProvider) login(w http.ResponseWriter, r *http.Request) error {
+	state := &oidcState{
+		State: randomToken(16),
+		Nonce: randomToken(16),
+	}
+
+	cookie, err := p.su.sign(oidcStateCookie, state, oidcStateExpiresIn)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    cookie,
+		Path:     "/auth/oidc",
+		MaxAge:   int(oidcStateExpiresIn.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   !p.su.dev,
+	})
+
+	url := p.oauth2Config(r).AuthCodeURL(state.State, oauth2.SetAuthURLParam("nonce", state.Nonce))
+	http.Redirect(w, r, url, http.StatusFound)
+	return nil
+}
+
+func (p *OIDCAuthProvider) callback(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	if e := r.FormValue("error"); e != "" {
+		err := fmt.Errorf("OIDC provider returned error: %v %v", e, r.FormValue("error_description"))
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	state := &oidcState{}
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err == nil {
+		err = p.su.verify(oidcStateCookie, cookie.Value, state)
+	}
+	if err != nil || state.State != r.FormValue("state") {
+		err = fmt.Errorf("Invalid OIDC login state, please try to login again: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	token, err := p.oauth2Config(r).Exchange(ctx, r.FormValue("code"))
+	if err != nil {
+		err = fmt.Errorf("Failed to exchange OIDC code: %w", err)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	idToken, _ := token.Extra("id_token").(string)
+	claims, err := p.parseIDToken(idToken, state.Nonce)
+	if err != nil {
+		err = fmt.Errorf("Invalid OIDC ID token: %w", err)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	err = p.su.startSession(w, r, p.name(), &AuthUserRecord{
+		UID:           p.userId(claims.Subject),
+		DisplayName:   claims.Name,
+		Email:         claims.Email,
+		PhoneNumber:   claims.PhoneNumber,
+		EmailVerified: true,
+	})
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	http.Redirect(w, r, "/home", http.StatusFound)
+	return nil
+}
+
+// ID token is received directly from the token endpoint over TLS, so per
+// OpenID Connect Core 3.1.3.7 TLS server validation is used in place of
+// checking the token signature (https is required outside dev mode); the
+// claims are validated here
+func (p *OIDCAuthProvider) parseIDToken(idToken string, nonce string) (*oidcClaims, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("Malformed token")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &oidcClaims{}
+	if err = json.Unmarshal(payload, claims); err != nil {
+		return nil, err
+	}
+
+	if claims.Issuer != p.issuer {
+		return nil, fmt.Errorf("Unexpected issuer %v", claims.Issuer)
+	}
+	if !claims.Audience.contains(p.config.ClientID) {
+		return nil, fmt.Errorf("Token is not issued for %v", p.config.ClientID)
+	}
+	if time.Now().Unix() > claims.Expires {
+		return nil, errors.New("Token expired")
+	}
+	if claims.Nonce != nonce {
+		return nil, errors.New("Nonce does not match")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("Subject is missing")
+	}
+	if claims.Email != "" && claims.EmailVerified != nil && !*claims.EmailVerified {
+		return nil, fmt.Errorf("Email %v is not verified by identity provider", claims.Email)
+	}
+
+	return claims, nil
+}
+
+// user id is derived from issuer and subject, so that it is stable and does
+// not clash with other providers
+func (p *OIDCAuthProvider) userId(subject string) string {
+	h := sha256.Sum256([]byte(p.issuer + " " + subject))
+	return "oidc_" + hex.EncodeToString(h[:10])
+}
+
+func (p *OIDCAuthProvider) getUserRecord(ctx context.Context, userId string) (*AuthUserRecord, error) {
+	ui, err := p.su.db.GetUserInfo(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get user record: %w", err)
+	}
+
+	// login is refused when identity provider reports email as not verified
+	return &AuthUserRecord{
+		UID:           userId,
+		DisplayName:   ui.DisplayName,
+		Email:         ui.Email,
+		PhoneNumber:   ui.PhoneNumber,
+		EmailVerified: true,
+	}, nil
+}
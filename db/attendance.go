@@ -0,0 +1,65 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+// AttendanceRecord is participation of the squad member in archived event
+type AttendanceRecord struct {
+	EventId     string                `json:"eventId"`
+	Date        time.Time             `json:"date"`
+	UserId      string                `json:"userId"`
+	DisplayName string                `json:"displayName"`
+	Replicant   bool                  `json:"replicant"`
+	Tags        []string              `json:"tags"`
+	Status      ParticipantStatusType `json:"status"`
+}
+
+// GetSquadAttendance returns participants of the squad archived events with date in [from, to), ordered by date
+func (db *FirestoreDB) GetSquadAttendance(ctx context.Context, squadId string, from time.Time, to time.Time) ([]*AttendanceRecord, error) {
+	records := make([]*AttendanceRecord, 0)
+
+	iterEvents := db.Events.Where("SquadId", "==", squadId).Where("Archived", "==", true).Where("Date", ">=", from).Where("Date", "<", to).OrderBy("Date", firestore.Asc).Documents(ctx)
+	defer iterEvents.Stop()
+	for {
+		docEvent, err := iterEvents.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get squad %v attendance: %w", squadId, err)
+		}
+
+		e := &EventInfo{}
+		if err = docEvent.DataTo(e); err != nil {
+			return nil, fmt.Errorf("Failed to get squad %v attendance: %w", squadId, err)
+		}
+
+		docs, err := docEvent.Ref.Collection(MEMBERS).Documents(ctx).GetAll()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get event %v participants: %w", docEvent.Ref.ID, err)
+		}
+		for _, doc := range docs {
+			p := &ParticipantInfo{}
+			if err = doc.DataTo(p); err != nil {
+				return nil, fmt.Errorf("Failed to get event %v participants: %w", docEvent.Ref.ID, err)
+			}
+			records = append(records, &AttendanceRecord{
+				EventId:     docEvent.Ref.ID,
+				Date:        e.Date.UTC(),
+				UserId:      doc.Ref.ID,
+				DisplayName: p.DisplayName,
+				Replicant:   p.Replicant,
+				Tags:        p.Tags,
+				Status:      p.Status,
+			})
+		}
+	}
+
+	return records, nil
+}
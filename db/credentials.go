@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Credentials of the user signing in with email and password (local auth
+// provider). Email is stored lowercased and is the key of the record.
+type Credentials struct {
+	UserId        string `json:"userId"`
+	Email         string `json:"email"`
+	PasswordHash  string `json:"-"`
+	EmailVerified bool   `json:"emailVerified"`
+}
+
+func (db *FirestoreDB) CreateCredentials(ctx context.Context, c *Credentials) error {
+	if db.dev {
+		log.Printf("Creating credentials for user %v (%v)", c.UserId, c.Email)
+	}
+
+	_, err := db.Credentials.Doc(c.Email).Create(ctx, c)
+	if err != nil {
+		if status.Code(err) == codes.AlreadyExists {
+			return status.Errorf(codes.AlreadyExists, "User with email %v already exists", c.Email)
+		}
+		return fmt.Errorf("Failed to create credentials for %v: %w", c.Email, err)
+	}
+
+	return nil
+}
+
+func (db *FirestoreDB) GetCredentials(ctx context.Context, email string) (*Credentials, error) {
+	doc, err := db.Credentials.Doc(email).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, status.Errorf(codes.NotFound, "Credentials for %v not found", email)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get credentials for %v: %w", email, err)
+	}
+
+	c := &Credentials{}
+	if err = doc.DataTo(c); err != nil {
+		return nil, fmt.Errorf("Failed to get credentials for %v: %w", email, err)
+	}
+
+	return c, nil
+}
+
+func (db *FirestoreDB) GetCredentialsByUserId(ctx context.Context, userId string) (*Credentials, error) {
+	iter := db.Credentials.Where("UserId", "==", userId).Limit(1).Documents(ctx)
+	defer iter.Stop()
+
+	doc, err := iter.Next()
+	if err == iterator.Done {
+		return nil, status.Errorf(codes.NotFound, "Credentials for user %v not found", userId)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get credentials for user %v: %w", userId, err)
+	}
+
+	c := &Credentials{}
+	if err = doc.DataTo(c); err != nil {
+		return nil, fmt.Errorf("Failed to get credentials for user %v: %w", userId, err)
+	}
+
+	return c, nil
+}
+
+func (db *FirestoreDB) SetEmailVerified(ctx context.Context, email string) error {
+	err := db.updateDocProperty(ctx, db.Credentials.Doc(email), "EmailVerified", true)
+	if err != nil {
+		return fmt.Errorf("Failed to mark email %v verified: %w", email, err)
+	}
+
+	return nil
+}
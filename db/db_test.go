@@ -2,6 +2,8 @@ package db
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"strings"
@@ -11,15 +13,33 @@ import (
 
 	"cloud.google.com/go/firestore"
 	firebase "firebase.google.com/go"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-var db *FirestoreDB
+var db Store
 var ctx = context.Background()
 
+var store = flag.String("store", "memory", "storage backend to run tests against (memory, sqlite or firestore)")
+
 func TestInitDB(t *testing.T) {
 
 	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
 
+	if *store == "memory" {
+		db = NewMemoryDB(false)
+		return
+	}
+
+	if *store == "sqlite" {
+		var err error
+		db, err = NewSQLDB("sqlite3", ":memory:", false)
+		if err != nil {
+			t.Fatalf("Failed to init sqlite DB: %v", err)
+		}
+		return
+	}
+
 	t.Run("Init test firestore DB", func(t *testing.T) {
 		fireapp, err := firebase.NewApp(ctx, nil)
 		if err != nil {
@@ -49,31 +69,37 @@ func TestInitDB(t *testing.T) {
 }
 
 func TestCleanDB(t *testing.T) {
+	// memory storage is created empty
+	fdb, ok := db.(*FirestoreDB)
+	if !ok {
+		return
+	}
+
 	t.Run("Clean test DB", func(t *testing.T) {
 		var wg sync.WaitGroup
 
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			err := db.DeleteCollectionRecurse(ctx, db.Squads)
+			err := fdb.DeleteCollectionRecurse(ctx, fdb.Squads)
 			if err != nil {
-				t.Fatalf("Failed to clean test data: %v", err)
+				t.Errorf("Failed to clean test data: %v", err)
 			}
 		}()
 
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			err := db.DeleteCollectionRecurse(ctx, db.Events)
+			err := fdb.DeleteCollectionRecurse(ctx, fdb.Events)
 			if err != nil {
-				t.Fatalf("Failed to clean test data: %v", err)
+				t.Errorf("Failed to clean test data: %v", err)
 			}
 		}()
 		wg.Wait()
 	})
 
 	t.Run("Create ALL_USERS squad", func(t *testing.T) {
-		_, err := db.Squads.Doc(ALL_USERS_SQUAD).Set(ctx, &struct{ Description string }{"Special squad with all users"})
+		_, err := fdb.Squads.Doc(ALL_USERS_SQUAD).Set(ctx, &struct{ Description string }{"Special squad with all users"})
 		if err != nil {
 			t.Fatalf("Failed to touch ALL_SQUADS doc: %v", err)
 		}
@@ -110,7 +136,8 @@ func TestSquads(t *testing.T) {
 				}
 				err := db.CreateUser(ctx, fmt.Sprint("PENDING_APPROVE_USER_", i), userInfo, Member)
 				if err != nil {
-					t.Fatalf("Failed to create pending approve user: %v", err)
+					t.Errorf("Failed to create pending approve user: %v", err)
+					return
 				}
 			}(i)
 		}
@@ -125,7 +152,8 @@ func TestSquads(t *testing.T) {
 			}
 			err := db.CreateUser(ctx, "SUPER_USER", userInfo, Member)
 			if err != nil {
-				t.Fatalf("Failed to create user: %v", err)
+				t.Errorf("Failed to create user: %v", err)
+				return
 			}
 		}()
 
@@ -142,7 +170,8 @@ func TestSquads(t *testing.T) {
 				defer wg.Done()
 				err := db.CreateSquad(ctx, fmt.Sprint("TEST_SQUAD_", i), "SUPER_USER")
 				if err != nil {
-					t.Fatalf("Failed to create squad: %v", err)
+					t.Errorf("Failed to create squad: %v", err)
+					return
 				}
 			}(i)
 		}
@@ -158,10 +187,12 @@ func TestSquads(t *testing.T) {
 				defer wg.Done()
 				squad, err := db.AddMemberToSquad(ctx, fmt.Sprint("TEST_USER_", i), "TEST_SQUAD_0", Member)
 				if err != nil {
-					t.Fatalf("Failed to add user to squad: %v", err)
+					t.Errorf("Failed to add user to squad: %v", err)
+					return
 				}
 				if squad.Status != Member {
-					t.Fatalf("AddMemberToSquad returned wrong squad info, expected status=Member, memberCount=%v and recieved: %+v", i+2, squad)
+					t.Errorf("AddMemberToSquad returned wrong squad info, expected status=Member, memberCount=%v and recieved: %+v", i+2, squad)
+					return
 				}
 			}(i)
 		}
@@ -174,7 +205,8 @@ func TestSquads(t *testing.T) {
 				defer wg.Done()
 				_, err := db.AddMemberToSquad(ctx, fmt.Sprint("PENDING_APPROVE_USER_", i), "TEST_SQUAD_0", PendingApprove)
 				if err != nil {
-					t.Fatalf("Failed to add user to squad: %v", err)
+					t.Errorf("Failed to add user to squad: %v", err)
+					return
 				}
 			}(i)
 		}
@@ -239,7 +271,8 @@ func TestSquads(t *testing.T) {
 				}
 				_, err := db.CreateReplicant(ctx, userInfo, "TEST_SQUAD_1")
 				if err != nil {
-					t.Fatalf("Failed to create replicant: %v", err)
+					t.Errorf("Failed to create replicant: %v", err)
+					return
 				}
 			}(i)
 		}
@@ -567,14 +600,15 @@ func TestSquads(t *testing.T) {
 		for i := 0; i < 5; i++ {
 			wg.Add(1)
 			go func(i int) {
+				defer wg.Done()
 				squad, err := db.AddMemberToSquad(ctx, fmt.Sprint("TEST_USER_", i), "TEST_SQUAD_1", Member)
 				if err != nil {
-					t.Fatalf("Failed to add user to squad: %v", err)
+					t.Errorf("Failed to add user to squad: %v", err)
+					return
 				}
 				if squad.Status != Member {
 					t.Errorf("AddMemberToSquad returned wrong squad info: %+v", squad)
 				}
-				wg.Done()
 			}(i)
 		}
 		wg.Wait()
@@ -595,11 +629,12 @@ func TestSquads(t *testing.T) {
 		for i := 0; i < 5; i++ {
 			wg.Add(1)
 			go func(i int) {
+				defer wg.Done()
 				_, err := db.SetSquadMemberTag(ctx, fmt.Sprint("TEST_USER_", i), "TEST_SQUAD_1", "tag", fmt.Sprint("v", i))
 				if err != nil {
-					t.Fatalf("Failed to tag user: %v", err)
+					t.Errorf("Failed to tag user: %v", err)
+					return
 				}
-				wg.Done()
 			}(i)
 		}
 
@@ -607,6 +642,430 @@ func TestSquads(t *testing.T) {
 	})
 }
 
+func TestEventTemplates(t *testing.T) {
+	template := &EventTemplate{
+		Name:      "Training",
+		Text:      "Weekly training",
+		TimeFrom:  "19:00",
+		Capacity:  10,
+		Reminders: []int{60},
+		Location:  &EventLocation{Venue: "Gym"},
+		Tags:      []string{"tag/v0"},
+	}
+	templateId, err := db.CreateEventTemplate(ctx, "TEST_SQUAD_1", template)
+	if err != nil {
+		t.Fatalf("Failed to create event template: %v", err)
+	}
+	if _, err = db.CreateEventTemplate(ctx, "TEST_SQUAD_1", &EventTemplate{Text: "No name"}); err == nil {
+		t.Fatalf("Event template without name should not be created")
+	}
+
+	saved, err := db.GetEventTemplate(ctx, "TEST_SQUAD_1", templateId)
+	if err != nil || saved.Text != template.Text || saved.Capacity != 10 || saved.Reminders[0] != 60 || !saved.Location.Equal(template.Location) || len(saved.Tags) != 1 || saved.Tags[0] != "tag/v0" {
+		t.Fatalf("Wrong event template %+v (%v)", saved, err)
+	}
+	if _, err = db.GetEventTemplate(ctx, "TEST_SQUAD_1", "UNKNOWN"); status.Code(err) != codes.NotFound {
+		t.Fatalf("Unknown event template should fail with NotFound, got %v", err)
+	}
+
+	if err = db.UpdateEventTemplate(ctx, "TEST_SQUAD_1", templateId, &EventTemplate{Name: "Match", TimeTo: "21:00"}); err != nil {
+		t.Fatalf("Failed to update event template: %v", err)
+	}
+	if err = db.UpdateEventTemplate(ctx, "TEST_SQUAD_1", "UNKNOWN", template); status.Code(err) != codes.NotFound {
+		t.Fatalf("Update of unknown event template should fail with NotFound, got %v", err)
+	}
+
+	templates, err := db.GetEventTemplates(ctx, "TEST_SQUAD_1")
+	if err != nil || len(templates) != 1 || templates[0].ID != templateId || templates[0].Name != "Match" || templates[0].TimeTo != "21:00" || templates[0].Location != nil || len(templates[0].Tags) != 0 {
+		t.Fatalf("Wrong event templates %+v (%v)", templates, err)
+	}
+
+	if err = db.DeleteEventTemplate(ctx, "TEST_SQUAD_1", templateId); err != nil {
+		t.Fatalf("Failed to delete event template: %v", err)
+	}
+	if templates, err = db.GetEventTemplates(ctx, "TEST_SQUAD_1"); err != nil || len(templates) != 0 {
+		t.Fatalf("Event template should be deleted %+v (%v)", templates, err)
+	}
+}
+
+func TestRegistrationErrors(t *testing.T) {
+	date := time.Date(2031, time.Month(10), 1, 0, 0, 0, 0, time.UTC)
+	eventInfo := &EventInfo{
+		Date:    &date,
+		Text:    "Bulk registration",
+		SquadId: "TEST_SQUAD_1",
+		OwnerId: "TEST_USER_0",
+	}
+	eventId, err := db.CreateEvent(ctx, eventInfo)
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	defer db.DeleteEvent(ctx, eventId)
+
+	if err = db.RegisterParticipants(ctx, []string{"TEST_USER_1"}, eventId, eventInfo, Going); err != nil {
+		t.Fatalf("Failed to register participant: %v", err)
+	}
+
+	// users failed to register are reported one by one, the others are registered
+	err = db.RegisterParticipants(ctx, []string{"TEST_USER_1", "UNKNOWN", "TEST_USER_2"}, eventId, eventInfo, Going)
+	var regErrs RegistrationErrors
+	if !errors.As(err, &regErrs) || len(regErrs) != 2 || regErrs[0].UserId != "TEST_USER_1" || regErrs[1].UserId != "UNKNOWN" {
+		t.Fatalf("Wrong registration errors %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "Failed to register one or more participants for event:\n\t") {
+		t.Fatalf("Wrong registration error message %v", err)
+	}
+	if st, err := db.GetParticipantStatus(ctx, "TEST_USER_2", eventId); err != nil || st != Going {
+		t.Fatalf("TEST_USER_2 should be registered, got %v (%v)", st, err)
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	old := time.Date(2020, time.Month(1), 1, 10, 0, 0, 0, time.UTC)
+	err := db.CreateNotification(ctx, []string{"TEST_USER_1", "TEST_USER_2"}, &Notification{Time: old, Title: "Old", Text: "Old notification"})
+	if err != nil {
+		t.Fatalf("Failed to create notification: %v", err)
+	}
+	now := time.Now().UTC()
+	err = db.CreateNotification(ctx, []string{"TEST_USER_1"}, &Notification{Kind: KindRequestUpdate, Time: now, Title: "New", Text: "New notification", Link: &NotificationLink{Type: LinkRequest, ID: "REQUEST_1"}})
+	if err != nil {
+		t.Fatalf("Failed to create notification: %v", err)
+	}
+
+	ns, err := db.GetNotifications(ctx, "TEST_USER_1", nil, false)
+	if err != nil || len(ns) != 2 || ns[0].Title != "New" || ns[0].Kind != KindRequestUpdate || !ns[0].Time.Equal(now) || ns[0].Link.ID != "REQUEST_1" || ns[1].Title != "Old" || ns[1].Link != nil {
+		t.Fatalf("Wrong notifications %+v (%v)", ns, err)
+	}
+	if page, err := db.GetNotifications(ctx, "TEST_USER_1", &now, false); err != nil || len(page) != 1 || page[0].Title != "Old" {
+		t.Fatalf("Wrong notifications page %+v (%v)", page, err)
+	}
+
+	// notification is marked read only in the inbox of its user
+	if err = db.MarkNotificationRead(ctx, "TEST_USER_2", ns[0].ID); status.Code(err) != codes.NotFound {
+		t.Fatalf("Notification of another user should not be found, got %v", err)
+	}
+	if err = db.MarkNotificationRead(ctx, "TEST_USER_1", ns[0].ID); err != nil {
+		t.Fatalf("Failed to mark notification read: %v", err)
+	}
+	if n, err := db.GetUnreadNotificationsCount(ctx, "TEST_USER_1"); err != nil || n != 1 {
+		t.Fatalf("Expected 1 unread notification, got %v (%v)", n, err)
+	}
+	if unread, err := db.GetNotifications(ctx, "TEST_USER_1", nil, true); err != nil || len(unread) != 1 || unread[0].Title != "Old" {
+		t.Fatalf("Wrong unread notifications %+v (%v)", unread, err)
+	}
+	if err = db.MarkNotificationsRead(ctx, "TEST_USER_1"); err != nil {
+		t.Fatalf("Failed to mark notifications read: %v", err)
+	}
+	if n, err := db.GetUnreadNotificationsCount(ctx, "TEST_USER_1"); err != nil || n != 0 {
+		t.Fatalf("Expected no unread notifications, got %v (%v)", n, err)
+	}
+	if n, err := db.GetUnreadNotificationsCount(ctx, "TEST_USER_2"); err != nil || n != 1 {
+		t.Fatalf("Notifications of another user should stay unread, got %v (%v)", n, err)
+	}
+
+	if n, err := db.DeleteNotificationsBefore(ctx, old.AddDate(0, 0, 1)); err != nil || n != 2 {
+		t.Fatalf("Expected 2 old notifications to be deleted, got %v (%v)", n, err)
+	}
+	if ns, err = db.GetNotifications(ctx, "TEST_USER_1", nil, false); err != nil || len(ns) != 1 || ns[0].Title != "New" {
+		t.Fatalf("Wrong notifications after retention %+v (%v)", ns, err)
+	}
+	if ns, err = db.GetNotifications(ctx, "TEST_USER_2", nil, false); err != nil || len(ns) != 0 {
+		t.Fatalf("Wrong notifications after retention %+v (%v)", ns, err)
+	}
+}
+
+func TestNotificationPrefs(t *testing.T) {
+	prefs := []*NotificationPref{
+		{SquadId: "SQUAD_2", Kind: KindNewEvent, Muted: true, Channels: []string{}},
+		{SquadId: "SQUAD_1", Kind: KindRequestApprove, Channels: []string{"push"}},
+		{SquadId: "SQUAD_1", Kind: KindNewEvent, Channels: []string{"email"}},
+	}
+	for _, p := range prefs {
+		if err := db.SetNotificationPref(ctx, "TEST_USER_1", p); err != nil {
+			t.Fatalf("Failed to set notification preference: %v", err)
+		}
+	}
+	if err := db.SetNotificationPref(ctx, "TEST_USER_2", &NotificationPref{SquadId: "SQUAD_1", Kind: KindNewEvent, Muted: true}); err != nil {
+		t.Fatalf("Failed to set notification preference: %v", err)
+	}
+	// preference is replaced, not merged
+	if err := db.SetNotificationPref(ctx, "TEST_USER_1", &NotificationPref{SquadId: "SQUAD_1", Kind: KindNewEvent, Channels: []string{"push", "email"}}); err != nil {
+		t.Fatalf("Failed to update notification preference: %v", err)
+	}
+
+	got, err := db.GetNotificationPrefs(ctx, "TEST_USER_1")
+	if err != nil || len(got) != 3 {
+		t.Fatalf("Expected 3 notification preferences, got %+v (%v)", got, err)
+	}
+	if got[0].SquadId != "SQUAD_1" || got[0].Kind != KindNewEvent || len(got[0].Channels) != 2 || got[1].Kind != KindRequestApprove || !got[1].HasChannel("push") ||
+		got[2].SquadId != "SQUAD_2" || !got[2].Muted || len(got[2].Channels) != 0 {
+		t.Fatalf("Wrong notification preferences %+v %+v %+v", got[0], got[1], got[2])
+	}
+
+	users, err := db.GetUsersNotificationPrefs(ctx, []string{"TEST_USER_1", "TEST_USER_2", "TEST_USER_3"}, "SQUAD_1", KindNewEvent)
+	if err != nil || len(users) != 2 || users["TEST_USER_1"].Muted || !users["TEST_USER_1"].HasChannel("email") || !users["TEST_USER_2"].Muted {
+		t.Fatalf("Wrong users notification preferences %+v (%v)", users, err)
+	}
+	if users, err = db.GetUsersNotificationPrefs(ctx, []string{"TEST_USER_1"}, "SQUAD_1", KindMembership); err != nil || len(users) != 0 {
+		t.Fatalf("There should be no preferences for membership notifications %+v (%v)", users, err)
+	}
+
+	if err = db.DeleteNotificationPref(ctx, "TEST_USER_1", "SQUAD_1", KindNewEvent); err != nil {
+		t.Fatalf("Failed to delete notification preference: %v", err)
+	}
+	if got, err = db.GetNotificationPrefs(ctx, "TEST_USER_1"); err != nil || len(got) != 2 || got[0].Kind != KindRequestApprove {
+		t.Fatalf("Wrong notification preferences after delete %+v (%v)", got, err)
+	}
+	if digest, err := db.GetEmailDigest(ctx, "TEST_USER_2"); err != nil || digest != DigestImmediate {
+		t.Fatalf("Preferences should not change email digest, got %v (%v)", digest, err)
+	}
+}
+
+func TestEmailDigests(t *testing.T) {
+	if digest, err := db.GetEmailDigest(ctx, "TEST_USER_1"); err != nil || digest != DigestImmediate {
+		t.Fatalf("Emails should be sent right away by default, got %v (%v)", digest, err)
+	}
+	if err := db.SetEmailDigest(ctx, "TEST_USER_1", DigestHourly); err != nil {
+		t.Fatalf("Failed to set email digest: %v", err)
+	}
+	if err := db.SetEmailDigest(ctx, "TEST_USER_1", DigestDaily); err != nil {
+		t.Fatalf("Failed to change email digest: %v", err)
+	}
+	if digest, err := db.GetEmailDigest(ctx, "TEST_USER_1"); err != nil || digest != DigestDaily {
+		t.Fatalf("Expected daily digest, got %v (%v)", digest, err)
+	}
+
+	now := time.Now().UTC()
+	items := []*DigestItem{
+		{Digest: DigestDaily, Notification: Notification{UserId: "TEST_USER_2", Kind: KindNewEvent, Time: now, Title: "Second user", Text: "Text"}},
+		{Digest: DigestDaily, Notification: Notification{UserId: "TEST_USER_1", Kind: KindEventChange, Time: now.Add(time.Minute), Title: "Later", Text: "Text", Link: &NotificationLink{Type: LinkEvent, ID: "EVENT_1"}}},
+		{Digest: DigestDaily, Notification: Notification{UserId: "TEST_USER_1", Kind: KindMembership, Time: now, Title: "Earlier", Text: "Text"}},
+		{Digest: DigestHourly, Notification: Notification{UserId: "TEST_USER_3", Time: now, Title: "Hourly", Text: "Text"}},
+	}
+	for _, item := range items {
+		if err := db.CreateDigestItem(ctx, item); err != nil {
+			t.Fatalf("Failed to create digest item: %v", err)
+		}
+	}
+
+	// items are grouped by user and ordered by time
+	daily, err := db.GetDigestItems(ctx, DigestDaily)
+	if err != nil || len(daily) != 3 {
+		t.Fatalf("Expected 3 daily digest items, got %+v (%v)", daily, err)
+	}
+	if daily[0].Title != "Earlier" || daily[1].Title != "Later" || daily[1].Kind != KindEventChange || daily[1].Link.ID != "EVENT_1" || daily[2].UserId != "TEST_USER_2" {
+		t.Fatalf("Wrong daily digest items %+v %+v %+v", daily[0], daily[1], daily[2])
+	}
+
+	if err = db.DeleteDigestItems(ctx, []string{daily[0].ID, daily[1].ID}); err != nil {
+		t.Fatalf("Failed to delete digest items: %v", err)
+	}
+	if daily, err = db.GetDigestItems(ctx, DigestDaily); err != nil || len(daily) != 1 || daily[0].Title != "Second user" {
+		t.Fatalf("Wrong daily digest items after delete %+v (%v)", daily, err)
+	}
+	if hourly, err := db.GetDigestItems(ctx, DigestHourly); err != nil || len(hourly) != 1 || !hourly[0].Time.Equal(now) {
+		t.Fatalf("Wrong hourly digest items %+v (%v)", hourly, err)
+	}
+}
+
+func TestPushTokens(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	tokens := []*PushToken{
+		{Token: "TOKEN_LAPTOP", UserId: "TEST_USER_1", Device: "Laptop", LastSeen: now.Add(-time.Hour)},
+		{Token: "TOKEN_PHONE", UserId: "TEST_USER_1", Device: "Phone", LastSeen: now},
+		{Token: "TOKEN_TABLET", UserId: "TEST_USER_2", Device: "Tablet", LastSeen: now},
+	}
+	for _, token := range tokens {
+		if err := db.SavePushToken(ctx, token); err != nil {
+			t.Fatalf("Failed to save push token: %v", err)
+		}
+	}
+	if err := db.SavePushToken(ctx, &PushToken{UserId: "TEST_USER_1", LastSeen: now}); err == nil {
+		t.Fatalf("Empty push token should not be saved")
+	}
+
+	// saving token again only updates last seen time
+	if err := db.SavePushToken(ctx, &PushToken{Token: "TOKEN_LAPTOP", UserId: "TEST_USER_1", Device: "Laptop", LastSeen: now.Add(time.Hour)}); err != nil {
+		t.Fatalf("Failed to save push token: %v", err)
+	}
+	got, err := db.GetPushTokens(ctx, "TEST_USER_1")
+	if err != nil || len(got) != 2 {
+		t.Fatalf("Expected 2 push tokens, got %+v (%v)", got, err)
+	}
+	if got[0].Token != "TOKEN_LAPTOP" || !got[0].LastSeen.Equal(now.Add(time.Hour)) || !got[0].Created.Equal(now.Add(-time.Hour)) || got[1].Device != "Phone" {
+		t.Fatalf("Wrong push tokens %+v %+v", got[0], got[1])
+	}
+
+	// another user signed in on the same device takes the token
+	if err = db.SavePushToken(ctx, &PushToken{Token: "TOKEN_TABLET", UserId: "TEST_USER_1", Device: "Tablet", LastSeen: now}); err != nil {
+		t.Fatalf("Failed to save push token: %v", err)
+	}
+	if err = db.DeletePushTokens(ctx, "TEST_USER_2", []string{"TOKEN_TABLET"}); err != nil {
+		t.Fatalf("Failed to delete push tokens: %v", err)
+	}
+	if got, err = db.GetPushTokens(ctx, "TEST_USER_1"); err != nil || len(got) != 3 {
+		t.Fatalf("Token of another user should not be deleted, got %+v (%v)", got, err)
+	}
+	if got, err = db.GetPushTokens(ctx, "TEST_USER_2"); err != nil || len(got) != 0 {
+		t.Fatalf("Expected no push tokens, got %+v (%v)", got, err)
+	}
+
+	if err = db.DeletePushTokens(ctx, "TEST_USER_1", []string{"TOKEN_LAPTOP", "TOKEN_TABLET", "UNKNOWN"}); err != nil {
+		t.Fatalf("Failed to delete push tokens: %v", err)
+	}
+	if got, err = db.GetPushTokens(ctx, "TEST_USER_1"); err != nil || len(got) != 1 || got[0].Token != "TOKEN_PHONE" {
+		t.Fatalf("Wrong push tokens after delete %+v (%v)", got, err)
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	firstId, err := db.CreateWebhook(ctx, "TEST_SQUAD_1", &Webhook{URL: "https://example.com/first", Events: []string{HookMemberJoined, HookEventCreated}, Secret: "SECRET", Created: now})
+	if err != nil {
+		t.Fatalf("Failed to create webhook: %v", err)
+	}
+	secondId, err := db.CreateWebhook(ctx, "TEST_SQUAD_1", &Webhook{URL: "https://example.com/second", Events: []string{HookRequestStatus}, Secret: "SECRET_2", Created: now.Add(time.Second)})
+	if err != nil {
+		t.Fatalf("Failed to create webhook: %v", err)
+	}
+	if _, err = db.CreateWebhook(ctx, "TEST_SQUAD_1", &Webhook{Events: []string{HookRequestStatus}}); err == nil {
+		t.Fatalf("Webhook without URL should not be created")
+	}
+
+	hooks, err := db.GetWebhooks(ctx, "TEST_SQUAD_1")
+	if err != nil || len(hooks) != 2 || hooks[0].ID != firstId || !hooks[0].HasEvent(HookEventCreated) || hooks[0].HasEvent(HookRequestStatus) ||
+		hooks[1].Secret != "SECRET_2" || !hooks[1].Created.Equal(now.Add(time.Second)) {
+		t.Fatalf("Wrong webhooks %+v (%v)", hooks, err)
+	}
+	if hook, err := db.GetWebhook(ctx, "TEST_SQUAD_1", secondId); err != nil || hook.URL != "https://example.com/second" || len(hook.Events) != 1 {
+		t.Fatalf("Wrong webhook %+v (%v)", hook, err)
+	}
+	if _, err = db.GetWebhook(ctx, "TEST_SQUAD_1", "UNKNOWN"); status.Code(err) != codes.NotFound {
+		t.Fatalf("Unknown webhook should not be found, got %v", err)
+	}
+
+	deliveries := []*WebhookDelivery{
+		{WebhookId: firstId, SquadId: "TEST_SQUAD_1", Event: HookMemberJoined, Payload: `{"n":1}`, Status: DeliveryPending, Created: now, NextAttempt: now.Add(time.Minute)},
+		{WebhookId: firstId, SquadId: "TEST_SQUAD_1", Event: HookEventCreated, Payload: `{"n":2}`, Status: DeliveryPending, Created: now.Add(time.Second), NextAttempt: now},
+		{WebhookId: secondId, SquadId: "TEST_SQUAD_1", Event: HookRequestStatus, Payload: `{"n":3}`, Status: DeliveryDelivered, Created: now, NextAttempt: now},
+	}
+	ids := make([]string, len(deliveries))
+	for i, d := range deliveries {
+		if ids[i], err = db.CreateWebhookDelivery(ctx, d); err != nil {
+			t.Fatalf("Failed to create webhook delivery: %v", err)
+		}
+	}
+
+	// only pending deliveries which are due are retried
+	due, err := db.GetDueWebhookDeliveries(ctx, now.Add(time.Minute))
+	if err != nil || len(due) != 2 || due[0].ID != ids[1] || due[1].Payload != `{"n":1}` || due[1].LastAttempt != nil {
+		t.Fatalf("Wrong due webhook deliveries %+v (%v)", due, err)
+	}
+	if due, err = db.GetDueWebhookDeliveries(ctx, now); err != nil || len(due) != 1 || due[0].ID != ids[1] {
+		t.Fatalf("Wrong due webhook deliveries %+v (%v)", due, err)
+	}
+
+	last := now.Add(2 * time.Second)
+	d := due[0].WebhookDelivery
+	d.Status, d.Attempts, d.LastAttempt, d.ResponseCode, d.Error = DeliveryFailed, 6, &last, 502, "Bad Gateway"
+	if err = db.UpdateWebhookDelivery(ctx, ids[1], &d); err != nil {
+		t.Fatalf("Failed to update webhook delivery: %v", err)
+	}
+
+	// delivery log goes from the newest
+	entries, err := db.GetWebhookDeliveries(ctx, firstId, 10)
+	if err != nil || len(entries) != 2 || entries[0].ID != ids[1] || entries[0].Status != DeliveryFailed || entries[0].Attempts != 6 || !entries[0].LastAttempt.Equal(last) ||
+		entries[0].ResponseCode != 502 || entries[0].Error != "Bad Gateway" || entries[1].Event != HookMemberJoined {
+		t.Fatalf("Wrong webhook delivery log %+v (%v)", entries, err)
+	}
+	if entries, err = db.GetWebhookDeliveries(ctx, firstId, 1); err != nil || len(entries) != 1 || entries[0].ID != ids[1] {
+		t.Fatalf("Delivery log should be limited %+v (%v)", entries, err)
+	}
+
+	// deliveries are deleted with the webhook
+	if err = db.DeleteWebhook(ctx, "TEST_SQUAD_1", firstId); err != nil {
+		t.Fatalf("Failed to delete webhook: %v", err)
+	}
+	if entries, err = db.GetWebhookDeliveries(ctx, firstId, 10); err != nil || len(entries) != 0 {
+		t.Fatalf("Deliveries of deleted webhook should be deleted %+v (%v)", entries, err)
+	}
+	if hooks, err = db.GetWebhooks(ctx, "TEST_SQUAD_1"); err != nil || len(hooks) != 1 || hooks[0].ID != secondId {
+		t.Fatalf("Wrong webhooks after delete %+v (%v)", hooks, err)
+	}
+	if err = db.DeleteWebhook(ctx, "TEST_SQUAD_1", secondId); err != nil {
+		t.Fatalf("Failed to delete webhook: %v", err)
+	}
+}
+
+func TestVenues(t *testing.T) {
+	lat, lon := 55.75, 37.62
+	venue := &EventLocation{Venue: "Main Hall", Address: "1 Main St", Lat: &lat, Lon: &lon}
+	venueId, err := db.CreateVenue(ctx, "TEST_SQUAD_1", venue)
+	if err != nil {
+		t.Fatalf("Failed to create venue: %v", err)
+	}
+	if _, err = db.CreateVenue(ctx, "TEST_SQUAD_1", &EventLocation{Address: "No name"}); err == nil {
+		t.Fatalf("Venue without name should not be created")
+	}
+
+	if err = db.UpdateVenue(ctx, "TEST_SQUAD_1", venueId, &EventLocation{Venue: "Hall", MeetingURL: "https://meet.example.com"}); err != nil {
+		t.Fatalf("Failed to update venue: %v", err)
+	}
+	if err = db.UpdateVenue(ctx, "TEST_SQUAD_1", "UNKNOWN", venue); status.Code(err) != codes.NotFound {
+		t.Fatalf("Update of unknown venue should fail with NotFound, got %v", err)
+	}
+
+	venues, err := db.GetVenues(ctx, "TEST_SQUAD_1")
+	if err != nil || len(venues) != 1 || venues[0].ID != venueId || venues[0].Venue != "Hall" || venues[0].Lat != nil || venues[0].MeetingURL != "https://meet.example.com" {
+		t.Fatalf("Wrong venues %+v (%v)", venues, err)
+	}
+
+	// event location is stored together with the event and its participant copies
+	date := time.Date(2031, time.Month(8), 1, 0, 0, 0, 0, time.UTC)
+	eventInfo := &EventInfo{
+		Date:     &date,
+		Text:     "Located event",
+		SquadId:  "TEST_SQUAD_1",
+		OwnerId:  "TEST_USER_0",
+		Location: venue,
+	}
+	eventId, err := db.CreateEvent(ctx, eventInfo)
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	if err = db.RegisterParticipants(ctx, []string{"TEST_USER_1"}, eventId, eventInfo, Going); err != nil {
+		t.Fatalf("Failed to register participant: %v", err)
+	}
+	e, err := db.GetEvent(ctx, eventId)
+	if err != nil || !e.Location.Equal(venue) {
+		t.Fatalf("Wrong event location %+v (%v)", e, err)
+	}
+
+	e.Location = &EventLocation{MeetingURL: "https://meet.example.com"}
+	if err = db.UpdateEvent(ctx, eventId, e); err != nil {
+		t.Fatalf("Failed to update event: %v", err)
+	}
+	events, err := db.GetUserEvents(ctx, "TEST_USER_1", 100)
+	if err != nil {
+		t.Fatalf("Failed to get user events: %v", err)
+	}
+	for _, ue := range events {
+		if ue.Text == "Located event" && (ue.Location == nil || ue.Location.Venue != "" || ue.Location.MeetingURL != "https://meet.example.com") {
+			t.Fatalf("Participant copy of the event location is not updated: %+v", ue.Location)
+		}
+	}
+
+	if err = db.DeleteEvent(ctx, eventId); err != nil {
+		t.Fatalf("Failed to delete event: %v", err)
+	}
+	if err = db.DeleteVenue(ctx, "TEST_SQUAD_1", venueId); err != nil {
+		t.Fatalf("Failed to delete venue: %v", err)
+	}
+	if venues, err = db.GetVenues(ctx, "TEST_SQUAD_1"); err != nil || len(venues) != 0 {
+		t.Fatalf("Venue should be deleted, got %+v (%v)", venues, err)
+	}
+}
+
 func TestEvents(t *testing.T) {
 	var eventIds [5]string
 	t.Run("Create events", func(t *testing.T) {
@@ -619,6 +1078,7 @@ func TestEvents(t *testing.T) {
 			}
 			wg.Add(1)
 			go func(i int, squadId string, tm *time.Time) {
+				defer wg.Done()
 				eventInfo := &EventInfo{
 					Date:     tm,
 					TimeFrom: "10:00",
@@ -630,9 +1090,9 @@ func TestEvents(t *testing.T) {
 				var err error
 				eventIds[i], err = db.CreateEvent(ctx, eventInfo)
 				if err != nil {
-					t.Fatalf("Failed to create event: %v", err)
+					t.Errorf("Failed to create event: %v", err)
+					return
 				}
-				wg.Done()
 			}(i, squadId, &tm)
 		}
 		wg.Wait()
@@ -696,43 +1156,47 @@ func TestEvents(t *testing.T) {
 
 		wg.Add(1)
 		go func() {
+			defer wg.Done()
 			filter := map[string]string{"Keys": "Re"}
 			candidates, err := db.GetCandidates(ctx, "TEST_SQUAD_1", eventIds[1], "", &filter)
 			if err != nil {
-				t.Fatalf("Failed to get event candidates: %v", err)
+				t.Errorf("Failed to get event candidates: %v", err)
+				return
 			}
 			if len(candidates) != 5 {
-				t.Fatalf("Wrong number of candidates, expected 5 (all replicants), recieved %v", len(candidates))
+				t.Errorf("Wrong number of candidates, expected 5 (all replicants), recieved %v", len(candidates))
+				return
 			}
 
 			candidateIds = []string{candidates[0].ID, candidates[1].ID}
 			err = db.RegisterParticipants(ctx, candidateIds, eventIds[1], eventInfo, Going)
-
-			wg.Done()
 		}()
 
 		wg.Add(1)
 		go func() {
+			defer wg.Done()
 			filter := map[string]string{"Keys": "Us"}
 			candidates, err := db.GetCandidates(ctx, "TEST_SQUAD_1", eventIds[1], "", &filter)
 			if err != nil {
-				t.Fatalf("Failed to get event candidates: %v", err)
+				t.Errorf("Failed to get event candidates: %v", err)
+				return
 			}
 			if len(candidates) != 3 {
-				t.Fatalf("Wrong number of candidates, expected 3 (SUPER_USER, TEST_USER_3, TEST_USER_4), recieved %v", len(candidates))
+				t.Errorf("Wrong number of candidates, expected 3 (SUPER_USER, TEST_USER_3, TEST_USER_4), recieved %v", len(candidates))
+				return
 			}
-			wg.Done()
 		}()
 	})
 
 	t.Run("Change participant status", func(t *testing.T) {
 		wg.Add(1)
 		go func() {
-			err := db.SetParticipantStatus(ctx, "TEST_USER_0", eventIds[1], Going)
+			defer wg.Done()
+			_, _, err := db.SetParticipantStatus(ctx, "TEST_USER_0", eventIds[1], Going)
 			if err != nil {
-				t.Fatalf("Failed to change participant status: %v", err)
+				t.Errorf("Failed to change participant status: %v", err)
+				return
 			}
-			wg.Done()
 		}()
 	})
 	wg.Wait()
@@ -756,7 +1220,8 @@ func TestEvents(t *testing.T) {
 			defer wg.Done()
 			err := db.DeleteParticipant(ctx, "TEST_USER_0", eventIds[1])
 			if err != nil {
-				t.Fatalf("Failed to remove TEST_USER_0 from event : %v", err)
+				t.Errorf("Failed to remove TEST_USER_0 from event : %v", err)
+				return
 			}
 		}()
 
@@ -765,7 +1230,8 @@ func TestEvents(t *testing.T) {
 			defer wg.Done()
 			err := db.DeleteParticipant(ctx, candidateIds[0], eventIds[1])
 			if err != nil {
-				t.Fatalf("Failed to remove replicant from event : %v", err)
+				t.Errorf("Failed to remove replicant from event : %v", err)
+				return
 			}
 		}()
 		wg.Wait()
@@ -776,10 +1242,12 @@ func TestEvents(t *testing.T) {
 			filter := map[string]string{"Tag": "tag/v0"}
 			candidates, err := db.GetCandidates(ctx, "TEST_SQUAD_1", eventIds[1], "", &filter)
 			if err != nil {
-				t.Fatalf("Failed to get event candidates: %v", err)
+				t.Errorf("Failed to get event candidates: %v", err)
+				return
 			}
 			if len(candidates) != 1 {
-				t.Fatalf("Wrong number of candidates, expected 1 (TEST_USER_0), recieved %v", len(candidates))
+				t.Errorf("Wrong number of candidates, expected 1 (TEST_USER_0), recieved %v", len(candidates))
+				return
 			}
 		}()
 
@@ -789,10 +1257,12 @@ func TestEvents(t *testing.T) {
 			squadIds := []string{"TEST_SQUAD_1"}
 			events, err := db.GetEvents(ctx, squadIds, "TEST_USER_1")
 			if err != nil {
-				t.Fatalf("Failed to get event info : %v", err)
+				t.Errorf("Failed to get event info : %v", err)
+				return
 			}
 			if events[1].Applied != 1 || events[1].Going != 1 {
-				t.Fatalf("Wrong numbers for applied & going to event %v, expected 1, 1 but recieved %v, %v", events[1].Text, events[1].Applied, events[1].Going)
+				t.Errorf("Wrong numbers for applied & going to event %v, expected 1, 1 but recieved %v, %v", events[1].Text, events[1].Applied, events[1].Going)
+				return
 			}
 		}()
 
@@ -801,14 +1271,832 @@ func TestEvents(t *testing.T) {
 			defer wg.Done()
 			events, err := db.GetUserEvents(ctx, "TEST_USER_2", 3)
 			if err != nil {
-				t.Fatalf("Failed to get event info : %v", err)
+				t.Errorf("Failed to get event info : %v", err)
+				return
 			}
 
 			if len(events) != 2 {
-				t.Fatalf("Wrong numbers for events for TEST_USER_2, expected 1, but recieved %v", len(events))
+				t.Errorf("Wrong numbers for events for TEST_USER_2, expected 1, but recieved %v", len(events))
+				return
 			}
 		}()
 		wg.Wait()
 	})
 
 }
+
+func TestEventCapacity(t *testing.T) {
+	date := time.Date(2031, time.Month(5), 6, 11, 00, 0, 0, time.UTC)
+	eventInfo := &EventInfo{
+		Date:     &date,
+		Text:     "Small event",
+		SquadId:  "TEST_SQUAD_1",
+		OwnerId:  "TEST_USER_0",
+		Capacity: 2,
+	}
+	eventId, err := db.CreateEvent(ctx, eventInfo)
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	checkStatus := func(userId string, expected ParticipantStatusType) {
+		t.Helper()
+		status, err := db.GetParticipantStatus(ctx, userId, eventId)
+		if err != nil {
+			t.Fatalf("Failed to get %v status: %v", userId, err)
+		}
+		if status != expected {
+			t.Fatalf("Wrong status of %v, expected %v but recieved %v", userId, expected, status)
+		}
+	}
+
+	checkCounters := func(going int, waitlisted int) {
+		t.Helper()
+		events, err := db.GetEvents(ctx, []string{"TEST_SQUAD_1"}, "TEST_USER_0")
+		if err != nil {
+			t.Fatalf("Failed to get events: %v", err)
+		}
+		for _, e := range events {
+			if e.ID == eventId {
+				if e.Capacity != 2 || e.Going != going || e.Waitlisted != waitlisted {
+					t.Fatalf("Wrong numbers for going & waitlisted, expected %v, %v but recieved %+v", going, waitlisted, e)
+				}
+				return
+			}
+		}
+		t.Fatalf("Event %v not found", eventId)
+	}
+
+	t.Run("Register beyond capacity", func(t *testing.T) {
+		if err := db.RegisterParticipants(ctx, []string{"TEST_USER_0", "TEST_USER_1", "TEST_USER_2"}, eventId, eventInfo, Going); err != nil {
+			t.Fatalf("Failed to register participants: %v", err)
+		}
+		if err := db.RegisterParticipants(ctx, []string{"TEST_USER_3"}, eventId, eventInfo, Applied); err != nil {
+			t.Fatalf("Failed to register participant: %v", err)
+		}
+		if err := db.RegisterParticipants(ctx, []string{"TEST_USER_4"}, eventId, eventInfo, NotGoing); err != nil {
+			t.Fatalf("Failed to register participant: %v", err)
+		}
+		checkStatus("TEST_USER_3", Waitlisted)
+		checkStatus("TEST_USER_4", NotGoing)
+		checkCounters(2, 2)
+
+		// participant changing mind is waitlisted as well, event is not overbooked
+		oldStatus, status, err := db.SetParticipantStatus(ctx, "TEST_USER_4", eventId, Going)
+		if err != nil || oldStatus != NotGoing || status != Waitlisted {
+			t.Fatalf("Participant going to full event should be waitlisted, got %v -> %v (%v)", oldStatus, status, err)
+		}
+		checkStatus("TEST_USER_4", Waitlisted)
+		checkCounters(2, 3)
+		if _, _, err = db.SetParticipantStatus(ctx, "TEST_USER_4", eventId, NotGoing); err != nil {
+			t.Fatalf("Failed to change participant status: %v", err)
+		}
+		checkCounters(2, 2)
+
+		promoted, err := db.PromoteWaitlisted(ctx, eventId)
+		if err != nil || len(promoted) != 0 {
+			t.Fatalf("Nobody should be promoted while event is full, promoted %v (%v)", promoted, err)
+		}
+	})
+
+	t.Run("Promote from waitlist", func(t *testing.T) {
+		// one of the first three registered participants is on the waitlist
+		waitlisted := ""
+		for _, userId := range []string{"TEST_USER_0", "TEST_USER_1", "TEST_USER_2"} {
+			if status, _ := db.GetParticipantStatus(ctx, userId, eventId); status == Waitlisted {
+				waitlisted = userId
+			}
+		}
+		if waitlisted == "" {
+			t.Fatalf("One of the participants should be waitlisted")
+		}
+
+		leaving := "TEST_USER_0"
+		if waitlisted == leaving {
+			leaving = "TEST_USER_1"
+		}
+		if err := db.DeleteParticipant(ctx, leaving, eventId); err != nil {
+			t.Fatalf("Failed to remove participant: %v", err)
+		}
+		promoted, err := db.PromoteWaitlisted(ctx, eventId)
+		if err != nil {
+			t.Fatalf("Failed to promote waitlisted participants: %v", err)
+		}
+		if len(promoted) != 1 || promoted[0] != waitlisted {
+			t.Fatalf("Expected %v to be promoted, but promoted %v", waitlisted, promoted)
+		}
+		checkStatus(waitlisted, Going)
+		checkCounters(2, 1)
+
+		if _, _, err = db.SetParticipantStatus(ctx, waitlisted, eventId, NotGoing); err != nil {
+			t.Fatalf("Failed to change participant status: %v", err)
+		}
+		promoted, err = db.PromoteWaitlisted(ctx, eventId)
+		if err != nil || len(promoted) != 1 || promoted[0] != "TEST_USER_3" {
+			t.Fatalf("Expected TEST_USER_3 to be promoted, but promoted %v (%v)", promoted, err)
+		}
+		checkStatus("TEST_USER_3", Going)
+		checkCounters(2, 0)
+	})
+
+	t.Run("Mark no-shows", func(t *testing.T) {
+		if _, _, err := db.SetParticipantStatus(ctx, "TEST_USER_3", eventId, Attended); err != nil {
+			t.Fatalf("Failed to change participant status: %v", err)
+		}
+		marked, err := db.MarkNoShows(ctx, eventId)
+		if err != nil || marked != 1 {
+			t.Fatalf("Expected 1 participant marked as no-show, marked %v (%v)", marked, err)
+		}
+		checkStatus("TEST_USER_3", Attended)
+		checkCounters(0, 0)
+	})
+
+	if err = db.DeleteEvent(ctx, eventId); err != nil {
+		t.Fatalf("Failed to delete event: %v", err)
+	}
+}
+
+func TestEventCounters(t *testing.T) {
+	date := time.Date(2031, time.Month(5), 7, 11, 00, 0, 0, time.UTC)
+	eventInfo := &EventInfo{
+		Date:    &date,
+		Text:    "Busy event",
+		SquadId: "TEST_SQUAD_1",
+		OwnerId: "TEST_USER_0",
+	}
+	eventId, err := db.CreateEvent(ctx, eventInfo)
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	t.Run("Concurrent registrations", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			userId := fmt.Sprint("TEST_USER_", i)
+			// the same user registers twice, only one of the attempts should succeed
+			for j := 0; j < 2; j++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					db.RegisterParticipants(ctx, []string{userId}, eventId, eventInfo, Applied)
+				}()
+			}
+		}
+		wg.Wait()
+
+		for i := 0; i < 5; i++ {
+			userId := fmt.Sprint("TEST_USER_", i)
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				db.SetParticipantStatus(ctx, userId, eventId, Going)
+			}()
+			go func() {
+				defer wg.Done()
+				if userId != "TEST_USER_0" {
+					db.DeleteParticipant(ctx, userId, eventId)
+				}
+			}()
+		}
+		wg.Wait()
+
+		events, err := db.GetEvents(ctx, []string{"TEST_SQUAD_1"}, "TEST_USER_0")
+		if err != nil {
+			t.Fatalf("Failed to get events: %v", err)
+		}
+		for _, e := range events {
+			if e.ID == eventId && (e.Going != 1 || e.Applied != 0) {
+				t.Fatalf("Wrong numbers for applied & going, expected 0, 1 but recieved %v, %v", e.Applied, e.Going)
+			}
+		}
+	})
+
+	t.Run("Recount counters", func(t *testing.T) {
+		fixed, err := db.RecountEventCounters(ctx)
+		if err != nil {
+			t.Fatalf("Failed to recount event counters: %v", err)
+		}
+		if fixed != 0 {
+			t.Fatalf("Counters of %v events are wrong", fixed)
+		}
+	})
+
+	if err = db.DeleteEvent(ctx, eventId); err != nil {
+		t.Fatalf("Failed to delete event: %v", err)
+	}
+}
+
+func TestEventReminders(t *testing.T) {
+	date := time.Date(2031, time.Month(6), 7, 0, 0, 0, 0, time.UTC)
+	eventInfo := &EventInfo{
+		Date:      &date,
+		TimeFrom:  "10:00",
+		Text:      "Reminded event",
+		SquadId:   "TEST_SQUAD_1",
+		OwnerId:   "TEST_USER_0",
+		Reminders: []int{60},
+	}
+	eventId, err := db.CreateEvent(ctx, eventInfo)
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	events, err := db.GetUpcomingEvents(ctx, date.AddDate(0, 0, -1), date.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("Failed to get upcoming events: %v", err)
+	}
+	found := false
+	for _, e := range events {
+		if e.ID == eventId {
+			found = true
+			if len(e.Reminders) != 1 || e.Reminders[0] != 60 {
+				t.Fatalf("Wrong reminders of the event: %+v", e.Reminders)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("Event %v is not among upcoming events", eventId)
+	}
+
+	remindAt := time.Date(2031, time.Month(6), 7, 9, 0, 0, 0, time.UTC)
+	for i, expected := range []bool{true, false} {
+		marked, err := db.MarkEventReminderSent(ctx, eventId, remindAt)
+		if err != nil {
+			t.Fatalf("Failed to mark reminder sent: %v", err)
+		}
+		if marked != expected {
+			t.Fatalf("Attempt %v to mark reminder sent should return %v", i, expected)
+		}
+	}
+
+	err = db.DeleteEvent(ctx, eventId)
+	if err != nil {
+		t.Fatalf("Failed to delete event: %v", err)
+	}
+}
+
+func TestSquadAttendance(t *testing.T) {
+	date := time.Date(2020, time.Month(3), 2, 0, 0, 0, 0, time.UTC)
+	eventInfo := &EventInfo{
+		Date:    &date,
+		Text:    "Past event",
+		SquadId: "TEST_SQUAD_1",
+		OwnerId: "TEST_USER_0",
+	}
+	eventId, err := db.CreateEvent(ctx, eventInfo)
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	if err = db.RegisterParticipants(ctx, []string{"TEST_USER_1", "TEST_USER_2"}, eventId, eventInfo, Going); err != nil {
+		t.Fatalf("Failed to register participants: %v", err)
+	}
+	if _, _, err = db.SetParticipantStatus(ctx, "TEST_USER_1", eventId, Attended); err != nil {
+		t.Fatalf("Failed to change participant status: %v", err)
+	}
+	if _, err = db.MarkNoShows(ctx, eventId); err != nil {
+		t.Fatalf("Failed to mark no-shows: %v", err)
+	}
+
+	// only archived events get into attendance
+	records, err := db.GetSquadAttendance(ctx, "TEST_SQUAD_1", date, date.AddDate(0, 0, 1))
+	if err != nil || len(records) != 0 {
+		t.Fatalf("Expected no attendance before event is archived, got %v (%v)", records, err)
+	}
+	if err = db.ArchiveOldEvents(ctx); err != nil {
+		t.Fatalf("Failed to archive events: %v", err)
+	}
+
+	records, err = db.GetSquadAttendance(ctx, "TEST_SQUAD_1", date, date.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("Failed to get attendance: %v", err)
+	}
+	if len(records) != 2 || records[0].UserId != "TEST_USER_1" || records[0].Status != Attended || records[1].UserId != "TEST_USER_2" || records[1].Status != NoShow || records[1].Date.Format(FieldDateLayout) != "2020-03-02" {
+		t.Fatalf("Wrong attendance records %+v", records)
+	}
+
+	records, err = db.GetSquadAttendance(ctx, "TEST_SQUAD_1", date.AddDate(0, 0, 1), date.AddDate(0, 0, 2))
+	if err != nil || len(records) != 0 {
+		t.Fatalf("Expected no attendance out of period, got %v (%v)", records, err)
+	}
+
+	if err = db.DeleteEvent(ctx, eventId); err != nil {
+		t.Fatalf("Failed to delete event: %v", err)
+	}
+}
+
+func TestJobs(t *testing.T) {
+	now := time.Date(2031, time.Month(5), 6, 10, 0, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+
+	for i, test := range []struct {
+		instance string
+		due      time.Time
+		now      time.Time
+		started  bool
+	}{
+		{"A", now, now, true},
+		{"B", now, now, false},     // locked by A
+		{"B", later, later, true},  // lock of A has expired
+		{"A", later, later, false}, // started by B already
+		{"A", later.Add(time.Minute), later.Add(time.Minute), false}, // locked by B
+	} {
+		started, err := db.StartJob(ctx, "TEST_JOB", test.instance, test.due, test.now, test.now.Add(time.Hour))
+		if err != nil {
+			t.Fatalf("Failed to start job: %v", err)
+		}
+		if started != test.started {
+			t.Fatalf("Step %v: job should be started %v, got %v", i, test.started, started)
+		}
+	}
+
+	// only instance holding lock could finish the job
+	if err := db.FinishJob(ctx, "TEST_JOB", "A", later, "Wrong instance"); err != nil {
+		t.Fatalf("Failed to finish job: %v", err)
+	}
+	if err := db.FinishJob(ctx, "TEST_JOB", "B", later.Add(time.Minute), "Failure"); err != nil {
+		t.Fatalf("Failed to finish job: %v", err)
+	}
+
+	jobs, err := db.GetJobs(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get jobs: %v", err)
+	}
+	for _, j := range jobs {
+		if j.Name == "TEST_JOB" {
+			if j.LockedBy != "" || j.LockedUntil != nil || !j.LastStart.Equal(later) || !j.LastFinish.Equal(later.Add(time.Minute)) || j.LastError != "Failure" {
+				t.Fatalf("Wrong status of the job: %+v", j)
+			}
+			return
+		}
+	}
+	t.Fatalf("Job is not found")
+}
+
+func TestCredentials(t *testing.T) {
+	email := "local_user@mail.com"
+
+	t.Run("Create credentials", func(t *testing.T) {
+		err := db.CreateCredentials(ctx, &Credentials{
+			UserId:       "LOCAL_USER",
+			Email:        email,
+			PasswordHash: "hash",
+		})
+		if err != nil {
+			t.Fatalf("Failed to create credentials: %v", err)
+		}
+
+		err = db.CreateCredentials(ctx, &Credentials{
+			UserId:       "LOCAL_USER_2",
+			Email:        email,
+			PasswordHash: "hash",
+		})
+		if status.Code(err) != codes.AlreadyExists {
+			t.Fatalf("Credentials with duplicate email should not be created, got %v", err)
+		}
+	})
+
+	t.Run("Verify email", func(t *testing.T) {
+		c, err := db.GetCredentials(ctx, email)
+		if err != nil {
+			t.Fatalf("Failed to get credentials: %v", err)
+		}
+		if c.UserId != "LOCAL_USER" || c.PasswordHash != "hash" || c.EmailVerified {
+			t.Fatalf("Unexpected credentials %+v", c)
+		}
+
+		if err = db.SetEmailVerified(ctx, email); err != nil {
+			t.Fatalf("Failed to verify email: %v", err)
+		}
+
+		c, err = db.GetCredentialsByUserId(ctx, "LOCAL_USER")
+		if err != nil {
+			t.Fatalf("Failed to get credentials: %v", err)
+		}
+		if c.Email != email || !c.EmailVerified {
+			t.Fatalf("Email should be verified, got %+v", c)
+		}
+
+		if _, err = db.GetCredentials(ctx, "unknown@mail.com"); status.Code(err) != codes.NotFound {
+			t.Fatalf("Expected NotFound for unknown email, got %v", err)
+		}
+	})
+}
+
+func TestRequestWorkflow(t *testing.T) {
+	queueId := "TEST_WORKFLOW_QUEUE"
+	workflow := &Workflow{
+		Initial: "Review",
+		States: []WorkflowState{
+			{Name: "Review", Status: WaitingApprove},
+			{Name: "NeedsInfo", Status: WaitingApprove, Notify: []string{WorkflowRequester}},
+			{Name: "Processing", Status: Processing},
+			{Name: "Done", Status: Completed},
+		},
+		Transitions: []WorkflowTransition{
+			{Name: "Ask", From: []string{"Review"}, To: "NeedsInfo", Allowed: []string{WorkflowApprovers}},
+			{Name: "Reply", From: []string{"NeedsInfo"}, To: "Review", Allowed: []string{WorkflowRequester}},
+			{Name: "Approve", From: []string{"Review"}, To: "Processing", Allowed: []string{WorkflowApprovers}},
+			{Name: "Complete", From: []string{"Processing"}, To: "Done", Allowed: []string{WorkflowHandlers}},
+		},
+	}
+
+	t.Run("Validate workflow", func(t *testing.T) {
+		if err := workflow.Validate(); err != nil {
+			t.Fatalf("Workflow should be valid: %v", err)
+		}
+		if err := DefaultWorkflow(&QueueInfo{}).Validate(); err != nil {
+			t.Fatalf("Default workflow should be valid: %v", err)
+		}
+
+		invalid := *workflow
+		invalid.Initial = "Unknown"
+		if invalid.Validate() == nil {
+			t.Fatalf("Workflow with unknown initial state should not be valid")
+		}
+
+		invalid = *workflow
+		invalid.Transitions = []WorkflowTransition{{Name: "Skip", From: []string{"Review"}, To: "Nowhere", Allowed: []string{WorkflowAdmins}}}
+		if invalid.Validate() == nil {
+			t.Fatalf("Workflow with transition to unknown state should not be valid")
+		}
+
+		if len(workflow.GetTransitions("Review")) != 2 || workflow.GetTransition("NeedsInfo", "Processing") != nil {
+			t.Fatalf("Unexpected transitions from Review state")
+		}
+	})
+
+	t.Run("Move request through workflow", func(t *testing.T) {
+		err := db.CreateRequestsQueue(ctx, queueId, &QueueInfo{SquadId: "TEST_WORKFLOW_SQUAD", Workflow: workflow})
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		queue, err := db.GetRequestQueue(ctx, queueId)
+		if err != nil {
+			t.Fatalf("Failed to get queue: %v", err)
+		}
+		if queue.Workflow == nil || len(queue.Workflow.States) != 4 || queue.Workflow.Transitions[1].Allowed[0] != WorkflowRequester {
+			t.Fatalf("Queue workflow is not stored, got %+v", queue.Workflow)
+		}
+
+		requestId, err := db.CreateRequest(ctx, &RequestDetails{QueueId: queueId, UserId: "TEST_USER", State: "Review", Status: WaitingApprove})
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+
+		from := "Review"
+		for _, s := range []struct {
+			state          string
+			status         RequestStatusType
+			waitingApprove int
+			processing     int
+		}{
+			{"NeedsInfo", WaitingApprove, 1, 0},
+			{"Review", WaitingApprove, 1, 0},
+			{"Processing", Processing, 0, 1},
+			{"Done", Completed, 0, 0},
+		} {
+			if err = db.SetRequestState(ctx, requestId, from, s.state, s.status, &RequestHistoryEntry{UserId: "TEST_APPROVER", Comment: "moved to " + s.state}); err != nil {
+				t.Fatalf("Failed to set request state %v: %v", s.state, err)
+			}
+
+			request, err := db.GetRequest(ctx, requestId)
+			if err != nil {
+				t.Fatalf("Failed to get request: %v", err)
+			}
+			if request.CurrentState() != s.state || request.Status != s.status {
+				t.Fatalf("Expected request in state %v, got %v (%v)", s.state, request.CurrentState(), request.Status)
+			}
+
+			queue, err = db.GetRequestQueue(ctx, queueId)
+			if err != nil {
+				t.Fatalf("Failed to get queue: %v", err)
+			}
+			if queue.WaitingApprove != s.waitingApprove || queue.Processing != s.processing {
+				t.Fatalf("Unexpected queue counters in state %v: %v waiting approve, %v processing", s.state, queue.WaitingApprove, queue.Processing)
+			}
+			from = s.state
+		}
+
+		// transition from the stale state should not be applied
+		if err = db.SetRequestState(ctx, requestId, "Processing", "Review", WaitingApprove, &RequestHistoryEntry{UserId: "TEST_APPROVER"}); status.Code(err) != codes.FailedPrecondition {
+			t.Fatalf("Request moved by someone else should not be moved again, got %v", err)
+		}
+
+		history, err := db.GetRequestHistory(ctx, requestId)
+		if err != nil {
+			t.Fatalf("Failed to get request history: %v", err)
+		}
+		if len(history) != 5 || history[0].OldState != "" || history[0].NewState != "Review" || history[0].UserId != "TEST_USER" {
+			t.Fatalf("Request history should start with creation, got %+v", history[0])
+		}
+		last := history[4]
+		if last.OldState != "Processing" || last.OldStatus != Processing || last.NewState != "Done" || last.NewStatus != Completed ||
+			last.UserId != "TEST_APPROVER" || last.Comment != "moved to Done" || last.Time == nil {
+			t.Fatalf("Unexpected last history entry %+v", last)
+		}
+	})
+	t.Run("Assignee and SLA", func(t *testing.T) {
+		slaQueueId := "TEST_SLA_QUEUE"
+		err := db.CreateRequestsQueue(ctx, slaQueueId, &QueueInfo{SquadId: "TEST_WORKFLOW_SQUAD", SLA: &QueueSLA{ApproveMinutes: 60}})
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+
+		queues, err := db.GetQueuesWithSLA(ctx)
+		if err != nil {
+			t.Fatalf("Failed to get queues with SLA: %v", err)
+		}
+		found := false
+		for _, q := range queues {
+			if q.ID == slaQueueId {
+				found = q.SLA != nil && q.SLA.Limit(WaitingApprove) == time.Hour && q.SLA.Limit(Processing) == 0
+			} else if q.ID == queueId {
+				t.Fatalf("Queue without SLA should not be returned")
+			}
+		}
+		if !found {
+			t.Fatalf("Queue %v with SLA is not returned, got %+v", slaQueueId, queues)
+		}
+
+		requestId, err := db.CreateRequest(ctx, &RequestDetails{QueueId: slaQueueId, UserId: "TEST_USER", Status: WaitingApprove})
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+
+		if err = db.SetRequestAssignee(ctx, requestId, "", "TEST_HANDLER", "Handler"); err != nil {
+			t.Fatalf("Failed to claim request: %v", err)
+		}
+		if err = db.SetRequestAssignee(ctx, requestId, "", "TEST_APPROVER", "Approver"); status.Code(err) != codes.FailedPrecondition {
+			t.Fatalf("Claimed request should not be claimed again, got %v", err)
+		}
+		if err = db.SetRequestAssignee(ctx, "UNKNOWN_REQUEST", "", "TEST_APPROVER", "Approver"); status.Code(err) != codes.NotFound {
+			t.Fatalf("Unknown request should not be found, got %v", err)
+		}
+
+		request, err := db.GetRequest(ctx, requestId)
+		if err != nil {
+			t.Fatalf("Failed to get request: %v", err)
+		}
+		if request.AssigneeId != "TEST_HANDLER" || request.AssigneeName != "Handler" || request.StatusTime == nil {
+			t.Fatalf("Unexpected request %+v", request)
+		}
+		if request.IsOverdue(&QueueSLA{ApproveMinutes: 60}, time.Now()) || !request.IsOverdue(&QueueSLA{ApproveMinutes: 60}, time.Now().Add(2*time.Hour)) {
+			t.Fatalf("Request should become overdue in an hour")
+		}
+
+		overdue, err := db.GetOverdueRequests(ctx, slaQueueId, WaitingApprove, time.Now().Add(time.Minute))
+		if err != nil {
+			t.Fatalf("Failed to get overdue requests: %v", err)
+		}
+		if len(overdue) != 1 || overdue[0].RequestId != requestId {
+			t.Fatalf("Expected request %v to be overdue, got %+v", requestId, overdue)
+		}
+
+		if err = db.SetRequestEscalated(ctx, requestId); err != nil {
+			t.Fatalf("Failed to escalate request: %v", err)
+		}
+		overdue, err = db.GetOverdueRequests(ctx, slaQueueId, WaitingApprove, time.Now().Add(time.Minute))
+		if err != nil || len(overdue) != 0 {
+			t.Fatalf("Escalated request should not be returned again, got %+v, %v", overdue, err)
+		}
+
+		if err = db.SetRequestState(ctx, requestId, WaitingApprove.String(), Processing.String(), Processing, &RequestHistoryEntry{UserId: "TEST_APPROVER"}); err != nil {
+			t.Fatalf("Failed to set request state: %v", err)
+		}
+		overdue, err = db.GetOverdueRequests(ctx, slaQueueId, Processing, time.Now().Add(time.Minute))
+		if err != nil || len(overdue) != 1 {
+			t.Fatalf("Request should be escalated again in the new status, got %+v, %v", overdue, err)
+		}
+	})
+}
+
+func TestRequestFields(t *testing.T) {
+	queueId := "TEST_FIELDS_QUEUE"
+	queue := &QueueInfo{SquadId: "TEST_FIELDS_SQUAD", Fields: []QueueField{
+		{Name: "model", Type: FieldText, Required: true},
+		{Name: "price", Type: FieldNumber},
+		{Name: "due", Type: FieldDate},
+		{Name: "os", Type: FieldSelect, Options: []string{"Linux", "Windows"}},
+		{Name: "extras", Type: FieldMultiSelect, Options: []string{"Mouse", "Bag", "Dock"}},
+	}}
+
+	t.Run("Validate fields", func(t *testing.T) {
+		if err := queue.ValidateFields(); err != nil {
+			t.Fatalf("Fields should be valid: %v", err)
+		}
+		for _, f := range []QueueField{
+			{Name: "1st", Type: FieldText},
+			{Name: "os", Type: FieldSelect},
+			{Name: "color", Type: "color"},
+		} {
+			invalid := QueueInfo{Fields: []QueueField{f}}
+			if invalid.ValidateFields() == nil {
+				t.Fatalf("Field %+v should not be valid", f)
+			}
+		}
+
+		for _, values := range []map[string]interface{}{
+			{"price": 1000.0},
+			{"model": "X1", "price": "1000"},
+			{"model": "X1", "due": "31.12.2021"},
+			{"model": "X1", "os": "Mac"},
+			{"model": "X1", "extras": []interface{}{"Mouse", "Cup"}},
+			{"model": "X1", "color": "Black"},
+		} {
+			if _, err := queue.ParseFields(values); err == nil {
+				t.Fatalf("Values %+v should not be valid", values)
+			}
+		}
+	})
+
+	t.Run("Filter requests by fields", func(t *testing.T) {
+		err := db.CreateRequestsQueue(ctx, queueId, queue)
+		if err != nil {
+			t.Fatalf("Failed to create queue: %v", err)
+		}
+		stored, err := db.GetRequestQueue(ctx, queueId)
+		if err != nil {
+			t.Fatalf("Failed to get queue: %v", err)
+		}
+		if len(stored.Fields) != 5 || stored.Fields[4].Options[2] != "Dock" {
+			t.Fatalf("Queue fields are not stored, got %+v", stored.Fields)
+		}
+
+		for _, values := range []map[string]interface{}{
+			{"model": " X1 ", "price": 1500.5, "due": "2021-12-31", "os": "Linux", "extras": []interface{}{"Mouse", "Dock"}},
+			{"model": "X1", "price": 900.0, "os": "Windows", "extras": []interface{}{"Bag"}},
+			{"model": "T14"},
+		} {
+			fields, err := queue.ParseFields(values)
+			if err != nil {
+				t.Fatalf("Failed to parse fields %+v: %v", values, err)
+			}
+			_, err = db.CreateRequest(ctx, &RequestDetails{QueueId: queueId, UserId: "TEST_FIELDS_USER", Status: WaitingApprove, Fields: fields})
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+		}
+
+		for _, f := range []struct {
+			filter map[string]string
+			count  int
+		}{
+			{map[string]string{}, 3},
+			{map[string]string{"model": "X1"}, 2},
+			{map[string]string{"model": "X1", "os": "Linux"}, 1},
+			{map[string]string{"price": "900"}, 1},
+			{map[string]string{"due": "2021-12-31"}, 1},
+			{map[string]string{"extras": "Dock"}, 1},
+			{map[string]string{"extras": "Bag", "os": "Linux"}, 0},
+		} {
+			filter := &RequestsFilter{QueueId: queueId, Fields: make(map[string]interface{})}
+			for name, value := range f.filter {
+				if filter.Fields[name], err = queue.ParseFilter(name, value); err != nil {
+					t.Fatalf("Failed to parse filter %v=%v: %v", name, value, err)
+				}
+			}
+
+			requests, err := db.GetUserRequests(ctx, "TEST_FIELDS_USER", nil, filter)
+			if err != nil {
+				t.Fatalf("Failed to get requests: %v", err)
+			}
+			if len(requests) != f.count {
+				t.Fatalf("Expected %v requests matching %v, got %v", f.count, f.filter, len(requests))
+			}
+		}
+
+		requests, err := db.GetRequestsByTag(ctx, nil, []string{"TEST_FIELDS_SQUAD"}, WaitingApprove, nil, &RequestsFilter{QueueId: queueId, Fields: map[string]interface{}{"os": "Windows"}})
+		if err != nil {
+			t.Fatalf("Failed to get requests: %v", err)
+		}
+		if len(requests) != 1 || requests[0].Fields["model"] != "X1" || requests[0].Fields["price"] != 900.0 {
+			t.Fatalf("Unexpected requests %+v", requests)
+		}
+	})
+}
+
+func TestEventSeries(t *testing.T) {
+	day := func(s string) time.Time {
+		d, _ := time.Parse(FieldDateLayout, s)
+		return d
+	}
+
+	t.Run("Parse recurrence rules", func(t *testing.T) {
+		for _, s := range []string{"INTERVAL=2", "FREQ=YEARLY", "FREQ=DAILY;BYDAY=MO", "FREQ=WEEKLY;COUNT=2;UNTIL=20210101", "FREQ=WEEKLY;BYDAY=XX"} {
+			if _, err := ParseRRule(s); err == nil {
+				t.Fatalf("Rule %v should not be valid", s)
+			}
+		}
+
+		for _, c := range []struct {
+			rule    string
+			start   string
+			exDates []string
+			from    string
+			to      string
+			dates   []string
+		}{
+			{"FREQ=WEEKLY;BYDAY=TU,TH;COUNT=5", "2021-03-02", []string{"2021-03-04"}, "2021-01-01", "2022-01-01", []string{"2021-03-02", "2021-03-09", "2021-03-11", "2021-03-16"}},
+			{"FREQ=MONTHLY;UNTIL=20210630", "2021-01-31", nil, "2021-01-01", "2022-01-01", []string{"2021-01-31", "2021-03-31", "2021-05-31"}},
+			{"RRULE:FREQ=DAILY;INTERVAL=3", "2021-03-01", nil, "2021-03-05", "2021-03-12", []string{"2021-03-07", "2021-03-10"}},
+			{"FREQ=WEEKLY;INTERVAL=2", "2021-03-03", nil, "2021-03-01", "2021-04-01", []string{"2021-03-03", "2021-03-17", "2021-03-31"}},
+		} {
+			rule, err := ParseRRule(c.rule)
+			if err != nil {
+				t.Fatalf("Failed to parse rule %v: %v", c.rule, err)
+			}
+			dates := make([]string, 0)
+			for _, d := range rule.Occurrences(day(c.start), c.exDates, day(c.from), day(c.to)) {
+				dates = append(dates, d.Format(FieldDateLayout))
+			}
+			if strings.Join(dates, ",") != strings.Join(c.dates, ",") {
+				t.Fatalf("Rule %v: expected %v, got %v", c.rule, c.dates, dates)
+			}
+		}
+	})
+
+	t.Run("Store series and occurrences", func(t *testing.T) {
+		start := day("2031-03-04")
+		series := &EventSeries{
+			SquadId:  "TEST_SERIES_SQUAD",
+			OwnerId:  "TEST_SERIES_USER",
+			Text:     "Training",
+			TimeFrom: "19:00",
+			TimeTo:   "21:00",
+			Start:    &start,
+			RRule:    "FREQ=WEEKLY",
+		}
+		seriesId, err := db.CreateEventSeries(ctx, series)
+		if err != nil {
+			t.Fatalf("Failed to create event series: %v", err)
+		}
+
+		series.ExcludeDay(start.AddDate(0, 0, 7))
+		series.Participants = map[string]ParticipantStatusType{"TEST_USER_0": Going}
+		if err = db.UpdateEventSeries(ctx, seriesId, series); err != nil {
+			t.Fatalf("Failed to update event series: %v", err)
+		}
+		stored, err := db.GetEventSeries(ctx, seriesId)
+		if err != nil {
+			t.Fatalf("Failed to get event series: %v", err)
+		}
+		if stored.Text != "Training" || len(stored.ExDates) != 1 || stored.ExDates[0] != "2031-03-11" || stored.Participants["TEST_USER_0"] != Going {
+			t.Fatalf("Unexpected event series %+v", stored)
+		}
+
+		active, err := db.GetActiveEventSeries(ctx)
+		if err != nil {
+			t.Fatalf("Failed to get active event series: %v", err)
+		}
+		found := false
+		for _, s := range active {
+			found = found || s.ID == seriesId
+		}
+		if !found {
+			t.Fatalf("Series %v should be active", seriesId)
+		}
+
+		ids := make([]string, 0)
+		for _, d := range []time.Time{start.AddDate(0, 0, 14), start} {
+			date := d
+			id, err := db.CreateEvent(ctx, &EventInfo{Date: &date, Text: series.Text, SquadId: series.SquadId, OwnerId: series.OwnerId, SeriesId: seriesId})
+			if err != nil {
+				t.Fatalf("Failed to create occurrence: %v", err)
+			}
+			ids = append(ids, id)
+		}
+
+		events, err := db.GetSeriesEvents(ctx, seriesId)
+		if err != nil {
+			t.Fatalf("Failed to get series events: %v", err)
+		}
+		if len(events) != 2 || events[0].ID != ids[1] || events[1].ID != ids[0] || events[0].SeriesId != seriesId {
+			t.Fatalf("Unexpected series events %+v", events)
+		}
+
+		moved := start.AddDate(0, 0, 1)
+		e := &EventInfo{Date: &moved, Text: "Training (gym)", TimeFrom: "18:00", SeriesOverride: true}
+		if err = db.UpdateEvent(ctx, ids[1], e); err != nil {
+			t.Fatalf("Failed to update event: %v", err)
+		}
+		updated, err := db.GetEvent(ctx, ids[1])
+		if err != nil {
+			t.Fatalf("Failed to get event: %v", err)
+		}
+		if updated.Text != "Training (gym)" || updated.TimeFrom != "18:00" || !updated.SeriesOverride || updated.Date.Format(FieldDateLayout) != "2031-03-05" {
+			t.Fatalf("Unexpected updated event %+v", updated)
+		}
+
+		series.Cancelled = true
+		if err = db.UpdateEventSeries(ctx, seriesId, series); err != nil {
+			t.Fatalf("Failed to cancel event series: %v", err)
+		}
+		active, _ = db.GetActiveEventSeries(ctx)
+		for _, s := range active {
+			if s.ID == seriesId {
+				t.Fatalf("Cancelled series %v should not be active", seriesId)
+			}
+		}
+	})
+}
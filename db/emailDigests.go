@@ -0,0 +1,110 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// how often notifications are sent to the user by email
+const (
+	DigestImmediate = "immediate"
+	DigestHourly    = "hourly"
+	DigestDaily     = "daily"
+)
+
+// DigestItem is notification waiting to be sent to the user in the next email digest
+type DigestItem struct {
+	Digest string
+	Notification
+}
+
+type DigestItemRecord struct {
+	ID string
+	DigestItem
+}
+
+func sortDigestItems(items []*DigestItemRecord) {
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].UserId != items[j].UserId {
+			return items[i].UserId < items[j].UserId
+		}
+		return items[i].Time.Before(items[j].Time)
+	})
+}
+
+func (db *FirestoreDB) GetEmailDigest(ctx context.Context, userId string) (string, error) {
+	doc, err := db.NotificationPrefs.Doc(userId).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return DigestImmediate, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("Failed to get user %v email digest: %w", userId, err)
+	}
+
+	digest, _ := doc.DataAt("EmailDigest")
+	if s, ok := digest.(string); ok && s != "" {
+		return s, nil
+	}
+	return DigestImmediate, nil
+}
+
+func (db *FirestoreDB) SetEmailDigest(ctx context.Context, userId string, digest string) error {
+	_, err := db.NotificationPrefs.Doc(userId).Set(ctx, map[string]interface{}{"EmailDigest": digest}, firestore.MergeAll)
+	if err != nil {
+		return fmt.Errorf("Failed to set user %v email digest: %w", userId, err)
+	}
+
+	return nil
+}
+
+func (db *FirestoreDB) CreateDigestItem(ctx context.Context, item *DigestItem) error {
+	_, _, err := db.DigestItems.Add(ctx, item)
+	if err != nil {
+		return fmt.Errorf("Failed to add notification '%v' to user %v digest: %w", item.Title, item.UserId, err)
+	}
+
+	return nil
+}
+
+func (db *FirestoreDB) GetDigestItems(ctx context.Context, digest string) ([]*DigestItemRecord, error) {
+	docs, err := db.DigestItems.Where("Digest", "==", digest).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get %v digest notifications: %w", digest, err)
+	}
+
+	items := make([]*DigestItemRecord, 0, len(docs))
+	for _, doc := range docs {
+		item := &DigestItemRecord{ID: doc.Ref.ID}
+		if err = doc.DataTo(&item.DigestItem); err != nil {
+			return nil, fmt.Errorf("Failed to get %v digest notifications: %w", digest, err)
+		}
+		items = append(items, item)
+	}
+	sortDigestItems(items)
+
+	return items, nil
+}
+
+func (db *FirestoreDB) DeleteDigestItems(ctx context.Context, ids []string) error {
+	for from := 0; from < len(ids); from += notificationsBatchSize {
+		to := from + notificationsBatchSize
+		if to > len(ids) {
+			to = len(ids)
+		}
+
+		batch := db.Client.Batch()
+		for _, id := range ids[from:to] {
+			batch.Delete(db.DigestItems.Doc(id))
+		}
+		if _, err := batch.Commit(ctx); err != nil {
+			return fmt.Errorf("Failed to delete digest notifications: %w", err)
+		}
+	}
+
+	return nil
+}
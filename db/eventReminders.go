@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// sent reminders are kept in subcollection of the event
+const EVENT_REMINDERS = "reminders"
+
+// reminders are identified by event and time they should be sent at, so
+// moving event to another time makes them pending again
+func reminderKey(remindAt time.Time) string {
+	return remindAt.UTC().Format("20060102T150405Z")
+}
+
+// GetUpcomingEvents returns not archived events with date in [from, to)
+func (db *FirestoreDB) GetUpcomingEvents(ctx context.Context, from time.Time, to time.Time) ([]*EventRecord, error) {
+	events := make([]*EventRecord, 0)
+
+	iter := db.Events.Where("Archived", "==", false).Where("Date", ">=", from).Where("Date", "<", to).OrderBy("Date", firestore.Asc).Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get upcoming events: %w", err)
+		}
+
+		e := &EventRecord{ID: doc.Ref.ID}
+		if err = doc.DataTo(&e.EventInfo); err != nil {
+			return nil, fmt.Errorf("Failed to get upcoming events: %w", err)
+		}
+		events = append(events, e)
+	}
+
+	return events, nil
+}
+
+// GetParticipantIds returns ids of participants (not replicants) having given status
+func (db *FirestoreDB) GetParticipantIds(ctx context.Context, eventId string, status ParticipantStatusType) ([]string, error) {
+	ids := make([]string, 0)
+
+	iter := db.Events.Doc(eventId).Collection(MEMBERS).Where("Status", "==", status).Where("Replicant", "==", false).Select().Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get event %v participants: %w", eventId, err)
+		}
+		ids = append(ids, doc.Ref.ID)
+	}
+
+	return ids, nil
+}
+
+// MarkEventReminderSent returns false if the reminder has been already marked as sent
+func (db *FirestoreDB) MarkEventReminderSent(ctx context.Context, eventId string, remindAt time.Time) (bool, error) {
+	doc := db.Events.Doc(eventId).Collection(EVENT_REMINDERS).Doc(reminderKey(remindAt))
+
+	_, err := doc.Create(ctx, map[string]interface{}{
+		"RemindAt": remindAt,
+		"SentAt":   firestore.ServerTimestamp,
+	})
+	if err != nil {
+		if status.Code(err) == codes.AlreadyExists {
+			return false, nil
+		}
+		return false, fmt.Errorf("Failed to mark event %v reminder sent: %w", eventId, err)
+	}
+
+	return true, nil
+}
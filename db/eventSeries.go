@@ -0,0 +1,236 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// EventSeries describes recurring event, its occurrences are ordinary events
+// generated ahead of time and referring to the series by SeriesId
+type EventSeries struct {
+	SquadId  string     `json:"squadId"`
+	OwnerId  string     `json:"ownerId"`
+	Text     string     `json:"text"`
+	TimeFrom string     `json:"timeFrom"`
+	TimeTo   string     `json:"timeTo"`
+	Start    *time.Time `json:"start"`
+	RRule    string     `json:"rrule"`
+	// capacity and reminders of every occurrence
+	Capacity  int   `json:"capacity,omitempty"`
+	Reminders []int `json:"reminders,omitempty"`
+	// location of every occurrence
+	Location *EventLocation `json:"location,omitempty"`
+	// days (YYYY-MM-DD) excluded from the series
+	ExDates []string `json:"exDates"`
+	// users registered for the whole series, they are registered for every new occurrence
+	Participants   map[string]ParticipantStatusType `json:"participants"`
+	Cancelled      bool                             `json:"cancelled"`
+	GeneratedUntil *time.Time                       `json:"generatedUntil"`
+}
+
+type EventSeriesRecord struct {
+	ID string `json:"id"`
+	EventSeries
+}
+
+// ExcludeDay adds the day to exception dates, returns false if it is already there
+func (s *EventSeries) ExcludeDay(day time.Time) bool {
+	d := day.UTC().Format(FieldDateLayout)
+	if containsString(s.ExDates, d) {
+		return false
+	}
+	s.ExDates = append(s.ExDates, d)
+	return true
+}
+
+func (db *FirestoreDB) CreateEventSeries(ctx context.Context, series *EventSeries) (string, error) {
+	if db.dev {
+		log.Printf("Creating event series '%+v'", series)
+	}
+
+	doc, _, err := db.EventSeries.Add(ctx, series)
+	if err != nil {
+		return "", fmt.Errorf("Failed to create event series: %w", err)
+	}
+
+	return doc.ID, nil
+}
+
+func (db *FirestoreDB) GetEventSeries(ctx context.Context, seriesId string) (*EventSeries, error) {
+	doc, err := db.EventSeries.Doc(seriesId).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, notFound("Failed to get event series %v: not found", seriesId)
+		}
+		return nil, fmt.Errorf("Failed to get event series %v: %w", seriesId, err)
+	}
+
+	s := &EventSeries{}
+	if err = doc.DataTo(s); err != nil {
+		return nil, fmt.Errorf("Failed to get event series %v: %w", seriesId, err)
+	}
+
+	return s, nil
+}
+
+func (db *FirestoreDB) UpdateEventSeries(ctx context.Context, seriesId string, series *EventSeries) error {
+	if db.dev {
+		log.Printf("Updating event series %v: %+v", seriesId, series)
+	}
+
+	_, err := db.EventSeries.Doc(seriesId).Set(ctx, series)
+	if err != nil {
+		return fmt.Errorf("Failed to update event series %v: %w", seriesId, err)
+	}
+
+	return nil
+}
+
+func (db *FirestoreDB) GetActiveEventSeries(ctx context.Context) ([]*EventSeriesRecord, error) {
+	series := make([]*EventSeriesRecord, 0)
+
+	iter := db.EventSeries.Where("Cancelled", "==", false).Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get event series: %w", err)
+		}
+
+		s := &EventSeriesRecord{ID: doc.Ref.ID}
+		if err = doc.DataTo(&s.EventSeries); err != nil {
+			return nil, fmt.Errorf("Failed to get event series: %w", err)
+		}
+		series = append(series, s)
+	}
+
+	return series, nil
+}
+
+func (db *FirestoreDB) GetSeriesEvents(ctx context.Context, seriesId string) ([]*EventRecord, error) {
+	events := make([]*EventRecord, 0)
+
+	iter := db.Events.Where("SeriesId", "==", seriesId).Where("Archived", "==", false).OrderBy("Date", firestore.Asc).Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get events of series %v: %w", seriesId, err)
+		}
+
+		e := &EventRecord{ID: doc.Ref.ID}
+		if err = doc.DataTo(&e.EventInfo); err != nil {
+			return nil, fmt.Errorf("Failed to get events of series %v: %w", seriesId, err)
+		}
+		events = append(events, e)
+	}
+
+	return events, nil
+}
+
+// UpdateEvent changes date, time, text and series override flag of the
+// event and of its copies kept by participants
+func (db *FirestoreDB) UpdateEvent(ctx context.Context, eventId string, event *EventInfo) error {
+	if db.dev {
+		log.Printf("Updating event %v: %+v", eventId, event)
+	}
+
+	// callers may have modified cached event
+	db.eventDataCache.Delete(eventId)
+	old, err := db.GetEvent(ctx, eventId)
+	if err != nil {
+		return err
+	}
+
+	if event.Date == nil || startOfDay(*event.Date) == startOfDay(*old.Date) {
+		event.Date = old.Date
+	} else {
+		date, err := db.uniqueEventDate(ctx, event.Date)
+		if err != nil {
+			return fmt.Errorf("Failed to update event %v: %w", eventId, err)
+		}
+		event.Date = &date
+	}
+
+	updates := []firestore.Update{
+		{Path: "Date", Value: event.Date},
+		{Path: "TimeFrom", Value: event.TimeFrom},
+		{Path: "TimeTo", Value: event.TimeTo},
+		{Path: "Text", Value: event.Text},
+		{Path: "SeriesOverride", Value: event.SeriesOverride},
+		{Path: "Capacity", Value: event.Capacity},
+		{Path: "Reminders", Value: event.Reminders},
+		{Path: "Location", Value: event.Location},
+	}
+
+	// participants copies, owner keeps own copy even if not registered
+	userIds := []string{old.OwnerId}
+	iter := db.Events.Doc(eventId).Collection(MEMBERS).Where("Replicant", "==", false).Select().Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to get event %v participants: %w", eventId, err)
+		}
+		if doc.Ref.ID != old.OwnerId {
+			userIds = append(userIds, doc.Ref.ID)
+		}
+	}
+
+	refs := []*firestore.DocumentRef{db.Events.Doc(eventId)}
+	for from := 0; from < len(userIds); from += notificationsBatchSize {
+		to := from + notificationsBatchSize
+		if to > len(userIds) {
+			to = len(userIds)
+		}
+
+		copies := make([]*firestore.DocumentRef, 0, to-from)
+		for _, userId := range userIds[from:to] {
+			copies = append(copies, db.Users.Doc(userId).Collection(USER_EVENTS).Doc(eventId))
+		}
+		docs, err := db.Client.GetAll(ctx, copies)
+		if err != nil {
+			return fmt.Errorf("Failed to get event %v copies: %w", eventId, err)
+		}
+		for _, doc := range docs {
+			if doc.Exists() {
+				refs = append(refs, doc.Ref)
+			}
+		}
+	}
+
+	// event goes in the first batch, Firestore limits amount of writes in a batch
+	db.eventDataCache.Delete(eventId)
+	for from := 0; from < len(refs); from += notificationsBatchSize {
+		to := from + notificationsBatchSize
+		if to > len(refs) {
+			to = len(refs)
+		}
+
+		batch := db.Client.Batch()
+		for _, ref := range refs[from:to] {
+			batch.Update(ref, updates)
+		}
+		if _, err = batch.Commit(ctx); err != nil {
+			return fmt.Errorf("Failed to update event %v: %w", eventId, err)
+		}
+	}
+
+	return nil
+}
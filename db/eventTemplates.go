@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// EventTemplate keeps defaults of events which are created in the squad again and again
+type EventTemplate struct {
+	Name      string         `json:"name"`
+	Text      string         `json:"text"`
+	TimeFrom  string         `json:"timeFrom"`
+	TimeTo    string         `json:"timeTo"`
+	Capacity  int            `json:"capacity,omitempty"`
+	Reminders []int          `json:"reminders,omitempty"`
+	Location  *EventLocation `json:"location,omitempty"`
+	// members having any of these tags are registered to events created from the template
+	Tags []string `json:"tags,omitempty"`
+}
+
+type EventTemplateRecord struct {
+	ID string `json:"id"`
+	EventTemplate
+}
+
+func (db *FirestoreDB) CreateEventTemplate(ctx context.Context, squadId string, template *EventTemplate) (string, error) {
+	if template.Name == "" {
+		return "", fmt.Errorf("Failed to create event template, name is not provided: %+v", template)
+	}
+
+	log.Printf("Creating event template '%v' in squad '%v'", template.Name, squadId)
+
+	doc, _, err := db.Squads.Doc(squadId).Collection("eventTemplates").Add(ctx, template)
+	if err != nil {
+		return "", fmt.Errorf("Failed to create event template in squad %v: %w", squadId, err)
+	}
+
+	return doc.ID, nil
+}
+
+func (db *FirestoreDB) GetEventTemplates(ctx context.Context, squadId string) ([]*EventTemplateRecord, error) {
+	templates := make([]*EventTemplateRecord, 0)
+
+	iter := db.Squads.Doc(squadId).Collection("eventTemplates").OrderBy("Name", firestore.Asc).Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get squad %v event templates: %w", squadId, err)
+		}
+
+		t := &EventTemplateRecord{ID: doc.Ref.ID}
+		if err = doc.DataTo(&t.EventTemplate); err != nil {
+			return nil, fmt.Errorf("Failed to get squad %v event templates: %w", squadId, err)
+		}
+		templates = append(templates, t)
+	}
+
+	return templates, nil
+}
+
+func (db *FirestoreDB) GetEventTemplate(ctx context.Context, squadId string, templateId string) (*EventTemplate, error) {
+	doc, err := db.Squads.Doc(squadId).Collection("eventTemplates").Doc(templateId).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, notFound("Failed to get event template %v: not found", templateId)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get event template %v: %w", templateId, err)
+	}
+
+	template := &EventTemplate{}
+	if err = doc.DataTo(template); err != nil {
+		return nil, fmt.Errorf("Failed to get event template %v: %w", templateId, err)
+	}
+
+	return template, nil
+}
+
+func (db *FirestoreDB) UpdateEventTemplate(ctx context.Context, squadId string, templateId string, template *EventTemplate) error {
+	if template.Name == "" {
+		return fmt.Errorf("Failed to update event template, name is not provided: %+v", template)
+	}
+
+	log.Printf("Updating event template '%v' in squad '%v'", templateId, squadId)
+
+	doc := db.Squads.Doc(squadId).Collection("eventTemplates").Doc(templateId)
+	err := db.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		if _, err := tx.Get(doc); err != nil {
+			return err
+		}
+		return tx.Set(doc, template)
+	})
+	if status.Code(err) == codes.NotFound {
+		return notFound("Failed to update event template %v: not found", templateId)
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to update event template %v: %w", templateId, err)
+	}
+
+	return nil
+}
+
+func (db *FirestoreDB) DeleteEventTemplate(ctx context.Context, squadId string, templateId string) error {
+	log.Println("Deleting event template " + templateId + " from squad " + squadId)
+
+	_, err := db.Squads.Doc(squadId).Collection("eventTemplates").Doc(templateId).Delete(ctx)
+	if err != nil {
+		return fmt.Errorf("Error while deleting event template "+templateId+" from squad "+squadId+": %w", err)
+	}
+
+	return nil
+}
@@ -25,6 +25,8 @@ const (
 	Attended
 	NoShow
 	EventOwner
+	// registered when event has no free places
+	Waitlisted
 )
 
 var ParticipantStatusTypes = []ParticipantStatusType{
@@ -34,6 +36,7 @@ var ParticipantStatusTypes = []ParticipantStatusType{
 	Attended,
 	NoShow,
 	EventOwner,
+	Waitlisted,
 }
 
 func (s ParticipantStatusType) String() string {
@@ -44,11 +47,17 @@ func (s ParticipantStatusType) String() string {
 		"Attended",
 		"NoShow",
 		"Owner",
+		"Waitlisted",
 	}
 
 	return texts[s]
 }
 
+// TakesPlace tells if participant with this status counts against event capacity
+func (s ParticipantStatusType) TakesPlace() bool {
+	return s == Applied || s == Going || s == Attended
+}
+
 func eventStatusFromString(s string) int {
 	for _, t := range ParticipantStatusTypes {
 		if t.String() == s {
@@ -67,6 +76,15 @@ type EventInfo struct {
 	OwnerId  string                `json:"ownerId"`
 	Status   ParticipantStatusType `json:"status"`
 	Archived bool                  `json:"archived"`
+	// maximum amount of participants, 0 means unlimited
+	Capacity int `json:"capacity,omitempty"`
+	// minutes before the event start when participants get reminders
+	Reminders []int `json:"reminders,omitempty"`
+	// occurrence of the event series, override means it was edited separately
+	SeriesId       string `json:"seriesId,omitempty"`
+	SeriesOverride bool   `json:"seriesOverride,omitempty"`
+	// where the event takes place, nil if not set
+	Location *EventLocation `json:"location,omitempty"`
 }
 
 type EventRecord struct {
@@ -77,10 +95,35 @@ type EventRecord struct {
 type EventCountersRecord struct {
 	ID string `json:"id"`
 	EventInfo
-	Going    int `json:"going"`
-	Applied  int `json:"applied"`
-	Attended int `json:"attended"`
-	NoShow   int `json:"no-show"`
+	Going      int `json:"going"`
+	Applied    int `json:"applied"`
+	Attended   int `json:"attended"`
+	NoShow     int `json:"no-show"`
+	Waitlisted int `json:"waitlisted"`
+}
+
+// places taken by participants, NotGoing, NoShow and Waitlisted do not take place
+func (e *EventCountersRecord) taken() int {
+	return e.Applied + e.Going + e.Attended
+}
+
+// registrationStatus returns status of a new participant: the requested one
+// while event has free places and Waitlisted after that
+func registrationStatus(capacity int, taken int, status ParticipantStatusType) ParticipantStatusType {
+	if capacity > 0 && status.TakesPlace() && taken >= capacity {
+		return Waitlisted
+	}
+	return status
+}
+
+// changedStatus returns status of a participant changing from oldStatus: going
+// or applying for a place is limited by capacity like registration, other
+// changes (e.g. check in) are kept as requested
+func changedStatus(capacity int, taken int, oldStatus ParticipantStatusType, status ParticipantStatusType) ParticipantStatusType {
+	if oldStatus.TakesPlace() || (status != Going && status != Applied) {
+		return status
+	}
+	return registrationStatus(capacity, taken, status)
 }
 
 type ParticipantInfo struct {
@@ -91,11 +134,75 @@ type ParticipantInfo struct {
 	Status    ParticipantStatusType `json:"status"`
 }
 
+// ParticipantError is failure to register one of the users for the event
+type ParticipantError struct {
+	UserId string
+	Err    error
+}
+
+func (e *ParticipantError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ParticipantError) Unwrap() error {
+	return e.Err
+}
+
+// RegistrationErrors is returned by RegisterParticipants when some of the users
+// are not registered, the others are registered anyway
+type RegistrationErrors []*ParticipantError
+
+func (e RegistrationErrors) Error() string {
+	var sb strings.Builder
+	sb.WriteString("Failed to register one or more participants for event:")
+	for _, pe := range e {
+		sb.WriteString("\n\t")
+		sb.WriteString(pe.Err.Error())
+	}
+	return sb.String()
+}
+
+// registrationErrors returns RegistrationErrors for users having non-nil errors, or nil if there are none
+func registrationErrors(userIds []string, errs []error) error {
+	var res RegistrationErrors
+	for i, err := range errs {
+		if err != nil {
+			res = append(res, &ParticipantError{UserId: userIds[i], Err: err})
+		}
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
+
 type ParticipantRecord struct {
 	ID string `json:"id"`
 	ParticipantInfo
 }
 
+// events dates are used to page through them, so all of them should be unique
+func (db *FirestoreDB) uniqueEventDate(ctx context.Context, eventDate *time.Time) (date time.Time, err error) {
+	year, month, day := eventDate.Date()
+	startOfTheDay := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	endOfTheDay := time.Date(year, month, day, 24, 0, 0, 0, time.UTC)
+	lastDocIter := db.Events.OrderBy("Date", firestore.Desc).Where("Date", ">=", startOfTheDay).Where("Date", "<", endOfTheDay).Limit(1).Documents(ctx)
+	defer lastDocIter.Stop()
+	lastDoc, err := lastDocIter.Next()
+	if err != nil {
+		if err != iterator.Done {
+			return date, err
+		}
+		date = time.Date(year, month, day, 7, 0, rand.Intn(60), 0, time.UTC)
+	} else {
+		ei := EventInfo{}
+		lastDoc.DataTo(&ei)
+		date = ei.Date.Add(time.Duration(rand.Intn(60)) * time.Second)
+	}
+
+	return date, nil
+}
+
 func (db *FirestoreDB) CreateEvent(ctx context.Context, event *EventInfo) (id string, err error) {
 
 	if event.Text != "" && event.Date != nil && event.SquadId != "" {
@@ -103,24 +210,9 @@ func (db *FirestoreDB) CreateEvent(ctx context.Context, event *EventInfo) (id st
 			log.Printf("Creating event '%+v'", event)
 		}
 
-		var date time.Time
-
-		// let's ensure all dates are unique
-		year, month, day := event.Date.Date()
-		startOfTheDay := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
-		endOfTheDay := time.Date(year, month, day, 24, 0, 0, 0, time.UTC)
-		lastDocIter := db.Events.OrderBy("Date", firestore.Desc).Where("Date", ">=", startOfTheDay).Where("Date", "<", endOfTheDay).Limit(1).Documents(ctx)
-		defer lastDocIter.Stop()
-		lastDoc, err := lastDocIter.Next()
+		date, err := db.uniqueEventDate(ctx, event.Date)
 		if err != nil {
-			if err != iterator.Done {
-				return "", err
-			}
-			date = time.Date(year, month, day, 7, 0, rand.Intn(60), 0, time.UTC)
-		} else {
-			ei := EventInfo{}
-			lastDoc.DataTo(&ei)
-			date = ei.Date.Add(time.Duration(rand.Intn(60)) * time.Second)
+			return "", err
 		}
 
 		event.Date = &date
@@ -426,14 +518,20 @@ func (db *FirestoreDB) GetArchivedEvents(ctx context.Context, userId string, fro
 	return events, nil
 }
 
+// participants are added in one transaction per chunk, every participant takes up to 3 writes
+const participantsPerTransaction = 100
+
 func (db *FirestoreDB) RegisterParticipants(ctx context.Context, userIds []string, eventId string, eventInfo *EventInfo, status ParticipantStatusType) error {
 	if db.dev {
 		log.Println("Registering users " + strings.Join(userIds, ", ") + " for event " + eventId)
 	}
 
+	// event info is shared by concurrent callers, so it is not modified
+	eventInfo = copyEventInfo(eventInfo)
 	eventInfo.Status = status
 
 	errs := make([]error, len(userIds))
+	participants := make([]*ParticipantInfo, len(userIds))
 	var wg sync.WaitGroup
 
 	for i, uid := range userIds {
@@ -444,90 +542,252 @@ func (db *FirestoreDB) RegisterParticipants(ctx context.Context, userIds []strin
 
 			defer wg.Done()
 
-			// if I cared about conistency more, I would need
-			// some distributed lock here to keep status numbers
-			// consistent; now it is possilbe to send multiple
-			// requests to add (or remove) participant from event
-			// and counters would be screwed, but I dot think
-			// this is important
-			if st, err := db.GetParticipantStatus(ctx, userId, eventId); err != nil {
-
-				var userInfo *SquadUserInfo
-				userInfo, errs[i] = db.GetSquadMember(ctx, eventInfo.SquadId, userId)
-				if errs[i] != nil {
-					return
-				}
+			userInfo, err := db.GetSquadMember(ctx, eventInfo.SquadId, userId)
+			if err != nil {
+				errs[i] = err
+				return
+			}
 
-				participant := &ParticipantInfo{
-					UserInfo:  userInfo.UserInfo,
-					Replicant: userInfo.Replicant,
-					Tags:      userInfo.Tags,
-					Status:    status,
-				}
+			participants[i] = &ParticipantInfo{
+				UserInfo:  userInfo.UserInfo,
+				Replicant: userInfo.Replicant,
+				Tags:      userInfo.Tags,
+				Status:    status,
+			}
+		}(i, uid)
+	}
 
-				errs[i] = db.addParticipantRecordToEvent(ctx, eventId, userId, participant)
-				if errs[i] != nil {
-					return
-				}
+	wg.Wait()
 
-				if !userInfo.Replicant {
-					errs[i] = db.addEventRecordToParticipant(ctx, userId, eventId, eventInfo)
-					if errs[i] != nil {
-						return
-					}
+	for from := 0; from < len(userIds); from += participantsPerTransaction {
+		to := from + participantsPerTransaction
+		if to > len(userIds) {
+			to = len(userIds)
+		}
+		err := db.addParticipants(ctx, eventId, eventInfo, status, userIds[from:to], participants[from:to], errs[from:to])
+		if err != nil {
+			for i := from; i < to; i++ {
+				if errs[i] == nil {
+					errs[i] = err
 				}
-			} else {
-				errs[i] = fmt.Errorf("User %v is already registered for event %v with status %v", userId, eventId, st.String())
 			}
+		}
+	}
 
-		}(i, uid)
+	return registrationErrors(userIds, errs)
+}
+
+// addParticipants adds participant records to the event, event records to the
+// participants and updates event counters in one transaction, so that
+// concurrent registrations do not screw counters and capacity; users already
+// registered get an error in errs, nil participants are skipped
+func (db *FirestoreDB) addParticipants(ctx context.Context, eventId string, eventInfo *EventInfo, status ParticipantStatusType, userIds []string, participants []*ParticipantInfo, errs []error) error {
+
+	if db.dev {
+		log.Println("Adding participants " + strings.Join(userIds, ", ") + " to event " + eventId)
 	}
 
-	wg.Wait()
+	docEvent := db.Events.Doc(eventId)
+	docs := make([]*firestore.DocumentRef, 0, len(userIds))
+	idx := make([]int, 0, len(userIds))
+	for i, p := range participants {
+		if p != nil {
+			docs = append(docs, docEvent.Collection(MEMBERS).Doc(userIds[i]))
+			idx = append(idx, i)
+		}
+	}
+	if len(docs) == 0 {
+		return nil
+	}
 
-	var combinedError error
-	for _, err := range errs {
+	err := db.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		e, err := getEventCounters(tx, docEvent)
+		if err != nil {
+			return err
+		}
+		snapshots, err := tx.GetAll(docs)
 		if err != nil {
-			if combinedError == nil {
-				combinedError = fmt.Errorf("Failed to register one or more participants for event:")
+			return err
+		}
+
+		taken := e.taken()
+		counters := make(map[string]int)
+		for j, doc := range snapshots {
+			i := idx[j]
+			errs[i] = nil
+			if doc.Exists() {
+				st, _ := participantStatus(doc)
+				errs[i] = fmt.Errorf("User %v is already registered for event %v with status %v", userIds[i], eventId, st.String())
+				continue
+			}
+
+			p := participants[i]
+			p.Status = registrationStatus(e.Capacity, taken, status)
+			if p.Status.TakesPlace() {
+				taken++
+			}
+			counters[p.Status.String()]++
+
+			if err = tx.Set(doc.Ref, p); err != nil {
+				return err
+			}
+			err = tx.Update(doc.Ref, []firestore.Update{
+				{Path: "Keys", Value: p.Keys()},
+				{Path: "Timestamp", Value: firestore.ServerTimestamp},
+			})
+			if err != nil {
+				return err
+			}
+
+			if !p.Replicant {
+				ei := *eventInfo
+				ei.Status = p.Status
+				if err = tx.Set(db.Users.Doc(userIds[i]).Collection(USER_EVENTS).Doc(eventId), &ei); err != nil {
+					return err
+				}
 			}
-			combinedError = fmt.Errorf("%s\n\t%w", combinedError, err)
 		}
+
+		return updateEventCounters(tx, docEvent, counters)
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to add participants to event %v: %w", eventId, err)
 	}
 
-	return combinedError
+	return nil
 }
 
-func (db *FirestoreDB) addParticipantRecordToEvent(ctx context.Context, eventId string, userId string, userInfo *ParticipantInfo) error {
+// getEventCounters reads event document in the transaction, counters are not
+// cached so they are always read from the database
+func getEventCounters(tx *firestore.Transaction, docEvent *firestore.DocumentRef) (*EventCountersRecord, error) {
+	doc, err := tx.Get(docEvent)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get event %v: %w", docEvent.ID, err)
+	}
 
-	if db.dev {
-		log.Println("Adding participant " + userId + " to event " + eventId)
+	e := &EventCountersRecord{ID: doc.Ref.ID}
+	if err = doc.DataTo(e); err != nil {
+		return nil, fmt.Errorf("Failed to get event %v: %w", docEvent.ID, err)
 	}
 
-	batch := db.Client.Batch()
+	return e, nil
+}
+
+// updateEventCounters increments counters of the event, they are keyed by status names
+func updateEventCounters(tx *firestore.Transaction, docEvent *firestore.DocumentRef, counters map[string]int) error {
+	updates := make([]firestore.Update, 0, len(counters))
+	for path, inc := range counters {
+		if inc != 0 {
+			updates = append(updates, firestore.Update{Path: path, Value: firestore.Increment(inc)})
+		}
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	return tx.Update(docEvent, updates)
+}
 
+// setParticipantStatus changes status in participant record of the event and
+// in event record of the participant
+func (db *FirestoreDB) setParticipantStatus(tx *firestore.Transaction, eventId string, userId string, p *ParticipantInfo, status ParticipantStatusType) error {
+	err := tx.Update(db.Events.Doc(eventId).Collection(MEMBERS).Doc(userId), []firestore.Update{{Path: "Status", Value: status}})
+	if err != nil || p.Replicant {
+		return err
+	}
+
+	return tx.Update(db.Users.Doc(userId).Collection(USER_EVENTS).Doc(eventId), []firestore.Update{{Path: "Status", Value: status}})
+}
+
+func (db *FirestoreDB) PromoteWaitlisted(ctx context.Context, eventId string) ([]string, error) {
 	docEvent := db.Events.Doc(eventId)
-	docParticipant := docEvent.Collection(MEMBERS).Doc(userId)
-	batch.Set(docParticipant, userInfo)
 
-	path := userInfo.Status.String()
+	var promoted []string
+	err := db.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		promoted = make([]string, 0)
 
-	batch.Set(docParticipant, map[string]interface{}{
-		"Timestamp": firestore.ServerTimestamp,
-	}, firestore.MergeAll)
-	batch.Update(docParticipant, []firestore.Update{
-		{Path: "Keys", Value: userInfo.Keys()},
-	})
-	batch.Update(docEvent, []firestore.Update{
-		{Path: path, Value: firestore.Increment(1)},
-	})
+		e, err := getEventCounters(tx, docEvent)
+		if err != nil {
+			return err
+		}
 
-	_, err := batch.Commit(ctx)
+		query := docEvent.Collection(MEMBERS).Where("Status", "==", Waitlisted).OrderBy("Timestamp", firestore.Asc)
+		if e.Capacity > 0 {
+			if e.taken() >= e.Capacity {
+				return nil
+			}
+			query = query.Limit(e.Capacity - e.taken())
+		}
+
+		docs, err := tx.Documents(query).GetAll()
+		if err != nil {
+			return err
+		}
+
+		for _, doc := range docs {
+			p := &ParticipantInfo{}
+			if err = doc.DataTo(p); err != nil {
+				return err
+			}
+			if err = db.setParticipantStatus(tx, eventId, doc.Ref.ID, p, Going); err != nil {
+				return err
+			}
+			promoted = append(promoted, doc.Ref.ID)
+		}
+
+		return updateEventCounters(tx, docEvent, map[string]int{
+			Waitlisted.String(): -len(promoted),
+			Going.String():      len(promoted),
+		})
+	})
 	if err != nil {
-		return fmt.Errorf("Failed to add participant "+userId+" to event "+eventId+": %w", err)
+		return nil, fmt.Errorf("Failed to promote waitlisted participants of event %v: %w", eventId, err)
 	}
 
-	return nil
+	return promoted, nil
+}
+
+// MarkNoShows sets NoShow status to participants who are still Going, returns amount of them
+func (db *FirestoreDB) MarkNoShows(ctx context.Context, eventId string) (int, error) {
+	docEvent := db.Events.Doc(eventId)
+	query := docEvent.Collection(MEMBERS).Where("Status", "==", Going).Limit(participantsPerTransaction)
+
+	total := 0
+	for {
+		marked := 0
+		err := db.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+			marked = 0
+
+			docs, err := tx.Documents(query).GetAll()
+			if err != nil {
+				return err
+			}
+
+			for _, doc := range docs {
+				p := &ParticipantInfo{}
+				if err = doc.DataTo(p); err != nil {
+					return err
+				}
+				if err = db.setParticipantStatus(tx, eventId, doc.Ref.ID, p, NoShow); err != nil {
+					return err
+				}
+				marked++
+			}
+
+			return updateEventCounters(tx, docEvent, map[string]int{
+				Going.String():  -marked,
+				NoShow.String(): marked,
+			})
+		})
+		if err != nil {
+			return total, fmt.Errorf("Failed to mark no-shows of event %v: %w", eventId, err)
+		}
+
+		total += marked
+		if marked < participantsPerTransaction {
+			return total, nil
+		}
+	}
 }
 
 func (db *FirestoreDB) addEventRecordToParticipant(ctx context.Context, userId string, eventId string, eventInfo *EventInfo) error {
@@ -576,47 +836,67 @@ func (db *FirestoreDB) GetParticipants(ctx context.Context, eventId string, from
 	return participants, nil
 }
 
+func participantStatus(doc *firestore.DocumentSnapshot) (ParticipantStatusType, error) {
+	status, ok := doc.Data()["Status"]
+	if !ok {
+		return 0, fmt.Errorf("Failed to get event participant %v status", doc.Ref.ID)
+	}
+	return ParticipantStatusType(status.(int64)), nil
+}
+
 func (db *FirestoreDB) GetParticipantStatus(ctx context.Context, userId string, eventId string) (ParticipantStatusType, error) {
 	doc, err := db.Events.Doc(eventId).Collection(MEMBERS).Doc(userId).Get(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("Failed to get event "+eventId+": %w", err)
 	}
-	status, ok := doc.Data()["Status"]
-	if ok {
-		return ParticipantStatusType(status.(int64)), nil
-	} else {
-		return 0, fmt.Errorf("Failed to get event " + eventId + " status")
-	}
+	return participantStatus(doc)
 }
 
-func (db *FirestoreDB) SetParticipantStatus(ctx context.Context, userId string, eventId string, status ParticipantStatusType) error {
-	oldStatus, err := db.GetParticipantStatus(ctx, userId, eventId)
+// getParticipant reads participant record of the event in the transaction
+func (db *FirestoreDB) getParticipant(tx *firestore.Transaction, eventId string, userId string) (*ParticipantInfo, error) {
+	doc, err := tx.Get(db.Events.Doc(eventId).Collection(MEMBERS).Doc(userId))
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("Failed to get event %v participant %v: %w", eventId, userId, err)
 	}
 
-	batch := db.Client.Batch()
-
-	docParticipantEvent := db.Users.Doc(userId).Collection(USER_EVENTS).Doc(eventId)
-	batch.Update(docParticipantEvent, []firestore.Update{{Path: "Status", Value: status}})
+	p := &ParticipantInfo{}
+	if err = doc.DataTo(p); err != nil {
+		return nil, fmt.Errorf("Failed to get event %v participant %v: %w", eventId, userId, err)
+	}
 
-	docEventParticipant := db.Events.Doc(eventId).Collection(MEMBERS).Doc(userId)
-	batch.Update(docEventParticipant, []firestore.Update{{Path: "Status", Value: status}})
+	return p, nil
+}
 
+// SetParticipantStatus changes status of the participant, participant taking
+// a place of the full event is waitlisted instead; returns old and new status
+func (db *FirestoreDB) SetParticipantStatus(ctx context.Context, userId string, eventId string, status ParticipantStatusType) (oldStatus ParticipantStatusType, newStatus ParticipantStatusType, err error) {
 	docEvent := db.Events.Doc(eventId)
-	batch.Update(docEvent, []firestore.Update{
-		{Path: oldStatus.String(), Value: firestore.Increment(-1)},
-	})
-	batch.Update(docEvent, []firestore.Update{
-		{Path: status.String(), Value: firestore.Increment(1)},
-	})
+	err = db.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		e, err := getEventCounters(tx, docEvent)
+		if err != nil {
+			return err
+		}
+		p, err := db.getParticipant(tx, eventId, userId)
+		if err != nil {
+			return err
+		}
+
+		oldStatus = p.Status
+		newStatus = changedStatus(e.Capacity, e.taken(), p.Status, status)
+		if err = db.setParticipantStatus(tx, eventId, userId, p, newStatus); err != nil {
+			return err
+		}
 
-	_, err = batch.Commit(ctx)
+		counters := make(map[string]int)
+		counters[oldStatus.String()]--
+		counters[newStatus.String()]++
+		return updateEventCounters(tx, docEvent, counters)
+	})
 	if err != nil {
-		return fmt.Errorf("Failed to change user "+userId+" status: %w", err)
+		return 0, 0, fmt.Errorf("Failed to change user "+userId+" status: %w", err)
 	}
 
-	return nil
+	return oldStatus, newStatus, nil
 }
 
 func (db *FirestoreDB) DeleteParticipant(ctx context.Context, userId string, eventId string) error {
@@ -624,51 +904,94 @@ func (db *FirestoreDB) DeleteParticipant(ctx context.Context, userId string, eve
 		log.Println("Removing user " + userId + " from event " + eventId)
 	}
 
-	err := db.deleteEventRecordFromParticipant(ctx, userId, eventId)
-	if err != nil {
-		return err
-	}
+	docEvent := db.Events.Doc(eventId)
+	err := db.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		p, err := db.getParticipant(tx, eventId, userId)
+		if err != nil {
+			return err
+		}
+
+		if err = tx.Delete(docEvent.Collection(MEMBERS).Doc(userId)); err != nil {
+			return err
+		}
+		if err = tx.Delete(db.Users.Doc(userId).Collection(USER_EVENTS).Doc(eventId)); err != nil {
+			return err
+		}
 
-	err = db.deleteParticipantRecordFromEvent(ctx, eventId, userId)
+		return updateEventCounters(tx, docEvent, map[string]int{p.Status.String(): -1})
+	})
 	if err != nil {
-		return err
+		return fmt.Errorf("Failed to delete user %v from event %v: %w", userId, eventId, err)
 	}
+
 	return nil
 }
 
-func (db *FirestoreDB) deleteEventRecordFromParticipant(ctx context.Context, userId string, eventId string) error {
+// statuses having counters in the event
+var eventCounterStatuses = []ParticipantStatusType{NotGoing, Applied, Going, Attended, NoShow, Waitlisted}
 
-	doc := db.Users.Doc(userId).Collection(USER_EVENTS).Doc(eventId)
+// RecountEventCounters recalculates counters of every event from its
+// participants, returns amount of events which had wrong counters
+func (db *FirestoreDB) RecountEventCounters(ctx context.Context) (int, error) {
+	fixed := 0
 
-	_, err := doc.Delete(ctx)
-	if err != nil {
-		return fmt.Errorf("Failed to remove event from user "+userId+": %w", err)
-	}
+	iter := db.Events.Select().Documents(ctx)
+	defer iter.Stop()
+	for {
+		docEvent, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return fixed, fmt.Errorf("Failed to get events: %w", err)
+		}
 
-	return nil
-}
+		wrong := false
+		err = db.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+			wrong = false
 
-func (db *FirestoreDB) deleteParticipantRecordFromEvent(ctx context.Context, eventId string, userId string) error {
+			doc, err := tx.Get(docEvent.Ref)
+			if err != nil {
+				return err
+			}
+			participants, err := tx.Documents(docEvent.Ref.Collection(MEMBERS)).GetAll()
+			if err != nil {
+				return err
+			}
 
-	status, err := db.GetParticipantStatus(ctx, userId, eventId)
-	if err != nil {
-		return err
-	}
+			counters := make(map[ParticipantStatusType]int64)
+			for _, p := range participants {
+				status, err := participantStatus(p)
+				if err != nil {
+					return err
+				}
+				counters[status]++
+			}
 
-	batch := db.Client.Batch()
-	docEvent := db.Events.Doc(eventId)
-	docParticipant := docEvent.Collection(MEMBERS).Doc(userId)
-	batch.Delete(docParticipant)
-	batch.Update(docEvent, []firestore.Update{
-		{Path: status.String(), Value: firestore.Increment(-1)},
-	})
+			updates := make([]firestore.Update, 0)
+			for _, status := range eventCounterStatuses {
+				value, _ := doc.Data()[status.String()].(int64)
+				if value != counters[status] {
+					updates = append(updates, firestore.Update{Path: status.String(), Value: counters[status]})
+				}
+			}
+			if len(updates) == 0 {
+				return nil
+			}
 
-	_, err = batch.Commit(ctx)
-	if err != nil {
-		return fmt.Errorf("Failed to delete user %v from event %v: %w", userId, eventId, err)
+			wrong = true
+			return tx.Update(docEvent.Ref, updates)
+		})
+		if err != nil {
+			return fixed, fmt.Errorf("Failed to recount event %v counters: %w", docEvent.Ref.ID, err)
+		}
+		if wrong {
+			log.Printf("Fixed event %v counters", docEvent.Ref.ID)
+			fixed++
+		}
 	}
 
-	return nil
+	return fixed, nil
 }
 
 func (db *FirestoreDB) DeleteEvent(ctx context.Context, eventId string) error {
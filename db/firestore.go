@@ -24,8 +24,16 @@ type FirestoreDB struct {
 	Squads            *firestore.CollectionRef
 	Users             *firestore.CollectionRef
 	Events            *firestore.CollectionRef
+	EventSeries       *firestore.CollectionRef
 	RequestQueues     *firestore.CollectionRef
 	Requests          *firestore.CollectionRef
+	Credentials       *firestore.CollectionRef
+	Jobs              *firestore.CollectionRef
+	Notifications     *firestore.CollectionRef
+	NotificationPrefs *firestore.CollectionRef
+	DigestItems       *firestore.CollectionRef
+	PushTokens        *firestore.CollectionRef
+	WebhookDeliveries *firestore.CollectionRef
 	updater           *AsyncUpdater
 	userDataCache     *cache.Cache
 	userSquadsCache   *cache.Cache //userId:map[squadId]memberStatus
@@ -81,8 +89,16 @@ func NewFirestoreDB(fireapp *firebase.App, dev bool) (*FirestoreDB, error) {
 		Squads:            dbClient.Collection(testPrefix + "squads"),
 		Users:             dbClient.Collection(testPrefix + "squads").Doc(ALL_USERS_SQUAD).Collection("members"),
 		Events:            dbClient.Collection(testPrefix + "events"),
+		EventSeries:       dbClient.Collection(testPrefix + "event_series"),
 		RequestQueues:     dbClient.Collection(testPrefix + "queues"),
 		Requests:          dbClient.Collection(testPrefix + "requests"),
+		Credentials:       dbClient.Collection(testPrefix + "credentials"),
+		Jobs:              dbClient.Collection(testPrefix + "jobs"),
+		Notifications:     dbClient.Collection(testPrefix + "notifications"),
+		NotificationPrefs: dbClient.Collection(testPrefix + "notification_prefs"),
+		DigestItems:       dbClient.Collection(testPrefix + "digest_items"),
+		PushTokens:        dbClient.Collection(testPrefix + "push_tokens"),
+		WebhookDeliveries: dbClient.Collection(testPrefix + "webhook_deliveries"),
 		updater:           initAsyncUpdater(),
 		userDataCache:     uc,
 		userSquadsCache:   us,
@@ -187,7 +203,7 @@ func (db *FirestoreDB) GetFilteredQuery(collection *firestore.CollectionRef, fro
 		query = query.StartAfter(from)
 	}
 
-	query = db.AddFilterWhere(query, filter, statusFromString)
+	query = db.AddFilterWhere(query, filter, statusFromStringFunc)
 	query = query.Limit(numRecords)
 
 	return &query
@@ -0,0 +1,126 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// JobStatus is state of the background job shared by all instances of the
+// server. Instance running the job holds the lock until the job finishes or
+// the lock expires.
+type JobStatus struct {
+	Name        string     `json:"name"`
+	LockedBy    string     `json:"lockedBy,omitempty"`
+	LockedUntil *time.Time `json:"lockedUntil,omitempty"`
+	LastStart   *time.Time `json:"lastStart,omitempty"`
+	LastFinish  *time.Time `json:"lastFinish,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+}
+
+// canStart checks that job is not locked by another instance and has not been started since due time
+func (j *JobStatus) canStart(due time.Time, now time.Time) bool {
+	if j.LockedUntil != nil && j.LockedUntil.After(now) {
+		return false
+	}
+	return j.LastStart == nil || j.LastStart.Before(due)
+}
+
+func (db *FirestoreDB) GetJobs(ctx context.Context) ([]*JobStatus, error) {
+	jobs := make([]*JobStatus, 0)
+
+	iter := db.Jobs.Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get jobs: %w", err)
+		}
+
+		j := &JobStatus{}
+		if err = doc.DataTo(j); err != nil {
+			return nil, fmt.Errorf("Failed to get jobs: %w", err)
+		}
+		j.Name = doc.Ref.ID
+		jobs = append(jobs, j)
+	}
+
+	return jobs, nil
+}
+
+// StartJob locks the job until lockUntil and marks it started at now, returns
+// false if the job is locked or has been already started after due time
+func (db *FirestoreDB) StartJob(ctx context.Context, name string, instance string, due time.Time, now time.Time, lockUntil time.Time) (started bool, err error) {
+	doc := db.Jobs.Doc(name)
+
+	err = db.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		started = false
+
+		j := &JobStatus{}
+		snapshot, err := tx.Get(doc)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			if err = snapshot.DataTo(j); err != nil {
+				return err
+			}
+		}
+
+		if !j.canStart(due, now) {
+			return nil
+		}
+
+		j.Name = name
+		j.LockedBy = instance
+		j.LockedUntil = &lockUntil
+		j.LastStart = &now
+		started = true
+		return tx.Set(doc, j)
+	})
+	if err != nil {
+		return false, fmt.Errorf("Failed to start job %v: %w", name, err)
+	}
+
+	return started, nil
+}
+
+// FinishJob records result of the job and releases the lock if it is still held by the instance
+func (db *FirestoreDB) FinishJob(ctx context.Context, name string, instance string, finish time.Time, jobErr string) error {
+	doc := db.Jobs.Doc(name)
+
+	err := db.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		snapshot, err := tx.Get(doc)
+		if err != nil {
+			return err
+		}
+
+		j := &JobStatus{}
+		if err = snapshot.DataTo(j); err != nil {
+			return err
+		}
+		if j.LockedBy != instance {
+			return nil
+		}
+
+		return tx.Update(doc, []firestore.Update{
+			{Path: "LockedBy", Value: ""},
+			{Path: "LockedUntil", Value: nil},
+			{Path: "LastFinish", Value: finish},
+			{Path: "LastError", Value: jobErr},
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to finish job %v: %w", name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,353 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// MemoryDB implements Store in process memory. It follows the same
+// denormalization rules as FirestoreDB: every non-replicant member keeps a
+// copy of the squads they belong to (member_squads) and of the events they
+// participate in (participant_events), squads, events and queues keep
+// counters. Everything is protected by a single lock.
+type MemoryDB struct {
+	mx                sync.RWMutex
+	dev               bool
+	squads            map[string]*memSquad
+	events            map[string]*memEvent
+	series            map[string]*EventSeries
+	queues            map[string]*memQueue
+	requests          map[string]*RequestDetails
+	requestHistory    map[string][]*RequestHistoryEntry
+	requestComments   map[string][]*RequestCommentRecord
+	memberSquads      map[string]map[string]*MemberSquadInfo // userId:squadId:info
+	participantEvents map[string]map[string]*EventInfo       // userId:eventId:info
+	sentReminders     map[string]bool                        // eventId/time
+	userTags          map[string][]string
+	credentials       map[string]*Credentials // email:credentials
+	jobs              map[string]*JobStatus
+	notifications     map[string][]*NotificationRecord        // userId:notifications in order of creation
+	emailDigests      map[string]string                       // userId:digest
+	notificationPrefs map[string]map[string]*NotificationPref // userId:squadId/kind:pref
+	digestItems       map[string]*DigestItem
+	pushTokens        map[string]*PushToken // token:device
+	webhookDeliveries map[string]*WebhookDelivery
+}
+
+type memSquad struct {
+	SquadInfo
+	timestamp time.Time
+	members   map[string]*memMember
+	tags      map[string]map[string]int64
+	notes     map[string]*Note
+	venues    map[string]*EventLocation
+	webhooks  map[string]*Webhook
+
+	eventTemplates map[string]*EventTemplate
+}
+
+type memMember struct {
+	SquadUserInfo
+	keys []string
+}
+
+func NewMemoryDB(dev bool) *MemoryDB {
+	db := &MemoryDB{
+		dev:               dev,
+		squads:            make(map[string]*memSquad),
+		events:            make(map[string]*memEvent),
+		series:            make(map[string]*EventSeries),
+		queues:            make(map[string]*memQueue),
+		requests:          make(map[string]*RequestDetails),
+		requestHistory:    make(map[string][]*RequestHistoryEntry),
+		requestComments:   make(map[string][]*RequestCommentRecord),
+		memberSquads:      make(map[string]map[string]*MemberSquadInfo),
+		participantEvents: make(map[string]map[string]*EventInfo),
+		sentReminders:     make(map[string]bool),
+		userTags:          make(map[string][]string),
+		credentials:       make(map[string]*Credentials),
+		jobs:              make(map[string]*JobStatus),
+		notifications:     make(map[string][]*NotificationRecord),
+		emailDigests:      make(map[string]string),
+		notificationPrefs: make(map[string]map[string]*NotificationPref),
+		digestItems:       make(map[string]*DigestItem),
+		pushTokens:        make(map[string]*PushToken),
+		webhookDeliveries: make(map[string]*WebhookDelivery),
+	}
+
+	db.squads[ALL_USERS_SQUAD] = newMemSquad("")
+
+	return db
+}
+
+func newMemSquad(ownerId string) *memSquad {
+	return &memSquad{
+		SquadInfo: SquadInfo{Owner: ownerId},
+		timestamp: time.Now().UTC(),
+		members:   make(map[string]*memMember),
+		tags:      make(map[string]map[string]int64),
+		notes:     make(map[string]*Note),
+		venues:    make(map[string]*EventLocation),
+		webhooks:  make(map[string]*Webhook),
+
+		eventTemplates: make(map[string]*EventTemplate),
+	}
+}
+
+const docIdAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// generates 20 characters long id, the same way Firestore does for new documents
+func newDocId() string {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatalf("Failed to generate document id: %v", err)
+	}
+	for i := range b {
+		b[i] = docIdAlphabet[int(b[i])%len(docIdAlphabet)]
+	}
+	return string(b)
+}
+
+func notFound(format string, a ...interface{}) error {
+	return status.Errorf(codes.NotFound, format, a...)
+}
+
+// same semantics as FirestoreDB.AddFilterWhere
+func matchesFilter(keys []string, tags []string, st int, filter *map[string]string, statusFromStringFunc func(string) int) bool {
+	if filter == nil {
+		return true
+	}
+	f := *filter
+
+	if f["Keys"] != "" {
+		found := false
+		for _, k := range strings.Fields(strings.ToLower(f["Keys"])) {
+			if containsString(keys, k) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if f["Tag"] != "" && !containsString(tags, f["Tag"]) {
+		return false
+	}
+
+	if f["Status"] != "" && statusFromStringFunc != nil {
+		if s := statusFromStringFunc(f["Status"]); s != -1 && s != st {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func copyStrings(list []string) []string {
+	if list == nil {
+		return nil
+	}
+	return append([]string{}, list...)
+}
+
+func copyInts(list []int) []int {
+	if list == nil {
+		return nil
+	}
+	return append([]int{}, list...)
+}
+
+func (db *MemoryDB) getUser(userId string) (*memMember, error) {
+	user, ok := db.squads[ALL_USERS_SQUAD].members[userId]
+	if !ok {
+		return nil, notFound("Failed to get user %v: not found", userId)
+	}
+	return user, nil
+}
+
+func (db *MemoryDB) GetUserInfo(ctx context.Context, userId string) (*UserInfo, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	user, err := db.getUser(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	ui := user.UserInfo
+	return &ui, nil
+}
+
+func (db *MemoryDB) GetUserData(ctx context.Context, userId string) (*UserData, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	user, err := db.getUser(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserData{
+		UID:      userId,
+		UserInfo: user.UserInfo,
+		Status:   user.Status,
+		UserTags: copyStrings(db.userTags[userId]),
+		Admin:    user.Status == Admin,
+	}, nil
+}
+
+func (db *MemoryDB) GetUserByName(ctx context.Context, userName string) ([]string, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	users := make([]string, 0)
+	for id, user := range db.squads[ALL_USERS_SQUAD].members {
+		if user.DisplayName == userName {
+			users = append(users, id)
+		}
+	}
+	sort.Strings(users)
+
+	return users, nil
+}
+
+func (db *MemoryDB) CreateUser(ctx context.Context, userId string, userInfo *UserInfo, status MemberStatusType) error {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	sui := &SquadUserInfo{
+		UserInfo: *userInfo,
+		Status:   status,
+	}
+	err := db.addMemberRecordToSquad(ALL_USERS_SQUAD, userId, sui)
+	if err != nil {
+		return fmt.Errorf("Failed to add user "+userId+": %w", err)
+	}
+
+	return nil
+}
+
+func setUserInfoField(ui *UserInfo, field string, val interface{}) error {
+	s, ok := val.(string)
+	if !ok {
+		return fmt.Errorf("Unexpected value %v for field %v", val, field)
+	}
+
+	switch field {
+	case "DisplayName":
+		ui.DisplayName = s
+	case "Email":
+		ui.Email = s
+	case "PhoneNumber":
+		ui.PhoneNumber = s
+	default:
+		return fmt.Errorf("Field %v can not be updated", field)
+	}
+
+	return nil
+}
+
+func (db *MemoryDB) UpdateUser(ctx context.Context, userId string, field string, val interface{}) error {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	user, err := db.getUser(userId)
+	if err != nil {
+		return fmt.Errorf("Failed to update user "+userId+": %w", err)
+	}
+
+	err = setUserInfoField(&user.UserInfo, field, val)
+	if err != nil {
+		return fmt.Errorf("Failed to update user "+userId+": %w", err)
+	}
+	user.keys = user.Keys()
+
+	// propagate changed info to squads user is member of
+	for squadId := range db.memberSquads[userId] {
+		if squad, ok := db.squads[squadId]; ok {
+			if member, ok := squad.members[userId]; ok {
+				member.UserInfo = user.UserInfo
+				member.keys = user.keys
+			}
+		}
+	}
+
+	return nil
+}
+
+func (db *MemoryDB) UpdateUserInfoFromAuth(ctx context.Context, userId string, userInfo *UserInfo) error {
+	userData, err := db.GetUserData(ctx, userId)
+	if err != nil {
+		log.Println("Failed to get user " + userId + " from DB, adding new record to users collection")
+
+		err = db.CreateUser(ctx, userId, userInfo, PendingApprove)
+		if err != nil {
+			return fmt.Errorf("Failed to add user to database: %w", err)
+		}
+	} else {
+		if len(userInfo.Email) > 0 && userData.Email != userInfo.Email {
+			db.UpdateUser(ctx, userId, "Email", userInfo.Email)
+		}
+		if len(userInfo.PhoneNumber) > 0 && userData.PhoneNumber != userInfo.PhoneNumber {
+			db.UpdateUser(ctx, userId, "PhoneNumber", userInfo.PhoneNumber)
+		}
+	}
+
+	return nil
+}
+
+func (db *MemoryDB) GetSquadsCount(ctx context.Context, userId string) (interface{}, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	squadsCount := make([]int, len(MemberStatusTypes))
+	for _, v := range db.memberSquads[userId] {
+		squadsCount[v.Status]++
+	}
+
+	return squadsCount, nil
+}
+
+func (db *MemoryDB) GetSquadsWithPendingRequests(ctx context.Context, userId string, admin bool) (interface{}, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	squadsWithRequests := make([]*squadCount, 0)
+	for squadId, v := range db.memberSquads[userId] {
+		if (v.Status == Admin || v.Status == Owner) && v.PendingApproveCount != 0 {
+			squadsWithRequests = append(squadsWithRequests, &squadCount{squadId, int64(v.PendingApproveCount)})
+		}
+	}
+	sort.SliceStable(squadsWithRequests, func(i, j int) bool {
+		return squadsWithRequests[i].Count > squadsWithRequests[j].Count
+	})
+
+	if admin {
+		if pc := db.squads[ALL_USERS_SQUAD].PendingApproveCount; pc > 0 {
+			sc := &squadCount{ALL_USERS_SQUAD, int64(pc)}
+			squadsWithRequests = append([]*squadCount{sc}, squadsWithRequests...)
+		}
+	}
+
+	return squadsWithRequests, nil
+}
@@ -0,0 +1,38 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+func (db *MemoryDB) GetSquadAttendance(ctx context.Context, squadId string, from time.Time, to time.Time) ([]*AttendanceRecord, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	records := make([]*AttendanceRecord, 0)
+	for eventId, e := range db.events {
+		if e.SquadId != squadId || !e.Archived || e.Date.Before(from) || !e.Date.Before(to) {
+			continue
+		}
+		for userId, p := range e.participants {
+			records = append(records, &AttendanceRecord{
+				EventId:     eventId,
+				Date:        e.Date.UTC(),
+				UserId:      userId,
+				DisplayName: p.DisplayName,
+				Replicant:   p.Replicant,
+				Tags:        copyStrings(p.Tags),
+				Status:      p.Status,
+			})
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].Date.Equal(records[j].Date) {
+			return records[i].Date.Before(records[j].Date)
+		}
+		return records[i].UserId < records[j].UserId
+	})
+
+	return records, nil
+}
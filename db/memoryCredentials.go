@@ -0,0 +1,67 @@
+package db
+
+import (
+	"context"
+	"log"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (db *MemoryDB) CreateCredentials(ctx context.Context, c *Credentials) error {
+	if db.dev {
+		log.Printf("Creating credentials for user %v (%v)", c.UserId, c.Email)
+	}
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	if _, ok := db.credentials[c.Email]; ok {
+		return status.Errorf(codes.AlreadyExists, "User with email %v already exists", c.Email)
+	}
+
+	cc := *c
+	db.credentials[c.Email] = &cc
+
+	return nil
+}
+
+func (db *MemoryDB) GetCredentials(ctx context.Context, email string) (*Credentials, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	c, ok := db.credentials[email]
+	if !ok {
+		return nil, notFound("Credentials for %v not found", email)
+	}
+
+	cc := *c
+	return &cc, nil
+}
+
+func (db *MemoryDB) GetCredentialsByUserId(ctx context.Context, userId string) (*Credentials, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	for _, c := range db.credentials {
+		if c.UserId == userId {
+			cc := *c
+			return &cc, nil
+		}
+	}
+
+	return nil, notFound("Credentials for user %v not found", userId)
+}
+
+func (db *MemoryDB) SetEmailVerified(ctx context.Context, email string) error {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	c, ok := db.credentials[email]
+	if !ok {
+		return notFound("Credentials for %v not found", email)
+	}
+	c.EmailVerified = true
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"context"
+)
+
+func copyDigestItem(item *DigestItem) *DigestItem {
+	c := *item
+	if item.Link != nil {
+		link := *item.Link
+		c.Link = &link
+	}
+	return &c
+}
+
+func (db *MemoryDB) GetEmailDigest(ctx context.Context, userId string) (string, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	if digest := db.emailDigests[userId]; digest != "" {
+		return digest, nil
+	}
+	return DigestImmediate, nil
+}
+
+func (db *MemoryDB) SetEmailDigest(ctx context.Context, userId string, digest string) error {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	db.emailDigests[userId] = digest
+
+	return nil
+}
+
+func (db *MemoryDB) CreateDigestItem(ctx context.Context, item *DigestItem) error {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	db.digestItems[newDocId()] = copyDigestItem(item)
+
+	return nil
+}
+
+func (db *MemoryDB) GetDigestItems(ctx context.Context, digest string) ([]*DigestItemRecord, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	items := make([]*DigestItemRecord, 0)
+	for id, item := range db.digestItems {
+		if item.Digest == digest {
+			items = append(items, &DigestItemRecord{ID: id, DigestItem: *copyDigestItem(item)})
+		}
+	}
+	sortDigestItems(items)
+
+	return items, nil
+}
+
+func (db *MemoryDB) DeleteDigestItems(ctx context.Context, ids []string) error {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	for _, id := range ids {
+		delete(db.digestItems, id)
+	}
+
+	return nil
+}
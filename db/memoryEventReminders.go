@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+func (db *MemoryDB) GetUpcomingEvents(ctx context.Context, from time.Time, to time.Time) ([]*EventRecord, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	events := make([]*EventRecord, 0)
+	for id, e := range db.events {
+		if !e.Archived && !e.Date.Before(from) && e.Date.Before(to) {
+			events = append(events, &EventRecord{ID: id, EventInfo: *copyEventInfo(&e.EventInfo)})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Date.Before(*events[j].Date) })
+
+	return events, nil
+}
+
+func (db *MemoryDB) GetParticipantIds(ctx context.Context, eventId string, status ParticipantStatusType) ([]string, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	event, err := db.getEvent(eventId)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0)
+	for id, p := range event.participants {
+		if p.Status == status && !p.Replicant {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	return ids, nil
+}
+
+func (db *MemoryDB) MarkEventReminderSent(ctx context.Context, eventId string, remindAt time.Time) (bool, error) {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	key := eventId + "/" + reminderKey(remindAt)
+	if db.sentReminders[key] {
+		return false, nil
+	}
+	db.sentReminders[key] = true
+
+	return true, nil
+}
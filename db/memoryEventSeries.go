@@ -0,0 +1,139 @@
+package db
+
+import (
+	"context"
+	"log"
+	"sort"
+)
+
+func copyEventSeries(s *EventSeries) *EventSeries {
+	c := *s
+	if s.Start != nil {
+		start := *s.Start
+		c.Start = &start
+	}
+	if s.GeneratedUntil != nil {
+		until := *s.GeneratedUntil
+		c.GeneratedUntil = &until
+	}
+	c.ExDates = copyStrings(s.ExDates)
+	c.Reminders = copyInts(s.Reminders)
+	c.Location = s.Location.Copy()
+	if s.Participants != nil {
+		c.Participants = make(map[string]ParticipantStatusType, len(s.Participants))
+		for k, v := range s.Participants {
+			c.Participants[k] = v
+		}
+	}
+	return &c
+}
+
+func (db *MemoryDB) CreateEventSeries(ctx context.Context, series *EventSeries) (string, error) {
+	if db.dev {
+		log.Printf("Creating event series '%+v'", series)
+	}
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	id := newDocId()
+	db.series[id] = copyEventSeries(series)
+
+	return id, nil
+}
+
+func (db *MemoryDB) GetEventSeries(ctx context.Context, seriesId string) (*EventSeries, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	s, ok := db.series[seriesId]
+	if !ok {
+		return nil, notFound("Failed to get event series %v: not found", seriesId)
+	}
+
+	return copyEventSeries(s), nil
+}
+
+func (db *MemoryDB) UpdateEventSeries(ctx context.Context, seriesId string, series *EventSeries) error {
+	if db.dev {
+		log.Printf("Updating event series %v: %+v", seriesId, series)
+	}
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	db.series[seriesId] = copyEventSeries(series)
+
+	return nil
+}
+
+func (db *MemoryDB) GetActiveEventSeries(ctx context.Context) ([]*EventSeriesRecord, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	series := make([]*EventSeriesRecord, 0)
+	for id, s := range db.series {
+		if !s.Cancelled {
+			series = append(series, &EventSeriesRecord{ID: id, EventSeries: *copyEventSeries(s)})
+		}
+	}
+
+	return series, nil
+}
+
+func (db *MemoryDB) GetSeriesEvents(ctx context.Context, seriesId string) ([]*EventRecord, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	events := make([]*EventRecord, 0)
+	for id, e := range db.events {
+		if e.SeriesId == seriesId && !e.Archived {
+			events = append(events, &EventRecord{ID: id, EventInfo: *copyEventInfo(&e.EventInfo)})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Date.Before(*events[j].Date) })
+
+	return events, nil
+}
+
+func (db *MemoryDB) UpdateEvent(ctx context.Context, eventId string, event *EventInfo) error {
+	if db.dev {
+		log.Printf("Updating event %v: %+v", eventId, event)
+	}
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	e, err := db.getEvent(eventId)
+	if err != nil {
+		return err
+	}
+
+	if event.Date == nil || startOfDay(*event.Date) == startOfDay(*e.Date) {
+		event.Date = e.Date
+	} else {
+		date := db.uniqueEventDate(event.Date)
+		event.Date = &date
+	}
+
+	update := func(ei *EventInfo) {
+		date := *event.Date
+		ei.Date = &date
+		ei.TimeFrom = event.TimeFrom
+		ei.TimeTo = event.TimeTo
+		ei.Text = event.Text
+		ei.SeriesOverride = event.SeriesOverride
+		ei.Capacity = event.Capacity
+		ei.Reminders = copyInts(event.Reminders)
+		ei.Location = event.Location.Copy()
+	}
+
+	update(&e.EventInfo)
+	for userId := range db.participantEvents {
+		if ei, ok := db.participantEvents[userId][eventId]; ok {
+			update(ei)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+)
+
+func copyEventTemplate(t *EventTemplate) *EventTemplate {
+	c := *t
+	c.Reminders = copyInts(t.Reminders)
+	c.Location = t.Location.Copy()
+	c.Tags = copyStrings(t.Tags)
+	return &c
+}
+
+func (db *MemoryDB) CreateEventTemplate(ctx context.Context, squadId string, template *EventTemplate) (string, error) {
+	if template.Name == "" {
+		return "", fmt.Errorf("Failed to create event template, name is not provided: %+v", template)
+	}
+
+	log.Printf("Creating event template '%v' in squad '%v'", template.Name, squadId)
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	squad, err := db.getSquad(squadId)
+	if err != nil {
+		return "", fmt.Errorf("Failed to create event template in squad %v: %w", squadId, err)
+	}
+
+	id := newDocId()
+	squad.eventTemplates[id] = copyEventTemplate(template)
+
+	return id, nil
+}
+
+func (db *MemoryDB) GetEventTemplates(ctx context.Context, squadId string) ([]*EventTemplateRecord, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	squad, err := db.getSquad(squadId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get squad %v event templates: %w", squadId, err)
+	}
+
+	templates := make([]*EventTemplateRecord, 0, len(squad.eventTemplates))
+	for id, t := range squad.eventTemplates {
+		templates = append(templates, &EventTemplateRecord{ID: id, EventTemplate: *copyEventTemplate(t)})
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+
+	return templates, nil
+}
+
+func (db *MemoryDB) GetEventTemplate(ctx context.Context, squadId string, templateId string) (*EventTemplate, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	squad, err := db.getSquad(squadId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get event template %v: %w", templateId, err)
+	}
+	t, ok := squad.eventTemplates[templateId]
+	if !ok {
+		return nil, notFound("Failed to get event template %v: not found", templateId)
+	}
+
+	return copyEventTemplate(t), nil
+}
+
+func (db *MemoryDB) UpdateEventTemplate(ctx context.Context, squadId string, templateId string, template *EventTemplate) error {
+	if template.Name == "" {
+		return fmt.Errorf("Failed to update event template, name is not provided: %+v", template)
+	}
+
+	log.Printf("Updating event template '%v' in squad '%v'", templateId, squadId)
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	squad, err := db.getSquad(squadId)
+	if err != nil {
+		return fmt.Errorf("Failed to update event template %v: %w", templateId, err)
+	}
+	if _, ok := squad.eventTemplates[templateId]; !ok {
+		return notFound("Failed to update event template %v: not found", templateId)
+	}
+	squad.eventTemplates[templateId] = copyEventTemplate(template)
+
+	return nil
+}
+
+func (db *MemoryDB) DeleteEventTemplate(ctx context.Context, squadId string, templateId string) error {
+	log.Println("Deleting event template " + templateId + " from squad " + squadId)
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	squad, err := db.getSquad(squadId)
+	if err != nil {
+		return fmt.Errorf("Error while deleting event template "+templateId+" from squad "+squadId+": %w", err)
+	}
+	delete(squad.eventTemplates, templateId)
+
+	return nil
+}
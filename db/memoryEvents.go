@@ -0,0 +1,605 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+type memEvent struct {
+	EventInfo
+	counters     map[string]int
+	participants map[string]*memParticipant
+}
+
+type memParticipant struct {
+	ParticipantInfo
+	keys []string
+}
+
+func copyEventInfo(e *EventInfo) *EventInfo {
+	c := *e
+	if e.Date != nil {
+		date := *e.Date
+		c.Date = &date
+	}
+	c.Reminders = copyInts(e.Reminders)
+	c.Location = e.Location.Copy()
+	return &c
+}
+
+func (e *memEvent) countersRecord(id string) *EventCountersRecord {
+	return &EventCountersRecord{
+		ID:         id,
+		EventInfo:  *copyEventInfo(&e.EventInfo),
+		Going:      e.counters[Going.String()],
+		Applied:    e.counters[Applied.String()],
+		Attended:   e.counters[Attended.String()],
+		NoShow:     e.counters[NoShow.String()],
+		Waitlisted: e.counters[Waitlisted.String()],
+	}
+}
+
+func (e *memEvent) taken() int {
+	return e.counters[Applied.String()] + e.counters[Going.String()] + e.counters[Attended.String()]
+}
+
+func (db *MemoryDB) getEvent(eventId string) (*memEvent, error) {
+	event, ok := db.events[eventId]
+	if !ok {
+		return nil, notFound("Failed to get event %v: not found", eventId)
+	}
+	return event, nil
+}
+
+func (db *MemoryDB) getParticipant(eventId string, userId string) (*memParticipant, error) {
+	event, err := db.getEvent(eventId)
+	if err != nil {
+		return nil, err
+	}
+	p, ok := event.participants[userId]
+	if !ok {
+		return nil, notFound("Failed to get event %v participant %v: not found", eventId, userId)
+	}
+	return p, nil
+}
+
+func (db *MemoryDB) addEventRecordToParticipant(userId string, eventId string, eventInfo *EventInfo) {
+	if db.participantEvents[userId] == nil {
+		db.participantEvents[userId] = make(map[string]*EventInfo)
+	}
+	db.participantEvents[userId][eventId] = copyEventInfo(eventInfo)
+}
+
+// events dates are used to page through them, so all of them should be unique
+func (db *MemoryDB) uniqueEventDate(eventDate *time.Time) time.Time {
+	year, month, day := eventDate.Date()
+	startOfTheDay := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	endOfTheDay := time.Date(year, month, day, 24, 0, 0, 0, time.UTC)
+	var last *time.Time
+	for _, e := range db.events {
+		if e.Date.Before(startOfTheDay) || !e.Date.Before(endOfTheDay) {
+			continue
+		}
+		if last == nil || e.Date.After(*last) {
+			last = e.Date
+		}
+	}
+	if last == nil {
+		return time.Date(year, month, day, 7, 0, rand.Intn(60), 0, time.UTC)
+	}
+	return last.Add(time.Duration(rand.Intn(60)) * time.Second)
+}
+
+func (db *MemoryDB) CreateEvent(ctx context.Context, event *EventInfo) (string, error) {
+	if event.Text == "" || event.Date == nil || event.SquadId == "" {
+		return "", fmt.Errorf("Failed to create event, not enough details provided: %+v", event)
+	}
+
+	if db.dev {
+		log.Printf("Creating event '%+v'", event)
+	}
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	date := db.uniqueEventDate(event.Date)
+	event.Date = &date
+
+	id := newDocId()
+	db.events[id] = &memEvent{
+		EventInfo:    *copyEventInfo(event),
+		counters:     make(map[string]int),
+		participants: make(map[string]*memParticipant),
+	}
+
+	owner := copyEventInfo(event)
+	owner.Status = EventOwner
+	db.addEventRecordToParticipant(event.OwnerId, id, owner)
+
+	return id, nil
+}
+
+func (db *MemoryDB) GetEvent(ctx context.Context, ID string) (*EventInfo, error) {
+	if db.dev {
+		log.Println("Getting details for event " + ID)
+	}
+
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	event, err := db.getEvent(ID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get event "+ID+": %w", err)
+	}
+
+	return copyEventInfo(&event.EventInfo), nil
+}
+
+func (db *MemoryDB) getUserEventsMap(squads []string, userId string) map[string]*EventInfo {
+	events := make(map[string]*EventInfo, 0)
+	for id, e := range db.participantEvents[userId] {
+		if !e.Archived && containsString(squads, e.SquadId) {
+			events[id] = copyEventInfo(e)
+		}
+	}
+	return events
+}
+
+func (db *MemoryDB) GetUserEventsMap(ctx context.Context, squads []string, userId string) (map[string]*EventInfo, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	return db.getUserEventsMap(squads, userId), nil
+}
+
+func (db *MemoryDB) GetUserEventsCount(ctx context.Context, userId string) (int, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	count := 0
+	for _, e := range db.participantEvents[userId] {
+		if !e.Archived {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (db *MemoryDB) GetUserEvents(ctx context.Context, userId string, limit int) ([]*EventInfo, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	events := make([]*EventInfo, 0)
+	for _, e := range db.participantEvents[userId] {
+		if !e.Archived {
+			events = append(events, copyEventInfo(e))
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Date.Before(*events[j].Date) })
+	if limit != 0 && len(events) > limit {
+		events = events[:limit]
+	}
+
+	return events, nil
+}
+
+func (db *MemoryDB) ArchiveOldEvents(ctx context.Context) error {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	today := getToday()
+	for id, e := range db.events {
+		if !e.Archived && e.Date.Before(*today) {
+			log.Printf("Marking event %v as archived\n", id)
+			e.Archived = true
+		}
+	}
+
+	return nil
+}
+
+func (db *MemoryDB) GetEvents(ctx context.Context, squads []string, userId string) ([]*EventCountersRecord, error) {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	var myEvents map[string]*EventInfo
+	if userId != "" {
+		myEvents = db.getUserEventsMap(squads, userId)
+	}
+
+	events := make([]*EventCountersRecord, 0)
+	for id, e := range db.events {
+		if e.Archived || !containsString(squads, e.SquadId) {
+			continue
+		}
+		r := e.countersRecord(id)
+		if myEvent, ok := myEvents[id]; ok {
+			r.Status = myEvent.Status
+			delete(myEvents, id)
+		}
+		events = append(events, r)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Date.Before(*events[j].Date) })
+
+	// now mark archived events that user has but do not exist in the global list
+	for id := range myEvents {
+		db.participantEvents[userId][id].Archived = true
+	}
+
+	return events, nil
+}
+
+func (db *MemoryDB) GetEventsByStatus(ctx context.Context, squads []string, userId string, status string) ([]*EventCountersRecord, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	events := make([]*EventCountersRecord, 0)
+	for id, e := range db.events {
+		if e.Archived || !containsString(squads, e.SquadId) || e.counters[status] <= 0 {
+			continue
+		}
+		events = append(events, e.countersRecord(id))
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return db.events[events[i].ID].counters[status] > db.events[events[j].ID].counters[status]
+	})
+
+	return events, nil
+}
+
+func (db *MemoryDB) GetArchivedEvents(ctx context.Context, userId string, from *time.Time, filter *map[string]string) ([]*EventRecord, error) {
+	if db.dev {
+		log.Printf("Getting archived events for user %v (from %+v, filter %+v)\n", userId, from, filter)
+	}
+
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	events := make([]*EventRecord, 0)
+	for id, e := range db.participantEvents[userId] {
+		if !e.Archived || (from != nil && !e.Date.Before(*from)) {
+			continue
+		}
+		if !matchesFilter(nil, nil, int(e.Status), filter, eventStatusFromString) {
+			continue
+		}
+		events = append(events, &EventRecord{
+			ID:        id,
+			EventInfo: *copyEventInfo(e),
+		})
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Date.After(*events[j].Date) })
+	if len(events) > numRecords {
+		events = events[:numRecords]
+	}
+
+	return events, nil
+}
+
+func (db *MemoryDB) RegisterParticipants(ctx context.Context, userIds []string, eventId string, eventInfo *EventInfo, status ParticipantStatusType) error {
+	if db.dev {
+		log.Println("Registering users " + strings.Join(userIds, ", ") + " for event " + eventId)
+	}
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	// event info is shared by concurrent callers, so it is not modified
+	eventInfo = copyEventInfo(eventInfo)
+	eventInfo.Status = status
+
+	errs := make([]error, len(userIds))
+	for i, userId := range userIds {
+		if p, err := db.getParticipant(eventId, userId); err == nil {
+			errs[i] = fmt.Errorf("User %v is already registered for event %v with status %v", userId, eventId, p.Status.String())
+			continue
+		}
+
+		member, err := db.getSquadMember(eventInfo.SquadId, userId)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		event, err := db.getEvent(eventId)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		participant := &ParticipantInfo{
+			UserInfo:  member.UserInfo,
+			Replicant: member.Replicant,
+			Tags:      copyStrings(member.Tags),
+			Status:    registrationStatus(event.Capacity, event.taken(), status),
+		}
+
+		errs[i] = db.addParticipantRecordToEvent(eventId, userId, participant)
+		if errs[i] != nil {
+			continue
+		}
+
+		if !member.Replicant {
+			db.addEventRecordToParticipant(userId, eventId, eventInfo)
+			db.participantEvents[userId][eventId].Status = participant.Status
+		}
+	}
+
+	return registrationErrors(userIds, errs)
+}
+
+func (db *MemoryDB) addParticipantRecordToEvent(eventId string, userId string, userInfo *ParticipantInfo) error {
+	if db.dev {
+		log.Println("Adding participant " + userId + " to event " + eventId)
+	}
+
+	event, err := db.getEvent(eventId)
+	if err != nil {
+		return fmt.Errorf("Failed to add participant "+userId+" to event "+eventId+": %w", err)
+	}
+
+	p := &memParticipant{ParticipantInfo: *userInfo}
+	p.Timestamp = time.Now().UTC()
+	p.keys = userInfo.Keys()
+	event.participants[userId] = p
+	event.counters[userInfo.Status.String()]++
+
+	return nil
+}
+
+func (db *MemoryDB) GetParticipants(ctx context.Context, eventId string, from *time.Time, filter *map[string]string) ([]*ParticipantRecord, error) {
+	if db.dev {
+		log.Printf("Getting participants of the event %v (from %v, filter %v)\n", eventId, from, filter)
+	}
+
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	participants := make([]*ParticipantRecord, 0)
+	event, ok := db.events[eventId]
+	if !ok {
+		return participants, nil
+	}
+
+	for id, p := range event.participants {
+		if from != nil && !p.Timestamp.(time.Time).After(*from) {
+			continue
+		}
+		if !matchesFilter(p.keys, p.Tags, int(p.Status), filter, eventStatusFromString) {
+			continue
+		}
+		pr := &ParticipantRecord{
+			ID:              id,
+			ParticipantInfo: p.ParticipantInfo,
+		}
+		pr.Tags = copyStrings(p.Tags)
+		participants = append(participants, pr)
+	}
+	sort.Slice(participants, func(i, j int) bool {
+		return participants[i].Timestamp.(time.Time).Before(participants[j].Timestamp.(time.Time))
+	})
+	if len(participants) > numRecords {
+		participants = participants[:numRecords]
+	}
+
+	return participants, nil
+}
+
+func (db *MemoryDB) PromoteWaitlisted(ctx context.Context, eventId string) ([]string, error) {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	event, err := db.getEvent(eventId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to promote waitlisted participants of event %v: %w", eventId, err)
+	}
+
+	waitlisted := make([]string, 0)
+	for id, p := range event.participants {
+		if p.Status == Waitlisted {
+			waitlisted = append(waitlisted, id)
+		}
+	}
+	sort.Slice(waitlisted, func(i, j int) bool {
+		return event.participants[waitlisted[i]].Timestamp.(time.Time).Before(event.participants[waitlisted[j]].Timestamp.(time.Time))
+	})
+
+	promoted := make([]string, 0)
+	for _, userId := range waitlisted {
+		if event.Capacity > 0 && event.taken() >= event.Capacity {
+			break
+		}
+		event.participants[userId].Status = Going
+		event.counters[Waitlisted.String()]--
+		event.counters[Going.String()]++
+		if e, ok := db.participantEvents[userId][eventId]; ok {
+			e.Status = Going
+		}
+		promoted = append(promoted, userId)
+	}
+
+	return promoted, nil
+}
+
+func (db *MemoryDB) MarkNoShows(ctx context.Context, eventId string) (int, error) {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	event, err := db.getEvent(eventId)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to mark no-shows of event %v: %w", eventId, err)
+	}
+
+	marked := 0
+	for userId, p := range event.participants {
+		if p.Status != Going {
+			continue
+		}
+		p.Status = NoShow
+		event.counters[Going.String()]--
+		event.counters[NoShow.String()]++
+		if e, ok := db.participantEvents[userId][eventId]; ok {
+			e.Status = NoShow
+		}
+		marked++
+	}
+
+	return marked, nil
+}
+
+func (db *MemoryDB) GetParticipantStatus(ctx context.Context, userId string, eventId string) (ParticipantStatusType, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	p, err := db.getParticipant(eventId, userId)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get event "+eventId+": %w", err)
+	}
+
+	return p.Status, nil
+}
+
+func (db *MemoryDB) SetParticipantStatus(ctx context.Context, userId string, eventId string, status ParticipantStatusType) (ParticipantStatusType, ParticipantStatusType, error) {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	p, err := db.getParticipant(eventId, userId)
+	if err != nil {
+		return 0, 0, fmt.Errorf("Failed to get event "+eventId+": %w", err)
+	}
+
+	event := db.events[eventId]
+	oldStatus := p.Status
+	status = changedStatus(event.Capacity, event.taken(), oldStatus, status)
+	event.counters[oldStatus.String()]--
+	event.counters[status.String()]++
+	p.Status = status
+
+	if e, ok := db.participantEvents[userId][eventId]; ok {
+		e.Status = status
+	}
+
+	return oldStatus, status, nil
+}
+
+func (db *MemoryDB) DeleteParticipant(ctx context.Context, userId string, eventId string) error {
+	if db.dev {
+		log.Println("Removing user " + userId + " from event " + eventId)
+	}
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	delete(db.participantEvents[userId], eventId)
+
+	p, err := db.getParticipant(eventId, userId)
+	if err != nil {
+		return fmt.Errorf("Failed to get event "+eventId+": %w", err)
+	}
+
+	event := db.events[eventId]
+	delete(event.participants, userId)
+	event.counters[p.Status.String()]--
+
+	return nil
+}
+
+func (db *MemoryDB) RecountEventCounters(ctx context.Context) (int, error) {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	fixed := 0
+	for id, event := range db.events {
+		counters := make(map[string]int)
+		for _, p := range event.participants {
+			counters[p.Status.String()]++
+		}
+
+		wrong := false
+		for _, status := range eventCounterStatuses {
+			if event.counters[status.String()] != counters[status.String()] {
+				wrong = true
+			}
+		}
+		if wrong {
+			log.Printf("Fixed event %v counters", id)
+			event.counters = counters
+			fixed++
+		}
+	}
+
+	return fixed, nil
+}
+
+func (db *MemoryDB) DeleteEvent(ctx context.Context, eventId string) error {
+	if db.dev {
+		log.Println("Deleting event " + eventId)
+	}
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	event, err := db.getEvent(eventId)
+	if err != nil {
+		return fmt.Errorf("Error while deleting event %v: %w", eventId, err)
+	}
+
+	for userId := range event.participants {
+		delete(db.participantEvents[userId], eventId)
+	}
+	delete(db.participantEvents[event.OwnerId], eventId)
+	delete(db.events, eventId)
+	for key := range db.sentReminders {
+		if strings.HasPrefix(key, eventId+"/") {
+			delete(db.sentReminders, key)
+		}
+	}
+
+	return nil
+}
+
+func (db *MemoryDB) GetCandidates(ctx context.Context, squadId string, eventId string, from string, filter *map[string]string) ([]*SquadUserInfoRecord, error) {
+	if db.dev {
+		log.Printf("Getting candidates to participate in the event %v (filter %v)\n", eventId, filter)
+	}
+
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	var participants map[string]*memParticipant
+	if event, ok := db.events[eventId]; ok {
+		participants = event.participants
+	}
+
+	ids, err := db.getSquadMemberIds(squadId, func(m *memMember) bool {
+		return matchesFilter(m.keys, m.Tags, int(m.Status), filter, statusFromString)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get event participants: %w", err)
+	}
+
+	candidates := make([]*SquadUserInfoRecord, 0, numRecords)
+	for _, id := range ids {
+		if len(candidates) >= numRecords {
+			break
+		}
+		if id <= from {
+			continue
+		}
+		if _, ok := participants[id]; ok {
+			continue
+		}
+		candidates = append(candidates, &SquadUserInfoRecord{
+			ID:            id,
+			SquadUserInfo: *db.squads[squadId].members[id].copy(),
+		})
+	}
+
+	return candidates, nil
+}
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+func copyJobStatus(j *JobStatus) *JobStatus {
+	c := *j
+	if j.LockedUntil != nil {
+		c.LockedUntil = utcTime(*j.LockedUntil)
+	}
+	if j.LastStart != nil {
+		c.LastStart = utcTime(*j.LastStart)
+	}
+	if j.LastFinish != nil {
+		c.LastFinish = utcTime(*j.LastFinish)
+	}
+	return &c
+}
+
+func (db *MemoryDB) GetJobs(ctx context.Context) ([]*JobStatus, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	jobs := make([]*JobStatus, 0, len(db.jobs))
+	for _, j := range db.jobs {
+		jobs = append(jobs, copyJobStatus(j))
+	}
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].Name < jobs[k].Name })
+
+	return jobs, nil
+}
+
+func (db *MemoryDB) StartJob(ctx context.Context, name string, instance string, due time.Time, now time.Time, lockUntil time.Time) (bool, error) {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	j, ok := db.jobs[name]
+	if !ok {
+		j = &JobStatus{Name: name}
+		db.jobs[name] = j
+	}
+	if !j.canStart(due, now) {
+		return false, nil
+	}
+
+	j.LockedBy = instance
+	j.LockedUntil = utcTime(lockUntil)
+	j.LastStart = utcTime(now)
+
+	return true, nil
+}
+
+func (db *MemoryDB) FinishJob(ctx context.Context, name string, instance string, finish time.Time, jobErr string) error {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	j, ok := db.jobs[name]
+	if !ok || j.LockedBy != instance {
+		return nil
+	}
+
+	j.LockedBy = ""
+	j.LockedUntil = nil
+	j.LastFinish = utcTime(finish)
+	j.LastError = jobErr
+
+	return nil
+}
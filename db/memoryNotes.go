@@ -0,0 +1,104 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+func (db *MemoryDB) CreateNote(ctx context.Context, squadId string, note *NoteUpdate) (string, error) {
+	if note.Title == nil || note.Text == nil {
+		return "", fmt.Errorf("Failed to create note, not enough details provided: %+v", note)
+	}
+
+	log.Printf("Creating note '%+v' in squad '%v'", note, squadId)
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	squad, err := db.getSquad(squadId)
+	if err != nil {
+		return "", err
+	}
+
+	id := newDocId()
+	squad.notes[id] = &Note{
+		Title:     *note.Title,
+		Text:      *note.Text,
+		Timestamp: time.Now().UTC(),
+	}
+
+	return id, nil
+}
+
+func (db *MemoryDB) GetNotes(ctx context.Context, squadId string, publishedOnly bool) ([]*NoteRecord, error) {
+	log.Printf("Getting notes for squad '%v'", squadId)
+
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	squad, err := db.getSquad(squadId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get squad notes: %w", err)
+	}
+
+	notes := make([]*NoteRecord, 0)
+	for id, note := range squad.notes {
+		if publishedOnly && !note.Published {
+			continue
+		}
+		notes = append(notes, &NoteRecord{
+			ID:   id,
+			Note: *note,
+		})
+	}
+	sort.Slice(notes, func(i, j int) bool { return notes[i].Timestamp.After(notes[j].Timestamp) })
+
+	return notes, nil
+}
+
+func (db *MemoryDB) DeleteNote(ctx context.Context, squadId string, noteId string) error {
+	log.Println("Deleting note " + noteId + " from squad " + squadId)
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	squad, err := db.getSquad(squadId)
+	if err != nil {
+		return fmt.Errorf("Error while deleting note "+noteId+" from squad "+squadId+": %w", err)
+	}
+	delete(squad.notes, noteId)
+
+	return nil
+}
+
+func (db *MemoryDB) UpdateNote(ctx context.Context, squadId string, noteId string, note *NoteUpdate) error {
+	log.Printf("Updating note '%v' in squad '%v'", noteId, squadId)
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	squad, err := db.getSquad(squadId)
+	if err != nil {
+		return err
+	}
+	n, ok := squad.notes[noteId]
+	if !ok {
+		n = &Note{}
+		squad.notes[noteId] = n
+	}
+
+	if note.Title != nil && note.Text != nil {
+		n.Title = *note.Title
+		n.Text = *note.Text
+		n.Timestamp = time.Now().UTC()
+		return nil
+	} else if note.Published != nil {
+		n.Published = *note.Published
+		return nil
+	}
+
+	return fmt.Errorf("Failed to update note, not enough details provided: %+v", note)
+}
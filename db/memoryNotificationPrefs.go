@@ -0,0 +1,61 @@
+package db
+
+import (
+	"context"
+)
+
+func copyNotificationPref(p *NotificationPref) *NotificationPref {
+	c := *p
+	c.Channels = copyStrings(p.Channels)
+	return &c
+}
+
+func (db *MemoryDB) GetNotificationPrefs(ctx context.Context, userId string) ([]*NotificationPref, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	prefs := make([]*NotificationPref, 0)
+	for _, p := range db.notificationPrefs[userId] {
+		prefs = append(prefs, copyNotificationPref(p))
+	}
+	sortNotificationPrefs(prefs)
+
+	return prefs, nil
+}
+
+func (db *MemoryDB) SetNotificationPref(ctx context.Context, userId string, pref *NotificationPref) error {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	prefs, ok := db.notificationPrefs[userId]
+	if !ok {
+		prefs = make(map[string]*NotificationPref)
+		db.notificationPrefs[userId] = prefs
+	}
+	prefs[pref.SquadId+"/"+pref.Kind] = copyNotificationPref(pref)
+
+	return nil
+}
+
+func (db *MemoryDB) DeleteNotificationPref(ctx context.Context, userId string, squadId string, kind string) error {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	delete(db.notificationPrefs[userId], squadId+"/"+kind)
+
+	return nil
+}
+
+func (db *MemoryDB) GetUsersNotificationPrefs(ctx context.Context, userIds []string, squadId string, kind string) (map[string]*NotificationPref, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	prefs := make(map[string]*NotificationPref)
+	for _, userId := range userIds {
+		if p, ok := db.notificationPrefs[userId][squadId+"/"+kind]; ok {
+			prefs[userId] = copyNotificationPref(p)
+		}
+	}
+
+	return prefs, nil
+}
@@ -0,0 +1,115 @@
+package db
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+func copyNotification(n *NotificationRecord) *NotificationRecord {
+	c := *n
+	if n.Link != nil {
+		link := *n.Link
+		c.Link = &link
+	}
+	return &c
+}
+
+func (db *MemoryDB) CreateNotification(ctx context.Context, userIds []string, n *Notification) error {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	for _, userId := range userIds {
+		un := &NotificationRecord{ID: newDocId(), Notification: *n}
+		un.UserId = userId
+		un.Read = false
+		db.notifications[userId] = append(db.notifications[userId], copyNotification(un))
+	}
+
+	return nil
+}
+
+func (db *MemoryDB) GetNotifications(ctx context.Context, userId string, before *time.Time, unreadOnly bool) ([]*NotificationRecord, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	// newest first
+	list := db.notifications[userId]
+	notifications := make([]*NotificationRecord, 0)
+	for i := len(list) - 1; i >= 0 && len(notifications) < numRecords; i-- {
+		n := list[i]
+		if (before != nil && !n.Time.Before(*before)) || (unreadOnly && n.Read) {
+			continue
+		}
+		notifications = append(notifications, copyNotification(n))
+	}
+
+	return notifications, nil
+}
+
+func (db *MemoryDB) GetUnreadNotificationsCount(ctx context.Context, userId string) (int, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	count := 0
+	for _, n := range db.notifications[userId] {
+		if !n.Read {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (db *MemoryDB) MarkNotificationRead(ctx context.Context, userId string, notificationId string) error {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	for _, n := range db.notifications[userId] {
+		if n.ID == notificationId {
+			n.Read = true
+			return nil
+		}
+	}
+
+	return notFound("Failed to mark notification %v read: not found", notificationId)
+}
+
+func (db *MemoryDB) MarkNotificationsRead(ctx context.Context, userId string) error {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	for _, n := range db.notifications[userId] {
+		n.Read = true
+	}
+
+	return nil
+}
+
+func (db *MemoryDB) DeleteNotificationsBefore(ctx context.Context, before time.Time) (int, error) {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	deleted := 0
+	for userId, list := range db.notifications {
+		kept := list[:0]
+		for _, n := range list {
+			if n.Time.Before(before) {
+				deleted++
+			} else {
+				kept = append(kept, n)
+			}
+		}
+		if len(kept) == 0 {
+			delete(db.notifications, userId)
+		} else {
+			db.notifications[userId] = kept
+		}
+	}
+
+	if deleted > 0 {
+		log.Printf("Deleted %v notifications older than %v", deleted, before)
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+func (db *MemoryDB) SavePushToken(ctx context.Context, token *PushToken) error {
+	if token.Token == "" {
+		return fmt.Errorf("Failed to save push token of user %v: token is empty", token.UserId)
+	}
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	t := *token
+	t.Created = t.LastSeen
+	if old, ok := db.pushTokens[t.Token]; ok {
+		t.Created = old.Created
+	}
+	db.pushTokens[t.Token] = &t
+
+	return nil
+}
+
+func (db *MemoryDB) GetPushTokens(ctx context.Context, userId string) ([]*PushToken, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	tokens := make([]*PushToken, 0)
+	for _, t := range db.pushTokens {
+		if t.UserId == userId {
+			c := *t
+			tokens = append(tokens, &c)
+		}
+	}
+	sortPushTokens(tokens)
+
+	return tokens, nil
+}
+
+func (db *MemoryDB) DeletePushTokens(ctx context.Context, userId string, tokens []string) error {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	for _, token := range tokens {
+		if t, ok := db.pushTokens[token]; ok && t.UserId == userId {
+			delete(db.pushTokens, token)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+func (db *MemoryDB) CreateRequestComment(ctx context.Context, requestId string, comment *RequestComment) (string, error) {
+	if db.dev {
+		log.Printf("Creating comment %+v for request %v\n", comment, requestId)
+	}
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	if _, ok := db.requests[requestId]; !ok {
+		return "", notFound("Failed to get request %v: not found", requestId)
+	}
+
+	c := &RequestCommentRecord{ID: newDocId(), RequestComment: *comment}
+	now := time.Now().UTC()
+	c.Time = &now
+	db.requestComments[requestId] = append(db.requestComments[requestId], c)
+
+	return c.ID, nil
+}
+
+func (db *MemoryDB) GetRequestComments(ctx context.Context, requestId string) ([]*RequestCommentRecord, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	comments := make([]*RequestCommentRecord, len(db.requestComments[requestId]))
+	for i, c := range db.requestComments[requestId] {
+		comment := *c
+		comments[i] = &comment
+	}
+
+	return comments, nil
+}
+
+func (db *MemoryDB) GetRequestComment(ctx context.Context, requestId string, commentId string) (*RequestComment, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	for _, c := range db.requestComments[requestId] {
+		if c.ID == commentId {
+			comment := c.RequestComment
+			return &comment, nil
+		}
+	}
+
+	return nil, notFound("Failed to get comment %v: not found", commentId)
+}
+
+func (db *MemoryDB) DeleteRequestComment(ctx context.Context, requestId string, commentId string) error {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	comments := db.requestComments[requestId]
+	for i, c := range comments {
+		if c.ID == commentId {
+			db.requestComments[requestId] = append(comments[:i:i], comments[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
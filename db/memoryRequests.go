@@ -0,0 +1,384 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+type memQueue struct {
+	QueueInfo
+	approversPath string
+	handlersPath  string
+	counters      map[string]int
+}
+
+func (q *memQueue) info() *QueueInfo {
+	qi := q.QueueInfo
+	qi.WaitingApprove = q.counters[WaitingApprove.String()]
+	qi.Processing = q.counters[Processing.String()]
+	return &qi
+}
+
+func (db *MemoryDB) getQueue(queueId string) (*memQueue, error) {
+	queue, ok := db.queues[queueId]
+	if !ok {
+		return nil, notFound("Failed to get queue %v: not found", queueId)
+	}
+	return queue, nil
+}
+
+func (db *MemoryDB) CreateRequestsQueue(ctx context.Context, queueId string, qi *QueueInfo) error {
+	if db.dev {
+		log.Println("Creating queue " + qi.SquadId + "/" + queueId)
+	}
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	queue := &memQueue{
+		QueueInfo: *qi,
+		counters: map[string]int{
+			WaitingApprove.String(): qi.WaitingApprove,
+			Processing.String():     qi.Processing,
+		},
+	}
+	if qi.Approvers != "" {
+		queue.approversPath = qi.SquadId + "/" + qi.Approvers
+	}
+	if qi.Handlers != "" {
+		queue.handlersPath = qi.SquadId + "/" + qi.Handlers
+	}
+	db.queues[queueId] = queue
+
+	return nil
+}
+
+func (db *MemoryDB) GetRequest(ctx context.Context, requestId string) (*RequestDetails, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	r, ok := db.requests[requestId]
+	if !ok {
+		return nil, notFound("Failed to get request %v: not found", requestId)
+	}
+
+	rd := *r
+	return &rd, nil
+}
+
+func (db *MemoryDB) GetRequestQueue(ctx context.Context, queueId string) (*QueueInfo, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	queue, err := db.getQueue(queueId)
+	if err != nil {
+		return nil, err
+	}
+
+	return queue.info(), nil
+}
+
+func (db *MemoryDB) DeleteRequestsQueue(ctx context.Context, queueId string) error {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	delete(db.queues, queueId)
+
+	return nil
+}
+
+func (db *MemoryDB) GetRequestQueues(ctx context.Context, squadId string) ([]*QueueRecord, error) {
+	if db.dev {
+		log.Println("Getting request queues for squad " + squadId)
+	}
+
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	queues := make([]*QueueRecord, 0)
+	for id, q := range db.queues {
+		if q.SquadId == squadId {
+			queues = append(queues, &QueueRecord{
+				ID:        id,
+				QueueInfo: *q.info(),
+			})
+		}
+	}
+	sort.Slice(queues, func(i, j int) bool { return queues[i].ID < queues[j].ID })
+
+	return queues, nil
+}
+
+// approvers should be able to see requests they approved and being processed
+// now, so queuesToHandle is a superset for queuesToApprove
+func (db *MemoryDB) getQueuesToApproveAndHandleIds(userTags []string, squadsAdmin []string) (map[string]int, map[string]int) {
+	queuesToApprove := make(map[string]int, 0)
+	queuesToHandle := make(map[string]int, 0)
+
+	for id, q := range db.queues {
+		if (q.approversPath != "" && containsString(userTags, q.approversPath)) || containsString(squadsAdmin, q.SquadId) {
+			queuesToApprove[id] = -1
+			queuesToHandle[id] = -1
+		}
+		if q.handlersPath != "" && containsString(userTags, q.handlersPath) {
+			queuesToHandle[id] = -1
+		}
+	}
+
+	return queuesToApprove, queuesToHandle
+}
+
+func (db *MemoryDB) GetQueuesToApproveAndHandle(ctx context.Context, userTags []string, squadsAdmin []string) (map[string]int, map[string]int, error) {
+	if db.dev {
+		log.Printf("Getting request queues for user tags %v", userTags)
+	}
+
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	queuesToApprove, queuesToHandle := db.getQueuesToApproveAndHandleIds(userTags, squadsAdmin)
+	for k := range queuesToApprove {
+		queuesToApprove[k] = db.queues[k].counters[WaitingApprove.String()]
+	}
+	for k := range queuesToHandle {
+		queuesToHandle[k] = db.queues[k].counters[Processing.String()]
+	}
+
+	return queuesToApprove, queuesToHandle, nil
+}
+
+// returns first 10 requests passing the check ordered by time desc
+func (db *MemoryDB) getRequests(from *time.Time, check func(r *RequestDetails) bool) []RequestRecord {
+	requests := make([]RequestRecord, 0)
+	for id, r := range db.requests {
+		if from != nil && !r.Time.Before(*from) {
+			continue
+		}
+		if check(r) {
+			requests = append(requests, RequestRecord{
+				RequestId:      id,
+				RequestDetails: *r,
+			})
+		}
+	}
+	sort.Slice(requests, func(i, j int) bool { return requests[i].Time.After(*requests[j].Time) })
+	if len(requests) > 10 {
+		requests = requests[:10]
+	}
+
+	return requests
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (db *MemoryDB) GetUserQueuesAndRequests(ctx context.Context, userId string, userTags []string, squadsAdmin []string, squadsAll []string) (userQueues []string, userRequests []RequestRecord, queuesToApprove []string, requestsToApprove []RequestRecord, queuesToHandle []string, requestsToHandle []RequestRecord, err error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	queuesToApproveMap, queuesToHandleMap := db.getQueuesToApproveAndHandleIds(userTags, squadsAdmin)
+
+	queuesToApprove = sortedKeys(queuesToApproveMap)
+	if len(queuesToApprove) > 0 {
+		requestsToApprove = db.getRequests(nil, func(r *RequestDetails) bool {
+			return r.Status == WaitingApprove && containsString(queuesToApprove, r.QueueId)
+		})
+	}
+
+	queuesToHandle = sortedKeys(queuesToHandleMap)
+	if len(queuesToHandle) > 0 {
+		requestsToHandle = db.getRequests(nil, func(r *RequestDetails) bool {
+			return r.Status == Processing && containsString(queuesToHandle, r.QueueId)
+		})
+	}
+
+	// get queues from user squads (where they might file requests)
+	userQueues = make([]string, 0)
+	for id, q := range db.queues {
+		if containsString(squadsAll, q.SquadId) {
+			userQueues = append(userQueues, id)
+		}
+	}
+	sort.Strings(userQueues)
+
+	userRequests = db.getRequests(nil, func(r *RequestDetails) bool {
+		return r.UserId == userId
+	})
+
+	return userQueues, userRequests, queuesToApprove, requestsToApprove, queuesToHandle, requestsToHandle, nil
+}
+
+func (db *MemoryDB) CreateRequest(ctx context.Context, request *RequestDetails) (string, error) {
+	if db.dev {
+		log.Printf("Creating request %+v\n", request)
+	}
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	queue, err := db.getQueue(request.QueueId)
+	if err != nil {
+		return "", err
+	}
+
+	id := newDocId()
+	r := *request
+	now := time.Now().UTC()
+	r.Time = &now
+	r.StatusTime = &now
+	r.Escalated = false
+	db.requests[id] = &r
+	queue.counters[request.Status.String()]++
+	db.requestHistory[id] = []*RequestHistoryEntry{{
+		UserId:    r.UserId,
+		UserName:  r.UserName,
+		NewState:  r.CurrentState(),
+		NewStatus: r.Status,
+		Time:      &now,
+	}}
+
+	return id, nil
+}
+
+func (db *MemoryDB) GetUserRequests(ctx context.Context, userId string, from *time.Time, filter *RequestsFilter) ([]RequestRecord, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	return db.getRequests(from, func(r *RequestDetails) bool {
+		return r.UserId == userId && filter.Matches(r)
+	}), nil
+}
+
+func (db *MemoryDB) GetRequestsByTag(ctx context.Context, tags []string, squadsAdmin []string, status RequestStatusType, from *time.Time, filter *RequestsFilter) ([]RequestRecord, error) {
+	if db.dev {
+		log.Printf("Getting requests %v by %v, from %v", status, tags, from)
+	}
+
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	queuesMap, queuesToHandleMap := db.getQueuesToApproveAndHandleIds(tags, squadsAdmin)
+	if status == Processing {
+		queuesMap = queuesToHandleMap
+	}
+
+	return db.getRequests(from, func(r *RequestDetails) bool {
+		_, ok := queuesMap[r.QueueId]
+		return ok && r.Status == status && filter.Matches(r)
+	}), nil
+}
+
+func (db *MemoryDB) SetRequestState(ctx context.Context, requestId string, fromState string, state string, status RequestStatusType, entry *RequestHistoryEntry) error {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	request, ok := db.requests[requestId]
+	if !ok {
+		return notFound("Failed to get request %v: not found", requestId)
+	}
+	if request.CurrentState() != fromState {
+		return stateChanged(requestId)
+	}
+
+	e := *entry
+	now := time.Now().UTC()
+	e.OldState, e.OldStatus = request.CurrentState(), request.Status
+	e.NewState, e.NewStatus = state, status
+	e.Time = &now
+
+	if request.Status != status {
+		queue, err := db.getQueue(request.QueueId)
+		if err != nil {
+			return fmt.Errorf("Failed to set request "+requestId+" status: %w", err)
+		}
+		queue.counters[request.Status.String()]--
+		queue.counters[status.String()]++
+		request.Status = status
+		request.StatusTime = &now
+		request.Escalated = false
+	}
+	request.State = state
+	db.requestHistory[requestId] = append(db.requestHistory[requestId], &e)
+
+	return nil
+}
+
+func (db *MemoryDB) GetRequestHistory(ctx context.Context, requestId string) ([]*RequestHistoryEntry, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	history := make([]*RequestHistoryEntry, len(db.requestHistory[requestId]))
+	for i, e := range db.requestHistory[requestId] {
+		entry := *e
+		history[i] = &entry
+	}
+
+	return history, nil
+}
+
+func (db *MemoryDB) SetRequestAssignee(ctx context.Context, requestId string, fromAssigneeId string, assigneeId string, assigneeName string) error {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	request, ok := db.requests[requestId]
+	if !ok {
+		return notFound("Failed to get request %v: not found", requestId)
+	}
+	if request.AssigneeId != fromAssigneeId {
+		return assigneeChanged(requestId, request.AssigneeName)
+	}
+
+	request.AssigneeId = assigneeId
+	request.AssigneeName = assigneeName
+
+	return nil
+}
+
+func (db *MemoryDB) GetQueuesWithSLA(ctx context.Context) ([]*QueueRecord, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	queues := make([]*QueueRecord, 0)
+	for id, q := range db.queues {
+		if q.SLA.enabled() {
+			queues = append(queues, &QueueRecord{
+				ID:        id,
+				QueueInfo: *q.info(),
+			})
+		}
+	}
+	sort.Slice(queues, func(i, j int) bool { return queues[i].ID < queues[j].ID })
+
+	return queues, nil
+}
+
+func (db *MemoryDB) GetOverdueRequests(ctx context.Context, queueId string, status RequestStatusType, before time.Time) ([]RequestRecord, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	return db.getRequests(nil, func(r *RequestDetails) bool {
+		return r.QueueId == queueId && r.Status == status && !r.Escalated && r.StatusTime != nil && r.StatusTime.Before(before)
+	}), nil
+}
+
+func (db *MemoryDB) SetRequestEscalated(ctx context.Context, requestId string) error {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	request, ok := db.requests[requestId]
+	if !ok {
+		return notFound("Failed to get request %v: not found", requestId)
+	}
+	request.Escalated = true
+
+	return nil
+}
@@ -0,0 +1,516 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (db *MemoryDB) getSquad(squadId string) (*memSquad, error) {
+	squad, ok := db.squads[squadId]
+	if !ok {
+		return nil, notFound("Failed to get squad %v: not found", squadId)
+	}
+	return squad, nil
+}
+
+func (db *MemoryDB) getSquadMember(squadId string, userId string) (*memMember, error) {
+	squad, err := db.getSquad(squadId)
+	if err != nil {
+		return nil, err
+	}
+	member, ok := squad.members[userId]
+	if !ok {
+		return nil, notFound("Failed to get squad %v member %v: not found", squadId, userId)
+	}
+	return member, nil
+}
+
+func (db *MemoryDB) CreateSquad(ctx context.Context, squadId string, ownerId string) error {
+	if db.dev {
+		log.Println("Creating squad " + squadId)
+	}
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	if len(db.memberSquads[ownerId]) >= 10 {
+		return fmt.Errorf("User might participate in 10 squads maximum")
+	}
+
+	if _, ok := db.squads[squadId]; ok {
+		return status.Errorf(codes.AlreadyExists, "Squad %v already exists", squadId)
+	}
+	db.squads[squadId] = newMemSquad(ownerId)
+
+	_, err := db.addMemberToSquad(ownerId, squadId, Owner)
+
+	return err
+}
+
+func (db *MemoryDB) GetSquad(ctx context.Context, ID string) (*SquadInfo, error) {
+	if db.dev {
+		log.Println("Getting details for squad " + ID)
+	}
+
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	squad, err := db.getSquad(ID)
+	if err != nil {
+		return nil, err
+	}
+
+	s := squad.SquadInfo
+	return &s, nil
+}
+
+func (db *MemoryDB) DeleteSquad(ctx context.Context, squadId string) error {
+	if db.dev {
+		log.Println("Deleting squad " + squadId)
+	}
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	squad, err := db.getSquad(squadId)
+	if err != nil {
+		return fmt.Errorf("Error while deleting squad %v: %w", squadId, err)
+	}
+
+	for userId := range squad.members {
+		delete(db.memberSquads[userId], squadId)
+	}
+	delete(db.squads, squadId)
+
+	return nil
+}
+
+func (db *MemoryDB) GetOtherSquads(ctx context.Context, userId string) ([]string, error) {
+	if db.dev {
+		log.Println("Getting squads for user " + userId)
+	}
+
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	otherSquads := make([]string, 0)
+	for squadId := range db.squads {
+		if squadId == ALL_USERS_SQUAD {
+			continue
+		}
+		if _, ok := db.memberSquads[userId][squadId]; !ok {
+			otherSquads = append(otherSquads, squadId)
+		}
+	}
+	sort.Strings(otherSquads)
+
+	return otherSquads, nil
+}
+
+func (db *MemoryDB) GetUserSquads(ctx context.Context, userId string, status string) ([]string, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	squads := make([]string, 0)
+	for squadId, v := range db.memberSquads[userId] {
+		switch status {
+		case "":
+			squads = append(squads, squadId)
+		case "admin":
+			if v.Status > Admin {
+				squads = append(squads, squadId)
+			}
+		default:
+			return nil, fmt.Errorf("Do not know what to do with status=%v", status)
+		}
+	}
+
+	return squads, nil
+}
+
+func (db *MemoryDB) GetUserSquadsMap(ctx context.Context, userID string, status string, includeAllUsersSquad bool) (map[string]*MemberSquadInfoRecord, error) {
+	if status != "" && status != "admin" {
+		return nil, fmt.Errorf("Do not know what to do with status=%v", status)
+	}
+
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	squads_map := make(map[string]*MemberSquadInfoRecord, 0)
+
+	if includeAllUsersSquad {
+		squads_map[ALL_USERS_SQUAD] = &MemberSquadInfoRecord{
+			ID: ALL_USERS_SQUAD,
+			MemberSquadInfo: MemberSquadInfo{
+				SquadInfo: db.squads[ALL_USERS_SQUAD].SquadInfo,
+				Status:    Owner,
+			},
+		}
+	}
+
+	for squadId, v := range db.memberSquads[userID] {
+		if status == "admin" && v.Status < Admin {
+			continue
+		}
+		squads_map[squadId] = &MemberSquadInfoRecord{
+			ID:              squadId,
+			MemberSquadInfo: *v,
+		}
+	}
+
+	return squads_map, nil
+}
+
+func (db *MemoryDB) GetSquadMember(ctx context.Context, squadId string, userId string) (*SquadUserInfo, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	member, err := db.getSquadMember(squadId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return member.copy(), nil
+}
+
+func (m *memMember) copy() *SquadUserInfo {
+	s := m.SquadUserInfo
+	s.Tags = copyStrings(m.Tags)
+	if m.Notes != nil {
+		s.Notes = make(map[string]string, len(m.Notes))
+		for k, v := range m.Notes {
+			s.Notes[k] = v
+		}
+	}
+	return &s
+}
+
+// returns ids of squad members that pass the check, ordered by id
+func (db *MemoryDB) getSquadMemberIds(squadId string, check func(m *memMember) bool) ([]string, error) {
+	squad, err := db.getSquad(squadId)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0)
+	for id, m := range squad.members {
+		if check(m) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	return ids, nil
+}
+
+func (db *MemoryDB) GetSquadMemberIds(ctx context.Context, squadId string, statuses []int, memberToSkip string) ([]string, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	ids, err := db.getSquadMemberIds(squadId, func(m *memMember) bool {
+		for _, s := range statuses {
+			if int(m.Status) == s {
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get squad admins: %w", err)
+	}
+
+	for i, id := range ids {
+		if id == memberToSkip {
+			ids = append(ids[:i], ids[i+1:]...)
+			break
+		}
+	}
+
+	return ids, nil
+}
+
+func (db *MemoryDB) GetSquadMemberIdsByTag(ctx context.Context, squadId string, tag string) ([]string, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	ids, err := db.getSquadMemberIds(squadId, func(m *memMember) bool {
+		return containsString(m.Tags, tag)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get squad members with tag: %w", err)
+	}
+
+	return ids, nil
+}
+
+func (db *MemoryDB) GetSquadMembers(ctx context.Context, squadId string, from *time.Time, filter *map[string]string) ([]*SquadUserInfoRecord, error) {
+	if db.dev {
+		log.Printf("Getting members of the squad %v\n", squadId)
+	}
+
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	squad, err := db.getSquad(squadId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get squad members: %w", err)
+	}
+
+	squadMembers := make([]*SquadUserInfoRecord, 0)
+	for id, m := range squad.members {
+		if from != nil && !m.Timestamp.(time.Time).After(*from) {
+			continue
+		}
+		if !matchesFilter(m.keys, m.Tags, int(m.Status), filter, statusFromString) {
+			continue
+		}
+		squadMembers = append(squadMembers, &SquadUserInfoRecord{
+			ID:            id,
+			SquadUserInfo: *m.copy(),
+		})
+	}
+
+	sort.Slice(squadMembers, func(i, j int) bool {
+		return squadMembers[i].Timestamp.(time.Time).Before(squadMembers[j].Timestamp.(time.Time))
+	})
+	if len(squadMembers) > numRecords {
+		squadMembers = squadMembers[:numRecords]
+	}
+
+	return squadMembers, nil
+}
+
+func (db *MemoryDB) CheckIfUserIsSquadMember(ctx context.Context, userId string, squadId string) error {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	_, err := db.getSquadMember(squadId, userId)
+
+	return err
+}
+
+func (db *MemoryDB) GetSquadMemberStatus(ctx context.Context, userId string, squadId string) (MemberStatusType, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	member, err := db.getSquadMember(squadId, userId)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get squad "+squadId+": %w", err)
+	}
+
+	return member.Status, nil
+}
+
+func (db *MemoryDB) SetSquadMemberStatus(ctx context.Context, userId string, squadId string, status MemberStatusType) error {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	member, err := db.getSquadMember(squadId, userId)
+	if err != nil {
+		return err
+	}
+	oldStatus := member.Status
+
+	member.Status = status
+	if squadId != ALL_USERS_SQUAD {
+		if ms, ok := db.memberSquads[userId][squadId]; ok {
+			ms.Status = status
+		}
+	}
+
+	changedCount := 0
+	if oldStatus == PendingApprove && status != PendingApprove {
+		changedCount = 1
+	}
+	if oldStatus != PendingApprove && status == PendingApprove {
+		changedCount = -1
+	}
+	if changedCount != 0 {
+		squad := db.squads[squadId]
+		squad.MembersCount += changedCount
+		squad.PendingApproveCount -= changedCount
+
+		if squadId != ALL_USERS_SQUAD {
+			db.propagateChangedSquadCounters(squadId)
+		}
+	}
+
+	return nil
+}
+
+func (db *MemoryDB) SetSquadMemberNotes(ctx context.Context, userId string, squadId string, notes *map[string]string) error {
+	if db.dev {
+		log.Printf("Updating note for user '%v' in squad '%v': %+v", userId, squadId, notes)
+	}
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	member, err := db.getSquadMember(squadId, userId)
+	if err != nil {
+		log.Printf("Failed to update user notes: %v", err)
+		return err
+	}
+
+	member.Notes = nil
+	if notes != nil {
+		member.Notes = make(map[string]string, len(*notes))
+		for k, v := range *notes {
+			member.Notes[k] = v
+		}
+	}
+
+	return nil
+}
+
+func (db *MemoryDB) CreateReplicant(ctx context.Context, replicantInfo *UserInfo, squadId string) (string, error) {
+	if db.dev {
+		log.Println("Creating replicant " + replicantInfo.DisplayName + " in squad " + squadId)
+	}
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	squadReplicantInfo := &SquadUserInfo{
+		UserInfo:  *replicantInfo,
+		Replicant: true,
+		Status:    Member,
+	}
+
+	replicantId := newDocId()
+	err := db.addMemberRecordToSquad(squadId, replicantId, squadReplicantInfo)
+	if err != nil {
+		log.Printf("Failed to add replicant record to squad: %v", err)
+		return "", err
+	}
+
+	if squadId != ALL_USERS_SQUAD {
+		db.propagateChangedSquadCounters(squadId)
+	}
+
+	return replicantId, nil
+}
+
+func (db *MemoryDB) AddMemberToSquad(ctx context.Context, userId string, squadId string, memberStatus MemberStatusType) (*MemberSquadInfo, error) {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	return db.addMemberToSquad(userId, squadId, memberStatus)
+}
+
+func (db *MemoryDB) addMemberToSquad(userId string, squadId string, memberStatus MemberStatusType) (*MemberSquadInfo, error) {
+	if db.dev {
+		log.Println("Adding user " + userId + " to squad " + squadId)
+	}
+
+	if len(db.memberSquads[userId]) >= 10 {
+		return nil, fmt.Errorf("User might participate in 10 squads maximum")
+	}
+
+	user, err := db.getUser(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	squadUserInfo := &SquadUserInfo{
+		UserInfo: user.UserInfo,
+		Status:   memberStatus,
+	}
+
+	err = db.addMemberRecordToSquad(squadId, userId, squadUserInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	memberSquadInfo := &MemberSquadInfo{
+		SquadInfo: db.squads[squadId].SquadInfo,
+		Status:    memberStatus,
+	}
+
+	if db.memberSquads[userId] == nil {
+		db.memberSquads[userId] = make(map[string]*MemberSquadInfo)
+	}
+	ms := *memberSquadInfo
+	db.memberSquads[userId][squadId] = &ms
+
+	if squadId != ALL_USERS_SQUAD {
+		db.propagateChangedSquadCounters(squadId)
+	}
+
+	return memberSquadInfo, nil
+}
+
+func (db *MemoryDB) addMemberRecordToSquad(squadId string, userId string, userInfo *SquadUserInfo) error {
+	if db.dev {
+		log.Println("Adding member " + userId + " to squad " + squadId)
+	}
+
+	squad, err := db.getSquad(squadId)
+	if err != nil {
+		return fmt.Errorf("Failed to add user "+userId+" to squad "+squadId+": %w", err)
+	}
+	if _, ok := squad.members[userId]; ok {
+		return status.Errorf(codes.AlreadyExists, "User %v is already a member of squad %v", userId, squadId)
+	}
+
+	m := &memMember{SquadUserInfo: *userInfo}
+	m.Tags = copyStrings(userInfo.Tags)
+	m.Timestamp = time.Now().UTC()
+	m.keys = userInfo.Keys()
+	squad.members[userId] = m
+
+	if userInfo.Status == PendingApprove {
+		squad.PendingApproveCount++
+	} else {
+		squad.MembersCount++
+	}
+
+	return nil
+}
+
+// copy squad counters to member_squads records of all squad members
+func (db *MemoryDB) propagateChangedSquadCounters(squadId string) {
+	squad := db.squads[squadId]
+	for userId, m := range squad.members {
+		if m.Replicant {
+			continue
+		}
+		if ms, ok := db.memberSquads[userId][squadId]; ok {
+			ms.MembersCount = squad.MembersCount
+			ms.PendingApproveCount = squad.PendingApproveCount
+		}
+	}
+}
+
+func (db *MemoryDB) DeleteMemberFromSquad(ctx context.Context, userId string, squadId string) error {
+	if db.dev {
+		log.Println("Removing user " + userId + " from squad " + squadId)
+	}
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	member, err := db.getSquadMember(squadId, userId)
+	if err != nil {
+		return err
+	}
+
+	squad := db.squads[squadId]
+	delete(squad.members, userId)
+	if member.Status == PendingApprove {
+		squad.PendingApproveCount--
+	} else {
+		squad.MembersCount--
+	}
+	delete(db.memberSquads[userId], squadId)
+
+	db.propagateChangedSquadCounters(squadId)
+
+	return nil
+}
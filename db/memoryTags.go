@@ -0,0 +1,222 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+func (db *MemoryDB) CreateTag(ctx context.Context, squadId string, tag *Tag) error {
+	if db.dev {
+		log.Printf("Creating tag '%+v' in squad '%v'", tag, squadId)
+	}
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	squad, err := db.getSquad(squadId)
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string]int64, len(tag.Values))
+	for k, v := range tag.Values {
+		values[k] = v
+	}
+	squad.tags[tag.Name] = values
+
+	return nil
+}
+
+func (db *MemoryDB) GetTags(ctx context.Context, squadId string) ([]*Tag, error) {
+	log.Printf("Getting tags for squad '%v'", squadId)
+
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	squad, err := db.getSquad(squadId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get squad tags: %w", err)
+	}
+
+	tags := make([]*Tag, 0, len(squad.tags))
+	for name, v := range squad.tags {
+		values := make(map[string]int64, len(v))
+		for k, c := range v {
+			values[k] = c
+		}
+		tags = append(tags, &Tag{
+			Name:   name,
+			Values: values,
+		})
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+
+	return tags, nil
+}
+
+func (db *MemoryDB) DeleteTag(ctx context.Context, squadId string, tagName string) error {
+	log.Println("Deleting tag " + tagName + " from squad " + squadId)
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	squad, err := db.getSquad(squadId)
+	if err != nil {
+		return fmt.Errorf("Error while deleting tag "+tagName+" from squad "+squadId+": %w", err)
+	}
+	delete(squad.tags, tagName)
+
+	return nil
+}
+
+func (db *MemoryDB) getSquadMemberTags(userId string, squadId string) ([]interface{}, error) {
+	member, err := db.getSquadMember(squadId, userId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get squad "+squadId+" member "+userId+": %w", err)
+	}
+
+	if member.Tags == nil {
+		return nil, nil
+	}
+
+	tags := make([]interface{}, len(member.Tags))
+	for i, t := range member.Tags {
+		tags[i] = t
+	}
+
+	return tags, nil
+}
+
+func (db *MemoryDB) GetSquadMemberTags(ctx context.Context, userId string, squadId string) ([]interface{}, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	return db.getSquadMemberTags(userId, squadId)
+}
+
+// checks that tag counters exist, the same way Firestore fails a batch updating missing document
+func (db *MemoryDB) checkTagCounter(squadId string, tag string) error {
+	if _, ok := db.squads[squadId].tags[tag]; !ok {
+		return notFound("Tag %v does not exist in squad %v", tag, squadId)
+	}
+	return nil
+}
+
+func (db *MemoryDB) updateTagCounter(squadId string, tag string, value string, inc int64) {
+	if value == "" {
+		value = "_"
+	}
+	db.squads[squadId].tags[tag][value] += inc
+}
+
+func (db *MemoryDB) setSquadMemberTags(userId string, squadId string, tags []interface{}) {
+	member := db.squads[squadId].members[userId]
+	member.Tags = make([]string, len(tags))
+	for i, t := range tags {
+		member.Tags[i] = t.(string)
+	}
+}
+
+func (db *MemoryDB) SetSquadMemberTag(ctx context.Context, userId string, squadId string, tagName string, tagValue string) ([]interface{}, error) {
+	tagNew := tagName
+	if tagValue != "" {
+		tagNew = tagName + "/" + tagValue
+	}
+
+	if db.dev {
+		log.Println("Setting tag " + tagName + " to user " + userId + " from squad " + squadId)
+	}
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	tags, err := db.getSquadMemberTags(userId, squadId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tags) >= 10 {
+		return nil, fmt.Errorf("User might have max 10 tags assigned")
+	}
+
+	tagFound := false
+	tagOldValue := ""
+
+	for i, tag := range tags {
+		s := strings.Split(tag.(string), "/")
+		name := s[0]
+		if name == tagName {
+			tagFound = true
+			if len(s) == 2 {
+				tagOldValue = s[1]
+				tags[i] = tagNew
+			}
+			break
+		}
+	}
+
+	if !tagFound {
+		tags = append(tags, tagNew)
+	}
+
+	if !tagFound || tagOldValue != tagValue {
+		if err := db.checkTagCounter(squadId, tagName); err != nil {
+			return nil, err
+		}
+
+		db.setSquadMemberTags(userId, squadId, tags)
+
+		if _, err := db.getUser(userId); err == nil {
+			squadTags := make([]string, len(tags))
+			for i, v := range tags {
+				squadTags[i] = squadId + "/" + v.(string)
+			}
+			db.userTags[userId] = squadTags
+		}
+
+		db.updateTagCounter(squadId, tagName, tagValue, 1)
+		if tagFound {
+			db.updateTagCounter(squadId, tagName, tagOldValue, -1)
+		}
+	}
+
+	return tags, nil
+}
+
+func (db *MemoryDB) DeleteSquadMemberTag(ctx context.Context, userId string, squadId string, tagName string, tagValue string) ([]interface{}, error) {
+	tag := tagName
+	if tagValue != "" {
+		tag = tag + "/" + tagValue
+	}
+	log.Println("Deleting tag " + tag + " from user " + userId + " from squad " + squadId)
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	tags, err := db.getSquadMemberTags(userId, squadId)
+	if err != nil {
+		return nil, err
+	}
+
+	tagFound := false
+	for i, t := range tags {
+		if t == tag {
+			tagFound = true
+			tags = append(tags[:i], tags[i+1:]...)
+			break
+		}
+	}
+
+	if tagFound {
+		if err := db.checkTagCounter(squadId, tagName); err != nil {
+			return nil, err
+		}
+		db.updateTagCounter(squadId, tagName, tagValue, -1)
+	}
+	db.setSquadMemberTags(userId, squadId, tags)
+
+	return tags, nil
+}
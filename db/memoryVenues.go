@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+)
+
+func (db *MemoryDB) CreateVenue(ctx context.Context, squadId string, venue *EventLocation) (string, error) {
+	if venue.Venue == "" {
+		return "", fmt.Errorf("Failed to create venue, name is not provided: %+v", venue)
+	}
+
+	log.Printf("Creating venue '%+v' in squad '%v'", venue, squadId)
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	squad, err := db.getSquad(squadId)
+	if err != nil {
+		return "", fmt.Errorf("Failed to create venue in squad %v: %w", squadId, err)
+	}
+
+	id := newDocId()
+	squad.venues[id] = venue.Copy()
+
+	return id, nil
+}
+
+func (db *MemoryDB) GetVenues(ctx context.Context, squadId string) ([]*VenueRecord, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	squad, err := db.getSquad(squadId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get squad %v venues: %w", squadId, err)
+	}
+
+	venues := make([]*VenueRecord, 0, len(squad.venues))
+	for id, v := range squad.venues {
+		venues = append(venues, &VenueRecord{ID: id, EventLocation: *v.Copy()})
+	}
+	sort.Slice(venues, func(i, j int) bool { return venues[i].Venue < venues[j].Venue })
+
+	return venues, nil
+}
+
+func (db *MemoryDB) GetVenue(ctx context.Context, squadId string, venueId string) (*EventLocation, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	squad, err := db.getSquad(squadId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get venue %v: %w", venueId, err)
+	}
+	v, ok := squad.venues[venueId]
+	if !ok {
+		return nil, notFound("Failed to get venue %v: not found", venueId)
+	}
+
+	return v.Copy(), nil
+}
+
+func (db *MemoryDB) UpdateVenue(ctx context.Context, squadId string, venueId string, venue *EventLocation) error {
+	if venue.Venue == "" {
+		return fmt.Errorf("Failed to update venue, name is not provided: %+v", venue)
+	}
+
+	log.Printf("Updating venue '%v' in squad '%v'", venueId, squadId)
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	squad, err := db.getSquad(squadId)
+	if err != nil {
+		return fmt.Errorf("Failed to update venue %v: %w", venueId, err)
+	}
+	if _, ok := squad.venues[venueId]; !ok {
+		return notFound("Failed to update venue %v: not found", venueId)
+	}
+	squad.venues[venueId] = venue.Copy()
+
+	return nil
+}
+
+func (db *MemoryDB) DeleteVenue(ctx context.Context, squadId string, venueId string) error {
+	log.Println("Deleting venue " + venueId + " from squad " + squadId)
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	squad, err := db.getSquad(squadId)
+	if err != nil {
+		return fmt.Errorf("Error while deleting venue "+venueId+" from squad "+squadId+": %w", err)
+	}
+	delete(squad.venues, venueId)
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+func copyWebhook(h *Webhook) *Webhook {
+	c := *h
+	c.Events = copyStrings(h.Events)
+	return &c
+}
+
+func copyWebhookDelivery(d *WebhookDelivery) *WebhookDelivery {
+	c := *d
+	if d.LastAttempt != nil {
+		t := *d.LastAttempt
+		c.LastAttempt = &t
+	}
+	return &c
+}
+
+func (db *MemoryDB) CreateWebhook(ctx context.Context, squadId string, hook *Webhook) (string, error) {
+	if hook.URL == "" {
+		return "", fmt.Errorf("Failed to create webhook, URL is not provided: %+v", hook)
+	}
+
+	log.Printf("Creating webhook '%v' in squad '%v'", hook.URL, squadId)
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	squad, err := db.getSquad(squadId)
+	if err != nil {
+		return "", fmt.Errorf("Failed to create webhook in squad %v: %w", squadId, err)
+	}
+
+	id := newDocId()
+	squad.webhooks[id] = copyWebhook(hook)
+
+	return id, nil
+}
+
+func (db *MemoryDB) GetWebhooks(ctx context.Context, squadId string) ([]*WebhookRecord, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	squad, err := db.getSquad(squadId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get squad %v webhooks: %w", squadId, err)
+	}
+
+	hooks := make([]*WebhookRecord, 0, len(squad.webhooks))
+	for id, h := range squad.webhooks {
+		hooks = append(hooks, &WebhookRecord{ID: id, Webhook: *copyWebhook(h)})
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].Created.Before(hooks[j].Created) })
+
+	return hooks, nil
+}
+
+func (db *MemoryDB) GetWebhook(ctx context.Context, squadId string, webhookId string) (*Webhook, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	squad, err := db.getSquad(squadId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get webhook %v: %w", webhookId, err)
+	}
+	h, ok := squad.webhooks[webhookId]
+	if !ok {
+		return nil, notFound("Failed to get webhook %v: not found", webhookId)
+	}
+
+	return copyWebhook(h), nil
+}
+
+func (db *MemoryDB) DeleteWebhook(ctx context.Context, squadId string, webhookId string) error {
+	log.Println("Deleting webhook " + webhookId + " from squad " + squadId)
+
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	squad, err := db.getSquad(squadId)
+	if err != nil {
+		return fmt.Errorf("Error while deleting webhook "+webhookId+" from squad "+squadId+": %w", err)
+	}
+	delete(squad.webhooks, webhookId)
+
+	for id, d := range db.webhookDeliveries {
+		if d.WebhookId == webhookId {
+			delete(db.webhookDeliveries, id)
+		}
+	}
+
+	return nil
+}
+
+func (db *MemoryDB) CreateWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) (string, error) {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	id := newDocId()
+	db.webhookDeliveries[id] = copyWebhookDelivery(delivery)
+
+	return id, nil
+}
+
+func (db *MemoryDB) UpdateWebhookDelivery(ctx context.Context, deliveryId string, delivery *WebhookDelivery) error {
+	db.mx.Lock()
+	defer db.mx.Unlock()
+
+	if _, ok := db.webhookDeliveries[deliveryId]; !ok {
+		return notFound("Failed to update webhook delivery %v: not found", deliveryId)
+	}
+	db.webhookDeliveries[deliveryId] = copyWebhookDelivery(delivery)
+
+	return nil
+}
+
+func (db *MemoryDB) GetWebhookDeliveries(ctx context.Context, webhookId string, limit int) ([]*WebhookDeliveryRecord, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	deliveries := make([]*WebhookDeliveryRecord, 0)
+	for id, d := range db.webhookDeliveries {
+		if d.WebhookId == webhookId {
+			deliveries = append(deliveries, &WebhookDeliveryRecord{ID: id, WebhookDelivery: *copyWebhookDelivery(d)})
+		}
+	}
+	sortWebhookDeliveries(deliveries)
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+
+	return deliveries, nil
+}
+
+func (db *MemoryDB) GetDueWebhookDeliveries(ctx context.Context, before time.Time) ([]*WebhookDeliveryRecord, error) {
+	db.mx.RLock()
+	defer db.mx.RUnlock()
+
+	deliveries := make([]*WebhookDeliveryRecord, 0)
+	for id, d := range db.webhookDeliveries {
+		if d.Status == DeliveryPending && !d.NextAttempt.After(before) {
+			deliveries = append(deliveries, &WebhookDeliveryRecord{ID: id, WebhookDelivery: *copyWebhookDelivery(d)})
+		}
+	}
+	sortDueWebhookDeliveries(deliveries)
+
+	return deliveries, nil
+}
@@ -0,0 +1,137 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// NotificationPref is the user choice how to get notifications of the kind from
+// the squad: muted ones are not even put to the inbox, others are delivered only
+// via listed channels
+type NotificationPref struct {
+	SquadId  string   `json:"squadId"`
+	Kind     string   `json:"kind"`
+	Muted    bool     `json:"muted"`
+	Channels []string `json:"channels"`
+}
+
+func (p *NotificationPref) HasChannel(channel string) bool {
+	for _, c := range p.Channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+func sortNotificationPrefs(prefs []*NotificationPref) {
+	sort.Slice(prefs, func(i, j int) bool {
+		if prefs[i].SquadId != prefs[j].SquadId {
+			return prefs[i].SquadId < prefs[j].SquadId
+		}
+		return prefs[i].Kind < prefs[j].Kind
+	})
+}
+
+// preferences of the user are kept in one document together with email digest,
+// Kinds map is keyed by squad and then by kind
+type firestoreNotificationPrefs struct {
+	Kinds map[string]map[string]*NotificationPref
+}
+
+func (db *FirestoreDB) GetNotificationPrefs(ctx context.Context, userId string) ([]*NotificationPref, error) {
+	prefs := make([]*NotificationPref, 0)
+
+	doc, err := db.NotificationPrefs.Doc(userId).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return prefs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get user %v notification preferences: %w", userId, err)
+	}
+
+	var data firestoreNotificationPrefs
+	if err = doc.DataTo(&data); err != nil {
+		return nil, fmt.Errorf("Failed to get user %v notification preferences: %w", userId, err)
+	}
+	for squadId, kinds := range data.Kinds {
+		for kind, pref := range kinds {
+			pref.SquadId = squadId
+			pref.Kind = kind
+			prefs = append(prefs, pref)
+		}
+	}
+	sortNotificationPrefs(prefs)
+
+	return prefs, nil
+}
+
+func (db *FirestoreDB) SetNotificationPref(ctx context.Context, userId string, pref *NotificationPref) error {
+	log.Printf("Setting user %v notification preference %+v", userId, pref)
+
+	value := map[string]interface{}{"Muted": pref.Muted, "Channels": pref.Channels}
+	_, err := db.NotificationPrefs.Doc(userId).Set(ctx, map[string]interface{}{
+		"Kinds": map[string]interface{}{pref.SquadId: map[string]interface{}{pref.Kind: value}},
+	}, firestore.Merge(firestore.FieldPath{"Kinds", pref.SquadId, pref.Kind}))
+	if err != nil {
+		return fmt.Errorf("Failed to set user %v notification preference: %w", userId, err)
+	}
+
+	return nil
+}
+
+func (db *FirestoreDB) DeleteNotificationPref(ctx context.Context, userId string, squadId string, kind string) error {
+	log.Printf("Deleting user %v notification preference for %v in squad %v", userId, kind, squadId)
+
+	_, err := db.NotificationPrefs.Doc(userId).Set(ctx, map[string]interface{}{
+		"Kinds": map[string]interface{}{squadId: map[string]interface{}{kind: firestore.Delete}},
+	}, firestore.Merge(firestore.FieldPath{"Kinds", squadId, kind}))
+	if err != nil {
+		return fmt.Errorf("Failed to delete user %v notification preference: %w", userId, err)
+	}
+
+	return nil
+}
+
+func (db *FirestoreDB) GetUsersNotificationPrefs(ctx context.Context, userIds []string, squadId string, kind string) (map[string]*NotificationPref, error) {
+	prefs := make(map[string]*NotificationPref)
+
+	for from := 0; from < len(userIds); from += notificationsBatchSize {
+		to := from + notificationsBatchSize
+		if to > len(userIds) {
+			to = len(userIds)
+		}
+
+		refs := make([]*firestore.DocumentRef, 0, to-from)
+		for _, userId := range userIds[from:to] {
+			refs = append(refs, db.NotificationPrefs.Doc(userId))
+		}
+		docs, err := db.Client.GetAll(ctx, refs)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get users notification preferences: %w", err)
+		}
+
+		for _, doc := range docs {
+			if !doc.Exists() {
+				continue
+			}
+			var data firestoreNotificationPrefs
+			if err = doc.DataTo(&data); err != nil {
+				return nil, fmt.Errorf("Failed to get users notification preferences: %w", err)
+			}
+			if pref := data.Kinds[squadId][kind]; pref != nil {
+				pref.SquadId = squadId
+				pref.Kind = kind
+				prefs[doc.Ref.ID] = pref
+			}
+		}
+	}
+
+	return prefs, nil
+}
@@ -0,0 +1,205 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// types of objects notifications could link to
+const (
+	LinkSquad   = "squad"
+	LinkEvent   = "event"
+	LinkRequest = "request"
+)
+
+// kinds of notifications, email subject and body templates are chosen by kind
+const (
+	KindNewEvent         = "newEvent"
+	KindEventChange      = "eventChange"
+	KindEventReminder    = "eventReminder"
+	KindParticipation    = "participation"
+	KindRequestApprove   = "requestApprove"
+	KindRequestHandle    = "requestHandle"
+	KindRequestCompleted = "requestCompleted"
+	KindRequestUpdate    = "requestUpdate"
+	KindMembership       = "membership"
+)
+
+// kinds of notifications users could mute or choose channels for per squad
+var PrefKinds = map[string]bool{
+	KindNewEvent:         true,
+	KindEventChange:      true,
+	KindRequestApprove:   true,
+	KindRequestHandle:    true,
+	KindRequestCompleted: true,
+	KindMembership:       true,
+}
+
+// NotificationLink points to the squad, event or request the notification is about
+type NotificationLink struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// Notification is kept in the user inbox until retention period passes
+type Notification struct {
+	UserId string            `json:"-"`
+	Kind   string            `json:"kind,omitempty"`
+	Time   time.Time         `json:"time"`
+	Title  string            `json:"title"`
+	Text   string            `json:"text"`
+	Read   bool              `json:"read"`
+	Link   *NotificationLink `json:"link,omitempty"`
+}
+
+type NotificationRecord struct {
+	ID string `json:"id"`
+	Notification
+}
+
+// notifications are deleted in batches of this size
+const notificationsBatchSize = 400
+
+func (db *FirestoreDB) CreateNotification(ctx context.Context, userIds []string, n *Notification) error {
+	for from := 0; from < len(userIds); from += notificationsBatchSize {
+		to := from + notificationsBatchSize
+		if to > len(userIds) {
+			to = len(userIds)
+		}
+
+		batch := db.Client.Batch()
+		for _, userId := range userIds[from:to] {
+			un := *n
+			un.UserId = userId
+			un.Read = false
+			batch.Create(db.Notifications.NewDoc(), &un)
+		}
+		if _, err := batch.Commit(ctx); err != nil {
+			return fmt.Errorf("Failed to create notification '%v': %w", n.Title, err)
+		}
+	}
+
+	return nil
+}
+
+func (db *FirestoreDB) GetNotifications(ctx context.Context, userId string, before *time.Time, unreadOnly bool) ([]*NotificationRecord, error) {
+	query := db.Notifications.Where("UserId", "==", userId)
+	if unreadOnly {
+		query = query.Where("Read", "==", false)
+	}
+	query = query.OrderBy("Time", firestore.Desc)
+	if before != nil {
+		query = query.StartAfter(*before)
+	}
+
+	notifications := make([]*NotificationRecord, 0)
+	iter := query.Limit(numRecords).Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get user %v notifications: %w", userId, err)
+		}
+
+		n := &NotificationRecord{ID: doc.Ref.ID}
+		if err = doc.DataTo(&n.Notification); err != nil {
+			return nil, fmt.Errorf("Failed to get user %v notifications: %w", userId, err)
+		}
+		notifications = append(notifications, n)
+	}
+
+	return notifications, nil
+}
+
+func (db *FirestoreDB) GetUnreadNotificationsCount(ctx context.Context, userId string) (int, error) {
+	docs, err := db.Notifications.Where("UserId", "==", userId).Where("Read", "==", false).Select().Documents(ctx).GetAll()
+	if err != nil {
+		return 0, fmt.Errorf("Failed to count user %v notifications: %w", userId, err)
+	}
+
+	return len(docs), nil
+}
+
+func (db *FirestoreDB) MarkNotificationRead(ctx context.Context, userId string, notificationId string) error {
+	doc := db.Notifications.Doc(notificationId)
+	err := db.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		snapshot, err := tx.Get(doc)
+		if err != nil {
+			return err
+		}
+		if owner, _ := snapshot.DataAt("UserId"); owner != userId {
+			return status.Errorf(codes.NotFound, "Notification %v not found", notificationId)
+		}
+		return tx.Update(doc, []firestore.Update{{Path: "Read", Value: true}})
+	})
+	if status.Code(err) == codes.NotFound {
+		return notFound("Failed to mark notification %v read: not found", notificationId)
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to mark notification %v read: %w", notificationId, err)
+	}
+
+	return nil
+}
+
+func (db *FirestoreDB) MarkNotificationsRead(ctx context.Context, userId string) error {
+	docs, err := db.Notifications.Where("UserId", "==", userId).Where("Read", "==", false).Select().Documents(ctx).GetAll()
+	if err != nil {
+		return fmt.Errorf("Failed to mark user %v notifications read: %w", userId, err)
+	}
+
+	for from := 0; from < len(docs); from += notificationsBatchSize {
+		to := from + notificationsBatchSize
+		if to > len(docs) {
+			to = len(docs)
+		}
+
+		batch := db.Client.Batch()
+		for _, doc := range docs[from:to] {
+			batch.Update(doc.Ref, []firestore.Update{{Path: "Read", Value: true}})
+		}
+		if _, err = batch.Commit(ctx); err != nil {
+			return fmt.Errorf("Failed to mark user %v notifications read: %w", userId, err)
+		}
+	}
+
+	return nil
+}
+
+func (db *FirestoreDB) DeleteNotificationsBefore(ctx context.Context, before time.Time) (int, error) {
+	deleted := 0
+	for {
+		docs, err := db.Notifications.Where("Time", "<", before).Select().Limit(notificationsBatchSize).Documents(ctx).GetAll()
+		if err != nil {
+			return deleted, fmt.Errorf("Failed to delete old notifications: %w", err)
+		}
+		if len(docs) == 0 {
+			break
+		}
+
+		batch := db.Client.Batch()
+		for _, doc := range docs {
+			batch.Delete(doc.Ref)
+		}
+		if _, err = batch.Commit(ctx); err != nil {
+			return deleted, fmt.Errorf("Failed to delete old notifications: %w", err)
+		}
+		deleted += len(docs)
+	}
+
+	if deleted > 0 {
+		log.Printf("Deleted %v notifications older than %v", deleted, before)
+	}
+
+	return deleted, nil
+}
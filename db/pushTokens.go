@@ -0,0 +1,102 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// PushToken is FCM registration token of one of the user devices, token is
+// bound to the user who signed in on the device last
+type PushToken struct {
+	Token    string    `json:"token"`
+	UserId   string    `json:"userId"`
+	Device   string    `json:"device"`
+	Created  time.Time `json:"created"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// devices used recently go first
+func sortPushTokens(tokens []*PushToken) {
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].LastSeen.After(tokens[j].LastSeen) })
+}
+
+func (db *FirestoreDB) SavePushToken(ctx context.Context, token *PushToken) error {
+	if token.Token == "" {
+		return fmt.Errorf("Failed to save push token of user %v: token is empty", token.UserId)
+	}
+
+	doc := db.PushTokens.Doc(token.Token)
+	err := db.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		t := *token
+		t.Created = t.LastSeen
+		snapshot, err := tx.Get(doc)
+		if err == nil {
+			if created, err := snapshot.DataAt("Created"); err == nil {
+				if c, ok := created.(time.Time); ok {
+					t.Created = c
+				}
+			}
+		} else if status.Code(err) != codes.NotFound {
+			return err
+		}
+		return tx.Set(doc, &t)
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to save push token of user %v: %w", token.UserId, err)
+	}
+
+	return nil
+}
+
+func (db *FirestoreDB) GetPushTokens(ctx context.Context, userId string) ([]*PushToken, error) {
+	docs, err := db.PushTokens.Where("UserId", "==", userId).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get user %v push tokens: %w", userId, err)
+	}
+
+	tokens := make([]*PushToken, 0, len(docs))
+	for _, doc := range docs {
+		t := &PushToken{}
+		if err = doc.DataTo(t); err != nil {
+			return nil, fmt.Errorf("Failed to get user %v push tokens: %w", userId, err)
+		}
+		tokens = append(tokens, t)
+	}
+	sortPushTokens(tokens)
+
+	return tokens, nil
+}
+
+func (db *FirestoreDB) DeletePushTokens(ctx context.Context, userId string, tokens []string) error {
+	log.Printf("Deleting %v push tokens of user %v", len(tokens), userId)
+
+	for _, token := range tokens {
+		doc := db.PushTokens.Doc(token)
+		err := db.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+			snapshot, err := tx.Get(doc)
+			if status.Code(err) == codes.NotFound {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			// token might be taken by another user signed in on the same device
+			if owner, _ := snapshot.DataAt("UserId"); owner != userId {
+				return nil
+			}
+			return tx.Delete(doc)
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to delete user %v push tokens: %w", userId, err)
+		}
+	}
+
+	return nil
+}
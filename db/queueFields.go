@@ -0,0 +1,233 @@
+package db
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type QueueFieldType string
+
+const (
+	FieldText        QueueFieldType = "text"
+	FieldNumber      QueueFieldType = "number"
+	FieldDate        QueueFieldType = "date"
+	FieldSelect      QueueFieldType = "select"
+	FieldMultiSelect QueueFieldType = "multiselect"
+)
+
+// dates are kept as strings in this format, so they compare in chronological order
+const FieldDateLayout = "2006-01-02"
+
+// QueueField describes a value requester should provide when filing a request
+type QueueField struct {
+	Name     string         `json:"name"`
+	Label    string         `json:"label,omitempty"`
+	Type     QueueFieldType `json:"type"`
+	Required bool           `json:"required,omitempty"`
+	// allowed values of select and multiselect fields
+	Options []string `json:"options,omitempty"`
+}
+
+// RequestsFilter narrows lists of requests down to a queue and values of its fields
+type RequestsFilter struct {
+	QueueId string
+	Fields  map[string]interface{}
+}
+
+// field names are used as document paths and query parameters
+var fieldNameRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+func (qi *QueueInfo) GetField(name string) *QueueField {
+	for i := range qi.Fields {
+		if qi.Fields[i].Name == name {
+			return &qi.Fields[i]
+		}
+	}
+	return nil
+}
+
+// ValidateFields checks fields schema defined for the queue
+func (qi *QueueInfo) ValidateFields() error {
+	names := make(map[string]bool, len(qi.Fields))
+	for _, f := range qi.Fields {
+		if !fieldNameRe.MatchString(f.Name) {
+			return fmt.Errorf("Field name '%v' should start with a letter and contain only letters, digits and underscores", f.Name)
+		}
+		if names[f.Name] {
+			return fmt.Errorf("Field %v is defined more than once", f.Name)
+		}
+		names[f.Name] = true
+
+		switch f.Type {
+		case FieldText, FieldNumber, FieldDate:
+		case FieldSelect, FieldMultiSelect:
+			if len(f.Options) == 0 {
+				return fmt.Errorf("Field %v should have at least one option", f.Name)
+			}
+		default:
+			return fmt.Errorf("Field %v has unknown type '%v'", f.Name, f.Type)
+		}
+	}
+
+	return nil
+}
+
+// ParseFields validates values submitted with a request against the queue
+// schema and converts them to typed values: text, date and select fields
+// become strings, numbers become float64 and multiselect fields []string
+func (qi *QueueInfo) ParseFields(values map[string]interface{}) (map[string]interface{}, error) {
+	for name := range values {
+		if qi.GetField(name) == nil {
+			return nil, fmt.Errorf("Queue does not have field %v", name)
+		}
+	}
+
+	fields := make(map[string]interface{}, len(qi.Fields))
+	for _, f := range qi.Fields {
+		v, err := f.parse(values[f.Name])
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			if f.Required {
+				return nil, fmt.Errorf("Field %v is required", f.Name)
+			}
+			continue
+		}
+		fields[f.Name] = v
+	}
+
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return fields, nil
+}
+
+// ParseFilter converts value of a field from the query string, filter by
+// multiselect field is a list with one option the field should contain
+func (qi *QueueInfo) ParseFilter(name string, value string) (interface{}, error) {
+	f := qi.GetField(name)
+	if f == nil {
+		return nil, fmt.Errorf("Queue does not have field %v", name)
+	}
+
+	var v interface{} = value
+	switch f.Type {
+	case FieldMultiSelect:
+		v = []interface{}{value}
+	case FieldNumber:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Field %v should be a number", name)
+		}
+		v = n
+	}
+
+	v, err := f.parse(v)
+	if err == nil && v == nil {
+		err = fmt.Errorf("Field %v value should not be empty", name)
+	}
+	return v, err
+}
+
+// returns nil if value is not provided
+func (f *QueueField) parse(value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	switch f.Type {
+	case FieldNumber:
+		n, ok := value.(float64)
+		if !ok {
+			return nil, fmt.Errorf("Field %v should be a number", f.Name)
+		}
+		return n, nil
+	case FieldMultiSelect:
+		list, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Field %v should be a list of options", f.Name)
+		}
+		options := make([]string, 0, len(list))
+		for _, o := range list {
+			s, ok := o.(string)
+			if !ok || !containsString(f.Options, s) {
+				return nil, fmt.Errorf("Field %v has unknown option %v", f.Name, o)
+			}
+			if !containsString(options, s) {
+				options = append(options, s)
+			}
+		}
+		if len(options) == 0 {
+			return nil, nil
+		}
+		return options, nil
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("Field %v should be a string", f.Name)
+	}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	switch f.Type {
+	case FieldDate:
+		t, err := time.Parse(FieldDateLayout, s)
+		if err != nil {
+			return nil, fmt.Errorf("Field %v should be a date in YYYY-MM-DD format", f.Name)
+		}
+		s = t.Format(FieldDateLayout)
+	case FieldSelect:
+		if !containsString(f.Options, s) {
+			return nil, fmt.Errorf("Field %v has unknown option %v", f.Name, s)
+		}
+	}
+
+	return s, nil
+}
+
+// fieldStrings returns string representation of a typed field value, every
+// option of multiselect fields is a separate value
+func fieldStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}
+	case int64:
+		return []string{strconv.FormatInt(v, 10)}
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		strs := make([]string, 0, len(v))
+		for _, o := range v {
+			strs = append(strs, fieldStrings(o)...)
+		}
+		return strs
+	}
+	return []string{fmt.Sprint(value)}
+}
+
+// Matches tells if request passes the filter
+func (f *RequestsFilter) Matches(r *RequestDetails) bool {
+	if f == nil {
+		return true
+	}
+	if f.QueueId != "" && r.QueueId != f.QueueId {
+		return false
+	}
+	for name, value := range f.Fields {
+		if !containsString(fieldStrings(r.Fields[name]), fieldStrings(value)[0]) {
+			return false
+		}
+	}
+	return true
+}
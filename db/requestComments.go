@@ -0,0 +1,95 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const COMMENTS = "comments"
+
+type RequestComment struct {
+	UserId   string     `json:"userId"`
+	UserName string     `json:"userName"`
+	Text     string     `json:"text"`
+	Time     *time.Time `json:"time"`
+}
+
+type RequestCommentRecord struct {
+	ID string `json:"id"`
+	RequestComment
+}
+
+func (db *FirestoreDB) CreateRequestComment(ctx context.Context, requestId string, comment *RequestComment) (string, error) {
+	if db.dev {
+		log.Printf("Creating comment %+v for request %v\n", comment, requestId)
+	}
+
+	doc, _, err := db.Requests.Doc(requestId).Collection(COMMENTS).Add(ctx, map[string]interface{}{
+		"UserId":   comment.UserId,
+		"UserName": comment.UserName,
+		"Text":     comment.Text,
+		"Time":     firestore.ServerTimestamp,
+	})
+	if err != nil {
+		return "", fmt.Errorf("Failed to create comment for request %v: %w", requestId, err)
+	}
+
+	return doc.ID, nil
+}
+
+func (db *FirestoreDB) GetRequestComments(ctx context.Context, requestId string) ([]*RequestCommentRecord, error) {
+	comments := make([]*RequestCommentRecord, 0)
+
+	iter := db.Requests.Doc(requestId).Collection(COMMENTS).OrderBy("Time", firestore.Asc).Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get request %v comments: %w", requestId, err)
+		}
+
+		c := &RequestCommentRecord{ID: doc.Ref.ID}
+		if err = doc.DataTo(&c.RequestComment); err != nil {
+			return nil, fmt.Errorf("Failed to get request %v comments: %w", requestId, err)
+		}
+		comments = append(comments, c)
+	}
+
+	return comments, nil
+}
+
+func (db *FirestoreDB) GetRequestComment(ctx context.Context, requestId string, commentId string) (*RequestComment, error) {
+	doc, err := db.Requests.Doc(requestId).Collection(COMMENTS).Doc(commentId).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, notFound("Failed to get comment %v: not found", commentId)
+		}
+		return nil, fmt.Errorf("Failed to get comment %v: %w", commentId, err)
+	}
+
+	c := &RequestComment{}
+	if err = doc.DataTo(c); err != nil {
+		return nil, fmt.Errorf("Failed to get comment %v: %w", commentId, err)
+	}
+
+	return c, nil
+}
+
+func (db *FirestoreDB) DeleteRequestComment(ctx context.Context, requestId string, commentId string) error {
+	_, err := db.Requests.Doc(requestId).Collection(COMMENTS).Doc(commentId).Delete(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to delete comment %v: %w", commentId, err)
+	}
+
+	return nil
+}
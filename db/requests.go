@@ -10,6 +10,8 @@ import (
 
 	"cloud.google.com/go/firestore"
 	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type QueueInfo struct {
@@ -18,6 +20,36 @@ type QueueInfo struct {
 	Handlers       string `json:"handlers"`
 	WaitingApprove int    `json:"waitingApprove"`
 	Processing     int    `json:"processing"`
+	// nil means the default workflow
+	Workflow *Workflow `json:"workflow,omitempty"`
+	SLA      *QueueSLA `json:"sla,omitempty"`
+	// fields requester fills in addition to request details
+	Fields []QueueField `json:"fields,omitempty"`
+}
+
+// QueueSLA defines how long requests might stay waiting for approve and being
+// processed before they are escalated to squad admins, zero means no limit
+type QueueSLA struct {
+	ApproveMinutes int `json:"approveMinutes"`
+	ProcessMinutes int `json:"processMinutes"`
+}
+
+// Limit returns time allowed for requests with given status, zero if not limited
+func (sla *QueueSLA) Limit(status RequestStatusType) time.Duration {
+	if sla == nil {
+		return 0
+	}
+	switch status {
+	case WaitingApprove:
+		return time.Duration(sla.ApproveMinutes) * time.Minute
+	case Processing:
+		return time.Duration(sla.ProcessMinutes) * time.Minute
+	}
+	return 0
+}
+
+func (sla *QueueSLA) enabled() bool {
+	return sla != nil && (sla.ApproveMinutes > 0 || sla.ProcessMinutes > 0)
 }
 
 type QueueRecord struct {
@@ -26,6 +58,7 @@ type QueueRecord struct {
 }
 
 const REQUESTS = "requests"
+const HISTORY = "history"
 
 type RequestStatusType int
 
@@ -69,15 +102,46 @@ func RequestStatusFromString(s string) RequestStatusType {
 type RequestDetails struct {
 	Details  string            `json:"details"`
 	Status   RequestStatusType `json:"status"`
+	State    string            `json:"state"`
 	QueueId  string            `json:"queueId"`
 	Time     *time.Time        `json:"time"`
 	UserId   string            `json:"userId"`
 	UserName string            `json:"userName"`
+	// typed values of queue fields, see QueueInfo.ParseFields
+	Fields map[string]interface{} `json:"fields,omitempty"`
+
+	AssigneeId   string `json:"assigneeId"`
+	AssigneeName string `json:"assigneeName"`
+	// time request got its current status, queue SLA is checked against it
+	StatusTime *time.Time `json:"statusTime"`
+	Escalated  bool       `json:"escalated"`
+}
+
+// IsOverdue returns true if request stays in its status longer than queue SLA allows
+func (r *RequestDetails) IsOverdue(sla *QueueSLA, now time.Time) bool {
+	limit := sla.Limit(r.Status)
+	return limit > 0 && r.StatusTime != nil && now.Sub(*r.StatusTime) > limit
+}
+
+// RequestHistoryEntry records a change of the request state, the first entry
+// is added when request is created and has empty OldState
+type RequestHistoryEntry struct {
+	UserId    string            `json:"userId"`
+	UserName  string            `json:"userName"`
+	OldState  string            `json:"oldState"`
+	NewState  string            `json:"newState"`
+	OldStatus RequestStatusType `json:"oldStatus"`
+	NewStatus RequestStatusType `json:"newStatus"`
+	Comment   string            `json:"comment,omitempty"`
+	Time      *time.Time        `json:"time"`
 }
 
 type RequestRecord struct {
 	RequestId string `json:"requestId"`
 	RequestDetails
+	// transitions current user might perform and SLA flag, filled by the app
+	Actions []*WorkflowTransition `json:"actions,omitempty" firestore:"-"`
+	Overdue bool                  `json:"overdue,omitempty" firestore:"-"`
 }
 
 func (db *FirestoreDB) CreateRequestsQueue(ctx context.Context, queueId string, qi *QueueInfo) (err error) {
@@ -103,15 +167,73 @@ func (db *FirestoreDB) CreateRequestsQueue(ctx context.Context, queueId string,
 			{Path: "HandlersPath", Value: handlers},
 		})
 	}
+	if qi.SLA.enabled() {
+		batch.Update(queueRef, []firestore.Update{
+			{Path: "HasSLA", Value: true},
+		})
+	}
 
 	_, err = batch.Commit(ctx)
 	if err != nil {
 		return err
 	}
 
+	if qi.SLA.enabled() {
+		return db.backfillRequestsSLA(ctx, queueId)
+	}
+
 	return err
 }
 
+// backfillRequestsSLA sets StatusTime and Escalated of queue requests created
+// before these fields were introduced, Firestore does not return documents
+// missing fields used in the query, so otherwise they are never escalated
+func (db *FirestoreDB) backfillRequestsSLA(ctx context.Context, queueId string) error {
+	docs, err := db.Requests.Where("QueueId", "==", queueId).Documents(ctx).GetAll()
+	if err != nil {
+		return fmt.Errorf("Failed to get queue %v requests: %w", queueId, err)
+	}
+
+	refs := make([]*firestore.DocumentRef, 0)
+	times := make([]interface{}, 0)
+	for _, doc := range docs {
+		data := doc.Data()
+		_, hasEscalated := data["Escalated"]
+		_, hasStatusTime := data["StatusTime"]
+		if hasEscalated && hasStatusTime {
+			continue
+		}
+
+		// time of the last status change is not known, creation time is the best guess
+		var statusTime interface{} = firestore.ServerTimestamp
+		if t, ok := data["Time"].(time.Time); ok {
+			statusTime = t
+		}
+		refs = append(refs, doc.Ref)
+		times = append(times, statusTime)
+	}
+
+	for from := 0; from < len(refs); from += notificationsBatchSize {
+		to := from + notificationsBatchSize
+		if to > len(refs) {
+			to = len(refs)
+		}
+
+		batch := db.Client.Batch()
+		for i := from; i < to; i++ {
+			batch.Update(refs[i], []firestore.Update{
+				{Path: "StatusTime", Value: times[i]},
+				{Path: "Escalated", Value: false},
+			})
+		}
+		if _, err := batch.Commit(ctx); err != nil {
+			return fmt.Errorf("Failed to backfill queue %v requests: %w", queueId, err)
+		}
+	}
+
+	return nil
+}
+
 func (db *FirestoreDB) GetRequest(ctx context.Context, requestId string) (request *RequestDetails, err error) {
 	doc, err := db.Requests.Doc(requestId).Get(ctx)
 	if err != nil {
@@ -435,11 +557,18 @@ func (db *FirestoreDB) CreateRequest(ctx context.Context, request *RequestDetail
 
 	batch.Set(newRequestDoc, request)
 	batch.Set(newRequestDoc, map[string]interface{}{
-		"Time": firestore.ServerTimestamp,
+		"Time":       firestore.ServerTimestamp,
+		"StatusTime": firestore.ServerTimestamp,
 	}, firestore.MergeAll)
 	batch.Update(queueDoc, []firestore.Update{
 		{Path: request.Status.String(), Value: firestore.Increment(1)},
 	})
+	db.addRequestHistory(batch, newRequestDoc, &RequestHistoryEntry{
+		UserId:    request.UserId,
+		UserName:  request.UserName,
+		NewState:  request.CurrentState(),
+		NewStatus: request.Status,
+	})
 
 	_, err = batch.Commit(ctx)
 	if err != nil {
@@ -449,9 +578,29 @@ func (db *FirestoreDB) CreateRequest(ctx context.Context, request *RequestDetail
 	return newRequestDoc.ID, nil
 }
 
-func (db *FirestoreDB) GetUserRequests(ctx context.Context, userId string, from *time.Time) (requests []RequestRecord, err error) {
+// adds conditions of the filter to the query, every combination of filtered
+// fields needs a composite index
+func addRequestsFilter(query firestore.Query, filter *RequestsFilter) firestore.Query {
+	if filter == nil {
+		return query
+	}
+	if filter.QueueId != "" {
+		query = query.Where("QueueId", "==", filter.QueueId)
+	}
+	for name, value := range filter.Fields {
+		// multiselect fields are stored as arrays of options
+		if options, ok := value.([]string); ok {
+			query = query.Where("Fields."+name, "array-contains", options[0])
+		} else {
+			query = query.Where("Fields."+name, "==", value)
+		}
+	}
+	return query
+}
+
+func (db *FirestoreDB) GetUserRequests(ctx context.Context, userId string, from *time.Time, filter *RequestsFilter) (requests []RequestRecord, err error) {
 
-	query := db.Requests.Where("UserId", "==", userId).OrderBy("Time", firestore.Desc)
+	query := addRequestsFilter(db.Requests.Where("UserId", "==", userId), filter).OrderBy("Time", firestore.Desc)
 	if from != nil {
 		query = query.StartAfter(from)
 	}
@@ -463,7 +612,7 @@ func (db *FirestoreDB) GetUserRequests(ctx context.Context, userId string, from
 	return requests, nil
 }
 
-func (db *FirestoreDB) GetRequestsByTag(ctx context.Context, tags []string, squadsAdmin []string, status RequestStatusType, from *time.Time) (requests []RequestRecord, err error) {
+func (db *FirestoreDB) GetRequestsByTag(ctx context.Context, tags []string, squadsAdmin []string, status RequestStatusType, from *time.Time, filter *RequestsFilter) (requests []RequestRecord, err error) {
 
 	if db.dev {
 		log.Printf("Getting requests %v by %v, from %v", status, tags, from)
@@ -480,7 +629,15 @@ func (db *FirestoreDB) GetRequestsByTag(ctx context.Context, tags []string, squa
 		queues[i] = k
 		i++
 	}
-	query := db.Requests.Where("QueueId", "in", queues).Where("Status", "==", status).OrderBy("Time", firestore.Desc)
+	query := db.Requests.Where("Status", "==", status)
+	if filter != nil && filter.QueueId != "" {
+		if _, ok := queuesMap[filter.QueueId]; !ok {
+			return make([]RequestRecord, 0), nil
+		}
+	} else {
+		query = query.Where("QueueId", "in", queues)
+	}
+	query = addRequestsFilter(query, filter).OrderBy("Time", firestore.Desc)
 
 	if from != nil {
 		query = query.StartAfter(from)
@@ -495,34 +652,163 @@ func (db *FirestoreDB) GetRequestsByTag(ctx context.Context, tags []string, squa
 	return requests, nil
 }
 
-func (db *FirestoreDB) SetRequestStatus(ctx context.Context, requestId string, status RequestStatusType) error {
-	request, err := db.GetRequest(ctx, requestId)
-	if err != nil {
-		return err
-	}
+func (db *FirestoreDB) addRequestHistory(batch *firestore.WriteBatch, docRequest *firestore.DocumentRef, entry *RequestHistoryEntry) {
+	docEntry := docRequest.Collection(HISTORY).NewDoc()
+	batch.Set(docEntry, entry)
+	batch.Set(docEntry, map[string]interface{}{
+		"Time": firestore.ServerTimestamp,
+	}, firestore.MergeAll)
+}
 
-	if request.Status != status {
-		batch := db.Client.Batch()
-		docRequest := db.Requests.Doc(requestId)
-		batch.Update(docRequest, []firestore.Update{{Path: "Status", Value: status}})
+// SetRequestState moves request to the new state and appends entry to the
+// request history, but only if request is still in fromState, so that two
+// concurrent transitions can not both be applied
+func (db *FirestoreDB) SetRequestState(ctx context.Context, requestId string, fromState string, state string, newStatus RequestStatusType, entry *RequestHistoryEntry) error {
+	docRequest := db.Requests.Doc(requestId)
+
+	err := db.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(docRequest)
 		if err != nil {
-			return fmt.Errorf("Failed to set request "+requestId+" status: %w", err)
+			return err
+		}
+
+		request := &RequestDetails{}
+		if err = doc.DataTo(request); err != nil {
+			return err
+		}
+		if request.CurrentState() != fromState {
+			return stateChanged(requestId)
 		}
 
-		docQueue := db.RequestQueues.Doc(request.QueueId)
+		updates := []firestore.Update{
+			{Path: "State", Value: state},
+			{Path: "Status", Value: newStatus},
+		}
+		if request.Status != newStatus {
+			updates = append(updates,
+				firestore.Update{Path: "StatusTime", Value: firestore.ServerTimestamp},
+				firestore.Update{Path: "Escalated", Value: false})
+		}
+		if err = tx.Update(docRequest, updates); err != nil {
+			return err
+		}
+
+		e := *entry
+		e.OldState, e.OldStatus = request.CurrentState(), request.Status
+		e.NewState, e.NewStatus = state, newStatus
+		docEntry := docRequest.Collection(HISTORY).NewDoc()
+		if err = tx.Set(docEntry, &e); err != nil {
+			return err
+		}
+		if err = tx.Set(docEntry, map[string]interface{}{"Time": firestore.ServerTimestamp}, firestore.MergeAll); err != nil {
+			return err
+		}
+
+		if request.Status == newStatus {
+			return nil
+		}
 
-		batch.Update(docQueue, []firestore.Update{
+		return tx.Update(db.RequestQueues.Doc(request.QueueId), []firestore.Update{
 			{Path: request.Status.String(), Value: firestore.Increment(-1)},
+			{Path: newStatus.String(), Value: firestore.Increment(1)},
 		})
-		batch.Update(docQueue, []firestore.Update{
-			{Path: status.String(), Value: firestore.Increment(1)},
-		})
+	})
+	if err != nil {
+		if status.Code(err) == codes.FailedPrecondition {
+			return err
+		}
+		return fmt.Errorf("Failed to set request "+requestId+" status: %w", err)
+	}
+
+	return nil
+}
 
-		_, err = batch.Commit(ctx)
+func (db *FirestoreDB) GetRequestHistory(ctx context.Context, requestId string) ([]*RequestHistoryEntry, error) {
+	history := make([]*RequestHistoryEntry, 0)
+
+	iter := db.Requests.Doc(requestId).Collection(HISTORY).OrderBy("Time", firestore.Asc).Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
 		if err != nil {
-			return fmt.Errorf("Failed to set request "+requestId+" status: %w", err)
+			return nil, fmt.Errorf("Failed to get request %v history: %w", requestId, err)
+		}
+
+		entry := &RequestHistoryEntry{}
+		if err = doc.DataTo(entry); err != nil {
+			return nil, fmt.Errorf("Failed to get request %v history: %w", requestId, err)
 		}
+		history = append(history, entry)
+	}
+
+	return history, nil
+}
+
+// SetRequestAssignee assigns request to assigneeId (or unassigns it if empty),
+// but only if request is still assigned to fromAssigneeId, so that two
+// handlers can not claim the same request
+func (db *FirestoreDB) SetRequestAssignee(ctx context.Context, requestId string, fromAssigneeId string, assigneeId string, assigneeName string) error {
+	docRequest := db.Requests.Doc(requestId)
+
+	err := db.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(docRequest)
+		if err != nil {
+			return err
+		}
+
+		request := &RequestDetails{}
+		if err = doc.DataTo(request); err != nil {
+			return err
+		}
+		if request.AssigneeId != fromAssigneeId {
+			return assigneeChanged(requestId, request.AssigneeName)
+		}
+
+		return tx.Update(docRequest, []firestore.Update{
+			{Path: "AssigneeId", Value: assigneeId},
+			{Path: "AssigneeName", Value: assigneeName},
+		})
+	})
+	if err != nil {
+		if status.Code(err) == codes.FailedPrecondition {
+			return err
+		}
+		return fmt.Errorf("Failed to set request %v assignee: %w", requestId, err)
 	}
 
 	return nil
 }
+
+func (db *FirestoreDB) GetQueuesWithSLA(ctx context.Context) ([]*QueueRecord, error) {
+	return db.getQueuesFromQuery(ctx, db.RequestQueues.Where("HasSLA", "==", true))
+}
+
+// GetOverdueRequests returns requests from the queue having given status since
+// before the given time and not escalated yet
+func (db *FirestoreDB) GetOverdueRequests(ctx context.Context, queueId string, status RequestStatusType, before time.Time) ([]RequestRecord, error) {
+	return db.getRequestsFromQuery(ctx, db.Requests.Where("QueueId", "==", queueId).Where("Status", "==", status).
+		Where("Escalated", "==", false).Where("StatusTime", "<", before).OrderBy("StatusTime", firestore.Asc))
+}
+
+func (db *FirestoreDB) SetRequestEscalated(ctx context.Context, requestId string) error {
+	_, err := db.Requests.Doc(requestId).Update(ctx, []firestore.Update{{Path: "Escalated", Value: true}})
+	if err != nil {
+		return fmt.Errorf("Failed to mark request %v escalated: %w", requestId, err)
+	}
+
+	return nil
+}
+
+func stateChanged(requestId string) error {
+	return status.Errorf(codes.FailedPrecondition, "Request %v has already been moved to other state", requestId)
+}
+
+func assigneeChanged(requestId string, assigneeName string) error {
+	if assigneeName == "" {
+		return status.Errorf(codes.FailedPrecondition, "Request %v is not assigned anymore", requestId)
+	}
+	return status.Errorf(codes.FailedPrecondition, "Request %v is already assigned to %v", requestId, assigneeName)
+}
@@ -0,0 +1,155 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RRule is a subset of RFC 5545 recurrence rule supported for event series:
+// FREQ=DAILY|WEEKLY|MONTHLY, INTERVAL, BYDAY (weekly only), COUNT and UNTIL
+type RRule struct {
+	Freq     string
+	Interval int
+	ByDay    []time.Weekday
+	Count    int
+	Until    *time.Time
+}
+
+var rruleWeekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+func ParseRRule(s string) (*RRule, error) {
+	r := &RRule{Interval: 1}
+
+	for _, part := range strings.Split(strings.TrimPrefix(strings.TrimSpace(s), "RRULE:"), ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("Invalid recurrence rule part '%v'", part)
+		}
+		name, value := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+
+		switch name {
+		case "FREQ":
+			if value != "DAILY" && value != "WEEKLY" && value != "MONTHLY" {
+				return nil, fmt.Errorf("Recurrence frequency %v is not supported", value)
+			}
+			r.Freq = value
+		case "INTERVAL":
+			i, err := strconv.Atoi(value)
+			if err != nil || i < 1 {
+				return nil, fmt.Errorf("Invalid recurrence interval %v", value)
+			}
+			r.Interval = i
+		case "COUNT":
+			c, err := strconv.Atoi(value)
+			if err != nil || c < 1 {
+				return nil, fmt.Errorf("Invalid recurrence count %v", value)
+			}
+			r.Count = c
+		case "UNTIL":
+			// date or UTC date-time
+			t, err := time.Parse("20060102", value)
+			if err != nil {
+				t, err = time.Parse("20060102T150405Z", value)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("Invalid recurrence end date %v", value)
+			}
+			t = startOfDay(t)
+			r.Until = &t
+		case "BYDAY":
+			for _, d := range strings.Split(value, ",") {
+				wd, ok := rruleWeekdays[d]
+				if !ok {
+					return nil, fmt.Errorf("Invalid recurrence week day %v", d)
+				}
+				r.ByDay = append(r.ByDay, wd)
+			}
+		default:
+			return nil, fmt.Errorf("Recurrence rule part %v is not supported", name)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, fmt.Errorf("Recurrence rule should define frequency")
+	}
+	if r.Count > 0 && r.Until != nil {
+		return nil, fmt.Errorf("Recurrence rule should not define both count and end date")
+	}
+	if len(r.ByDay) > 0 && r.Freq != "WEEKLY" {
+		return nil, fmt.Errorf("Recurrence week days are supported only with weekly frequency")
+	}
+
+	return r, nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// Occurrences returns days of occurrences starting on start day which fall
+// into [from, to) and are not excluded by exDates (YYYY-MM-DD); like in RFC
+// 5545 excluded dates are still counted by COUNT
+func (r *RRule) Occurrences(start time.Time, exDates []string, from time.Time, to time.Time) []time.Time {
+	start, from, to = startOfDay(start), startOfDay(from), startOfDay(to)
+	if r.Until != nil && r.Until.Before(to) {
+		to = r.Until.AddDate(0, 0, 1)
+	}
+
+	byDay := r.ByDay
+	if len(byDay) == 0 {
+		byDay = []time.Weekday{start.Weekday()}
+	}
+	// days of the week counted from Monday
+	offsets := make([]int, len(byDay))
+	for i, wd := range byDay {
+		offsets[i] = (int(wd) + 6) % 7
+	}
+	sort.Ints(offsets)
+	weekStart := start.AddDate(0, 0, -(int(start.Weekday())+6)%7)
+
+	dates := make([]time.Time, 0)
+	count := 0
+	for period := 0; ; period++ {
+		var candidates []time.Time
+		switch r.Freq {
+		case "DAILY":
+			candidates = []time.Time{start.AddDate(0, 0, period*r.Interval)}
+		case "WEEKLY":
+			week := weekStart.AddDate(0, 0, 7*period*r.Interval)
+			for _, o := range offsets {
+				candidates = append(candidates, week.AddDate(0, 0, o))
+			}
+		case "MONTHLY":
+			// months without such day are skipped
+			d := time.Date(start.Year(), start.Month()+time.Month(period*r.Interval), start.Day(), 0, 0, 0, 0, time.UTC)
+			if d.Day() == start.Day() {
+				candidates = []time.Time{d}
+			}
+		}
+
+		for _, d := range candidates {
+			if d.Before(start) {
+				continue
+			}
+			if !d.Before(to) || (r.Count > 0 && count >= r.Count) {
+				return dates
+			}
+			count++
+			if !d.Before(from) && !containsString(exDates, d.Format(FieldDateLayout)) {
+				dates = append(dates, d)
+			}
+		}
+	}
+}
@@ -0,0 +1,461 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SQLDB implements Store on top of PostgreSQL or SQLite. Instead of the
+// member_squads and participant_events copies kept by FirestoreDB, users'
+// squads and events are selected with joins, counters are updated in the same
+// transaction as the records they count.
+type SQLDB struct {
+	dev    bool
+	driver string
+	DB     *sql.DB
+}
+
+// common part of *sql.DB and *sql.Tx, so helpers work both inside and outside transactions
+type sqlQueryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// NewSQLDB connects to database using one of registered drivers (postgres or
+// sqlite3) and applies pending migrations.
+func NewSQLDB(driver string, dsn string, dev bool) (*SQLDB, error) {
+	if driver != "postgres" && driver != "sqlite3" {
+		return nil, fmt.Errorf("Unsupported SQL driver %v", driver)
+	}
+
+	conn, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("Could not open database: %w", err)
+	}
+
+	if driver == "sqlite3" {
+		// sqlite allows single writer only, and in-memory database lives as long as its connection
+		conn.SetMaxOpenConns(1)
+		conn.SetMaxIdleConns(1)
+	}
+
+	ctx := context.Background()
+	if err = conn.PingContext(ctx); err != nil {
+		return nil, fmt.Errorf("Could not connect to database: %w", err)
+	}
+
+	db := &SQLDB{
+		dev:    dev,
+		driver: driver,
+		DB:     conn,
+	}
+
+	if driver == "sqlite3" {
+		if _, err = conn.ExecContext(ctx, "PRAGMA foreign_keys = ON"); err != nil {
+			return nil, fmt.Errorf("Failed to enable foreign keys: %w", err)
+		}
+	}
+
+	if err = db.migrate(ctx); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// queries are written with ? placeholders, postgres expects $1, $2, ...
+func (db *SQLDB) rebind(query string) string {
+	if db.driver != "postgres" {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+		} else {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+func (db *SQLDB) exec(ctx context.Context, q sqlQueryer, query string, args ...interface{}) (sql.Result, error) {
+	return q.ExecContext(ctx, db.rebind(query), args...)
+}
+
+func (db *SQLDB) query(ctx context.Context, q sqlQueryer, query string, args ...interface{}) (*sql.Rows, error) {
+	return q.QueryContext(ctx, db.rebind(query), args...)
+}
+
+func (db *SQLDB) queryRow(ctx context.Context, q sqlQueryer, query string, args ...interface{}) *sql.Row {
+	return q.QueryRowContext(ctx, db.rebind(query), args...)
+}
+
+// runs f in transaction, commits if it succeeds and rolls back otherwise
+func (db *SQLDB) inTx(ctx context.Context, f func(tx *sql.Tx) error) error {
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("Failed to start transaction: %w", err)
+	}
+
+	if err = f(tx); err != nil {
+		if errRollback := tx.Rollback(); errRollback != nil {
+			log.Printf("Failed to rollback transaction: %v", errRollback)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// checks that update or delete changed something, returns NotFound otherwise
+func checkAffected(res sql.Result, format string, a ...interface{}) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return status.Errorf(codes.NotFound, format, a...)
+	}
+	return nil
+}
+
+func sqlNotFound(err error, format string, a ...interface{}) error {
+	if err == sql.ErrNoRows {
+		return status.Errorf(codes.NotFound, format, a...)
+	}
+	return err
+}
+
+// returns "?, ?, ?" and list of arguments for IN clause
+func inArgs(values []string) (string, []interface{}) {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", "), args
+}
+
+// search keys are stored as space separated string with leading and trailing spaces
+func sqlKeys(keys []string) string {
+	return " " + strings.Join(keys, " ") + " "
+}
+
+// builds where conditions with the same semantics as FirestoreDB.AddFilterWhere;
+// tagExists is a condition with single placeholder for the tag being searched
+func sqlFilterWhere(filter *map[string]string, keysColumn string, statusColumn string, tagExists string, statusFromStringFunc func(string) int) (string, []interface{}) {
+	where := ""
+	args := make([]interface{}, 0)
+	if filter == nil {
+		return where, args
+	}
+	f := *filter
+
+	if f["Keys"] != "" {
+		conds := make([]string, 0)
+		for _, k := range strings.Fields(strings.ToLower(f["Keys"])) {
+			conds = append(conds, keysColumn+" LIKE ?")
+			args = append(args, "% "+k+" %")
+		}
+		where += " AND (" + strings.Join(conds, " OR ") + ")"
+	}
+
+	if f["Tag"] != "" && tagExists != "" {
+		where += " AND " + tagExists
+		args = append(args, f["Tag"])
+	}
+
+	if f["Status"] != "" && statusFromStringFunc != nil {
+		if s := statusFromStringFunc(f["Status"]); s != -1 {
+			where += " AND " + statusColumn + " = ?"
+			args = append(args, s)
+		}
+	}
+
+	return where, args
+}
+
+func utcTime(t time.Time) *time.Time {
+	t = t.UTC()
+	return &t
+}
+
+const sqlMemberColumns = "m.user_id, m.display_name, m.email, m.phone_number, m.replicant, m.status, m.notes, m.created_at"
+
+func scanMember(row interface{ Scan(...interface{}) error }) (*SquadUserInfoRecord, error) {
+	r := &SquadUserInfoRecord{}
+	var notes string
+	var timestamp time.Time
+	err := row.Scan(&r.ID, &r.DisplayName, &r.Email, &r.PhoneNumber, &r.Replicant, &r.Status, &notes, &timestamp)
+	if err != nil {
+		return nil, err
+	}
+	r.Timestamp = timestamp.UTC()
+	if notes != "" {
+		if err = json.Unmarshal([]byte(notes), &r.Notes); err != nil {
+			return nil, fmt.Errorf("Failed to parse member %v notes: %w", r.ID, err)
+		}
+	}
+	return r, nil
+}
+
+// returns tags of the given squad members, in the order they were assigned
+func (db *SQLDB) getMembersTags(ctx context.Context, q sqlQueryer, squadId string, userIds []string) (map[string][]string, error) {
+	tags := make(map[string][]string, len(userIds))
+	if len(userIds) == 0 {
+		return tags, nil
+	}
+
+	in, args := inArgs(userIds)
+	rows, err := db.query(ctx, q, "SELECT user_id, tag FROM squad_member_tags WHERE squad_id = ? AND user_id IN ("+in+") ORDER BY position", append([]interface{}{squadId}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get squad %v members tags: %w", squadId, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userId, tag string
+		if err = rows.Scan(&userId, &tag); err != nil {
+			return nil, fmt.Errorf("Failed to get squad %v members tags: %w", squadId, err)
+		}
+		tags[userId] = append(tags[userId], tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// fills tags of the given squad members
+func (db *SQLDB) loadMemberTags(ctx context.Context, q sqlQueryer, squadId string, members []*SquadUserInfoRecord) error {
+	ids := make([]string, len(members))
+	for i, m := range members {
+		ids[i] = m.ID
+	}
+
+	tags, err := db.getMembersTags(ctx, q, squadId, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range members {
+		m.Tags = tags[m.ID]
+	}
+
+	return nil
+}
+
+func (db *SQLDB) getSquadMember(ctx context.Context, q sqlQueryer, squadId string, userId string) (*SquadUserInfoRecord, error) {
+	row := db.queryRow(ctx, q, "SELECT "+sqlMemberColumns+" FROM squad_members m WHERE m.squad_id = ? AND m.user_id = ?", squadId, userId)
+	m, err := scanMember(row)
+	if err != nil {
+		return nil, sqlNotFound(err, "Failed to get squad %v member %v: not found", squadId, userId)
+	}
+
+	err = db.loadMemberTags(ctx, q, squadId, []*SquadUserInfoRecord{m})
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (db *SQLDB) GetUserInfo(ctx context.Context, userId string) (*UserInfo, error) {
+	user, err := db.getSquadMember(ctx, db.DB, ALL_USERS_SQUAD, userId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get user "+userId+": %w", err)
+	}
+
+	return &user.UserInfo, nil
+}
+
+func (db *SQLDB) GetUserData(ctx context.Context, userId string) (*UserData, error) {
+	user, err := db.getSquadMember(ctx, db.DB, ALL_USERS_SQUAD, userId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get user "+userId+": %w", err)
+	}
+
+	ud := &UserData{
+		UID:      userId,
+		UserInfo: user.UserInfo,
+		Status:   user.Status,
+		UserTags: make([]string, 0),
+		Admin:    user.Status == Admin,
+	}
+
+	// user tags are prefixed with squad id, that is how queues reference approvers and handlers
+	rows, err := db.query(ctx, db.DB, "SELECT squad_id, tag FROM squad_member_tags WHERE user_id = ? AND squad_id <> ? ORDER BY squad_id, position", userId, ALL_USERS_SQUAD)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get user "+userId+" tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var squadId, tag string
+		if err = rows.Scan(&squadId, &tag); err != nil {
+			return nil, fmt.Errorf("Failed to get user "+userId+" tags: %w", err)
+		}
+		ud.UserTags = append(ud.UserTags, squadId+"/"+tag)
+	}
+
+	return ud, rows.Err()
+}
+
+func (db *SQLDB) GetUserByName(ctx context.Context, userName string) ([]string, error) {
+	rows, err := db.query(ctx, db.DB, "SELECT user_id FROM squad_members WHERE squad_id = ? AND display_name = ? ORDER BY user_id", ALL_USERS_SQUAD, userName)
+	if err != nil {
+		log.Printf("Error while quering users by name: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanStrings(rows)
+}
+
+func scanStrings(rows *sql.Rows) ([]string, error) {
+	list := make([]string, 0)
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
+
+func (db *SQLDB) CreateUser(ctx context.Context, userId string, userInfo *UserInfo, status MemberStatusType) error {
+	sui := &SquadUserInfo{
+		UserInfo: *userInfo,
+		Status:   status,
+	}
+
+	err := db.inTx(ctx, func(tx *sql.Tx) error {
+		return db.addMemberRecordToSquad(ctx, tx, ALL_USERS_SQUAD, userId, sui)
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to add user "+userId+": %w", err)
+	}
+
+	return nil
+}
+
+var sqlUserInfoColumns = map[string]string{
+	"DisplayName": "display_name",
+	"Email":       "email",
+	"PhoneNumber": "phone_number",
+}
+
+func (db *SQLDB) UpdateUser(ctx context.Context, userId string, field string, val interface{}) error {
+	ui, err := db.GetUserInfo(ctx, userId)
+	if err != nil {
+		return fmt.Errorf("Failed to update user "+userId+": %w", err)
+	}
+
+	err = setUserInfoField(ui, field, val)
+	if err != nil {
+		return fmt.Errorf("Failed to update user "+userId+": %w", err)
+	}
+
+	// user info is stored in every squad user is member of
+	_, err = db.exec(ctx, db.DB, "UPDATE squad_members SET "+sqlUserInfoColumns[field]+" = ?, search_keys = ? WHERE user_id = ? AND replicant = ?", val, sqlKeys(ui.Keys()), userId, false)
+	if err != nil {
+		return fmt.Errorf("Failed to update user "+userId+": %w", err)
+	}
+
+	return nil
+}
+
+func (db *SQLDB) UpdateUserInfoFromAuth(ctx context.Context, userId string, userInfo *UserInfo) error {
+	userData, err := db.GetUserData(ctx, userId)
+	if err != nil {
+		log.Println("Failed to get user " + userId + " from DB, adding new record to users collection")
+
+		err = db.CreateUser(ctx, userId, userInfo, PendingApprove)
+		if err != nil {
+			return fmt.Errorf("Failed to add user to database: %w", err)
+		}
+	} else {
+		if len(userInfo.Email) > 0 && userData.Email != userInfo.Email {
+			db.UpdateUser(ctx, userId, "Email", userInfo.Email)
+		}
+		if len(userInfo.PhoneNumber) > 0 && userData.PhoneNumber != userInfo.PhoneNumber {
+			db.UpdateUser(ctx, userId, "PhoneNumber", userInfo.PhoneNumber)
+		}
+	}
+
+	return nil
+}
+
+func (db *SQLDB) GetSquadsCount(ctx context.Context, userId string) (interface{}, error) {
+	squadsCount := make([]int, len(MemberStatusTypes))
+
+	rows, err := db.query(ctx, db.DB, "SELECT status, COUNT(*) FROM squad_members WHERE user_id = ? AND squad_id <> ? GROUP BY status", userId, ALL_USERS_SQUAD)
+	if err != nil {
+		log.Printf("Error while getting user squads: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var status, count int
+		if err = rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		squadsCount[status] = count
+	}
+
+	return squadsCount, rows.Err()
+}
+
+func (db *SQLDB) GetSquadsWithPendingRequests(ctx context.Context, userId string, admin bool) (interface{}, error) {
+	squadsWithRequests := make([]*squadCount, 0)
+
+	query := "SELECT s.id, s.pending_approve_count FROM squads s JOIN squad_members m ON m.squad_id = s.id" +
+		" WHERE m.user_id = ? AND m.status IN (?, ?) AND s.pending_approve_count <> 0 AND s.id <> ?" +
+		" ORDER BY s.pending_approve_count DESC"
+	rows, err := db.query(ctx, db.DB, query, userId, Admin, Owner, ALL_USERS_SQUAD)
+	if err != nil {
+		log.Printf("Failed to get squads with members pending approve: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		sc := &squadCount{}
+		if err = rows.Scan(&sc.Squad, &sc.Count); err != nil {
+			return nil, err
+		}
+		squadsWithRequests = append(squadsWithRequests, sc)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if admin {
+		sc := &squadCount{Squad: ALL_USERS_SQUAD}
+		err = db.queryRow(ctx, db.DB, "SELECT pending_approve_count FROM squads WHERE id = ?", ALL_USERS_SQUAD).Scan(&sc.Count)
+		if err != nil {
+			log.Printf("Failed to get counters for ALL_USERS_SQUAD: %v", err)
+			return nil, err
+		}
+		if sc.Count > 0 {
+			squadsWithRequests = append([]*squadCount{sc}, squadsWithRequests...)
+		}
+	}
+
+	return squadsWithRequests, nil
+}
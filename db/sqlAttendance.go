@@ -0,0 +1,49 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func (db *SQLDB) GetSquadAttendance(ctx context.Context, squadId string, from time.Time, to time.Time) ([]*AttendanceRecord, error) {
+	rows, err := db.query(ctx, db.DB, "SELECT e.id, e.date, p.user_id, COALESCE(m.display_name, ''), COALESCE(m.replicant, ?), p.status"+
+		" FROM events e JOIN event_participants p ON p.event_id = e.id"+
+		" LEFT JOIN squad_members m ON m.squad_id = e.squad_id AND m.user_id = p.user_id"+
+		" WHERE e.squad_id = ? AND e.archived = ? AND e.date >= ? AND e.date < ? ORDER BY e.date, p.user_id",
+		false, squadId, true, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get squad %v attendance: %w", squadId, err)
+	}
+	defer rows.Close()
+
+	records := make([]*AttendanceRecord, 0)
+	ids := make([]string, 0)
+	seen := make(map[string]bool)
+	for rows.Next() {
+		r := &AttendanceRecord{}
+		if err = rows.Scan(&r.EventId, &r.Date, &r.UserId, &r.DisplayName, &r.Replicant, &r.Status); err != nil {
+			return nil, fmt.Errorf("Failed to get squad %v attendance: %w", squadId, err)
+		}
+		r.Date = r.Date.UTC()
+		records = append(records, r)
+		if !seen[r.UserId] {
+			seen[r.UserId] = true
+			ids = append(ids, r.UserId)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to get squad %v attendance: %w", squadId, err)
+	}
+	rows.Close()
+
+	tags, err := db.getMembersTags(ctx, db.DB, squadId, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		r.Tags = tags[r.UserId]
+	}
+
+	return records, nil
+}
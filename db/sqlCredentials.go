@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (db *SQLDB) CreateCredentials(ctx context.Context, c *Credentials) error {
+	if db.dev {
+		log.Printf("Creating credentials for user %v (%v)", c.UserId, c.Email)
+	}
+
+	res, err := db.exec(ctx, db.DB, "INSERT INTO credentials (email, user_id, password_hash, email_verified) VALUES (?, ?, ?, ?) ON CONFLICT DO NOTHING",
+		c.Email, c.UserId, c.PasswordHash, c.EmailVerified)
+	if err != nil {
+		return fmt.Errorf("Failed to create credentials for %v: %w", c.Email, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return status.Errorf(codes.AlreadyExists, "User with email %v already exists", c.Email)
+	}
+
+	return nil
+}
+
+func (db *SQLDB) getCredentials(ctx context.Context, column string, value string) (*Credentials, error) {
+	c := &Credentials{}
+	err := db.queryRow(ctx, db.DB, "SELECT email, user_id, password_hash, email_verified FROM credentials WHERE "+column+" = ?", value).
+		Scan(&c.Email, &c.UserId, &c.PasswordHash, &c.EmailVerified)
+	if err == sql.ErrNoRows {
+		return nil, status.Errorf(codes.NotFound, "Credentials for %v not found", value)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get credentials for %v: %w", value, err)
+	}
+
+	return c, nil
+}
+
+func (db *SQLDB) GetCredentials(ctx context.Context, email string) (*Credentials, error) {
+	return db.getCredentials(ctx, "email", email)
+}
+
+func (db *SQLDB) GetCredentialsByUserId(ctx context.Context, userId string) (*Credentials, error) {
+	return db.getCredentials(ctx, "user_id", userId)
+}
+
+func (db *SQLDB) SetEmailVerified(ctx context.Context, email string) error {
+	res, err := db.exec(ctx, db.DB, "UPDATE credentials SET email_verified = ? WHERE email = ?", true, email)
+	if err == nil {
+		err = checkAffected(res, "Credentials for %v not found", email)
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to mark email %v verified: %w", email, err)
+	}
+
+	return nil
+}
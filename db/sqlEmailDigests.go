@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+func (db *SQLDB) GetEmailDigest(ctx context.Context, userId string) (string, error) {
+	var digest string
+	err := db.queryRow(ctx, db.DB, "SELECT email_digest FROM notification_prefs WHERE user_id = ?", userId).Scan(&digest)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("Failed to get user %v email digest: %w", userId, err)
+	}
+
+	if digest == "" {
+		return DigestImmediate, nil
+	}
+	return digest, nil
+}
+
+func (db *SQLDB) SetEmailDigest(ctx context.Context, userId string, digest string) error {
+	_, err := db.exec(ctx, db.DB, "INSERT INTO notification_prefs (user_id, email_digest) VALUES (?, ?) ON CONFLICT (user_id) DO UPDATE SET email_digest = excluded.email_digest",
+		userId, digest)
+	if err != nil {
+		return fmt.Errorf("Failed to set user %v email digest: %w", userId, err)
+	}
+
+	return nil
+}
+
+func (db *SQLDB) CreateDigestItem(ctx context.Context, item *DigestItem) error {
+	var linkType, linkId string
+	if item.Link != nil {
+		linkType, linkId = item.Link.Type, item.Link.ID
+	}
+
+	_, err := db.exec(ctx, db.DB, "INSERT INTO digest_items (id, user_id, digest, kind, time, title, text, link_type, link_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		newDocId(), item.UserId, item.Digest, item.Kind, item.Time.UTC(), item.Title, item.Text, linkType, linkId)
+	if err != nil {
+		return fmt.Errorf("Failed to add notification '%v' to user %v digest: %w", item.Title, item.UserId, err)
+	}
+
+	return nil
+}
+
+func (db *SQLDB) GetDigestItems(ctx context.Context, digest string) ([]*DigestItemRecord, error) {
+	rows, err := db.query(ctx, db.DB, "SELECT id, user_id, kind, time, title, text, link_type, link_id FROM digest_items WHERE digest = ? ORDER BY user_id, time", digest)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get %v digest notifications: %w", digest, err)
+	}
+	defer rows.Close()
+
+	items := make([]*DigestItemRecord, 0)
+	for rows.Next() {
+		item := &DigestItemRecord{DigestItem: DigestItem{Digest: digest}}
+		var t time.Time
+		var linkType, linkId string
+		if err = rows.Scan(&item.ID, &item.UserId, &item.Kind, &t, &item.Title, &item.Text, &linkType, &linkId); err != nil {
+			return nil, fmt.Errorf("Failed to get %v digest notifications: %w", digest, err)
+		}
+		item.Time = *utcTime(t)
+		if linkType != "" {
+			item.Link = &NotificationLink{Type: linkType, ID: linkId}
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+func (db *SQLDB) DeleteDigestItems(ctx context.Context, ids []string) error {
+	return db.inTx(ctx, func(tx *sql.Tx) error {
+		for _, id := range ids {
+			if _, err := db.exec(ctx, tx, "DELETE FROM digest_items WHERE id = ?", id); err != nil {
+				return fmt.Errorf("Failed to delete digest notifications: %w", err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func (db *SQLDB) GetUpcomingEvents(ctx context.Context, from time.Time, to time.Time) ([]*EventRecord, error) {
+	rows, err := db.query(ctx, db.DB, "SELECT "+sqlEventColumns+" FROM events e WHERE e.archived = ? AND e.date >= ? AND e.date < ? ORDER BY e.date", false, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get upcoming events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]*EventRecord, 0)
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get upcoming events: %w", err)
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+func (db *SQLDB) GetParticipantIds(ctx context.Context, eventId string, status ParticipantStatusType) ([]string, error) {
+	rows, err := db.query(ctx, db.DB, "SELECT p.user_id FROM event_participants p JOIN events e ON e.id = p.event_id"+
+		" LEFT JOIN squad_members m ON m.squad_id = e.squad_id AND m.user_id = p.user_id"+
+		" WHERE p.event_id = ? AND p.status = ? AND COALESCE(m.replicant, ?) = ? ORDER BY p.user_id", eventId, status, false, false)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get event %v participants: %w", eventId, err)
+	}
+	defer rows.Close()
+
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("Failed to get event %v participants: %w", eventId, err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+func (db *SQLDB) MarkEventReminderSent(ctx context.Context, eventId string, remindAt time.Time) (bool, error) {
+	res, err := db.exec(ctx, db.DB, "INSERT INTO event_reminders (event_id, remind_at, sent_at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING",
+		eventId, remindAt.UTC(), time.Now().UTC())
+	if err != nil {
+		return false, fmt.Errorf("Failed to mark event %v reminder sent: %w", eventId, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("Failed to mark event %v reminder sent: %w", eventId, err)
+	}
+
+	return n > 0, nil
+}
@@ -0,0 +1,194 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+const sqlEventSeriesColumns = "id, squad_id, owner_id, text, time_from, time_to, start, rrule, ex_dates, participants, cancelled, generated_until, capacity, reminders, location"
+
+func scanEventSeries(row interface{ Scan(...interface{}) error }) (*EventSeriesRecord, error) {
+	s := &EventSeriesRecord{}
+	var start time.Time
+	var generatedUntil sql.NullTime
+	var exDates, participants, reminders, location string
+	err := row.Scan(&s.ID, &s.SquadId, &s.OwnerId, &s.Text, &s.TimeFrom, &s.TimeTo, &start, &s.RRule, &exDates, &participants, &s.Cancelled, &generatedUntil, &s.Capacity, &reminders, &location)
+	if err != nil {
+		return nil, err
+	}
+	if s.Reminders, err = unmarshalReminders(reminders); err != nil {
+		return nil, err
+	}
+	if s.Location, err = unmarshalLocation(location); err != nil {
+		return nil, err
+	}
+	s.Start = utcTime(start)
+	if generatedUntil.Valid {
+		s.GeneratedUntil = utcTime(generatedUntil.Time)
+	}
+	if exDates != "" {
+		if err = json.Unmarshal([]byte(exDates), &s.ExDates); err != nil {
+			return nil, fmt.Errorf("Failed to unmarshal event series exception dates: %w", err)
+		}
+	}
+	if participants != "" {
+		if err = json.Unmarshal([]byte(participants), &s.Participants); err != nil {
+			return nil, fmt.Errorf("Failed to unmarshal event series participants: %w", err)
+		}
+	}
+	return s, nil
+}
+
+func (db *SQLDB) saveEventSeries(ctx context.Context, seriesId string, series *EventSeries) error {
+	var exDates, participants string
+	if len(series.ExDates) > 0 {
+		b, err := json.Marshal(series.ExDates)
+		if err != nil {
+			return fmt.Errorf("Failed to marshal event series %v exception dates: %w", seriesId, err)
+		}
+		exDates = string(b)
+	}
+	if len(series.Participants) > 0 {
+		b, err := json.Marshal(series.Participants)
+		if err != nil {
+			return fmt.Errorf("Failed to marshal event series %v participants: %w", seriesId, err)
+		}
+		participants = string(b)
+	}
+
+	reminders, err := marshalReminders(series.Reminders)
+	if err != nil {
+		return err
+	}
+	location, err := marshalLocation(series.Location)
+	if err != nil {
+		return err
+	}
+
+	var generatedUntil interface{}
+	if series.GeneratedUntil != nil {
+		generatedUntil = series.GeneratedUntil.UTC()
+	}
+
+	_, err = db.exec(ctx, db.DB, "INSERT INTO event_series ("+sqlEventSeriesColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"+
+		" ON CONFLICT (id) DO UPDATE SET squad_id = excluded.squad_id, owner_id = excluded.owner_id, text = excluded.text, time_from = excluded.time_from, time_to = excluded.time_to,"+
+		" start = excluded.start, rrule = excluded.rrule, ex_dates = excluded.ex_dates, participants = excluded.participants, cancelled = excluded.cancelled, generated_until = excluded.generated_until, capacity = excluded.capacity, reminders = excluded.reminders, location = excluded.location",
+		seriesId, series.SquadId, series.OwnerId, series.Text, series.TimeFrom, series.TimeTo, series.Start.UTC(), series.RRule, exDates, participants, series.Cancelled, generatedUntil, series.Capacity, reminders, location)
+	if err != nil {
+		return fmt.Errorf("Failed to save event series %v: %w", seriesId, err)
+	}
+
+	return nil
+}
+
+func (db *SQLDB) CreateEventSeries(ctx context.Context, series *EventSeries) (string, error) {
+	if db.dev {
+		log.Printf("Creating event series '%+v'", series)
+	}
+
+	id := newDocId()
+	if err := db.saveEventSeries(ctx, id, series); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (db *SQLDB) GetEventSeries(ctx context.Context, seriesId string) (*EventSeries, error) {
+	s, err := scanEventSeries(db.queryRow(ctx, db.DB, "SELECT "+sqlEventSeriesColumns+" FROM event_series WHERE id = ?", seriesId))
+	if err != nil {
+		return nil, sqlNotFound(err, "Failed to get event series %v: not found", seriesId)
+	}
+
+	return &s.EventSeries, nil
+}
+
+func (db *SQLDB) UpdateEventSeries(ctx context.Context, seriesId string, series *EventSeries) error {
+	if db.dev {
+		log.Printf("Updating event series %v: %+v", seriesId, series)
+	}
+
+	return db.saveEventSeries(ctx, seriesId, series)
+}
+
+func (db *SQLDB) GetActiveEventSeries(ctx context.Context) ([]*EventSeriesRecord, error) {
+	rows, err := db.query(ctx, db.DB, "SELECT "+sqlEventSeriesColumns+" FROM event_series WHERE cancelled = ?", false)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get event series: %w", err)
+	}
+	defer rows.Close()
+
+	series := make([]*EventSeriesRecord, 0)
+	for rows.Next() {
+		s, err := scanEventSeries(rows)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get event series: %w", err)
+		}
+		series = append(series, s)
+	}
+
+	return series, rows.Err()
+}
+
+func (db *SQLDB) GetSeriesEvents(ctx context.Context, seriesId string) ([]*EventRecord, error) {
+	rows, err := db.query(ctx, db.DB, "SELECT "+sqlEventColumns+" FROM events e WHERE e.series_id = ? AND e.archived = ? ORDER BY e.date", seriesId, false)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get events of series %v: %w", seriesId, err)
+	}
+	defer rows.Close()
+
+	events := make([]*EventRecord, 0)
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get events of series %v: %w", seriesId, err)
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+func (db *SQLDB) UpdateEvent(ctx context.Context, eventId string, event *EventInfo) error {
+	if db.dev {
+		log.Printf("Updating event %v: %+v", eventId, event)
+	}
+
+	reminders, err := marshalReminders(event.Reminders)
+	if err != nil {
+		return err
+	}
+	location, err := marshalLocation(event.Location)
+	if err != nil {
+		return err
+	}
+
+	return db.inTx(ctx, func(tx *sql.Tx) error {
+		var old time.Time
+		err = db.queryRow(ctx, tx, "SELECT date FROM events WHERE id = ?", eventId).Scan(&old)
+		if err != nil {
+			return fmt.Errorf("Failed to update event %v: %w", eventId, sqlNotFound(err, "Event %v not found", eventId))
+		}
+
+		if event.Date == nil || startOfDay(*event.Date) == startOfDay(old.UTC()) {
+			event.Date = utcTime(old)
+		} else {
+			date, err := db.uniqueEventDate(ctx, tx, event.Date)
+			if err != nil {
+				return fmt.Errorf("Failed to update event %v: %w", eventId, err)
+			}
+			event.Date = &date
+		}
+
+		_, err = db.exec(ctx, tx, "UPDATE events SET date = ?, time_from = ?, time_to = ?, text = ?, series_override = ?, capacity = ?, reminders = ?, location = ? WHERE id = ?",
+			*event.Date, event.TimeFrom, event.TimeTo, event.Text, event.SeriesOverride, event.Capacity, reminders, location, eventId)
+		if err != nil {
+			return fmt.Errorf("Failed to update event %v: %w", eventId, err)
+		}
+		return nil
+	})
+}
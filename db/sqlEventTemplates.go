@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+const sqlEventTemplateColumns = "id, name, text, time_from, time_to, capacity, reminders, location, tags"
+
+func scanEventTemplate(row interface{ Scan(...interface{}) error }, t *EventTemplateRecord) error {
+	var reminders, location, tags string
+	err := row.Scan(&t.ID, &t.Name, &t.Text, &t.TimeFrom, &t.TimeTo, &t.Capacity, &reminders, &location, &tags)
+	if err != nil {
+		return err
+	}
+	if t.Reminders, err = unmarshalReminders(reminders); err != nil {
+		return err
+	}
+	if t.Location, err = unmarshalLocation(location); err != nil {
+		return err
+	}
+	if tags != "" {
+		if err = json.Unmarshal([]byte(tags), &t.Tags); err != nil {
+			return fmt.Errorf("Failed to unmarshal event template tags: %w", err)
+		}
+	}
+	return nil
+}
+
+// marshalEventTemplate returns JSON encoded reminders, location and tags of the template
+func marshalEventTemplate(template *EventTemplate) (string, string, string, error) {
+	reminders, err := marshalReminders(template.Reminders)
+	if err != nil {
+		return "", "", "", err
+	}
+	location, err := marshalLocation(template.Location)
+	if err != nil {
+		return "", "", "", err
+	}
+	var tags string
+	if len(template.Tags) > 0 {
+		b, err := json.Marshal(template.Tags)
+		if err != nil {
+			return "", "", "", fmt.Errorf("Failed to marshal event template tags: %w", err)
+		}
+		tags = string(b)
+	}
+	return reminders, location, tags, nil
+}
+
+func (db *SQLDB) CreateEventTemplate(ctx context.Context, squadId string, template *EventTemplate) (string, error) {
+	if template.Name == "" {
+		return "", fmt.Errorf("Failed to create event template, name is not provided: %+v", template)
+	}
+
+	log.Printf("Creating event template '%v' in squad '%v'", template.Name, squadId)
+
+	reminders, location, tags, err := marshalEventTemplate(template)
+	if err != nil {
+		return "", err
+	}
+
+	id := newDocId()
+	_, err = db.exec(ctx, db.DB, "INSERT INTO squad_event_templates (id, squad_id, name, text, time_from, time_to, capacity, reminders, location, tags) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		id, squadId, template.Name, template.Text, template.TimeFrom, template.TimeTo, template.Capacity, reminders, location, tags)
+	if err != nil {
+		return "", fmt.Errorf("Failed to create event template in squad %v: %w", squadId, err)
+	}
+
+	return id, nil
+}
+
+func (db *SQLDB) GetEventTemplates(ctx context.Context, squadId string) ([]*EventTemplateRecord, error) {
+	rows, err := db.query(ctx, db.DB, "SELECT "+sqlEventTemplateColumns+" FROM squad_event_templates WHERE squad_id = ? ORDER BY name", squadId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get squad %v event templates: %w", squadId, err)
+	}
+	defer rows.Close()
+
+	templates := make([]*EventTemplateRecord, 0)
+	for rows.Next() {
+		t := &EventTemplateRecord{}
+		if err = scanEventTemplate(rows, t); err != nil {
+			return nil, fmt.Errorf("Failed to get squad %v event templates: %w", squadId, err)
+		}
+		templates = append(templates, t)
+	}
+
+	return templates, rows.Err()
+}
+
+func (db *SQLDB) GetEventTemplate(ctx context.Context, squadId string, templateId string) (*EventTemplate, error) {
+	t := &EventTemplateRecord{}
+	err := scanEventTemplate(db.queryRow(ctx, db.DB, "SELECT "+sqlEventTemplateColumns+" FROM squad_event_templates WHERE squad_id = ? AND id = ?", squadId, templateId), t)
+	if err == sql.ErrNoRows {
+		return nil, sqlNotFound(err, "Failed to get event template %v: not found", templateId)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get event template %v: %w", templateId, err)
+	}
+
+	return &t.EventTemplate, nil
+}
+
+func (db *SQLDB) UpdateEventTemplate(ctx context.Context, squadId string, templateId string, template *EventTemplate) error {
+	if template.Name == "" {
+		return fmt.Errorf("Failed to update event template, name is not provided: %+v", template)
+	}
+
+	log.Printf("Updating event template '%v' in squad '%v'", templateId, squadId)
+
+	reminders, location, tags, err := marshalEventTemplate(template)
+	if err != nil {
+		return err
+	}
+
+	res, err := db.exec(ctx, db.DB, "UPDATE squad_event_templates SET name = ?, text = ?, time_from = ?, time_to = ?, capacity = ?, reminders = ?, location = ?, tags = ? WHERE squad_id = ? AND id = ?",
+		template.Name, template.Text, template.TimeFrom, template.TimeTo, template.Capacity, reminders, location, tags, squadId, templateId)
+	if err != nil {
+		return fmt.Errorf("Failed to update event template %v: %w", templateId, err)
+	}
+
+	return checkAffected(res, "Event template %v not found", templateId)
+}
+
+func (db *SQLDB) DeleteEventTemplate(ctx context.Context, squadId string, templateId string) error {
+	log.Println("Deleting event template " + templateId + " from squad " + squadId)
+
+	_, err := db.exec(ctx, db.DB, "DELETE FROM squad_event_templates WHERE squad_id = ? AND id = ?", squadId, templateId)
+	if err != nil {
+		return fmt.Errorf("Error while deleting event template "+templateId+" from squad "+squadId+": %w", err)
+	}
+
+	return nil
+}
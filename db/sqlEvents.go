@@ -0,0 +1,691 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// columns of events table holding amount of participants with given status
+var sqlEventCounters = map[ParticipantStatusType]string{
+	NotGoing:   "not_going",
+	Applied:    "applied",
+	Going:      "going",
+	Attended:   "attended",
+	NoShow:     "no_show",
+	Waitlisted: "waitlisted",
+}
+
+const sqlEventColumns = "e.id, e.date, e.time_from, e.time_to, e.text, e.squad_id, e.owner_id, e.archived, e.series_id, e.series_override, e.capacity, e.reminders, e.location"
+
+// status of the user in the event, event owner that did not register gets EventOwner
+const sqlUserEventStatus = "CASE WHEN p.status IS NOT NULL THEN p.status WHEN e.owner_id = ? THEN ? ELSE ? END"
+
+// events user participates in or owns
+const sqlUserEventsFrom = " FROM events e LEFT JOIN event_participants p ON p.event_id = e.id AND p.user_id = ? WHERE (p.user_id IS NOT NULL OR e.owner_id = ?)"
+
+func scanEvent(row interface{ Scan(...interface{}) error }, dest ...interface{}) (*EventRecord, error) {
+	e := &EventRecord{}
+	var date time.Time
+	var reminders, location string
+	err := row.Scan(append([]interface{}{&e.ID, &date, &e.TimeFrom, &e.TimeTo, &e.Text, &e.SquadId, &e.OwnerId, &e.Archived, &e.SeriesId, &e.SeriesOverride, &e.Capacity, &reminders, &location}, dest...)...)
+	if err != nil {
+		return nil, err
+	}
+	e.Date = utcTime(date)
+	if e.Reminders, err = unmarshalReminders(reminders); err != nil {
+		return nil, err
+	}
+	if e.Location, err = unmarshalLocation(location); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// reminders are kept as JSON list of minutes
+func marshalReminders(reminders []int) (string, error) {
+	if len(reminders) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(reminders)
+	if err != nil {
+		return "", fmt.Errorf("Failed to marshal event reminders: %w", err)
+	}
+	return string(b), nil
+}
+
+func unmarshalReminders(s string) ([]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var reminders []int
+	if err := json.Unmarshal([]byte(s), &reminders); err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal event reminders: %w", err)
+	}
+	return reminders, nil
+}
+
+// location is kept as JSON, empty if not set
+func marshalLocation(location *EventLocation) (string, error) {
+	if location.IsEmpty() {
+		return "", nil
+	}
+	b, err := json.Marshal(location)
+	if err != nil {
+		return "", fmt.Errorf("Failed to marshal event location: %w", err)
+	}
+	return string(b), nil
+}
+
+func unmarshalLocation(s string) (*EventLocation, error) {
+	if s == "" {
+		return nil, nil
+	}
+	location := &EventLocation{}
+	if err := json.Unmarshal([]byte(s), location); err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal event location: %w", err)
+	}
+	return location, nil
+}
+
+func (db *SQLDB) updateEventCounter(ctx context.Context, q sqlQueryer, eventId string, status ParticipantStatusType, inc int) error {
+	column, ok := sqlEventCounters[status]
+	if !ok {
+		return nil
+	}
+
+	res, err := db.exec(ctx, q, "UPDATE events SET "+column+" = "+column+" + ? WHERE id = ?", inc, eventId)
+	if err != nil {
+		return fmt.Errorf("Failed to update event %v counters: %w", eventId, err)
+	}
+	return checkAffected(res, "Event %v not found", eventId)
+}
+
+// events dates are used to page through them, so all of them should be unique
+func (db *SQLDB) uniqueEventDate(ctx context.Context, q sqlQueryer, eventDate *time.Time) (time.Time, error) {
+	year, month, day := eventDate.Date()
+	startOfTheDay := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	endOfTheDay := time.Date(year, month, day, 24, 0, 0, 0, time.UTC)
+
+	var last time.Time
+	err := db.queryRow(ctx, q, "SELECT date FROM events WHERE date >= ? AND date < ? ORDER BY date DESC LIMIT 1", startOfTheDay, endOfTheDay).Scan(&last)
+	if err == sql.ErrNoRows {
+		return time.Date(year, month, day, 7, 0, rand.Intn(60), 0, time.UTC), nil
+	} else if err != nil {
+		return last, err
+	}
+	return last.UTC().Add(time.Duration(rand.Intn(60)) * time.Second), nil
+}
+
+func (db *SQLDB) CreateEvent(ctx context.Context, event *EventInfo) (string, error) {
+	if event.Text == "" || event.Date == nil || event.SquadId == "" {
+		return "", fmt.Errorf("Failed to create event, not enough details provided: %+v", event)
+	}
+
+	if db.dev {
+		log.Printf("Creating event '%+v'", event)
+	}
+
+	reminders, err := marshalReminders(event.Reminders)
+	if err != nil {
+		return "", err
+	}
+	location, err := marshalLocation(event.Location)
+	if err != nil {
+		return "", err
+	}
+
+	id := newDocId()
+	err = db.inTx(ctx, func(tx *sql.Tx) error {
+		date, err := db.uniqueEventDate(ctx, tx, event.Date)
+		if err != nil {
+			return err
+		}
+		event.Date = &date
+
+		_, err = db.exec(ctx, tx, "INSERT INTO events (id, squad_id, owner_id, date, time_from, time_to, text, archived, series_id, series_override, capacity, reminders, location) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			id, event.SquadId, event.OwnerId, date, event.TimeFrom, event.TimeTo, event.Text, event.Archived, event.SeriesId, event.SeriesOverride, event.Capacity, reminders, location)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (db *SQLDB) GetEvent(ctx context.Context, ID string) (*EventInfo, error) {
+	if db.dev {
+		log.Println("Getting details for event " + ID)
+	}
+
+	e, err := scanEvent(db.queryRow(ctx, db.DB, "SELECT "+sqlEventColumns+" FROM events e WHERE e.id = ?", ID))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get event "+ID+": %w", sqlNotFound(err, "Event %v not found", ID))
+	}
+
+	return &e.EventInfo, nil
+}
+
+func (db *SQLDB) getUserEvents(ctx context.Context, userId string, where string, args []interface{}, orderAndLimit string) ([]*EventRecord, error) {
+	query := "SELECT " + sqlEventColumns + ", " + sqlUserEventStatus + sqlUserEventsFrom + where + orderAndLimit
+	args = append([]interface{}{userId, EventOwner, NotGoing, userId, userId}, args...)
+
+	rows, err := db.query(ctx, db.DB, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get user events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]*EventRecord, 0)
+	for rows.Next() {
+		var status ParticipantStatusType
+		e, err := scanEvent(rows, &status)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get user events: %w", err)
+		}
+		e.Status = status
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+func (db *SQLDB) GetUserEventsMap(ctx context.Context, squads []string, userId string) (map[string]*EventInfo, error) {
+	events := make(map[string]*EventInfo, 0)
+	if len(squads) == 0 {
+		return events, nil
+	}
+
+	in, args := inArgs(squads)
+	list, err := db.getUserEvents(ctx, userId, " AND e.archived = ? AND e.squad_id IN ("+in+")", append([]interface{}{false}, args...), "")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range list {
+		events[e.ID] = &e.EventInfo
+	}
+
+	return events, nil
+}
+
+func (db *SQLDB) GetUserEventsCount(ctx context.Context, userId string) (int, error) {
+	var count int
+	err := db.queryRow(ctx, db.DB, "SELECT COUNT(*)"+sqlUserEventsFrom+" AND e.archived = ?", userId, userId, false).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get amount of user %s events: %w", userId, err)
+	}
+
+	return count, nil
+}
+
+func (db *SQLDB) GetUserEvents(ctx context.Context, userId string, limit int) ([]*EventInfo, error) {
+	orderAndLimit := " ORDER BY e.date"
+	if limit != 0 {
+		orderAndLimit += fmt.Sprintf(" LIMIT %d", limit)
+	}
+
+	list, err := db.getUserEvents(ctx, userId, " AND e.archived = ?", []interface{}{false}, orderAndLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*EventInfo, len(list))
+	for i, e := range list {
+		events[i] = &e.EventInfo
+	}
+
+	return events, nil
+}
+
+func (db *SQLDB) ArchiveOldEvents(ctx context.Context) error {
+	res, err := db.exec(ctx, db.DB, "UPDATE events SET archived = ? WHERE archived = ? AND date < ?", true, false, *getToday())
+	if err != nil {
+		return fmt.Errorf("Failed to archive events: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n > 0 {
+		log.Printf("Marked %v events as archived\n", n)
+	}
+
+	return nil
+}
+
+func (db *SQLDB) getEventsWithCounters(ctx context.Context, squads []string, userId string, where string, whereArgs []interface{}, order string) ([]*EventCountersRecord, error) {
+	events := make([]*EventCountersRecord, 0)
+	if len(squads) == 0 {
+		return events, nil
+	}
+
+	in, squadArgs := inArgs(squads)
+	query := "SELECT " + sqlEventColumns + ", e.going, e.applied, e.attended, e.no_show, e.waitlisted, " + sqlUserEventStatus +
+		" FROM events e LEFT JOIN event_participants p ON p.event_id = e.id AND p.user_id = ?" +
+		" WHERE e.squad_id IN (" + in + ") AND e.archived = ?" + where + " ORDER BY " + order
+	args := append([]interface{}{userId, EventOwner, NotGoing, userId}, squadArgs...)
+	args = append(append(args, false), whereArgs...)
+
+	rows, err := db.query(ctx, db.DB, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r := &EventCountersRecord{}
+		var status ParticipantStatusType
+		e, err := scanEvent(rows, &r.Going, &r.Applied, &r.Attended, &r.NoShow, &r.Waitlisted, &status)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get events: %w", err)
+		}
+		r.ID = e.ID
+		r.EventInfo = e.EventInfo
+		r.Status = status
+		events = append(events, r)
+	}
+
+	return events, rows.Err()
+}
+
+func (db *SQLDB) GetEvents(ctx context.Context, squads []string, userId string) ([]*EventCountersRecord, error) {
+	return db.getEventsWithCounters(ctx, squads, userId, "", nil, "e.date")
+}
+
+func (db *SQLDB) GetEventsByStatus(ctx context.Context, squads []string, userId string, status string) ([]*EventCountersRecord, error) {
+	column, ok := sqlEventCounters[ParticipantStatusType(eventStatusFromString(status))]
+	if !ok {
+		return nil, fmt.Errorf("Unknown participant status %v", status)
+	}
+
+	return db.getEventsWithCounters(ctx, squads, userId, " AND e."+column+" > 0", nil, "e."+column+" DESC, e.date")
+}
+
+func (db *SQLDB) GetArchivedEvents(ctx context.Context, userId string, from *time.Time, filter *map[string]string) ([]*EventRecord, error) {
+	if db.dev {
+		log.Printf("Getting archived events for user %v (from %+v, filter %+v)\n", userId, from, filter)
+	}
+
+	where := " AND e.archived = ?"
+	args := []interface{}{true}
+	if from != nil {
+		where += " AND e.date < ?"
+		args = append(args, from.UTC())
+	}
+	if filter != nil {
+		if s := eventStatusFromString((*filter)["Status"]); s != -1 {
+			where += " AND " + sqlUserEventStatus + " = ?"
+			args = append(args, userId, EventOwner, NotGoing, s)
+		}
+	}
+
+	return db.getUserEvents(ctx, userId, where, args, fmt.Sprintf(" ORDER BY e.date DESC LIMIT %d", numRecords))
+}
+
+func (db *SQLDB) RegisterParticipants(ctx context.Context, userIds []string, eventId string, eventInfo *EventInfo, status ParticipantStatusType) error {
+	if db.dev {
+		log.Println("Registering users " + strings.Join(userIds, ", ") + " for event " + eventId)
+	}
+
+	// every participant is registered in own transaction, failing to add one should not affect others
+	errs := make([]error, len(userIds))
+	for i, userId := range userIds {
+		errs[i] = db.inTx(ctx, func(tx *sql.Tx) error {
+			if err := db.lockEvent(ctx, tx, eventId); err != nil {
+				return err
+			}
+
+			if st, err := db.getParticipantStatus(ctx, tx, userId, eventId); err == nil {
+				return fmt.Errorf("User %v is already registered for event %v with status %v", userId, eventId, st.String())
+			}
+
+			if _, err := db.getSquadMemberStatus(ctx, tx, userId, eventInfo.SquadId); err != nil {
+				return err
+			}
+
+			capacity, taken, err := db.getEventPlaces(ctx, tx, eventId)
+			if err != nil {
+				return err
+			}
+			status := registrationStatus(capacity, taken, status)
+
+			if db.dev {
+				log.Println("Adding participant " + userId + " to event " + eventId)
+			}
+
+			_, err = db.exec(ctx, tx, "INSERT INTO event_participants (event_id, user_id, status, created_at) VALUES (?, ?, ?, ?)", eventId, userId, status, time.Now().UTC())
+			if err != nil {
+				return fmt.Errorf("Failed to add participant "+userId+" to event "+eventId+": %w", err)
+			}
+
+			return db.updateEventCounter(ctx, tx, eventId, status, 1)
+		})
+	}
+
+	return registrationErrors(userIds, errs)
+}
+
+const sqlParticipantTagExists = "EXISTS (SELECT 1 FROM squad_member_tags t WHERE t.squad_id = e.squad_id AND t.user_id = p.user_id AND t.tag = ?)"
+
+func (db *SQLDB) GetParticipants(ctx context.Context, eventId string, from *time.Time, filter *map[string]string) ([]*ParticipantRecord, error) {
+	if db.dev {
+		log.Printf("Getting participants of the event %v (from %v, filter %v)\n", eventId, from, filter)
+	}
+
+	var squadId string
+	err := db.queryRow(ctx, db.DB, "SELECT squad_id FROM events WHERE id = ?", eventId).Scan(&squadId)
+	if err == sql.ErrNoRows {
+		return make([]*ParticipantRecord, 0), nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to get event participants: %w", err)
+	}
+
+	// participant details are taken from the squad
+	query := "SELECT p.user_id, COALESCE(m.display_name, ''), COALESCE(m.email, ''), COALESCE(m.phone_number, ''), COALESCE(m.replicant, ?), p.status, p.created_at" +
+		" FROM event_participants p JOIN events e ON e.id = p.event_id" +
+		" LEFT JOIN squad_members m ON m.squad_id = e.squad_id AND m.user_id = p.user_id" +
+		" WHERE p.event_id = ?"
+	args := []interface{}{false, eventId}
+	if from != nil {
+		query += " AND p.created_at > ?"
+		args = append(args, from.UTC())
+	}
+	where, filterArgs := sqlFilterWhere(filter, "m.search_keys", "p.status", sqlParticipantTagExists, eventStatusFromString)
+	query += where + " ORDER BY p.created_at, p.user_id LIMIT ?"
+	args = append(append(args, filterArgs...), numRecords)
+
+	rows, err := db.query(ctx, db.DB, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get event participants: %w", err)
+	}
+	defer rows.Close()
+
+	participants := make([]*ParticipantRecord, 0)
+	ids := make([]string, 0)
+	for rows.Next() {
+		p := &ParticipantRecord{}
+		var timestamp time.Time
+		err = rows.Scan(&p.ID, &p.DisplayName, &p.Email, &p.PhoneNumber, &p.Replicant, &p.Status, &timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get event participants: %w", err)
+		}
+		p.Timestamp = timestamp.UTC()
+		participants = append(participants, p)
+		ids = append(ids, p.ID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to get event participants: %w", err)
+	}
+	rows.Close()
+
+	tags, err := db.getMembersTags(ctx, db.DB, squadId, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range participants {
+		p.Tags = tags[p.ID]
+	}
+
+	return participants, nil
+}
+
+// lockEvent serializes changes of the event participants and counters, event
+// row stays locked till the end of transaction (SQLite locks whole database)
+func (db *SQLDB) lockEvent(ctx context.Context, tx *sql.Tx, eventId string) error {
+	res, err := db.exec(ctx, tx, "UPDATE events SET id = id WHERE id = ?", eventId)
+	if err != nil {
+		return fmt.Errorf("Failed to lock event %v: %w", eventId, err)
+	}
+	return checkAffected(res, "Event %v not found", eventId)
+}
+
+// capacity of the event and amount of places taken by participants
+func (db *SQLDB) getEventPlaces(ctx context.Context, q sqlQueryer, eventId string) (capacity int, taken int, err error) {
+	err = db.queryRow(ctx, q, "SELECT capacity, applied + going + attended FROM events WHERE id = ?", eventId).Scan(&capacity, &taken)
+	if err != nil {
+		return 0, 0, fmt.Errorf("Failed to get event %v: %w", eventId, sqlNotFound(err, "Event %v not found", eventId))
+	}
+	return capacity, taken, nil
+}
+
+func (db *SQLDB) PromoteWaitlisted(ctx context.Context, eventId string) ([]string, error) {
+	promoted := make([]string, 0)
+	err := db.inTx(ctx, func(tx *sql.Tx) error {
+		if err := db.lockEvent(ctx, tx, eventId); err != nil {
+			return err
+		}
+
+		capacity, taken, err := db.getEventPlaces(ctx, tx, eventId)
+		if err != nil {
+			return err
+		}
+
+		query := "SELECT user_id FROM event_participants WHERE event_id = ? AND status = ? ORDER BY created_at"
+		if capacity > 0 {
+			if taken >= capacity {
+				return nil
+			}
+			query += fmt.Sprintf(" LIMIT %d", capacity-taken)
+		}
+
+		rows, err := db.query(ctx, tx, query, eventId, Waitlisted)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var userId string
+			if err = rows.Scan(&userId); err != nil {
+				return err
+			}
+			promoted = append(promoted, userId)
+		}
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		for _, userId := range promoted {
+			_, err = db.exec(ctx, tx, "UPDATE event_participants SET status = ? WHERE event_id = ? AND user_id = ?", Going, eventId, userId)
+			if err != nil {
+				return err
+			}
+		}
+		if err = db.updateEventCounter(ctx, tx, eventId, Waitlisted, -len(promoted)); err != nil {
+			return err
+		}
+		return db.updateEventCounter(ctx, tx, eventId, Going, len(promoted))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to promote waitlisted participants of event %v: %w", eventId, err)
+	}
+
+	return promoted, nil
+}
+
+func (db *SQLDB) MarkNoShows(ctx context.Context, eventId string) (int, error) {
+	marked := 0
+	err := db.inTx(ctx, func(tx *sql.Tx) error {
+		if err := db.lockEvent(ctx, tx, eventId); err != nil {
+			return err
+		}
+
+		res, err := db.exec(ctx, tx, "UPDATE event_participants SET status = ? WHERE event_id = ? AND status = ?", NoShow, eventId, Going)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		marked = int(n)
+
+		if err = db.updateEventCounter(ctx, tx, eventId, Going, -marked); err != nil {
+			return err
+		}
+		return db.updateEventCounter(ctx, tx, eventId, NoShow, marked)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("Failed to mark no-shows of event %v: %w", eventId, err)
+	}
+
+	return marked, nil
+}
+
+func (db *SQLDB) getParticipantStatus(ctx context.Context, q sqlQueryer, userId string, eventId string) (ParticipantStatusType, error) {
+	var status ParticipantStatusType
+	err := db.queryRow(ctx, q, "SELECT status FROM event_participants WHERE event_id = ? AND user_id = ?", eventId, userId).Scan(&status)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get event "+eventId+": %w", sqlNotFound(err, "Participant %v not found", userId))
+	}
+	return status, nil
+}
+
+func (db *SQLDB) GetParticipantStatus(ctx context.Context, userId string, eventId string) (ParticipantStatusType, error) {
+	return db.getParticipantStatus(ctx, db.DB, userId, eventId)
+}
+
+func (db *SQLDB) SetParticipantStatus(ctx context.Context, userId string, eventId string, status ParticipantStatusType) (oldStatus ParticipantStatusType, newStatus ParticipantStatusType, err error) {
+	err = db.inTx(ctx, func(tx *sql.Tx) error {
+		if err := db.lockEvent(ctx, tx, eventId); err != nil {
+			return err
+		}
+
+		capacity, taken, err := db.getEventPlaces(ctx, tx, eventId)
+		if err != nil {
+			return err
+		}
+		oldStatus, err = db.getParticipantStatus(ctx, tx, userId, eventId)
+		if err != nil {
+			return err
+		}
+		newStatus = changedStatus(capacity, taken, oldStatus, status)
+
+		_, err = db.exec(ctx, tx, "UPDATE event_participants SET status = ? WHERE event_id = ? AND user_id = ?", newStatus, eventId, userId)
+		if err != nil {
+			return err
+		}
+
+		if err = db.updateEventCounter(ctx, tx, eventId, oldStatus, -1); err != nil {
+			return err
+		}
+		return db.updateEventCounter(ctx, tx, eventId, newStatus, 1)
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("Failed to change user "+userId+" status: %w", err)
+	}
+
+	return oldStatus, newStatus, nil
+}
+
+func (db *SQLDB) DeleteParticipant(ctx context.Context, userId string, eventId string) error {
+	if db.dev {
+		log.Println("Removing user " + userId + " from event " + eventId)
+	}
+
+	return db.inTx(ctx, func(tx *sql.Tx) error {
+		if err := db.lockEvent(ctx, tx, eventId); err != nil {
+			return err
+		}
+
+		status, err := db.getParticipantStatus(ctx, tx, userId, eventId)
+		if err != nil {
+			return err
+		}
+
+		_, err = db.exec(ctx, tx, "DELETE FROM event_participants WHERE event_id = ? AND user_id = ?", eventId, userId)
+		if err != nil {
+			return fmt.Errorf("Failed to delete user %v from event %v: %w", userId, eventId, err)
+		}
+
+		return db.updateEventCounter(ctx, tx, eventId, status, -1)
+	})
+}
+
+func (db *SQLDB) RecountEventCounters(ctx context.Context) (int, error) {
+	var fixed int
+	err := db.inTx(ctx, func(tx *sql.Tx) error {
+		wrong := make([]string, 0, len(eventCounterStatuses))
+		args := make([]interface{}, 0, len(eventCounterStatuses))
+		for _, status := range eventCounterStatuses {
+			wrong = append(wrong, "e."+sqlEventCounters[status]+" <> (SELECT COUNT(*) FROM event_participants p WHERE p.event_id = e.id AND p.status = ?)")
+			args = append(args, status)
+		}
+		err := db.queryRow(ctx, tx, "SELECT COUNT(*) FROM events e WHERE "+strings.Join(wrong, " OR "), args...).Scan(&fixed)
+		if err != nil || fixed == 0 {
+			return err
+		}
+
+		for _, status := range eventCounterStatuses {
+			column := sqlEventCounters[status]
+			_, err = db.exec(ctx, tx, "UPDATE events SET "+column+" = (SELECT COUNT(*) FROM event_participants p WHERE p.event_id = events.id AND p.status = ?)", status)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("Failed to recount events counters: %w", err)
+	}
+
+	return fixed, nil
+}
+
+func (db *SQLDB) DeleteEvent(ctx context.Context, eventId string) error {
+	if db.dev {
+		log.Println("Deleting event " + eventId)
+	}
+
+	res, err := db.exec(ctx, db.DB, "DELETE FROM events WHERE id = ?", eventId)
+	if err == nil {
+		err = checkAffected(res, "Event %v not found", eventId)
+	}
+	if err != nil {
+		return fmt.Errorf("Error while deleting event %v: %w", eventId, err)
+	}
+
+	return nil
+}
+
+func (db *SQLDB) GetCandidates(ctx context.Context, squadId string, eventId string, from string, filter *map[string]string) ([]*SquadUserInfoRecord, error) {
+	if db.dev {
+		log.Printf("Getting candidates to participate in the event %v (filter %v)\n", eventId, filter)
+	}
+
+	query := "SELECT " + sqlMemberColumns + " FROM squad_members m WHERE m.squad_id = ? AND m.user_id > ?" +
+		" AND NOT EXISTS (SELECT 1 FROM event_participants p WHERE p.event_id = ? AND p.user_id = m.user_id)"
+	args := []interface{}{squadId, from, eventId}
+	where, filterArgs := sqlFilterWhere(filter, "m.search_keys", "m.status", sqlMemberTagExists, statusFromString)
+	query += where + " ORDER BY m.user_id LIMIT ?"
+	args = append(append(args, filterArgs...), numRecords)
+
+	rows, err := db.query(ctx, db.DB, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get event participants: %w", err)
+	}
+	defer rows.Close()
+
+	candidates := make([]*SquadUserInfoRecord, 0, numRecords)
+	for rows.Next() {
+		m, err := scanMember(rows)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get event participants: %w", err)
+		}
+		candidates = append(candidates, m)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to get event participants: %w", err)
+	}
+	rows.Close()
+
+	err = db.loadMemberTags(ctx, db.DB, squadId, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	return candidates, nil
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+func (db *SQLDB) GetJobs(ctx context.Context) ([]*JobStatus, error) {
+	rows, err := db.query(ctx, db.DB, "SELECT name, locked_by, locked_until, last_start, last_finish, last_error FROM jobs ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := make([]*JobStatus, 0)
+	for rows.Next() {
+		j := &JobStatus{}
+		var lockedUntil, lastStart, lastFinish sql.NullTime
+		if err = rows.Scan(&j.Name, &j.LockedBy, &lockedUntil, &lastStart, &lastFinish, &j.LastError); err != nil {
+			return nil, fmt.Errorf("Failed to get jobs: %w", err)
+		}
+		if lockedUntil.Valid {
+			j.LockedUntil = utcTime(lockedUntil.Time)
+		}
+		if lastStart.Valid {
+			j.LastStart = utcTime(lastStart.Time)
+		}
+		if lastFinish.Valid {
+			j.LastFinish = utcTime(lastFinish.Time)
+		}
+		jobs = append(jobs, j)
+	}
+
+	return jobs, rows.Err()
+}
+
+func (db *SQLDB) StartJob(ctx context.Context, name string, instance string, due time.Time, now time.Time, lockUntil time.Time) (bool, error) {
+	_, err := db.exec(ctx, db.DB, "INSERT INTO jobs (name) VALUES (?) ON CONFLICT DO NOTHING", name)
+	if err != nil {
+		return false, fmt.Errorf("Failed to start job %v: %w", name, err)
+	}
+
+	// lock is taken by single update, so only one instance could succeed
+	res, err := db.exec(ctx, db.DB, "UPDATE jobs SET locked_by = ?, locked_until = ?, last_start = ?"+
+		" WHERE name = ? AND (locked_until IS NULL OR locked_until <= ?) AND (last_start IS NULL OR last_start < ?)",
+		instance, lockUntil.UTC(), now.UTC(), name, now.UTC(), due.UTC())
+	if err != nil {
+		return false, fmt.Errorf("Failed to start job %v: %w", name, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("Failed to start job %v: %w", name, err)
+	}
+
+	return n > 0, nil
+}
+
+func (db *SQLDB) FinishJob(ctx context.Context, name string, instance string, finish time.Time, jobErr string) error {
+	_, err := db.exec(ctx, db.DB, "UPDATE jobs SET locked_by = '', locked_until = NULL, last_finish = ?, last_error = ? WHERE name = ? AND locked_by = ?",
+		finish.UTC(), jobErr, name, instance)
+	if err != nil {
+		return fmt.Errorf("Failed to finish job %v: %w", name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+func (db *SQLDB) CreateNote(ctx context.Context, squadId string, note *NoteUpdate) (string, error) {
+	if note.Title == nil || note.Text == nil {
+		return "", fmt.Errorf("Failed to create note, not enough details provided: %+v", note)
+	}
+
+	log.Printf("Creating note '%+v' in squad '%v'", note, squadId)
+
+	id := newDocId()
+	_, err := db.exec(ctx, db.DB, "INSERT INTO squad_notes (id, squad_id, title, text, created_at) VALUES (?, ?, ?, ?, ?)", id, squadId, *note.Title, *note.Text, time.Now().UTC())
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (db *SQLDB) GetNotes(ctx context.Context, squadId string, publishedOnly bool) ([]*NoteRecord, error) {
+	log.Printf("Getting notes for squad '%v'", squadId)
+
+	query := "SELECT id, title, text, published, created_at FROM squad_notes WHERE squad_id = ?"
+	args := []interface{}{squadId}
+	if publishedOnly {
+		query += " AND published = ?"
+		args = append(args, true)
+	}
+
+	rows, err := db.query(ctx, db.DB, query+" ORDER BY created_at DESC", args...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get squad notes: %w", err)
+	}
+	defer rows.Close()
+
+	notes := make([]*NoteRecord, 0)
+	for rows.Next() {
+		n := &NoteRecord{}
+		if err = rows.Scan(&n.ID, &n.Title, &n.Text, &n.Published, &n.Timestamp); err != nil {
+			return nil, fmt.Errorf("Failed to get squad notes: %w", err)
+		}
+		n.Timestamp = n.Timestamp.UTC()
+		notes = append(notes, n)
+	}
+
+	return notes, rows.Err()
+}
+
+func (db *SQLDB) DeleteNote(ctx context.Context, squadId string, noteId string) error {
+	log.Println("Deleting note " + noteId + " from squad " + squadId)
+
+	_, err := db.exec(ctx, db.DB, "DELETE FROM squad_notes WHERE squad_id = ? AND id = ?", squadId, noteId)
+	if err != nil {
+		return fmt.Errorf("Error while deleting note "+noteId+" from squad "+squadId+": %w", err)
+	}
+
+	return nil
+}
+
+func (db *SQLDB) UpdateNote(ctx context.Context, squadId string, noteId string, note *NoteUpdate) error {
+	log.Printf("Updating note '%v' in squad '%v'", noteId, squadId)
+
+	var err error
+	if note.Title != nil && note.Text != nil {
+		_, err = db.exec(ctx, db.DB, "UPDATE squad_notes SET title = ?, text = ?, created_at = ? WHERE squad_id = ? AND id = ?", *note.Title, *note.Text, time.Now().UTC(), squadId, noteId)
+	} else if note.Published != nil {
+		_, err = db.exec(ctx, db.DB, "UPDATE squad_notes SET published = ? WHERE squad_id = ? AND id = ?", *note.Published, squadId, noteId)
+	} else {
+		return fmt.Errorf("Failed to update note, not enough details provided: %+v", note)
+	}
+
+	return err
+}
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+func splitChannels(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
+
+func (db *SQLDB) GetNotificationPrefs(ctx context.Context, userId string) ([]*NotificationPref, error) {
+	rows, err := db.query(ctx, db.DB, "SELECT squad_id, kind, muted, channels FROM notification_kind_prefs WHERE user_id = ? ORDER BY squad_id, kind", userId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get user %v notification preferences: %w", userId, err)
+	}
+	defer rows.Close()
+
+	prefs := make([]*NotificationPref, 0)
+	for rows.Next() {
+		p := &NotificationPref{}
+		var channels string
+		if err = rows.Scan(&p.SquadId, &p.Kind, &p.Muted, &channels); err != nil {
+			return nil, fmt.Errorf("Failed to get user %v notification preferences: %w", userId, err)
+		}
+		p.Channels = splitChannels(channels)
+		prefs = append(prefs, p)
+	}
+
+	return prefs, rows.Err()
+}
+
+func (db *SQLDB) SetNotificationPref(ctx context.Context, userId string, pref *NotificationPref) error {
+	log.Printf("Setting user %v notification preference %+v", userId, pref)
+
+	_, err := db.exec(ctx, db.DB, "INSERT INTO notification_kind_prefs (user_id, squad_id, kind, muted, channels) VALUES (?, ?, ?, ?, ?)"+
+		" ON CONFLICT (user_id, squad_id, kind) DO UPDATE SET muted = excluded.muted, channels = excluded.channels",
+		userId, pref.SquadId, pref.Kind, pref.Muted, strings.Join(pref.Channels, ","))
+	if err != nil {
+		return fmt.Errorf("Failed to set user %v notification preference: %w", userId, err)
+	}
+
+	return nil
+}
+
+func (db *SQLDB) DeleteNotificationPref(ctx context.Context, userId string, squadId string, kind string) error {
+	log.Printf("Deleting user %v notification preference for %v in squad %v", userId, kind, squadId)
+
+	_, err := db.exec(ctx, db.DB, "DELETE FROM notification_kind_prefs WHERE user_id = ? AND squad_id = ? AND kind = ?", userId, squadId, kind)
+	if err != nil {
+		return fmt.Errorf("Failed to delete user %v notification preference: %w", userId, err)
+	}
+
+	return nil
+}
+
+func (db *SQLDB) GetUsersNotificationPrefs(ctx context.Context, userIds []string, squadId string, kind string) (map[string]*NotificationPref, error) {
+	wanted := make(map[string]bool, len(userIds))
+	for _, userId := range userIds {
+		wanted[userId] = true
+	}
+
+	// squad rarely has many preferences for the kind, so they are filtered here rather than with long IN list
+	rows, err := db.query(ctx, db.DB, "SELECT user_id, muted, channels FROM notification_kind_prefs WHERE squad_id = ? AND kind = ?", squadId, kind)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get squad %v notification preferences: %w", squadId, err)
+	}
+	defer rows.Close()
+
+	prefs := make(map[string]*NotificationPref)
+	for rows.Next() {
+		p := &NotificationPref{SquadId: squadId, Kind: kind}
+		var userId, channels string
+		if err = rows.Scan(&userId, &p.Muted, &channels); err != nil {
+			return nil, fmt.Errorf("Failed to get squad %v notification preferences: %w", squadId, err)
+		}
+		if wanted[userId] {
+			p.Channels = splitChannels(channels)
+			prefs[userId] = p
+		}
+	}
+
+	return prefs, rows.Err()
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+func (db *SQLDB) CreateNotification(ctx context.Context, userIds []string, n *Notification) error {
+	var linkType, linkId string
+	if n.Link != nil {
+		linkType, linkId = n.Link.Type, n.Link.ID
+	}
+
+	return db.inTx(ctx, func(tx *sql.Tx) error {
+		for _, userId := range userIds {
+			_, err := db.exec(ctx, tx, "INSERT INTO notifications (id, user_id, kind, time, title, text, is_read, link_type, link_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+				newDocId(), userId, n.Kind, n.Time.UTC(), n.Title, n.Text, false, linkType, linkId)
+			if err != nil {
+				return fmt.Errorf("Failed to create notification '%v': %w", n.Title, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (db *SQLDB) GetNotifications(ctx context.Context, userId string, before *time.Time, unreadOnly bool) ([]*NotificationRecord, error) {
+	where := "user_id = ?"
+	args := []interface{}{userId}
+	if before != nil {
+		where += " AND time < ?"
+		args = append(args, before.UTC())
+	}
+	if unreadOnly {
+		where += " AND NOT is_read"
+	}
+
+	rows, err := db.query(ctx, db.DB, fmt.Sprintf("SELECT id, user_id, kind, time, title, text, is_read, link_type, link_id FROM notifications WHERE %v ORDER BY time DESC LIMIT %d", where, numRecords), args...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get user %v notifications: %w", userId, err)
+	}
+	defer rows.Close()
+
+	notifications := make([]*NotificationRecord, 0)
+	for rows.Next() {
+		n := &NotificationRecord{}
+		var t time.Time
+		var linkType, linkId string
+		if err = rows.Scan(&n.ID, &n.UserId, &n.Kind, &t, &n.Title, &n.Text, &n.Read, &linkType, &linkId); err != nil {
+			return nil, fmt.Errorf("Failed to get user %v notifications: %w", userId, err)
+		}
+		n.Time = *utcTime(t)
+		if linkType != "" {
+			n.Link = &NotificationLink{Type: linkType, ID: linkId}
+		}
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
+
+func (db *SQLDB) GetUnreadNotificationsCount(ctx context.Context, userId string) (int, error) {
+	var count int
+	err := db.queryRow(ctx, db.DB, "SELECT COUNT(*) FROM notifications WHERE user_id = ? AND NOT is_read", userId).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to count user %v notifications: %w", userId, err)
+	}
+
+	return count, nil
+}
+
+func (db *SQLDB) MarkNotificationRead(ctx context.Context, userId string, notificationId string) error {
+	res, err := db.exec(ctx, db.DB, "UPDATE notifications SET is_read = ? WHERE user_id = ? AND id = ?", true, userId, notificationId)
+	if err != nil {
+		return fmt.Errorf("Failed to mark notification %v read: %w", notificationId, err)
+	}
+
+	return checkAffected(res, "Failed to mark notification %v read: not found", notificationId)
+}
+
+func (db *SQLDB) MarkNotificationsRead(ctx context.Context, userId string) error {
+	_, err := db.exec(ctx, db.DB, "UPDATE notifications SET is_read = ? WHERE user_id = ? AND NOT is_read", true, userId)
+	if err != nil {
+		return fmt.Errorf("Failed to mark user %v notifications read: %w", userId, err)
+	}
+
+	return nil
+}
+
+func (db *SQLDB) DeleteNotificationsBefore(ctx context.Context, before time.Time) (int, error) {
+	res, err := db.exec(ctx, db.DB, "DELETE FROM notifications WHERE time < ?", before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("Failed to delete old notifications: %w", err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("Failed to delete old notifications: %w", err)
+	}
+	if deleted > 0 {
+		log.Printf("Deleted %v notifications older than %v", deleted, before)
+	}
+
+	return int(deleted), nil
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+func (db *SQLDB) SavePushToken(ctx context.Context, token *PushToken) error {
+	if token.Token == "" {
+		return fmt.Errorf("Failed to save push token of user %v: token is empty", token.UserId)
+	}
+
+	_, err := db.exec(ctx, db.DB, "INSERT INTO push_tokens (token, user_id, device, created_at, last_seen) VALUES (?, ?, ?, ?, ?)"+
+		" ON CONFLICT (token) DO UPDATE SET user_id = excluded.user_id, device = excluded.device, last_seen = excluded.last_seen",
+		token.Token, token.UserId, token.Device, token.LastSeen.UTC(), token.LastSeen.UTC())
+	if err != nil {
+		return fmt.Errorf("Failed to save push token of user %v: %w", token.UserId, err)
+	}
+
+	return nil
+}
+
+func (db *SQLDB) GetPushTokens(ctx context.Context, userId string) ([]*PushToken, error) {
+	rows, err := db.query(ctx, db.DB, "SELECT token, user_id, device, created_at, last_seen FROM push_tokens WHERE user_id = ? ORDER BY last_seen DESC", userId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get user %v push tokens: %w", userId, err)
+	}
+	defer rows.Close()
+
+	tokens := make([]*PushToken, 0)
+	for rows.Next() {
+		t := &PushToken{}
+		var created, lastSeen time.Time
+		if err = rows.Scan(&t.Token, &t.UserId, &t.Device, &created, &lastSeen); err != nil {
+			return nil, fmt.Errorf("Failed to get user %v push tokens: %w", userId, err)
+		}
+		t.Created = *utcTime(created)
+		t.LastSeen = *utcTime(lastSeen)
+		tokens = append(tokens, t)
+	}
+
+	return tokens, rows.Err()
+}
+
+func (db *SQLDB) DeletePushTokens(ctx context.Context, userId string, tokens []string) error {
+	log.Printf("Deleting %v push tokens of user %v", len(tokens), userId)
+
+	return db.inTx(ctx, func(tx *sql.Tx) error {
+		for _, token := range tokens {
+			// token might be taken by another user signed in on the same device
+			if _, err := db.exec(ctx, tx, "DELETE FROM push_tokens WHERE token = ? AND user_id = ?", token, userId); err != nil {
+				return fmt.Errorf("Failed to delete user %v push tokens: %w", userId, err)
+			}
+		}
+		return nil
+	})
+}
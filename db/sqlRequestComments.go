@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+func (db *SQLDB) CreateRequestComment(ctx context.Context, requestId string, comment *RequestComment) (string, error) {
+	if db.dev {
+		log.Printf("Creating comment %+v for request %v\n", comment, requestId)
+	}
+
+	id := newDocId()
+	_, err := db.exec(ctx, db.DB, "INSERT INTO request_comments (id, request_id, user_id, user_name, text, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		id, requestId, comment.UserId, comment.UserName, comment.Text, time.Now().UTC())
+	if err != nil {
+		return "", fmt.Errorf("Failed to create comment for request %v: %w", requestId, err)
+	}
+
+	return id, nil
+}
+
+func (db *SQLDB) GetRequestComments(ctx context.Context, requestId string) ([]*RequestCommentRecord, error) {
+	rows, err := db.query(ctx, db.DB, "SELECT id, user_id, user_name, text, created_at FROM request_comments WHERE request_id = ? ORDER BY created_at, id", requestId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get request %v comments: %w", requestId, err)
+	}
+	defer rows.Close()
+
+	comments := make([]*RequestCommentRecord, 0)
+	for rows.Next() {
+		c := &RequestCommentRecord{}
+		var timestamp time.Time
+		if err = rows.Scan(&c.ID, &c.UserId, &c.UserName, &c.Text, &timestamp); err != nil {
+			return nil, fmt.Errorf("Failed to get request %v comments: %w", requestId, err)
+		}
+		c.Time = utcTime(timestamp)
+		comments = append(comments, c)
+	}
+
+	return comments, rows.Err()
+}
+
+func (db *SQLDB) GetRequestComment(ctx context.Context, requestId string, commentId string) (*RequestComment, error) {
+	c := &RequestComment{}
+	var timestamp time.Time
+	err := db.queryRow(ctx, db.DB, "SELECT user_id, user_name, text, created_at FROM request_comments WHERE request_id = ? AND id = ?", requestId, commentId).
+		Scan(&c.UserId, &c.UserName, &c.Text, &timestamp)
+	if err != nil {
+		return nil, sqlNotFound(err, "Failed to get comment %v: not found", commentId)
+	}
+	c.Time = utcTime(timestamp)
+
+	return c, nil
+}
+
+func (db *SQLDB) DeleteRequestComment(ctx context.Context, requestId string, commentId string) error {
+	_, err := db.exec(ctx, db.DB, "DELETE FROM request_comments WHERE request_id = ? AND id = ?", requestId, commentId)
+	if err != nil {
+		return fmt.Errorf("Failed to delete comment %v: %w", commentId, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,565 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// columns of request_queues table holding amount of requests with given status
+var sqlRequestCounters = map[RequestStatusType]string{
+	WaitingApprove: "waiting_approve",
+	Processing:     "processing",
+	Completed:      "completed",
+	Declined:       "declined",
+	Cancelled:      "cancelled",
+}
+
+const sqlRequestColumns = "r.id, r.queue_id, r.user_id, r.user_name, r.details, r.status, r.state, r.created_at, r.assignee_id, r.assignee_name, r.status_time, r.escalated, r.fields"
+
+const sqlQueueColumns = "squad_id, approvers, handlers, waiting_approve, processing, workflow, sla_approve_minutes, sla_process_minutes, fields"
+
+func (db *SQLDB) updateQueueCounter(ctx context.Context, q sqlQueryer, queueId string, status RequestStatusType, inc int) error {
+	column := sqlRequestCounters[status]
+	res, err := db.exec(ctx, q, "UPDATE request_queues SET "+column+" = "+column+" + ? WHERE id = ?", inc, queueId)
+	if err != nil {
+		return fmt.Errorf("Failed to update queue %v counters: %w", queueId, err)
+	}
+	return checkAffected(res, "Failed to get queue %v: not found", queueId)
+}
+
+func (db *SQLDB) CreateRequestsQueue(ctx context.Context, queueId string, qi *QueueInfo) error {
+	if db.dev {
+		log.Println("Creating queue " + qi.SquadId + "/" + queueId)
+	}
+
+	var approversPath, handlersPath string
+	if qi.Approvers != "" {
+		approversPath = qi.SquadId + "/" + qi.Approvers
+	}
+	if qi.Handlers != "" {
+		handlersPath = qi.SquadId + "/" + qi.Handlers
+	}
+
+	var workflow string
+	if qi.Workflow != nil {
+		b, err := json.Marshal(qi.Workflow)
+		if err != nil {
+			return fmt.Errorf("Failed to marshal queue %v workflow: %w", queueId, err)
+		}
+		workflow = string(b)
+	}
+
+	sla := QueueSLA{}
+	if qi.SLA != nil {
+		sla = *qi.SLA
+	}
+
+	var fields string
+	if len(qi.Fields) > 0 {
+		b, err := json.Marshal(qi.Fields)
+		if err != nil {
+			return fmt.Errorf("Failed to marshal queue %v fields: %w", queueId, err)
+		}
+		fields = string(b)
+	}
+
+	_, err := db.exec(ctx, db.DB, "INSERT INTO request_queues (id, squad_id, approvers, handlers, approvers_path, handlers_path, waiting_approve, processing, workflow, sla_approve_minutes, sla_process_minutes, fields) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"+
+		" ON CONFLICT (id) DO UPDATE SET squad_id = excluded.squad_id, approvers = excluded.approvers, handlers = excluded.handlers, approvers_path = excluded.approvers_path, handlers_path = excluded.handlers_path, workflow = excluded.workflow,"+
+		" sla_approve_minutes = excluded.sla_approve_minutes, sla_process_minutes = excluded.sla_process_minutes, fields = excluded.fields",
+		queueId, qi.SquadId, qi.Approvers, qi.Handlers, approversPath, handlersPath, qi.WaitingApprove, qi.Processing, workflow, sla.ApproveMinutes, sla.ProcessMinutes, fields)
+	if err != nil {
+		return fmt.Errorf("Failed to create queue %v: %w", queueId, err)
+	}
+
+	return nil
+}
+
+func scanRequest(row interface{ Scan(...interface{}) error }) (*RequestRecord, error) {
+	r := &RequestRecord{}
+	var timestamp time.Time
+	var statusTime sql.NullTime
+	var fields string
+	err := row.Scan(&r.RequestId, &r.QueueId, &r.UserId, &r.UserName, &r.Details, &r.Status, &r.State, &timestamp, &r.AssigneeId, &r.AssigneeName, &statusTime, &r.Escalated, &fields)
+	if err != nil {
+		return nil, err
+	}
+	if fields != "" {
+		if err = json.Unmarshal([]byte(fields), &r.Fields); err != nil {
+			return nil, fmt.Errorf("Failed to unmarshal request fields: %w", err)
+		}
+	}
+	r.Time = utcTime(timestamp)
+	if statusTime.Valid {
+		r.StatusTime = utcTime(statusTime.Time)
+	}
+	return r, nil
+}
+
+func (db *SQLDB) GetRequest(ctx context.Context, requestId string) (*RequestDetails, error) {
+	r, err := scanRequest(db.queryRow(ctx, db.DB, "SELECT "+sqlRequestColumns+" FROM requests r WHERE r.id = ?", requestId))
+	if err != nil {
+		return nil, sqlNotFound(err, "Failed to get request %v: not found", requestId)
+	}
+
+	return &r.RequestDetails, nil
+}
+
+func scanQueue(row interface{ Scan(...interface{}) error }, qi *QueueInfo, dest ...interface{}) error {
+	var workflow, fields string
+	sla := &QueueSLA{}
+	dest = append(dest, &qi.SquadId, &qi.Approvers, &qi.Handlers, &qi.WaitingApprove, &qi.Processing, &workflow, &sla.ApproveMinutes, &sla.ProcessMinutes, &fields)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+
+	if fields != "" {
+		if err := json.Unmarshal([]byte(fields), &qi.Fields); err != nil {
+			return fmt.Errorf("Failed to unmarshal queue fields: %w", err)
+		}
+	}
+
+	if sla.enabled() {
+		qi.SLA = sla
+	}
+
+	if workflow != "" {
+		qi.Workflow = &Workflow{}
+		if err := json.Unmarshal([]byte(workflow), qi.Workflow); err != nil {
+			return fmt.Errorf("Failed to unmarshal queue workflow: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (db *SQLDB) GetRequestQueue(ctx context.Context, queueId string) (*QueueInfo, error) {
+	qi := &QueueInfo{}
+	err := scanQueue(db.queryRow(ctx, db.DB, "SELECT "+sqlQueueColumns+" FROM request_queues WHERE id = ?", queueId), qi)
+	if err != nil {
+		return nil, sqlNotFound(err, "Failed to get queue %v: not found", queueId)
+	}
+
+	return qi, nil
+}
+
+func (db *SQLDB) DeleteRequestsQueue(ctx context.Context, queueId string) error {
+	_, err := db.exec(ctx, db.DB, "DELETE FROM request_queues WHERE id = ?", queueId)
+	if err != nil {
+		return fmt.Errorf("Failed to delete queue %v: %w", queueId, err)
+	}
+
+	return nil
+}
+
+func (db *SQLDB) GetRequestQueues(ctx context.Context, squadId string) ([]*QueueRecord, error) {
+	if db.dev {
+		log.Println("Getting request queues for squad " + squadId)
+	}
+
+	rows, err := db.query(ctx, db.DB, "SELECT id, "+sqlQueueColumns+" FROM request_queues WHERE squad_id = ? ORDER BY id", squadId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get request queues: %w", err)
+	}
+	defer rows.Close()
+
+	queues := make([]*QueueRecord, 0)
+	for rows.Next() {
+		q := &QueueRecord{}
+		if err = scanQueue(rows, &q.QueueInfo, &q.ID); err != nil {
+			return nil, fmt.Errorf("Failed to get request queues: %w", err)
+		}
+		queues = append(queues, q)
+	}
+
+	return queues, rows.Err()
+}
+
+// approvers should be able to see requests they approved and being processed
+// now, so queuesToHandle is a superset for queuesToApprove; values are the
+// amount of requests waiting for approve and being processed respectively
+func (db *SQLDB) getQueuesToApproveAndHandleIds(ctx context.Context, q sqlQueryer, userTags []string, squadsAdmin []string) (map[string]int, map[string]int, error) {
+	queuesToApprove := make(map[string]int, 0)
+	queuesToHandle := make(map[string]int, 0)
+
+	approve := "FALSE"
+	handle := "FALSE"
+	var approveArgs, handleArgs []interface{}
+	if len(userTags) > 0 {
+		in, tagArgs := inArgs(userTags)
+		approve = "approvers_path IN (" + in + ")"
+		handle = "handlers_path IN (" + in + ")"
+		approveArgs = append(approveArgs, tagArgs...)
+		handleArgs = append(handleArgs, tagArgs...)
+	}
+	if len(squadsAdmin) > 0 {
+		in, squadArgs := inArgs(squadsAdmin)
+		approve = "(" + approve + " OR squad_id IN (" + in + "))"
+		approveArgs = append(approveArgs, squadArgs...)
+	}
+	if len(approveArgs) == 0 {
+		return queuesToApprove, queuesToHandle, nil
+	}
+
+	query := "SELECT id, waiting_approve, processing, CASE WHEN " + approve + " THEN 1 ELSE 0 END FROM request_queues WHERE " + approve + " OR " + handle
+	args := append(append(append([]interface{}{}, approveArgs...), approveArgs...), handleArgs...)
+
+	rows, err := db.query(ctx, q, query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to get request queues: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var waitingApprove, processing, canApprove int
+		if err = rows.Scan(&id, &waitingApprove, &processing, &canApprove); err != nil {
+			return nil, nil, fmt.Errorf("Failed to get request queues: %w", err)
+		}
+		if canApprove == 1 {
+			queuesToApprove[id] = waitingApprove
+		}
+		queuesToHandle[id] = processing
+	}
+
+	return queuesToApprove, queuesToHandle, rows.Err()
+}
+
+func (db *SQLDB) GetQueuesToApproveAndHandle(ctx context.Context, userTags []string, squadsAdmin []string) (map[string]int, map[string]int, error) {
+	if db.dev {
+		log.Printf("Getting request queues for user tags %v", userTags)
+	}
+
+	return db.getQueuesToApproveAndHandleIds(ctx, db.DB, userTags, squadsAdmin)
+}
+
+// returns condition matching requests passing the filter
+func sqlRequestsFilterWhere(filter *RequestsFilter) (string, []interface{}) {
+	where := ""
+	args := make([]interface{}, 0)
+	if filter == nil {
+		return where, args
+	}
+
+	if filter.QueueId != "" {
+		where += " AND r.queue_id = ?"
+		args = append(args, filter.QueueId)
+	}
+
+	names := make([]string, 0, len(filter.Fields))
+	for name := range filter.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		where += " AND EXISTS (SELECT 1 FROM request_fields f WHERE f.request_id = r.id AND f.name = ? AND f.value = ?)"
+		args = append(args, name, fieldStrings(filter.Fields[name])[0])
+	}
+
+	return where, args
+}
+
+// returns first 10 requests matching the condition ordered by time desc
+func (db *SQLDB) getRequests(ctx context.Context, q sqlQueryer, from *time.Time, where string, args ...interface{}) ([]RequestRecord, error) {
+	query := "SELECT " + sqlRequestColumns + " FROM requests r WHERE " + where
+	if from != nil {
+		query += " AND r.created_at < ?"
+		args = append(args, from.UTC())
+	}
+	query += " ORDER BY r.created_at DESC LIMIT ?"
+	args = append(args, numRecords)
+
+	rows, err := db.query(ctx, q, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get requests: %w", err)
+	}
+	defer rows.Close()
+
+	requests := make([]RequestRecord, 0)
+	for rows.Next() {
+		r, err := scanRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get requests: %w", err)
+		}
+		requests = append(requests, *r)
+	}
+
+	return requests, rows.Err()
+}
+
+func (db *SQLDB) getQueueRequests(ctx context.Context, q sqlQueryer, queues []string, status RequestStatusType, from *time.Time, filter *RequestsFilter) ([]RequestRecord, error) {
+	if len(queues) == 0 {
+		return make([]RequestRecord, 0), nil
+	}
+
+	in, args := inArgs(queues)
+	where, filterArgs := sqlRequestsFilterWhere(filter)
+	args = append(append([]interface{}{status}, args...), filterArgs...)
+	return db.getRequests(ctx, q, from, "r.status = ? AND r.queue_id IN ("+in+")"+where, args...)
+}
+
+func (db *SQLDB) GetUserQueuesAndRequests(ctx context.Context, userId string, userTags []string, squadsAdmin []string, squadsAll []string) (userQueues []string, userRequests []RequestRecord, queuesToApprove []string, requestsToApprove []RequestRecord, queuesToHandle []string, requestsToHandle []RequestRecord, err error) {
+	queuesToApproveMap, queuesToHandleMap, err := db.getQueuesToApproveAndHandleIds(ctx, db.DB, userTags, squadsAdmin)
+	if err != nil {
+		return
+	}
+
+	queuesToApprove = sortedKeys(queuesToApproveMap)
+	if len(queuesToApprove) > 0 {
+		if requestsToApprove, err = db.getQueueRequests(ctx, db.DB, queuesToApprove, WaitingApprove, nil, nil); err != nil {
+			return
+		}
+	}
+
+	queuesToHandle = sortedKeys(queuesToHandleMap)
+	if len(queuesToHandle) > 0 {
+		if requestsToHandle, err = db.getQueueRequests(ctx, db.DB, queuesToHandle, Processing, nil, nil); err != nil {
+			return
+		}
+	}
+
+	// get queues from user squads (where they might file requests)
+	userQueues = make([]string, 0)
+	if len(squadsAll) > 0 {
+		in, args := inArgs(squadsAll)
+		var rows *sql.Rows
+		rows, err = db.query(ctx, db.DB, "SELECT id FROM request_queues WHERE squad_id IN ("+in+") ORDER BY id", args...)
+		if err != nil {
+			err = fmt.Errorf("Failed to get user queues: %w", err)
+			return
+		}
+		userQueues, err = scanStrings(rows)
+		if err != nil {
+			return
+		}
+	}
+
+	userRequests, err = db.getRequests(ctx, db.DB, nil, "r.user_id = ?", userId)
+
+	return
+}
+
+func (db *SQLDB) CreateRequest(ctx context.Context, request *RequestDetails) (string, error) {
+	if db.dev {
+		log.Printf("Creating request %+v\n", request)
+	}
+
+	id := newDocId()
+	err := db.inTx(ctx, func(tx *sql.Tx) error {
+		if err := db.updateQueueCounter(ctx, tx, request.QueueId, request.Status, 1); err != nil {
+			return err
+		}
+
+		var fields string
+		if len(request.Fields) > 0 {
+			b, err := json.Marshal(request.Fields)
+			if err != nil {
+				return fmt.Errorf("Failed to marshal request fields: %w", err)
+			}
+			fields = string(b)
+		}
+
+		now := time.Now().UTC()
+		_, err := db.exec(ctx, tx, "INSERT INTO requests (id, queue_id, user_id, user_name, details, status, state, created_at, status_time, fields) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			id, request.QueueId, request.UserId, request.UserName, request.Details, request.Status, request.State, now, now, fields)
+		if err != nil {
+			return err
+		}
+
+		for name, value := range request.Fields {
+			for _, v := range fieldStrings(value) {
+				if _, err = db.exec(ctx, tx, "INSERT INTO request_fields (request_id, name, value) VALUES (?, ?, ?)", id, name, v); err != nil {
+					return err
+				}
+			}
+		}
+
+		return db.addRequestHistory(ctx, tx, id, &RequestHistoryEntry{
+			UserId:    request.UserId,
+			UserName:  request.UserName,
+			NewState:  request.CurrentState(),
+			NewStatus: request.Status,
+			Time:      &now,
+		})
+	})
+	if err != nil {
+		return "", fmt.Errorf("Failed to create request: %w", err)
+	}
+
+	return id, nil
+}
+
+func (db *SQLDB) GetUserRequests(ctx context.Context, userId string, from *time.Time, filter *RequestsFilter) ([]RequestRecord, error) {
+	where, args := sqlRequestsFilterWhere(filter)
+	return db.getRequests(ctx, db.DB, from, "r.user_id = ?"+where, append([]interface{}{userId}, args...)...)
+}
+
+func (db *SQLDB) GetRequestsByTag(ctx context.Context, tags []string, squadsAdmin []string, status RequestStatusType, from *time.Time, filter *RequestsFilter) ([]RequestRecord, error) {
+	if db.dev {
+		log.Printf("Getting requests %v by %v, from %v", status, tags, from)
+	}
+
+	queuesMap, queuesToHandleMap, err := db.getQueuesToApproveAndHandleIds(ctx, db.DB, tags, squadsAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if status == Processing {
+		queuesMap = queuesToHandleMap
+	}
+
+	return db.getQueueRequests(ctx, db.DB, sortedKeys(queuesMap), status, from, filter)
+}
+
+func (db *SQLDB) addRequestHistory(ctx context.Context, q sqlQueryer, requestId string, entry *RequestHistoryEntry) error {
+	_, err := db.exec(ctx, q, "INSERT INTO request_history (id, request_id, user_id, user_name, old_state, new_state, old_status, new_status, comment, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		newDocId(), requestId, entry.UserId, entry.UserName, entry.OldState, entry.NewState, entry.OldStatus, entry.NewStatus, entry.Comment, entry.Time.UTC())
+	if err != nil {
+		return fmt.Errorf("Failed to add request %v history: %w", requestId, err)
+	}
+	return nil
+}
+
+func (db *SQLDB) SetRequestState(ctx context.Context, requestId string, fromState string, state string, newStatus RequestStatusType, entry *RequestHistoryEntry) error {
+	err := db.inTx(ctx, func(tx *sql.Tx) error {
+		var queueId, oldState string
+		var oldStatus RequestStatusType
+		err := db.queryRow(ctx, tx, "SELECT queue_id, status, state FROM requests WHERE id = ?", requestId).Scan(&queueId, &oldStatus, &oldState)
+		if err != nil {
+			return sqlNotFound(err, "Failed to get request %v: not found", requestId)
+		}
+		current := (&RequestDetails{State: oldState, Status: oldStatus}).CurrentState()
+		if current != fromState {
+			return stateChanged(requestId)
+		}
+
+		// state is compared again, request could be moved after it was read
+		res, err := db.exec(ctx, tx, "UPDATE requests SET status = ?, state = ? WHERE id = ? AND status = ? AND state = ?", newStatus, state, requestId, oldStatus, oldState)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return stateChanged(requestId)
+		}
+
+		e := *entry
+		now := time.Now().UTC()
+		e.OldState, e.OldStatus = current, oldStatus
+		e.NewState, e.NewStatus = state, newStatus
+		e.Time = &now
+		if err = db.addRequestHistory(ctx, tx, requestId, &e); err != nil {
+			return err
+		}
+
+		if oldStatus == newStatus {
+			return nil
+		}
+
+		if _, err = db.exec(ctx, tx, "UPDATE requests SET status_time = ?, escalated = ? WHERE id = ?", now, false, requestId); err != nil {
+			return err
+		}
+		if err = db.updateQueueCounter(ctx, tx, queueId, oldStatus, -1); err != nil {
+			return err
+		}
+		return db.updateQueueCounter(ctx, tx, queueId, newStatus, 1)
+	})
+	if err != nil {
+		if status.Code(err) == codes.FailedPrecondition || status.Code(err) == codes.NotFound {
+			return err
+		}
+		return fmt.Errorf("Failed to set request "+requestId+" status: %w", err)
+	}
+
+	return nil
+}
+
+func (db *SQLDB) GetRequestHistory(ctx context.Context, requestId string) ([]*RequestHistoryEntry, error) {
+	rows, err := db.query(ctx, db.DB, "SELECT user_id, user_name, old_state, new_state, old_status, new_status, comment, created_at FROM request_history WHERE request_id = ? ORDER BY created_at, id", requestId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get request %v history: %w", requestId, err)
+	}
+	defer rows.Close()
+
+	history := make([]*RequestHistoryEntry, 0)
+	for rows.Next() {
+		e := &RequestHistoryEntry{}
+		var timestamp time.Time
+		if err = rows.Scan(&e.UserId, &e.UserName, &e.OldState, &e.NewState, &e.OldStatus, &e.NewStatus, &e.Comment, &timestamp); err != nil {
+			return nil, fmt.Errorf("Failed to get request %v history: %w", requestId, err)
+		}
+		e.Time = utcTime(timestamp)
+		history = append(history, e)
+	}
+
+	return history, rows.Err()
+}
+
+func (db *SQLDB) SetRequestAssignee(ctx context.Context, requestId string, fromAssigneeId string, assigneeId string, assigneeName string) error {
+	err := db.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := db.exec(ctx, tx, "UPDATE requests SET assignee_id = ?, assignee_name = ? WHERE id = ? AND assignee_id = ?", assigneeId, assigneeName, requestId, fromAssigneeId)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n > 0 {
+			return err
+		}
+
+		// find out why request was not updated
+		var currentName string
+		err = db.queryRow(ctx, tx, "SELECT assignee_name FROM requests WHERE id = ?", requestId).Scan(&currentName)
+		if err != nil {
+			return sqlNotFound(err, "Failed to get request %v: not found", requestId)
+		}
+		return assigneeChanged(requestId, currentName)
+	})
+	if err != nil {
+		if status.Code(err) == codes.FailedPrecondition || status.Code(err) == codes.NotFound {
+			return err
+		}
+		return fmt.Errorf("Failed to set request %v assignee: %w", requestId, err)
+	}
+
+	return nil
+}
+
+func (db *SQLDB) GetQueuesWithSLA(ctx context.Context) ([]*QueueRecord, error) {
+	rows, err := db.query(ctx, db.DB, "SELECT id, "+sqlQueueColumns+" FROM request_queues WHERE sla_approve_minutes > 0 OR sla_process_minutes > 0 ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get request queues: %w", err)
+	}
+	defer rows.Close()
+
+	queues := make([]*QueueRecord, 0)
+	for rows.Next() {
+		q := &QueueRecord{}
+		if err = scanQueue(rows, &q.QueueInfo, &q.ID); err != nil {
+			return nil, fmt.Errorf("Failed to get request queues: %w", err)
+		}
+		queues = append(queues, q)
+	}
+
+	return queues, rows.Err()
+}
+
+func (db *SQLDB) GetOverdueRequests(ctx context.Context, queueId string, status RequestStatusType, before time.Time) ([]RequestRecord, error) {
+	return db.getRequests(ctx, db.DB, nil, "r.queue_id = ? AND r.status = ? AND r.escalated = ? AND r.status_time < ?", queueId, status, false, before.UTC())
+}
+
+func (db *SQLDB) SetRequestEscalated(ctx context.Context, requestId string) error {
+	res, err := db.exec(ctx, db.DB, "UPDATE requests SET escalated = ? WHERE id = ?", true, requestId)
+	if err != nil {
+		return fmt.Errorf("Failed to mark request %v escalated: %w", requestId, err)
+	}
+
+	return checkAffected(res, "Failed to get request %v: not found", requestId)
+}
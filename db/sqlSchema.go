@@ -0,0 +1,388 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Schema migrations, applied in order and never edited once released - add
+// a new entry to change the schema. Statements must be valid both for
+// PostgreSQL and SQLite.
+var sqlMigrations = [][]string{
+	// 1: initial schema
+	{
+		`CREATE TABLE squads (
+			id TEXT PRIMARY KEY,
+			owner_id TEXT NOT NULL DEFAULT '',
+			members_count INTEGER NOT NULL DEFAULT 0,
+			pending_approve_count INTEGER NOT NULL DEFAULT 0,
+			created_at TIMESTAMP NOT NULL
+		)`,
+		// members of ALL_USERS_SQUAD are the users of the system
+		`CREATE TABLE squad_members (
+			squad_id TEXT NOT NULL REFERENCES squads(id) ON DELETE CASCADE,
+			user_id TEXT NOT NULL,
+			display_name TEXT NOT NULL DEFAULT '',
+			email TEXT NOT NULL DEFAULT '',
+			phone_number TEXT NOT NULL DEFAULT '',
+			replicant BOOLEAN NOT NULL DEFAULT FALSE,
+			status INTEGER NOT NULL,
+			notes TEXT NOT NULL DEFAULT '',
+			search_keys TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (squad_id, user_id)
+		)`,
+		`CREATE INDEX squad_members_user_id ON squad_members (user_id)`,
+		`CREATE TABLE squad_member_tags (
+			squad_id TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			tag TEXT NOT NULL,
+			position INTEGER NOT NULL,
+			PRIMARY KEY (squad_id, user_id, tag),
+			FOREIGN KEY (squad_id, user_id) REFERENCES squad_members (squad_id, user_id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX squad_member_tags_tag ON squad_member_tags (squad_id, tag)`,
+		`CREATE TABLE squad_tags (
+			squad_id TEXT NOT NULL REFERENCES squads(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			PRIMARY KEY (squad_id, name)
+		)`,
+		`CREATE TABLE squad_tag_values (
+			squad_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			value TEXT NOT NULL,
+			count INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (squad_id, name, value),
+			FOREIGN KEY (squad_id, name) REFERENCES squad_tags (squad_id, name) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE squad_notes (
+			id TEXT PRIMARY KEY,
+			squad_id TEXT NOT NULL REFERENCES squads(id) ON DELETE CASCADE,
+			title TEXT NOT NULL,
+			text TEXT NOT NULL,
+			published BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE INDEX squad_notes_squad_id ON squad_notes (squad_id, created_at)`,
+		`CREATE TABLE events (
+			id TEXT PRIMARY KEY,
+			squad_id TEXT NOT NULL,
+			owner_id TEXT NOT NULL,
+			date TIMESTAMP NOT NULL,
+			time_from TEXT NOT NULL DEFAULT '',
+			time_to TEXT NOT NULL DEFAULT '',
+			text TEXT NOT NULL DEFAULT '',
+			archived BOOLEAN NOT NULL DEFAULT FALSE,
+			not_going INTEGER NOT NULL DEFAULT 0,
+			applied INTEGER NOT NULL DEFAULT 0,
+			going INTEGER NOT NULL DEFAULT 0,
+			attended INTEGER NOT NULL DEFAULT 0,
+			no_show INTEGER NOT NULL DEFAULT 0
+		)`,
+		`CREATE INDEX events_squad_id ON events (squad_id, archived, date)`,
+		`CREATE INDEX events_date ON events (date)`,
+		// participant details are taken from squad_members of the event squad
+		`CREATE TABLE event_participants (
+			event_id TEXT NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+			user_id TEXT NOT NULL,
+			status INTEGER NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (event_id, user_id)
+		)`,
+		`CREATE INDEX event_participants_user_id ON event_participants (user_id)`,
+		`CREATE TABLE request_queues (
+			id TEXT PRIMARY KEY,
+			squad_id TEXT NOT NULL,
+			approvers TEXT NOT NULL DEFAULT '',
+			handlers TEXT NOT NULL DEFAULT '',
+			approvers_path TEXT NOT NULL DEFAULT '',
+			handlers_path TEXT NOT NULL DEFAULT '',
+			waiting_approve INTEGER NOT NULL DEFAULT 0,
+			processing INTEGER NOT NULL DEFAULT 0,
+			completed INTEGER NOT NULL DEFAULT 0,
+			declined INTEGER NOT NULL DEFAULT 0,
+			cancelled INTEGER NOT NULL DEFAULT 0
+		)`,
+		`CREATE INDEX request_queues_squad_id ON request_queues (squad_id)`,
+		`CREATE TABLE requests (
+			id TEXT PRIMARY KEY,
+			queue_id TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			user_name TEXT NOT NULL DEFAULT '',
+			details TEXT NOT NULL DEFAULT '',
+			status INTEGER NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE INDEX requests_queue_id ON requests (queue_id, status, created_at)`,
+		`CREATE INDEX requests_user_id ON requests (user_id, created_at)`,
+		`INSERT INTO squads (id, created_at) VALUES ('` + ALL_USERS_SQUAD + `', CURRENT_TIMESTAMP)`,
+	},
+	// 2: credentials of the local auth provider
+	{
+		`CREATE TABLE credentials (
+			email TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL UNIQUE,
+			password_hash TEXT NOT NULL,
+			email_verified BOOLEAN NOT NULL DEFAULT FALSE
+		)`,
+	},
+	// 3: request workflows, queue workflow is kept as JSON
+	{
+		`ALTER TABLE request_queues ADD COLUMN workflow TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE requests ADD COLUMN state TEXT NOT NULL DEFAULT ''`,
+	},
+	// 4: request history
+	{
+		`CREATE TABLE request_history (
+			id TEXT PRIMARY KEY,
+			request_id TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			user_name TEXT NOT NULL DEFAULT '',
+			old_state TEXT NOT NULL DEFAULT '',
+			new_state TEXT NOT NULL,
+			old_status INTEGER NOT NULL DEFAULT 0,
+			new_status INTEGER NOT NULL,
+			comment TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE INDEX request_history_request_id ON request_history (request_id, created_at)`,
+	},
+	// 5: request comments
+	{
+		`CREATE TABLE request_comments (
+			id TEXT PRIMARY KEY,
+			request_id TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			user_name TEXT NOT NULL DEFAULT '',
+			text TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE INDEX request_comments_request_id ON request_comments (request_id, created_at)`,
+	},
+	// 6: request assignee and queue SLA
+	{
+		`ALTER TABLE requests ADD COLUMN assignee_id TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE requests ADD COLUMN assignee_name TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE requests ADD COLUMN status_time TIMESTAMP`,
+		`ALTER TABLE requests ADD COLUMN escalated BOOLEAN NOT NULL DEFAULT FALSE`,
+		`UPDATE requests SET status_time = created_at`,
+		`ALTER TABLE request_queues ADD COLUMN sla_approve_minutes INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE request_queues ADD COLUMN sla_process_minutes INTEGER NOT NULL DEFAULT 0`,
+	},
+	// 7: queue fields, values are kept as JSON and also one row per value for filtering
+	{
+		`ALTER TABLE request_queues ADD COLUMN fields TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE requests ADD COLUMN fields TEXT NOT NULL DEFAULT ''`,
+		`CREATE TABLE request_fields (
+			request_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			value TEXT NOT NULL
+		)`,
+		`CREATE INDEX request_fields_name_value ON request_fields (name, value)`,
+		`CREATE INDEX request_fields_request_id ON request_fields (request_id)`,
+	},
+	// 8: recurring events, exception dates and series participants are kept as JSON
+	{
+		`CREATE TABLE event_series (
+			id TEXT PRIMARY KEY,
+			squad_id TEXT NOT NULL,
+			owner_id TEXT NOT NULL,
+			text TEXT NOT NULL DEFAULT '',
+			time_from TEXT NOT NULL DEFAULT '',
+			time_to TEXT NOT NULL DEFAULT '',
+			start TIMESTAMP NOT NULL,
+			rrule TEXT NOT NULL,
+			ex_dates TEXT NOT NULL DEFAULT '',
+			participants TEXT NOT NULL DEFAULT '',
+			cancelled BOOLEAN NOT NULL DEFAULT FALSE,
+			generated_until TIMESTAMP
+		)`,
+		`ALTER TABLE events ADD COLUMN series_id TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE events ADD COLUMN series_override BOOLEAN NOT NULL DEFAULT FALSE`,
+		`CREATE INDEX events_series_id ON events (series_id, archived, date)`,
+	},
+	// 9: events capacity and waitlist
+	{
+		`ALTER TABLE events ADD COLUMN capacity INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE events ADD COLUMN waitlisted INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE event_series ADD COLUMN capacity INTEGER NOT NULL DEFAULT 0`,
+	},
+	// 10: events reminders, minutes before the event are kept as JSON
+	{
+		`ALTER TABLE events ADD COLUMN reminders TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE event_series ADD COLUMN reminders TEXT NOT NULL DEFAULT ''`,
+		// reminders already sent, so they are not sent again after restart
+		`CREATE TABLE event_reminders (
+			event_id TEXT NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+			remind_at TIMESTAMP NOT NULL,
+			sent_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (event_id, remind_at)
+		)`,
+	},
+	// 11: background jobs status and lock
+	{
+		`CREATE TABLE jobs (
+			name TEXT PRIMARY KEY,
+			locked_by TEXT NOT NULL DEFAULT '',
+			locked_until TIMESTAMP,
+			last_start TIMESTAMP,
+			last_finish TIMESTAMP,
+			last_error TEXT NOT NULL DEFAULT ''
+		)`,
+	},
+	// 12: events location kept as JSON, and venues saved in squads
+	{
+		`ALTER TABLE events ADD COLUMN location TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE event_series ADD COLUMN location TEXT NOT NULL DEFAULT ''`,
+		`CREATE TABLE squad_venues (
+			id TEXT PRIMARY KEY,
+			squad_id TEXT NOT NULL REFERENCES squads(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			address TEXT NOT NULL DEFAULT '',
+			lat DOUBLE PRECISION,
+			lon DOUBLE PRECISION,
+			meeting_url TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE INDEX squad_venues_squad_id ON squad_venues (squad_id, name)`,
+	},
+	// 13: event templates of squads, reminders, location and tags kept as JSON
+	{
+		`CREATE TABLE squad_event_templates (
+			id TEXT PRIMARY KEY,
+			squad_id TEXT NOT NULL REFERENCES squads(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			text TEXT NOT NULL DEFAULT '',
+			time_from TEXT NOT NULL DEFAULT '',
+			time_to TEXT NOT NULL DEFAULT '',
+			capacity INTEGER NOT NULL DEFAULT 0,
+			reminders TEXT NOT NULL DEFAULT '',
+			location TEXT NOT NULL DEFAULT '',
+			tags TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE INDEX squad_event_templates_squad_id ON squad_event_templates (squad_id, name)`,
+	},
+	// 14: notifications inbox of users
+	{
+		`CREATE TABLE notifications (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			time TIMESTAMP NOT NULL,
+			title TEXT NOT NULL,
+			text TEXT NOT NULL,
+			is_read BOOLEAN NOT NULL DEFAULT FALSE,
+			link_type TEXT NOT NULL DEFAULT '',
+			link_id TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE INDEX notifications_user_id ON notifications (user_id, time)`,
+		`CREATE INDEX notifications_time ON notifications (time)`,
+	},
+	// 15: notification kinds, email digest preferences of users and notifications waiting for digest
+	{
+		`ALTER TABLE notifications ADD COLUMN kind TEXT NOT NULL DEFAULT ''`,
+		`CREATE TABLE notification_prefs (
+			user_id TEXT PRIMARY KEY,
+			email_digest TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE TABLE digest_items (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			digest TEXT NOT NULL,
+			kind TEXT NOT NULL DEFAULT '',
+			time TIMESTAMP NOT NULL,
+			title TEXT NOT NULL,
+			text TEXT NOT NULL,
+			link_type TEXT NOT NULL DEFAULT '',
+			link_id TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE INDEX digest_items_digest ON digest_items (digest, user_id, time)`,
+	},
+	// 16: notification preferences of users per squad and kind, channels kept comma separated
+	{
+		`CREATE TABLE notification_kind_prefs (
+			user_id TEXT NOT NULL,
+			squad_id TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			muted BOOLEAN NOT NULL DEFAULT FALSE,
+			channels TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (user_id, squad_id, kind)
+		)`,
+		`CREATE INDEX notification_kind_prefs_squad_id ON notification_kind_prefs (squad_id, kind)`,
+	},
+	// 17: FCM tokens of user devices
+	{
+		`CREATE TABLE push_tokens (
+			token TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			device TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL,
+			last_seen TIMESTAMP NOT NULL
+		)`,
+		`CREATE INDEX push_tokens_user_id ON push_tokens (user_id)`,
+	},
+	// 18: webhooks of squads with events kept comma separated, and log of their deliveries
+	{
+		`CREATE TABLE squad_webhooks (
+			id TEXT PRIMARY KEY,
+			squad_id TEXT NOT NULL REFERENCES squads(id) ON DELETE CASCADE,
+			url TEXT NOT NULL,
+			events TEXT NOT NULL DEFAULT '',
+			secret TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE INDEX squad_webhooks_squad_id ON squad_webhooks (squad_id, created_at)`,
+		`CREATE TABLE webhook_deliveries (
+			id TEXT PRIMARY KEY,
+			webhook_id TEXT NOT NULL REFERENCES squad_webhooks(id) ON DELETE CASCADE,
+			squad_id TEXT NOT NULL,
+			event TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			status TEXT NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			created_at TIMESTAMP NOT NULL,
+			next_attempt TIMESTAMP NOT NULL,
+			last_attempt TIMESTAMP,
+			response_code INTEGER NOT NULL DEFAULT 0,
+			error TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE INDEX webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id, created_at)`,
+		`CREATE INDEX webhook_deliveries_due ON webhook_deliveries (status, next_attempt)`,
+	},
+}
+
+func (db *SQLDB) migrate(ctx context.Context) error {
+	_, err := db.DB.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("Failed to create schema_migrations table: %w", err)
+	}
+
+	var version int
+	err = db.DB.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		return fmt.Errorf("Failed to get schema version: %w", err)
+	}
+
+	for v := version + 1; v <= len(sqlMigrations); v++ {
+		log.Printf("Applying database migration %v", v)
+
+		err = db.inTx(ctx, func(tx *sql.Tx) error {
+			for _, stmt := range sqlMigrations[v-1] {
+				if _, err := tx.ExecContext(ctx, stmt); err != nil {
+					return fmt.Errorf("Failed to apply migration %v: %w", v, err)
+				}
+			}
+			_, err := db.exec(ctx, tx, "INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)", v, time.Now().UTC())
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
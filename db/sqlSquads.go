@@ -0,0 +1,469 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (db *SQLDB) updateSquadCounters(ctx context.Context, q sqlQueryer, squadId string, membersDelta int, pendingApproveDelta int) error {
+	res, err := db.exec(ctx, q, "UPDATE squads SET members_count = members_count + ?, pending_approve_count = pending_approve_count + ? WHERE id = ?", membersDelta, pendingApproveDelta, squadId)
+	if err != nil {
+		return fmt.Errorf("Failed to update squad %v counters: %w", squadId, err)
+	}
+	return checkAffected(res, "Squad %v not found", squadId)
+}
+
+func memberCounterDelta(status MemberStatusType, inc int) (int, int) {
+	if status == PendingApprove {
+		return 0, inc
+	}
+	return inc, 0
+}
+
+func (db *SQLDB) getUserSquadsCount(ctx context.Context, q sqlQueryer, userId string) (int, error) {
+	var count int
+	err := db.queryRow(ctx, q, "SELECT COUNT(*) FROM squad_members WHERE user_id = ? AND squad_id <> ?", userId, ALL_USERS_SQUAD).Scan(&count)
+	return count, err
+}
+
+func (db *SQLDB) CreateSquad(ctx context.Context, squadId string, ownerId string) error {
+	if db.dev {
+		log.Println("Creating squad " + squadId)
+	}
+
+	return db.inTx(ctx, func(tx *sql.Tx) error {
+		count, err := db.getUserSquadsCount(ctx, tx, ownerId)
+		if err != nil {
+			return err
+		}
+		if count >= 10 {
+			return fmt.Errorf("User might participate in 10 squads maximum")
+		}
+
+		var exists int
+		err = db.queryRow(ctx, tx, "SELECT COUNT(*) FROM squads WHERE id = ?", squadId).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists > 0 {
+			return status.Errorf(codes.AlreadyExists, "Squad %v already exists", squadId)
+		}
+
+		_, err = db.exec(ctx, tx, "INSERT INTO squads (id, owner_id, created_at) VALUES (?, ?, ?)", squadId, ownerId, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+
+		_, err = db.addMemberToSquad(ctx, tx, ownerId, squadId, Owner)
+		return err
+	})
+}
+
+func (db *SQLDB) getSquad(ctx context.Context, q sqlQueryer, squadId string) (*SquadInfo, error) {
+	s := &SquadInfo{}
+	err := db.queryRow(ctx, q, "SELECT owner_id, members_count, pending_approve_count FROM squads WHERE id = ?", squadId).Scan(&s.Owner, &s.MembersCount, &s.PendingApproveCount)
+	if err != nil {
+		return nil, sqlNotFound(err, "Failed to get squad %v: not found", squadId)
+	}
+	return s, nil
+}
+
+func (db *SQLDB) GetSquad(ctx context.Context, ID string) (*SquadInfo, error) {
+	if db.dev {
+		log.Println("Getting details for squad " + ID)
+	}
+
+	return db.getSquad(ctx, db.DB, ID)
+}
+
+func (db *SQLDB) DeleteSquad(ctx context.Context, squadId string) error {
+	if db.dev {
+		log.Println("Deleting squad " + squadId)
+	}
+
+	res, err := db.exec(ctx, db.DB, "DELETE FROM squads WHERE id = ?", squadId)
+	if err == nil {
+		err = checkAffected(res, "Squad %v not found", squadId)
+	}
+	if err != nil {
+		return fmt.Errorf("Error while deleting squad %v: %w", squadId, err)
+	}
+
+	return nil
+}
+
+func (db *SQLDB) GetOtherSquads(ctx context.Context, userId string) ([]string, error) {
+	if db.dev {
+		log.Println("Getting squads for user " + userId)
+	}
+
+	rows, err := db.query(ctx, db.DB, "SELECT id FROM squads s WHERE id <> ? AND NOT EXISTS (SELECT 1 FROM squad_members m WHERE m.squad_id = s.id AND m.user_id = ?) ORDER BY id", ALL_USERS_SQUAD, userId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get other squads: %w", err)
+	}
+	defer rows.Close()
+
+	return scanStrings(rows)
+}
+
+func (db *SQLDB) GetUserSquads(ctx context.Context, userId string, status string) ([]string, error) {
+	query := "SELECT squad_id FROM squad_members WHERE user_id = ? AND squad_id <> ?"
+	args := []interface{}{userId, ALL_USERS_SQUAD}
+	switch status {
+	case "":
+	case "admin":
+		query += " AND status > ?"
+		args = append(args, Admin)
+	default:
+		return nil, fmt.Errorf("Do not know what to do with status=%v", status)
+	}
+
+	rows, err := db.query(ctx, db.DB, query+" ORDER BY squad_id", args...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get user squads: %w", err)
+	}
+	defer rows.Close()
+
+	return scanStrings(rows)
+}
+
+func (db *SQLDB) GetUserSquadsMap(ctx context.Context, userID string, status string, includeAllUsersSquad bool) (map[string]*MemberSquadInfoRecord, error) {
+	query := "SELECT s.id, s.owner_id, s.members_count, s.pending_approve_count, m.status FROM squads s JOIN squad_members m ON m.squad_id = s.id WHERE m.user_id = ? AND s.id <> ?"
+	args := []interface{}{userID, ALL_USERS_SQUAD}
+	switch status {
+	case "":
+	case "admin":
+		query += " AND m.status >= ?"
+		args = append(args, Admin)
+	default:
+		return nil, fmt.Errorf("Do not know what to do with status=%v", status)
+	}
+
+	squads_map := make(map[string]*MemberSquadInfoRecord, 0)
+
+	if includeAllUsersSquad {
+		s, err := db.getSquad(ctx, db.DB, ALL_USERS_SQUAD)
+		if err != nil {
+			return nil, err
+		}
+		squads_map[ALL_USERS_SQUAD] = &MemberSquadInfoRecord{
+			ID: ALL_USERS_SQUAD,
+			MemberSquadInfo: MemberSquadInfo{
+				SquadInfo: *s,
+				Status:    Owner,
+			},
+		}
+	}
+
+	rows, err := db.query(ctx, db.DB, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get user squads: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r := &MemberSquadInfoRecord{}
+		err = rows.Scan(&r.ID, &r.Owner, &r.MembersCount, &r.PendingApproveCount, &r.Status)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get user squads: %w", err)
+		}
+		squads_map[r.ID] = r
+	}
+
+	return squads_map, rows.Err()
+}
+
+func (db *SQLDB) GetSquadMember(ctx context.Context, squadId string, userId string) (*SquadUserInfo, error) {
+	m, err := db.getSquadMember(ctx, db.DB, squadId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &m.SquadUserInfo, nil
+}
+
+func (db *SQLDB) GetSquadMemberIds(ctx context.Context, squadId string, statuses []int, memberToSkip string) ([]string, error) {
+	if len(statuses) == 0 {
+		return []string{}, nil
+	}
+
+	in := ""
+	args := []interface{}{squadId, memberToSkip}
+	for i, s := range statuses {
+		if i > 0 {
+			in += ", "
+		}
+		in += "?"
+		args = append(args, s)
+	}
+
+	rows, err := db.query(ctx, db.DB, "SELECT user_id FROM squad_members WHERE squad_id = ? AND user_id <> ? AND status IN ("+in+") ORDER BY user_id", args...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get squad admins: %w", err)
+	}
+	defer rows.Close()
+
+	return scanStrings(rows)
+}
+
+func (db *SQLDB) GetSquadMemberIdsByTag(ctx context.Context, squadId string, tag string) ([]string, error) {
+	rows, err := db.query(ctx, db.DB, "SELECT user_id FROM squad_member_tags WHERE squad_id = ? AND tag = ? ORDER BY user_id", squadId, tag)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get squad members with tag: %w", err)
+	}
+	defer rows.Close()
+
+	return scanStrings(rows)
+}
+
+const sqlMemberTagExists = "EXISTS (SELECT 1 FROM squad_member_tags t WHERE t.squad_id = m.squad_id AND t.user_id = m.user_id AND t.tag = ?)"
+
+func (db *SQLDB) GetSquadMembers(ctx context.Context, squadId string, from *time.Time, filter *map[string]string) ([]*SquadUserInfoRecord, error) {
+	if db.dev {
+		log.Printf("Getting members of the squad %v\n", squadId)
+	}
+
+	query := "SELECT " + sqlMemberColumns + " FROM squad_members m WHERE m.squad_id = ?"
+	args := []interface{}{squadId}
+	if from != nil {
+		query += " AND m.created_at > ?"
+		args = append(args, from.UTC())
+	}
+	where, filterArgs := sqlFilterWhere(filter, "m.search_keys", "m.status", sqlMemberTagExists, statusFromString)
+	query += where + " ORDER BY m.created_at, m.user_id LIMIT ?"
+	args = append(append(args, filterArgs...), numRecords)
+
+	rows, err := db.query(ctx, db.DB, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get squad members: %w", err)
+	}
+	defer rows.Close()
+
+	squadMembers := make([]*SquadUserInfoRecord, 0)
+	for rows.Next() {
+		m, err := scanMember(rows)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get squad members: %w", err)
+		}
+		squadMembers = append(squadMembers, m)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to get squad members: %w", err)
+	}
+	rows.Close()
+
+	err = db.loadMemberTags(ctx, db.DB, squadId, squadMembers)
+	if err != nil {
+		return nil, err
+	}
+
+	return squadMembers, nil
+}
+
+func (db *SQLDB) CheckIfUserIsSquadMember(ctx context.Context, userId string, squadId string) error {
+	_, err := db.GetSquadMemberStatus(ctx, userId, squadId)
+	return err
+}
+
+func (db *SQLDB) getSquadMemberStatus(ctx context.Context, q sqlQueryer, userId string, squadId string) (MemberStatusType, error) {
+	var status MemberStatusType
+	err := db.queryRow(ctx, q, "SELECT status FROM squad_members WHERE squad_id = ? AND user_id = ?", squadId, userId).Scan(&status)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get squad "+squadId+": %w", sqlNotFound(err, "Member %v not found", userId))
+	}
+	return status, nil
+}
+
+func (db *SQLDB) GetSquadMemberStatus(ctx context.Context, userId string, squadId string) (MemberStatusType, error) {
+	return db.getSquadMemberStatus(ctx, db.DB, userId, squadId)
+}
+
+func (db *SQLDB) SetSquadMemberStatus(ctx context.Context, userId string, squadId string, status MemberStatusType) error {
+	return db.inTx(ctx, func(tx *sql.Tx) error {
+		oldStatus, err := db.getSquadMemberStatus(ctx, tx, userId, squadId)
+		if err != nil {
+			return err
+		}
+
+		_, err = db.exec(ctx, tx, "UPDATE squad_members SET status = ? WHERE squad_id = ? AND user_id = ?", status, squadId, userId)
+		if err != nil {
+			return fmt.Errorf("Failed to change user "+userId+" status: %w", err)
+		}
+
+		changedCount := 0
+		if oldStatus == PendingApprove && status != PendingApprove {
+			changedCount = 1
+		}
+		if oldStatus != PendingApprove && status == PendingApprove {
+			changedCount = -1
+		}
+		if changedCount != 0 {
+			return db.updateSquadCounters(ctx, tx, squadId, changedCount, -changedCount)
+		}
+
+		return nil
+	})
+}
+
+func (db *SQLDB) SetSquadMemberNotes(ctx context.Context, userId string, squadId string, notes *map[string]string) error {
+	if db.dev {
+		log.Printf("Updating note for user '%v' in squad '%v': %+v", userId, squadId, notes)
+	}
+
+	text := ""
+	if notes != nil {
+		b, err := json.Marshal(notes)
+		if err != nil {
+			return err
+		}
+		text = string(b)
+	}
+
+	res, err := db.exec(ctx, db.DB, "UPDATE squad_members SET notes = ? WHERE squad_id = ? AND user_id = ?", text, squadId, userId)
+	if err == nil {
+		err = checkAffected(res, "Squad %v member %v not found", squadId, userId)
+	}
+	if err != nil {
+		log.Printf("Failed to update user notes: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (db *SQLDB) CreateReplicant(ctx context.Context, replicantInfo *UserInfo, squadId string) (string, error) {
+	if db.dev {
+		log.Println("Creating replicant " + replicantInfo.DisplayName + " in squad " + squadId)
+	}
+
+	squadReplicantInfo := &SquadUserInfo{
+		UserInfo:  *replicantInfo,
+		Replicant: true,
+		Status:    Member,
+	}
+
+	replicantId := newDocId()
+	err := db.inTx(ctx, func(tx *sql.Tx) error {
+		return db.addMemberRecordToSquad(ctx, tx, squadId, replicantId, squadReplicantInfo)
+	})
+	if err != nil {
+		log.Printf("Failed to add replicant record to squad: %v", err)
+		return "", err
+	}
+
+	return replicantId, nil
+}
+
+func (db *SQLDB) AddMemberToSquad(ctx context.Context, userId string, squadId string, memberStatus MemberStatusType) (*MemberSquadInfo, error) {
+	var memberSquadInfo *MemberSquadInfo
+	err := db.inTx(ctx, func(tx *sql.Tx) (err error) {
+		memberSquadInfo, err = db.addMemberToSquad(ctx, tx, userId, squadId, memberStatus)
+		return err
+	})
+
+	return memberSquadInfo, err
+}
+
+func (db *SQLDB) addMemberToSquad(ctx context.Context, tx *sql.Tx, userId string, squadId string, memberStatus MemberStatusType) (*MemberSquadInfo, error) {
+	if db.dev {
+		log.Println("Adding user " + userId + " to squad " + squadId)
+	}
+
+	count, err := db.getUserSquadsCount(ctx, tx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if count >= 10 {
+		return nil, fmt.Errorf("User might participate in 10 squads maximum")
+	}
+
+	user, err := db.getSquadMember(ctx, tx, ALL_USERS_SQUAD, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	squadUserInfo := &SquadUserInfo{
+		UserInfo: user.UserInfo,
+		Status:   memberStatus,
+	}
+
+	err = db.addMemberRecordToSquad(ctx, tx, squadId, userId, squadUserInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	squadInfo, err := db.getSquad(ctx, tx, squadId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MemberSquadInfo{
+		SquadInfo: *squadInfo,
+		Status:    memberStatus,
+	}, nil
+}
+
+func (db *SQLDB) addMemberRecordToSquad(ctx context.Context, tx *sql.Tx, squadId string, userId string, userInfo *SquadUserInfo) error {
+	if db.dev {
+		log.Println("Adding member " + userId + " to squad " + squadId)
+	}
+
+	if _, err := db.getSquad(ctx, tx, squadId); err != nil {
+		return fmt.Errorf("Failed to add user "+userId+" to squad "+squadId+": %w", err)
+	}
+
+	if _, err := db.getSquadMemberStatus(ctx, tx, userId, squadId); err == nil {
+		return status.Errorf(codes.AlreadyExists, "User %v is already a member of squad %v", userId, squadId)
+	}
+
+	notes := ""
+	if userInfo.Notes != nil {
+		b, err := json.Marshal(userInfo.Notes)
+		if err != nil {
+			return err
+		}
+		notes = string(b)
+	}
+
+	_, err := db.exec(ctx, tx, "INSERT INTO squad_members (squad_id, user_id, display_name, email, phone_number, replicant, status, notes, search_keys, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		squadId, userId, userInfo.DisplayName, userInfo.Email, userInfo.PhoneNumber, userInfo.Replicant, userInfo.Status, notes, sqlKeys(userInfo.Keys()), time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("Failed to add user "+userId+" to squad "+squadId+": %w", err)
+	}
+
+	err = db.setSquadMemberTags(ctx, tx, squadId, userId, userInfo.Tags)
+	if err != nil {
+		return err
+	}
+
+	membersDelta, pendingApproveDelta := memberCounterDelta(userInfo.Status, 1)
+	return db.updateSquadCounters(ctx, tx, squadId, membersDelta, pendingApproveDelta)
+}
+
+func (db *SQLDB) DeleteMemberFromSquad(ctx context.Context, userId string, squadId string) error {
+	if db.dev {
+		log.Println("Removing user " + userId + " from squad " + squadId)
+	}
+
+	return db.inTx(ctx, func(tx *sql.Tx) error {
+		status, err := db.getSquadMemberStatus(ctx, tx, userId, squadId)
+		if err != nil {
+			return err
+		}
+
+		_, err = db.exec(ctx, tx, "DELETE FROM squad_members WHERE squad_id = ? AND user_id = ?", squadId, userId)
+		if err != nil {
+			return fmt.Errorf("Failed to delete user %v from squad %v: %w", userId, squadId, err)
+		}
+
+		membersDelta, pendingApproveDelta := memberCounterDelta(status, -1)
+		return db.updateSquadCounters(ctx, tx, squadId, membersDelta, pendingApproveDelta)
+	})
+}
@@ -0,0 +1,258 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+func (db *SQLDB) CreateTag(ctx context.Context, squadId string, tag *Tag) error {
+	if db.dev {
+		log.Printf("Creating tag '%+v' in squad '%v'", tag, squadId)
+	}
+
+	return db.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := db.getSquad(ctx, tx, squadId); err != nil {
+			return err
+		}
+
+		_, err := db.exec(ctx, tx, "INSERT INTO squad_tags (squad_id, name) VALUES (?, ?) ON CONFLICT (squad_id, name) DO NOTHING", squadId, tag.Name)
+		if err != nil {
+			return fmt.Errorf("Failed to create tag: %w", err)
+		}
+
+		_, err = db.exec(ctx, tx, "DELETE FROM squad_tag_values WHERE squad_id = ? AND name = ?", squadId, tag.Name)
+		if err != nil {
+			return fmt.Errorf("Failed to create tag: %w", err)
+		}
+
+		for value, count := range tag.Values {
+			_, err = db.exec(ctx, tx, "INSERT INTO squad_tag_values (squad_id, name, value, count) VALUES (?, ?, ?, ?)", squadId, tag.Name, value, count)
+			if err != nil {
+				return fmt.Errorf("Failed to create tag: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func (db *SQLDB) GetTags(ctx context.Context, squadId string) ([]*Tag, error) {
+	log.Printf("Getting tags for squad '%v'", squadId)
+
+	rows, err := db.query(ctx, db.DB, "SELECT t.name, v.value, v.count FROM squad_tags t LEFT JOIN squad_tag_values v ON v.squad_id = t.squad_id AND v.name = t.name WHERE t.squad_id = ? ORDER BY t.name", squadId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get squad tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := make([]*Tag, 0)
+	for rows.Next() {
+		var name string
+		var value sql.NullString
+		var count sql.NullInt64
+		if err = rows.Scan(&name, &value, &count); err != nil {
+			return nil, fmt.Errorf("Failed to get squad tags: %w", err)
+		}
+
+		if len(tags) == 0 || tags[len(tags)-1].Name != name {
+			tags = append(tags, &Tag{
+				Name:   name,
+				Values: make(map[string]int64),
+			})
+		}
+		if value.Valid {
+			tags[len(tags)-1].Values[value.String] = count.Int64
+		}
+	}
+
+	return tags, rows.Err()
+}
+
+func (db *SQLDB) DeleteTag(ctx context.Context, squadId string, tagName string) error {
+	log.Println("Deleting tag " + tagName + " from squad " + squadId)
+
+	//TODO delete tag from all members
+	_, err := db.exec(ctx, db.DB, "DELETE FROM squad_tags WHERE squad_id = ? AND name = ?", squadId, tagName)
+	if err != nil {
+		return fmt.Errorf("Error while deleting tag "+tagName+" from squad "+squadId+": %w", err)
+	}
+
+	return nil
+}
+
+func (db *SQLDB) getSquadMemberTags(ctx context.Context, q sqlQueryer, userId string, squadId string) ([]interface{}, error) {
+	if _, err := db.getSquadMemberStatus(ctx, q, userId, squadId); err != nil {
+		return nil, fmt.Errorf("Failed to get squad "+squadId+" member "+userId+": %w", err)
+	}
+
+	rows, err := db.query(ctx, q, "SELECT tag FROM squad_member_tags WHERE squad_id = ? AND user_id = ? ORDER BY position", squadId, userId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get squad "+squadId+" member "+userId+": %w", err)
+	}
+	defer rows.Close()
+
+	var tags []interface{}
+	for rows.Next() {
+		var tag string
+		if err = rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+func (db *SQLDB) GetSquadMemberTags(ctx context.Context, userId string, squadId string) ([]interface{}, error) {
+	return db.getSquadMemberTags(ctx, db.DB, userId, squadId)
+}
+
+func (db *SQLDB) setSquadMemberTags(ctx context.Context, q sqlQueryer, squadId string, userId string, tags []string) error {
+	_, err := db.exec(ctx, q, "DELETE FROM squad_member_tags WHERE squad_id = ? AND user_id = ?", squadId, userId)
+	if err != nil {
+		return fmt.Errorf("Failed to update tags for user %v from squad %v: %w", userId, squadId, err)
+	}
+
+	for i, tag := range tags {
+		_, err = db.exec(ctx, q, "INSERT INTO squad_member_tags (squad_id, user_id, tag, position) VALUES (?, ?, ?, ?)", squadId, userId, tag, i)
+		if err != nil {
+			return fmt.Errorf("Failed to update tags for user %v from squad %v: %w", userId, squadId, err)
+		}
+	}
+
+	return nil
+}
+
+func (db *SQLDB) updateTagCounter(ctx context.Context, q sqlQueryer, squadId string, tag string, value string, inc int64) error {
+	if value == "" {
+		value = "_"
+	}
+
+	var exists int
+	err := db.queryRow(ctx, q, "SELECT COUNT(*) FROM squad_tags WHERE squad_id = ? AND name = ?", squadId, tag).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists == 0 {
+		return notFound("Tag %v does not exist in squad %v", tag, squadId)
+	}
+
+	_, err = db.exec(ctx, q, "INSERT INTO squad_tag_values (squad_id, name, value, count) VALUES (?, ?, ?, ?) ON CONFLICT (squad_id, name, value) DO UPDATE SET count = squad_tag_values.count + excluded.count", squadId, tag, value, inc)
+	if err != nil {
+		return fmt.Errorf("Failed to update tag %v counter: %w", tag, err)
+	}
+
+	return nil
+}
+
+func interfacesToStrings(list []interface{}) []string {
+	s := make([]string, len(list))
+	for i, v := range list {
+		s[i] = v.(string)
+	}
+	return s
+}
+
+func (db *SQLDB) SetSquadMemberTag(ctx context.Context, userId string, squadId string, tagName string, tagValue string) ([]interface{}, error) {
+	tagNew := tagName
+	if tagValue != "" {
+		tagNew = tagName + "/" + tagValue
+	}
+
+	if db.dev {
+		log.Println("Setting tag " + tagName + " to user " + userId + " from squad " + squadId)
+	}
+
+	var tags []interface{}
+	err := db.inTx(ctx, func(tx *sql.Tx) (err error) {
+		tags, err = db.getSquadMemberTags(ctx, tx, userId, squadId)
+		if err != nil {
+			return err
+		}
+
+		if len(tags) >= 10 {
+			return fmt.Errorf("User might have max 10 tags assigned")
+		}
+
+		tagFound := false
+		tagOldValue := ""
+
+		for i, tag := range tags {
+			s := strings.Split(tag.(string), "/")
+			name := s[0]
+			if name == tagName {
+				tagFound = true
+				if len(s) == 2 {
+					tagOldValue = s[1]
+					tags[i] = tagNew
+				}
+				break
+			}
+		}
+
+		if !tagFound {
+			tags = append(tags, tagNew)
+		}
+
+		if tagFound && tagOldValue == tagValue {
+			return nil
+		}
+
+		if err = db.updateTagCounter(ctx, tx, squadId, tagName, tagValue, 1); err != nil {
+			return err
+		}
+		if tagFound {
+			if err = db.updateTagCounter(ctx, tx, squadId, tagName, tagOldValue, -1); err != nil {
+				return err
+			}
+		}
+
+		return db.setSquadMemberTags(ctx, tx, squadId, userId, interfacesToStrings(tags))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+func (db *SQLDB) DeleteSquadMemberTag(ctx context.Context, userId string, squadId string, tagName string, tagValue string) ([]interface{}, error) {
+	tag := tagName
+	if tagValue != "" {
+		tag = tag + "/" + tagValue
+	}
+	log.Println("Deleting tag " + tag + " from user " + userId + " from squad " + squadId)
+
+	var tags []interface{}
+	err := db.inTx(ctx, func(tx *sql.Tx) (err error) {
+		tags, err = db.getSquadMemberTags(ctx, tx, userId, squadId)
+		if err != nil {
+			return err
+		}
+
+		tagFound := false
+		for i, t := range tags {
+			if t == tag {
+				tagFound = true
+				tags = append(tags[:i], tags[i+1:]...)
+				break
+			}
+		}
+
+		if tagFound {
+			if err = db.updateTagCounter(ctx, tx, squadId, tagName, tagValue, -1); err != nil {
+				return err
+			}
+		}
+
+		return db.setSquadMemberTags(ctx, tx, squadId, userId, interfacesToStrings(tags))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+func scanVenue(row interface{ Scan(...interface{}) error }, v *VenueRecord) error {
+	var lat, lon sql.NullFloat64
+	if err := row.Scan(&v.ID, &v.Venue, &v.Address, &lat, &lon, &v.MeetingURL); err != nil {
+		return err
+	}
+	if lat.Valid {
+		v.Lat = &lat.Float64
+	}
+	if lon.Valid {
+		v.Lon = &lon.Float64
+	}
+	return nil
+}
+
+func (db *SQLDB) CreateVenue(ctx context.Context, squadId string, venue *EventLocation) (string, error) {
+	if venue.Venue == "" {
+		return "", fmt.Errorf("Failed to create venue, name is not provided: %+v", venue)
+	}
+
+	log.Printf("Creating venue '%+v' in squad '%v'", venue, squadId)
+
+	id := newDocId()
+	_, err := db.exec(ctx, db.DB, "INSERT INTO squad_venues (id, squad_id, name, address, lat, lon, meeting_url) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		id, squadId, venue.Venue, venue.Address, venue.Lat, venue.Lon, venue.MeetingURL)
+	if err != nil {
+		return "", fmt.Errorf("Failed to create venue in squad %v: %w", squadId, err)
+	}
+
+	return id, nil
+}
+
+func (db *SQLDB) GetVenues(ctx context.Context, squadId string) ([]*VenueRecord, error) {
+	rows, err := db.query(ctx, db.DB, "SELECT id, name, address, lat, lon, meeting_url FROM squad_venues WHERE squad_id = ? ORDER BY name", squadId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get squad %v venues: %w", squadId, err)
+	}
+	defer rows.Close()
+
+	venues := make([]*VenueRecord, 0)
+	for rows.Next() {
+		v := &VenueRecord{}
+		if err = scanVenue(rows, v); err != nil {
+			return nil, fmt.Errorf("Failed to get squad %v venues: %w", squadId, err)
+		}
+		venues = append(venues, v)
+	}
+
+	return venues, rows.Err()
+}
+
+func (db *SQLDB) GetVenue(ctx context.Context, squadId string, venueId string) (*EventLocation, error) {
+	v := &VenueRecord{}
+	err := scanVenue(db.queryRow(ctx, db.DB, "SELECT id, name, address, lat, lon, meeting_url FROM squad_venues WHERE squad_id = ? AND id = ?", squadId, venueId), v)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get venue %v: %w", venueId, sqlNotFound(err, "Venue %v not found", venueId))
+	}
+
+	return &v.EventLocation, nil
+}
+
+func (db *SQLDB) UpdateVenue(ctx context.Context, squadId string, venueId string, venue *EventLocation) error {
+	if venue.Venue == "" {
+		return fmt.Errorf("Failed to update venue, name is not provided: %+v", venue)
+	}
+
+	log.Printf("Updating venue '%v' in squad '%v'", venueId, squadId)
+
+	res, err := db.exec(ctx, db.DB, "UPDATE squad_venues SET name = ?, address = ?, lat = ?, lon = ?, meeting_url = ? WHERE squad_id = ? AND id = ?",
+		venue.Venue, venue.Address, venue.Lat, venue.Lon, venue.MeetingURL, squadId, venueId)
+	if err != nil {
+		return fmt.Errorf("Failed to update venue %v: %w", venueId, err)
+	}
+
+	return checkAffected(res, "Venue %v not found", venueId)
+}
+
+func (db *SQLDB) DeleteVenue(ctx context.Context, squadId string, venueId string) error {
+	log.Println("Deleting venue " + venueId + " from squad " + squadId)
+
+	_, err := db.exec(ctx, db.DB, "DELETE FROM squad_venues WHERE squad_id = ? AND id = ?", squadId, venueId)
+	if err != nil {
+		return fmt.Errorf("Error while deleting venue "+venueId+" from squad "+squadId+": %w", err)
+	}
+
+	return nil
+}
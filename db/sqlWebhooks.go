@@ -0,0 +1,165 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+func scanWebhookDelivery(row interface{ Scan(...interface{}) error }, d *WebhookDeliveryRecord) error {
+	var created, next time.Time
+	var last sql.NullTime
+	if err := row.Scan(&d.ID, &d.WebhookId, &d.SquadId, &d.Event, &d.Payload, &d.Status, &d.Attempts, &created, &next, &last, &d.ResponseCode, &d.Error); err != nil {
+		return err
+	}
+	d.Created = *utcTime(created)
+	d.NextAttempt = *utcTime(next)
+	if last.Valid {
+		d.LastAttempt = utcTime(last.Time)
+	}
+	return nil
+}
+
+const webhookDeliveryColumns = "id, webhook_id, squad_id, event, payload, status, attempts, created_at, next_attempt, last_attempt, response_code, error"
+
+func (db *SQLDB) CreateWebhook(ctx context.Context, squadId string, hook *Webhook) (string, error) {
+	if hook.URL == "" {
+		return "", fmt.Errorf("Failed to create webhook, URL is not provided: %+v", hook)
+	}
+
+	log.Printf("Creating webhook '%v' in squad '%v'", hook.URL, squadId)
+
+	id := newDocId()
+	_, err := db.exec(ctx, db.DB, "INSERT INTO squad_webhooks (id, squad_id, url, events, secret, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		id, squadId, hook.URL, strings.Join(hook.Events, ","), hook.Secret, hook.Created.UTC())
+	if err != nil {
+		return "", fmt.Errorf("Failed to create webhook in squad %v: %w", squadId, err)
+	}
+
+	return id, nil
+}
+
+func (db *SQLDB) GetWebhooks(ctx context.Context, squadId string) ([]*WebhookRecord, error) {
+	rows, err := db.query(ctx, db.DB, "SELECT id, url, events, secret, created_at FROM squad_webhooks WHERE squad_id = ? ORDER BY created_at", squadId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get squad %v webhooks: %w", squadId, err)
+	}
+	defer rows.Close()
+
+	hooks := make([]*WebhookRecord, 0)
+	for rows.Next() {
+		h := &WebhookRecord{}
+		var events string
+		var created time.Time
+		if err = rows.Scan(&h.ID, &h.URL, &events, &h.Secret, &created); err != nil {
+			return nil, fmt.Errorf("Failed to get squad %v webhooks: %w", squadId, err)
+		}
+		h.Events = splitChannels(events)
+		h.Created = *utcTime(created)
+		hooks = append(hooks, h)
+	}
+
+	return hooks, rows.Err()
+}
+
+func (db *SQLDB) GetWebhook(ctx context.Context, squadId string, webhookId string) (*Webhook, error) {
+	h := &Webhook{}
+	var events string
+	var created time.Time
+	err := db.queryRow(ctx, db.DB, "SELECT url, events, secret, created_at FROM squad_webhooks WHERE squad_id = ? AND id = ?", squadId, webhookId).
+		Scan(&h.URL, &events, &h.Secret, &created)
+	if err == sql.ErrNoRows {
+		return nil, notFound("Failed to get webhook %v: not found", webhookId)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get webhook %v: %w", webhookId, err)
+	}
+	h.Events = splitChannels(events)
+	h.Created = *utcTime(created)
+
+	return h, nil
+}
+
+func (db *SQLDB) DeleteWebhook(ctx context.Context, squadId string, webhookId string) error {
+	log.Println("Deleting webhook " + webhookId + " from squad " + squadId)
+
+	// deliveries are deleted by cascade
+	_, err := db.exec(ctx, db.DB, "DELETE FROM squad_webhooks WHERE squad_id = ? AND id = ?", squadId, webhookId)
+	if err != nil {
+		return fmt.Errorf("Error while deleting webhook "+webhookId+" from squad "+squadId+": %w", err)
+	}
+
+	return nil
+}
+
+// nullable last attempt time of the delivery
+func lastAttempt(delivery *WebhookDelivery) interface{} {
+	if delivery.LastAttempt == nil {
+		return nil
+	}
+	return delivery.LastAttempt.UTC()
+}
+
+func (db *SQLDB) CreateWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) (string, error) {
+	id := newDocId()
+	_, err := db.exec(ctx, db.DB, "INSERT INTO webhook_deliveries ("+webhookDeliveryColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		id, delivery.WebhookId, delivery.SquadId, delivery.Event, delivery.Payload, delivery.Status, delivery.Attempts,
+		delivery.Created.UTC(), delivery.NextAttempt.UTC(), lastAttempt(delivery), delivery.ResponseCode, delivery.Error)
+	if err != nil {
+		return "", fmt.Errorf("Failed to create delivery of webhook %v: %w", delivery.WebhookId, err)
+	}
+
+	return id, nil
+}
+
+func (db *SQLDB) UpdateWebhookDelivery(ctx context.Context, deliveryId string, delivery *WebhookDelivery) error {
+	res, err := db.exec(ctx, db.DB, "UPDATE webhook_deliveries SET status = ?, attempts = ?, next_attempt = ?, last_attempt = ?, response_code = ?, error = ? WHERE id = ?",
+		delivery.Status, delivery.Attempts, delivery.NextAttempt.UTC(), lastAttempt(delivery), delivery.ResponseCode, delivery.Error, deliveryId)
+	if err != nil {
+		return fmt.Errorf("Failed to update webhook delivery %v: %w", deliveryId, err)
+	}
+
+	return checkAffected(res, "Webhook delivery %v not found", deliveryId)
+}
+
+func (db *SQLDB) GetWebhookDeliveries(ctx context.Context, webhookId string, limit int) ([]*WebhookDeliveryRecord, error) {
+	rows, err := db.query(ctx, db.DB, "SELECT "+webhookDeliveryColumns+" FROM webhook_deliveries WHERE webhook_id = ? ORDER BY created_at DESC LIMIT ?", webhookId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get webhook %v deliveries: %w", webhookId, err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]*WebhookDeliveryRecord, 0)
+	for rows.Next() {
+		d := &WebhookDeliveryRecord{}
+		if err = scanWebhookDelivery(rows, d); err != nil {
+			return nil, fmt.Errorf("Failed to get webhook %v deliveries: %w", webhookId, err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func (db *SQLDB) GetDueWebhookDeliveries(ctx context.Context, before time.Time) ([]*WebhookDeliveryRecord, error) {
+	rows, err := db.query(ctx, db.DB, "SELECT "+webhookDeliveryColumns+" FROM webhook_deliveries WHERE status = ? AND next_attempt <= ? ORDER BY next_attempt",
+		DeliveryPending, before.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get due webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]*WebhookDeliveryRecord, 0)
+	for rows.Next() {
+		d := &WebhookDeliveryRecord{}
+		if err = scanWebhookDelivery(rows, d); err != nil {
+			return nil, fmt.Errorf("Failed to get due webhook deliveries: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
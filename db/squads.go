@@ -439,7 +439,7 @@ func (db *FirestoreDB) getCounter(status MemberStatusType) string {
 	return path
 }
 
-func (db *FirestoreDB) addMemberRecordToSquad(ctx context.Context, squadId string, userId string, userInfo *SquadUserInfo) error {
+func (db *FirestoreDB) AddMemberRecordToSquad(ctx context.Context, squadId string, userId string, userInfo *SquadUserInfo) error {
 
 	if db.dev {
 		log.Println("Adding member " + userId + " to squad " + squadId)
@@ -636,7 +636,7 @@ func (db *FirestoreDB) CreateReplicant(ctx context.Context, replicantInfo *UserI
 
 	newReplicantDoc := db.Squads.Doc(squadId).Collection(MEMBERS).NewDoc()
 
-	err = db.addMemberRecordToSquad(ctx, squadId, newReplicantDoc.ID, squadReplicantInfo)
+	err = db.AddMemberRecordToSquad(ctx, squadId, newReplicantDoc.ID, squadReplicantInfo)
 	if err != nil {
 		log.Printf("Failed to add replicant record to squad: %v", err)
 		return "", err
@@ -673,7 +673,7 @@ func (db *FirestoreDB) AddMemberToSquad(ctx context.Context, userId string, squa
 		Status:   memberStatus,
 	}
 
-	err = db.addMemberRecordToSquad(ctx, squadId, userId, squadUserInfo)
+	err = db.AddMemberRecordToSquad(ctx, squadId, userId, squadUserInfo)
 	if err != nil {
 		return nil, err
 	}
@@ -688,7 +688,7 @@ func (db *FirestoreDB) AddMemberToSquad(ctx context.Context, userId string, squa
 		Status:    memberStatus,
 	}
 
-	err = db.addSquadRecordToMember(ctx, userId, squadId, memberSquadInfo)
+	err = db.AddSquadRecordToMember(ctx, userId, squadId, memberSquadInfo)
 	if err != nil {
 		return nil, err
 	}
@@ -707,7 +707,7 @@ func (db *FirestoreDB) AddMemberToSquad(ctx context.Context, userId string, squa
 	return memberSquadInfo, nil
 }
 
-func (db *FirestoreDB) addSquadRecordToMember(ctx context.Context, userId string, squadId string, squadInfo *MemberSquadInfo) error {
+func (db *FirestoreDB) AddSquadRecordToMember(ctx context.Context, userId string, squadId string, squadInfo *MemberSquadInfo) error {
 
 	doc := db.Users.Doc(userId).Collection(USER_SQUADS).Doc(squadId)
 
@@ -0,0 +1,181 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// Store is the set of operations the application performs on its storage.
+// FirestoreDB is the production implementation, MemoryDB keeps everything in
+// process memory and is used to run the app and its tests offline, SQLDB
+// stores data in PostgreSQL or SQLite.
+type Store interface {
+	// users
+	GetUserInfo(ctx context.Context, userId string) (*UserInfo, error)
+	GetUserData(ctx context.Context, userId string) (*UserData, error)
+	GetUserByName(ctx context.Context, userName string) ([]string, error)
+	CreateUser(ctx context.Context, userId string, userInfo *UserInfo, status MemberStatusType) error
+	UpdateUser(ctx context.Context, userId string, field string, val interface{}) error
+	UpdateUserInfoFromAuth(ctx context.Context, userId string, userInfo *UserInfo) error
+	GetSquadsCount(ctx context.Context, userId string) (interface{}, error)
+	GetSquadsWithPendingRequests(ctx context.Context, userId string, admin bool) (interface{}, error)
+
+	// credentials of the local auth provider
+	CreateCredentials(ctx context.Context, c *Credentials) error
+	GetCredentials(ctx context.Context, email string) (*Credentials, error)
+	GetCredentialsByUserId(ctx context.Context, userId string) (*Credentials, error)
+	SetEmailVerified(ctx context.Context, email string) error
+
+	// squads & members
+	CreateSquad(ctx context.Context, squadId string, ownerId string) error
+	GetSquad(ctx context.Context, ID string) (*SquadInfo, error)
+	DeleteSquad(ctx context.Context, squadId string) error
+	GetOtherSquads(ctx context.Context, userId string) ([]string, error)
+	GetUserSquads(ctx context.Context, userId string, status string) ([]string, error)
+	GetUserSquadsMap(ctx context.Context, userID string, status string, includeAllUsersSquad bool) (map[string]*MemberSquadInfoRecord, error)
+	GetSquadMember(ctx context.Context, squadId string, userId string) (*SquadUserInfo, error)
+	GetSquadMemberIds(ctx context.Context, squadId string, statuses []int, memberToSkip string) ([]string, error)
+	GetSquadMemberIdsByTag(ctx context.Context, squadId string, tag string) ([]string, error)
+	GetSquadMembers(ctx context.Context, squadId string, from *time.Time, filter *map[string]string) ([]*SquadUserInfoRecord, error)
+	CheckIfUserIsSquadMember(ctx context.Context, userId string, squadId string) error
+	GetSquadMemberStatus(ctx context.Context, userId string, squadId string) (MemberStatusType, error)
+	SetSquadMemberStatus(ctx context.Context, userId string, squadId string, status MemberStatusType) error
+	SetSquadMemberNotes(ctx context.Context, userId string, squadId string, notes *map[string]string) error
+	CreateReplicant(ctx context.Context, replicantInfo *UserInfo, squadId string) (string, error)
+	AddMemberToSquad(ctx context.Context, userId string, squadId string, memberStatus MemberStatusType) (*MemberSquadInfo, error)
+	DeleteMemberFromSquad(ctx context.Context, userId string, squadId string) error
+
+	// tags
+	CreateTag(ctx context.Context, squadId string, tag *Tag) error
+	GetTags(ctx context.Context, squadId string) ([]*Tag, error)
+	DeleteTag(ctx context.Context, squadId string, tagName string) error
+	GetSquadMemberTags(ctx context.Context, userId string, squadId string) ([]interface{}, error)
+	SetSquadMemberTag(ctx context.Context, userId string, squadId string, tagName string, tagValue string) ([]interface{}, error)
+	DeleteSquadMemberTag(ctx context.Context, userId string, squadId string, tagName string, tagValue string) ([]interface{}, error)
+
+	// notes
+	CreateNote(ctx context.Context, squadId string, note *NoteUpdate) (string, error)
+	GetNotes(ctx context.Context, squadId string, publishedOnly bool) ([]*NoteRecord, error)
+	DeleteNote(ctx context.Context, squadId string, noteId string) error
+	UpdateNote(ctx context.Context, squadId string, noteId string, note *NoteUpdate) error
+
+	// squad venues
+	CreateVenue(ctx context.Context, squadId string, venue *EventLocation) (string, error)
+	GetVenues(ctx context.Context, squadId string) ([]*VenueRecord, error)
+	GetVenue(ctx context.Context, squadId string, venueId string) (*EventLocation, error)
+	UpdateVenue(ctx context.Context, squadId string, venueId string, venue *EventLocation) error
+	DeleteVenue(ctx context.Context, squadId string, venueId string) error
+
+	// squad webhooks & their deliveries
+	CreateWebhook(ctx context.Context, squadId string, hook *Webhook) (string, error)
+	GetWebhooks(ctx context.Context, squadId string) ([]*WebhookRecord, error)
+	GetWebhook(ctx context.Context, squadId string, webhookId string) (*Webhook, error)
+	DeleteWebhook(ctx context.Context, squadId string, webhookId string) error
+	CreateWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) (string, error)
+	UpdateWebhookDelivery(ctx context.Context, deliveryId string, delivery *WebhookDelivery) error
+	// newest deliveries of the webhook first
+	GetWebhookDeliveries(ctx context.Context, webhookId string, limit int) ([]*WebhookDeliveryRecord, error)
+	// pending deliveries with next attempt not later than before
+	GetDueWebhookDeliveries(ctx context.Context, before time.Time) ([]*WebhookDeliveryRecord, error)
+
+	// squad event templates
+	CreateEventTemplate(ctx context.Context, squadId string, template *EventTemplate) (string, error)
+	GetEventTemplates(ctx context.Context, squadId string) ([]*EventTemplateRecord, error)
+	GetEventTemplate(ctx context.Context, squadId string, templateId string) (*EventTemplate, error)
+	UpdateEventTemplate(ctx context.Context, squadId string, templateId string, template *EventTemplate) error
+	DeleteEventTemplate(ctx context.Context, squadId string, templateId string) error
+
+	// events & participants
+	CreateEvent(ctx context.Context, event *EventInfo) (string, error)
+	GetEvent(ctx context.Context, ID string) (*EventInfo, error)
+	DeleteEvent(ctx context.Context, eventId string) error
+	ArchiveOldEvents(ctx context.Context) error
+	GetEvents(ctx context.Context, squads []string, userId string) ([]*EventCountersRecord, error)
+	GetEventsByStatus(ctx context.Context, squads []string, userId string, status string) ([]*EventCountersRecord, error)
+	GetArchivedEvents(ctx context.Context, userId string, from *time.Time, filter *map[string]string) ([]*EventRecord, error)
+	GetUserEventsMap(ctx context.Context, squads []string, userId string) (map[string]*EventInfo, error)
+	GetUserEventsCount(ctx context.Context, userId string) (int, error)
+	GetUserEvents(ctx context.Context, userId string, limit int) ([]*EventInfo, error)
+	RegisterParticipants(ctx context.Context, userIds []string, eventId string, eventInfo *EventInfo, status ParticipantStatusType) error
+	GetParticipants(ctx context.Context, eventId string, from *time.Time, filter *map[string]string) ([]*ParticipantRecord, error)
+	GetParticipantStatus(ctx context.Context, userId string, eventId string) (ParticipantStatusType, error)
+	SetParticipantStatus(ctx context.Context, userId string, eventId string, status ParticipantStatusType) (oldStatus ParticipantStatusType, newStatus ParticipantStatusType, err error)
+	DeleteParticipant(ctx context.Context, userId string, eventId string) error
+	// moves earliest waitlisted participants to Going while event has free places
+	PromoteWaitlisted(ctx context.Context, eventId string) ([]string, error)
+	MarkNoShows(ctx context.Context, eventId string) (int, error)
+	GetSquadAttendance(ctx context.Context, squadId string, from time.Time, to time.Time) ([]*AttendanceRecord, error)
+	// recalculates participant counters of all events, returns amount of events having wrong counters
+	RecountEventCounters(ctx context.Context) (int, error)
+	GetCandidates(ctx context.Context, squadId string, eventId string, from string, filter *map[string]string) ([]*SquadUserInfoRecord, error)
+	UpdateEvent(ctx context.Context, eventId string, event *EventInfo) error
+
+	// event reminders
+	GetUpcomingEvents(ctx context.Context, from time.Time, to time.Time) ([]*EventRecord, error)
+	GetParticipantIds(ctx context.Context, eventId string, status ParticipantStatusType) ([]string, error)
+	MarkEventReminderSent(ctx context.Context, eventId string, remindAt time.Time) (bool, error)
+
+	// recurring events
+	CreateEventSeries(ctx context.Context, series *EventSeries) (string, error)
+	GetEventSeries(ctx context.Context, seriesId string) (*EventSeries, error)
+	UpdateEventSeries(ctx context.Context, seriesId string, series *EventSeries) error
+	GetActiveEventSeries(ctx context.Context) ([]*EventSeriesRecord, error)
+	GetSeriesEvents(ctx context.Context, seriesId string) ([]*EventRecord, error)
+
+	// request queues & requests
+	CreateRequestsQueue(ctx context.Context, queueId string, qi *QueueInfo) error
+	GetRequestQueue(ctx context.Context, queueId string) (*QueueInfo, error)
+	GetRequestQueues(ctx context.Context, squadId string) ([]*QueueRecord, error)
+	DeleteRequestsQueue(ctx context.Context, queueId string) error
+	GetQueuesToApproveAndHandle(ctx context.Context, userTags []string, squadsAdmin []string) (map[string]int, map[string]int, error)
+	GetUserQueuesAndRequests(ctx context.Context, userId string, userTags []string, squadsAdmin []string, squadsAll []string) (userQueues []string, userRequests []RequestRecord, queuesToApprove []string, requestsToApprove []RequestRecord, queuesToHandle []string, requestsToHandle []RequestRecord, err error)
+	CreateRequest(ctx context.Context, request *RequestDetails) (string, error)
+	GetRequest(ctx context.Context, requestId string) (*RequestDetails, error)
+	GetUserRequests(ctx context.Context, userId string, from *time.Time, filter *RequestsFilter) ([]RequestRecord, error)
+	GetRequestsByTag(ctx context.Context, tags []string, squadsAdmin []string, status RequestStatusType, from *time.Time, filter *RequestsFilter) ([]RequestRecord, error)
+	SetRequestState(ctx context.Context, requestId string, fromState string, state string, status RequestStatusType, entry *RequestHistoryEntry) error
+	GetRequestHistory(ctx context.Context, requestId string) ([]*RequestHistoryEntry, error)
+	SetRequestAssignee(ctx context.Context, requestId string, fromAssigneeId string, assigneeId string, assigneeName string) error
+	GetQueuesWithSLA(ctx context.Context) ([]*QueueRecord, error)
+	GetOverdueRequests(ctx context.Context, queueId string, status RequestStatusType, before time.Time) ([]RequestRecord, error)
+	SetRequestEscalated(ctx context.Context, requestId string) error
+	CreateRequestComment(ctx context.Context, requestId string, comment *RequestComment) (string, error)
+	GetRequestComments(ctx context.Context, requestId string) ([]*RequestCommentRecord, error)
+	GetRequestComment(ctx context.Context, requestId string, commentId string) (*RequestComment, error)
+	DeleteRequestComment(ctx context.Context, requestId string, commentId string) error
+
+	// notifications inbox
+	CreateNotification(ctx context.Context, userIds []string, n *Notification) error
+	GetNotifications(ctx context.Context, userId string, before *time.Time, unreadOnly bool) ([]*NotificationRecord, error)
+	GetUnreadNotificationsCount(ctx context.Context, userId string) (int, error)
+	MarkNotificationRead(ctx context.Context, userId string, notificationId string) error
+	MarkNotificationsRead(ctx context.Context, userId string) error
+	DeleteNotificationsBefore(ctx context.Context, before time.Time) (int, error)
+
+	// notification preferences
+	GetNotificationPrefs(ctx context.Context, userId string) ([]*NotificationPref, error)
+	SetNotificationPref(ctx context.Context, userId string, pref *NotificationPref) error
+	DeleteNotificationPref(ctx context.Context, userId string, squadId string, kind string) error
+	GetUsersNotificationPrefs(ctx context.Context, userIds []string, squadId string, kind string) (map[string]*NotificationPref, error)
+
+	// push tokens of user devices
+	SavePushToken(ctx context.Context, token *PushToken) error
+	GetPushTokens(ctx context.Context, userId string) ([]*PushToken, error)
+	DeletePushTokens(ctx context.Context, userId string, tokens []string) error
+
+	// email digests
+	GetEmailDigest(ctx context.Context, userId string) (string, error)
+	SetEmailDigest(ctx context.Context, userId string, digest string) error
+	CreateDigestItem(ctx context.Context, item *DigestItem) error
+	GetDigestItems(ctx context.Context, digest string) ([]*DigestItemRecord, error)
+	DeleteDigestItems(ctx context.Context, ids []string) error
+
+	// background jobs
+	GetJobs(ctx context.Context) ([]*JobStatus, error)
+	StartJob(ctx context.Context, name string, instance string, due time.Time, now time.Time, lockUntil time.Time) (bool, error)
+	FinishJob(ctx context.Context, name string, instance string, finish time.Time, jobErr string) error
+}
+
+var _ Store = (*FirestoreDB)(nil)
+var _ Store = (*MemoryDB)(nil)
+var _ Store = (*SQLDB)(nil)
@@ -145,8 +145,7 @@ func (db *FirestoreDB) SetSquadMemberTag(ctx context.Context, userId string, squ
 		}
 		_, err := batch.Commit(ctx)
 		if err != nil {
-			fmt.Errorf("Failed to update tags for  user %v from squad %v to %+v: %w", userId, squadId, tags, err)
-			return nil, err
+			return nil, fmt.Errorf("Failed to update tags for user %v from squad %v to %+v: %w", userId, squadId, tags, err)
 		}
 	}
 
@@ -191,8 +190,7 @@ func (db *FirestoreDB) DeleteSquadMemberTag(ctx context.Context, userId string,
 
 	_, err = batch.Commit(ctx)
 	if err != nil {
-		fmt.Errorf("Failed to update tags for  user %v from squad %v to %+v: %w", userId, squadId, tags, err)
-		return nil, err
+		return nil, fmt.Errorf("Failed to update tags for user %v from squad %v to %+v: %w", userId, squadId, tags, err)
 	}
 
 	return tags, nil
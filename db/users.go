@@ -8,7 +8,6 @@ import (
 	"sync"
 
 	"cloud.google.com/go/firestore"
-	"firebase.google.com/go/auth"
 	"github.com/patrickmn/go-cache"
 	"google.golang.org/api/iterator"
 )
@@ -126,7 +125,7 @@ func (db *FirestoreDB) CreateUser(ctx context.Context, userId string, userInfo *
 	var sui = SquadUserInfo{
 		Status: status}
 	sui.UserInfo = *userInfo
-	err := db.addMemberRecordToSquad(ctx, ALL_USERS_SQUAD, userId, &sui)
+	err := db.AddMemberRecordToSquad(ctx, ALL_USERS_SQUAD, userId, &sui)
 	if err != nil {
 		return fmt.Errorf("Failed to add user "+userId+": %w", err)
 	}
@@ -181,29 +180,21 @@ func (db *FirestoreDB) UpdateUser(ctx context.Context, userId string, field stri
 	return nil
 }
 
-func (db *FirestoreDB) UpdateUserInfoFromFirebase(ctx context.Context, userRecord *auth.UserRecord) error {
-	userId := userRecord.UID
+func (db *FirestoreDB) UpdateUserInfoFromAuth(ctx context.Context, userId string, userInfo *UserInfo) error {
 	userData, err := db.GetUserData(ctx, userId)
 	if err != nil {
 		log.Println("Failed to get user " + userId + " from DB, adding new record to users collection")
 
-		userInfo := &UserInfo{
-			DisplayName: userRecord.DisplayName,
-			Email:       userRecord.Email,
-			PhoneNumber: userRecord.PhoneNumber,
-		}
-
-		db.CreateUser(ctx, userId, userInfo, PendingApprove)
-
+		err = db.CreateUser(ctx, userId, userInfo, PendingApprove)
 		if err != nil {
 			return fmt.Errorf("Failed to add user to database: %w", err)
 		}
 	} else {
-		if len(userRecord.Email) > 0 && userData.Email != userRecord.Email {
-			db.UpdateUser(ctx, userId, "Email", userRecord.Email)
+		if len(userInfo.Email) > 0 && userData.Email != userInfo.Email {
+			db.UpdateUser(ctx, userId, "Email", userInfo.Email)
 		}
-		if len(userRecord.PhoneNumber) > 0 && userData.PhoneNumber != userRecord.PhoneNumber {
-			db.UpdateUser(ctx, userId, "PhoneNumber", userRecord.PhoneNumber)
+		if len(userInfo.PhoneNumber) > 0 && userData.PhoneNumber != userInfo.PhoneNumber {
+			db.UpdateUser(ctx, userId, "PhoneNumber", userInfo.PhoneNumber)
 		}
 	}
 
@@ -226,11 +217,12 @@ func (db *FirestoreDB) GetSquadsCount(ctx context.Context, userId string) (inter
 	return squadsCount, nil
 }
 
+type squadCount struct {
+	Squad string `json:"squad"`
+	Count int64  `json:"count"`
+}
+
 func (db *FirestoreDB) GetSquadsWithPendingRequests(ctx context.Context, userId string, admin bool) (interface{}, error) {
-	type squadCount struct {
-		Squad string `json:"squad"`
-		Count int64  `json:"count"`
-	}
 	squadsWithRequests := make([]*squadCount, 0)
 
 	var wg sync.WaitGroup
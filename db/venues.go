@@ -0,0 +1,179 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// EventLocation is where the event takes place: venue with address and
+// optional coordinates, online meeting, or both
+type EventLocation struct {
+	Venue      string   `json:"venue,omitempty"`
+	Address    string   `json:"address,omitempty"`
+	Lat        *float64 `json:"lat,omitempty"`
+	Lon        *float64 `json:"lon,omitempty"`
+	MeetingURL string   `json:"meetingUrl,omitempty"`
+}
+
+// VenueRecord is location saved in the squad to be reused by its events
+type VenueRecord struct {
+	ID string `json:"id"`
+	EventLocation
+}
+
+func (l *EventLocation) Copy() *EventLocation {
+	if l == nil {
+		return nil
+	}
+	c := *l
+	if l.Lat != nil {
+		lat := *l.Lat
+		c.Lat = &lat
+	}
+	if l.Lon != nil {
+		lon := *l.Lon
+		c.Lon = &lon
+	}
+	return &c
+}
+
+func (l *EventLocation) IsEmpty() bool {
+	return l == nil || (l.Venue == "" && l.Address == "" && l.Lat == nil && l.Lon == nil && l.MeetingURL == "")
+}
+
+// Place returns venue and address joined, empty for online-only events
+func (l *EventLocation) Place() string {
+	if l == nil {
+		return ""
+	}
+	parts := make([]string, 0, 2)
+	for _, s := range []string{l.Venue, l.Address} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// String returns location as shown in notifications
+func (l *EventLocation) String() string {
+	if l.IsEmpty() {
+		return "not set"
+	}
+	parts := make([]string, 0, 2)
+	if place := l.Place(); place != "" {
+		parts = append(parts, place)
+	} else if l.Lat != nil && l.Lon != nil {
+		parts = append(parts, strconv.FormatFloat(*l.Lat, 'f', -1, 64)+","+strconv.FormatFloat(*l.Lon, 'f', -1, 64))
+	}
+	if l.MeetingURL != "" {
+		parts = append(parts, "online "+l.MeetingURL)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (l *EventLocation) Equal(o *EventLocation) bool {
+	if l.IsEmpty() || o.IsEmpty() {
+		return l.IsEmpty() == o.IsEmpty()
+	}
+	equalFloat := func(a *float64, b *float64) bool {
+		return (a == nil) == (b == nil) && (a == nil || *a == *b)
+	}
+	return l.Venue == o.Venue && l.Address == o.Address && l.MeetingURL == o.MeetingURL && equalFloat(l.Lat, o.Lat) && equalFloat(l.Lon, o.Lon)
+}
+
+func (db *FirestoreDB) CreateVenue(ctx context.Context, squadId string, venue *EventLocation) (string, error) {
+	if venue.Venue == "" {
+		return "", fmt.Errorf("Failed to create venue, name is not provided: %+v", venue)
+	}
+
+	log.Printf("Creating venue '%+v' in squad '%v'", venue, squadId)
+
+	doc, _, err := db.Squads.Doc(squadId).Collection("venues").Add(ctx, venue)
+	if err != nil {
+		return "", fmt.Errorf("Failed to create venue in squad %v: %w", squadId, err)
+	}
+
+	return doc.ID, nil
+}
+
+func (db *FirestoreDB) GetVenues(ctx context.Context, squadId string) ([]*VenueRecord, error) {
+	venues := make([]*VenueRecord, 0)
+
+	iter := db.Squads.Doc(squadId).Collection("venues").OrderBy("Venue", firestore.Asc).Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get squad %v venues: %w", squadId, err)
+		}
+
+		v := &VenueRecord{ID: doc.Ref.ID}
+		if err = doc.DataTo(&v.EventLocation); err != nil {
+			return nil, fmt.Errorf("Failed to get squad %v venues: %w", squadId, err)
+		}
+		venues = append(venues, v)
+	}
+
+	return venues, nil
+}
+
+func (db *FirestoreDB) GetVenue(ctx context.Context, squadId string, venueId string) (*EventLocation, error) {
+	doc, err := db.Squads.Doc(squadId).Collection("venues").Doc(venueId).Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get venue %v: %w", venueId, err)
+	}
+
+	venue := &EventLocation{}
+	if err = doc.DataTo(venue); err != nil {
+		return nil, fmt.Errorf("Failed to get venue %v: %w", venueId, err)
+	}
+
+	return venue, nil
+}
+
+func (db *FirestoreDB) UpdateVenue(ctx context.Context, squadId string, venueId string, venue *EventLocation) error {
+	if venue.Venue == "" {
+		return fmt.Errorf("Failed to update venue, name is not provided: %+v", venue)
+	}
+
+	log.Printf("Updating venue '%v' in squad '%v'", venueId, squadId)
+
+	doc := db.Squads.Doc(squadId).Collection("venues").Doc(venueId)
+	err := db.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		if _, err := tx.Get(doc); err != nil {
+			return err
+		}
+		return tx.Set(doc, venue)
+	})
+	if status.Code(err) == codes.NotFound {
+		return notFound("Failed to update venue %v: not found", venueId)
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to update venue %v: %w", venueId, err)
+	}
+
+	return nil
+}
+
+func (db *FirestoreDB) DeleteVenue(ctx context.Context, squadId string, venueId string) error {
+	log.Println("Deleting venue " + venueId + " from squad " + squadId)
+
+	_, err := db.Squads.Doc(squadId).Collection("venues").Doc(venueId).Delete(ctx)
+	if err != nil {
+		return fmt.Errorf("Error while deleting venue "+venueId+" from squad "+squadId+": %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,224 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// types of squad activity webhooks could be subscribed to
+const (
+	HookMemberJoined          = "member.joined"
+	HookMemberStatus          = "member.status"
+	HookEventCreated          = "event.created"
+	HookParticipantRegistered = "participant.registered"
+	HookRequestCreated        = "request.created"
+	HookRequestStatus         = "request.status"
+)
+
+var HookEvents = map[string]bool{
+	HookMemberJoined:          true,
+	HookMemberStatus:          true,
+	HookEventCreated:          true,
+	HookParticipantRegistered: true,
+	HookRequestCreated:        true,
+	HookRequestStatus:         true,
+}
+
+// states of webhook delivery
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook is URL of the squad where JSON payloads about chosen types of squad
+// activity are posted, payloads are signed with the secret
+type Webhook struct {
+	URL     string    `json:"url"`
+	Events  []string  `json:"events"`
+	Secret  string    `json:"secret,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+type WebhookRecord struct {
+	ID string `json:"id"`
+	Webhook
+}
+
+func (h *Webhook) HasEvent(event string) bool {
+	return containsString(h.Events, event)
+}
+
+// WebhookDelivery is payload posted to the webhook together with results of
+// the attempts, pending deliveries are retried at NextAttempt
+type WebhookDelivery struct {
+	WebhookId    string     `json:"webhookId"`
+	SquadId      string     `json:"squadId"`
+	Event        string     `json:"event"`
+	Payload      string     `json:"payload"`
+	Status       string     `json:"status"`
+	Attempts     int        `json:"attempts"`
+	Created      time.Time  `json:"created"`
+	NextAttempt  time.Time  `json:"nextAttempt"`
+	LastAttempt  *time.Time `json:"lastAttempt,omitempty"`
+	ResponseCode int        `json:"responseCode,omitempty"`
+	Error        string     `json:"error,omitempty"`
+}
+
+type WebhookDeliveryRecord struct {
+	ID string `json:"id"`
+	WebhookDelivery
+}
+
+// newest deliveries go first in the delivery log
+func sortWebhookDeliveries(deliveries []*WebhookDeliveryRecord) {
+	sort.SliceStable(deliveries, func(i, j int) bool { return deliveries[i].Created.After(deliveries[j].Created) })
+}
+
+// deliveries which are due longer go first
+func sortDueWebhookDeliveries(deliveries []*WebhookDeliveryRecord) {
+	sort.SliceStable(deliveries, func(i, j int) bool { return deliveries[i].NextAttempt.Before(deliveries[j].NextAttempt) })
+}
+
+func (db *FirestoreDB) CreateWebhook(ctx context.Context, squadId string, hook *Webhook) (string, error) {
+	if hook.URL == "" {
+		return "", fmt.Errorf("Failed to create webhook, URL is not provided: %+v", hook)
+	}
+
+	log.Printf("Creating webhook '%v' in squad '%v'", hook.URL, squadId)
+
+	doc, _, err := db.Squads.Doc(squadId).Collection("webhooks").Add(ctx, hook)
+	if err != nil {
+		return "", fmt.Errorf("Failed to create webhook in squad %v: %w", squadId, err)
+	}
+
+	return doc.ID, nil
+}
+
+func (db *FirestoreDB) GetWebhooks(ctx context.Context, squadId string) ([]*WebhookRecord, error) {
+	docs, err := db.Squads.Doc(squadId).Collection("webhooks").OrderBy("Created", firestore.Asc).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get squad %v webhooks: %w", squadId, err)
+	}
+
+	hooks := make([]*WebhookRecord, 0, len(docs))
+	for _, doc := range docs {
+		h := &WebhookRecord{ID: doc.Ref.ID}
+		if err = doc.DataTo(&h.Webhook); err != nil {
+			return nil, fmt.Errorf("Failed to get squad %v webhooks: %w", squadId, err)
+		}
+		hooks = append(hooks, h)
+	}
+
+	return hooks, nil
+}
+
+func (db *FirestoreDB) GetWebhook(ctx context.Context, squadId string, webhookId string) (*Webhook, error) {
+	doc, err := db.Squads.Doc(squadId).Collection("webhooks").Doc(webhookId).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, notFound("Failed to get webhook %v: not found", webhookId)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get webhook %v: %w", webhookId, err)
+	}
+
+	hook := &Webhook{}
+	if err = doc.DataTo(hook); err != nil {
+		return nil, fmt.Errorf("Failed to get webhook %v: %w", webhookId, err)
+	}
+
+	return hook, nil
+}
+
+func (db *FirestoreDB) DeleteWebhook(ctx context.Context, squadId string, webhookId string) error {
+	log.Println("Deleting webhook " + webhookId + " from squad " + squadId)
+
+	_, err := db.Squads.Doc(squadId).Collection("webhooks").Doc(webhookId).Delete(ctx)
+	if err != nil {
+		return fmt.Errorf("Error while deleting webhook "+webhookId+" from squad "+squadId+": %w", err)
+	}
+
+	// delivery log is not needed without the webhook
+	docs, err := db.WebhookDeliveries.Where("WebhookId", "==", webhookId).Documents(ctx).GetAll()
+	if err != nil {
+		return fmt.Errorf("Failed to get webhook %v deliveries: %w", webhookId, err)
+	}
+	for from := 0; from < len(docs); from += notificationsBatchSize {
+		to := from + notificationsBatchSize
+		if to > len(docs) {
+			to = len(docs)
+		}
+
+		batch := db.Client.Batch()
+		for _, doc := range docs[from:to] {
+			batch.Delete(doc.Ref)
+		}
+		if _, err := batch.Commit(ctx); err != nil {
+			return fmt.Errorf("Failed to delete webhook %v deliveries: %w", webhookId, err)
+		}
+	}
+
+	return nil
+}
+
+func (db *FirestoreDB) CreateWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) (string, error) {
+	doc, _, err := db.WebhookDeliveries.Add(ctx, delivery)
+	if err != nil {
+		return "", fmt.Errorf("Failed to create delivery of webhook %v: %w", delivery.WebhookId, err)
+	}
+
+	return doc.ID, nil
+}
+
+func (db *FirestoreDB) UpdateWebhookDelivery(ctx context.Context, deliveryId string, delivery *WebhookDelivery) error {
+	_, err := db.WebhookDeliveries.Doc(deliveryId).Set(ctx, delivery)
+	if err != nil {
+		return fmt.Errorf("Failed to update webhook delivery %v: %w", deliveryId, err)
+	}
+
+	return nil
+}
+
+func (db *FirestoreDB) GetWebhookDeliveries(ctx context.Context, webhookId string, limit int) ([]*WebhookDeliveryRecord, error) {
+	docs, err := db.WebhookDeliveries.Where("WebhookId", "==", webhookId).OrderBy("Created", firestore.Desc).Limit(limit).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get webhook %v deliveries: %w", webhookId, err)
+	}
+
+	deliveries := make([]*WebhookDeliveryRecord, 0, len(docs))
+	for _, doc := range docs {
+		d := &WebhookDeliveryRecord{ID: doc.Ref.ID}
+		if err = doc.DataTo(&d.WebhookDelivery); err != nil {
+			return nil, fmt.Errorf("Failed to get webhook %v deliveries: %w", webhookId, err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, nil
+}
+
+func (db *FirestoreDB) GetDueWebhookDeliveries(ctx context.Context, before time.Time) ([]*WebhookDeliveryRecord, error) {
+	docs, err := db.WebhookDeliveries.Where("Status", "==", DeliveryPending).Where("NextAttempt", "<=", before).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get due webhook deliveries: %w", err)
+	}
+
+	deliveries := make([]*WebhookDeliveryRecord, 0, len(docs))
+	for _, doc := range docs {
+		d := &WebhookDeliveryRecord{ID: doc.Ref.ID}
+		if err = doc.DataTo(&d.WebhookDelivery); err != nil {
+			return nil, fmt.Errorf("Failed to get due webhook deliveries: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	sortDueWebhookDeliveries(deliveries)
+
+	return deliveries, nil
+}
@@ -0,0 +1,183 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Roles which might be referenced in workflow transitions and notifications,
+// anything else is treated as a squad tag
+const (
+	WorkflowRequester = "@requester"
+	WorkflowApprovers = "@approvers"
+	WorkflowHandlers  = "@handlers"
+	WorkflowAdmins    = "@admins"
+	WorkflowAssignee  = "@assignee"
+)
+
+// WorkflowState is a named state of a request. Status defines in which list
+// request in this state is shown (to approve, to handle or closed ones), so
+// there might be several states with the same status.
+type WorkflowState struct {
+	Name   string            `json:"name"`
+	Status RequestStatusType `json:"status"`
+	// roles and tags notified when request gets into this state
+	Notify []string `json:"notify,omitempty"`
+}
+
+type WorkflowTransition struct {
+	Name string   `json:"name"`
+	From []string `json:"from"`
+	To   string   `json:"to"`
+	// roles and tags allowed to perform this transition
+	Allowed []string `json:"allowed"`
+}
+
+// Workflow is a state machine of requests in a queue
+type Workflow struct {
+	Initial     string               `json:"initial"`
+	States      []WorkflowState      `json:"states"`
+	Transitions []WorkflowTransition `json:"transitions"`
+}
+
+// DefaultWorkflow returns workflow used by queues without their own one:
+// WaitingApprove -> Processing -> Completed, approvers might decline request
+// and requester might cancel it until it is completed
+func DefaultWorkflow(qi *QueueInfo) *Workflow {
+	wf := &Workflow{
+		Initial: WaitingApprove.String(),
+		States: []WorkflowState{
+			{Name: WaitingApprove.String(), Status: WaitingApprove, Notify: []string{WorkflowApprovers}},
+			{Name: Processing.String(), Status: Processing, Notify: []string{WorkflowHandlers}},
+			{Name: Completed.String(), Status: Completed, Notify: []string{WorkflowRequester}},
+			{Name: Declined.String(), Status: Declined, Notify: []string{WorkflowRequester}},
+			{Name: Cancelled.String(), Status: Cancelled},
+		},
+		Transitions: []WorkflowTransition{
+			{
+				Name:    "Approve",
+				From:    []string{WaitingApprove.String()},
+				To:      Processing.String(),
+				Allowed: []string{WorkflowApprovers, WorkflowAdmins},
+			},
+			{
+				Name:    "Decline",
+				From:    []string{WaitingApprove.String(), Processing.String()},
+				To:      Declined.String(),
+				Allowed: []string{WorkflowApprovers, WorkflowAdmins},
+			},
+			{
+				Name:    "Complete",
+				From:    []string{Processing.String()},
+				To:      Completed.String(),
+				Allowed: []string{WorkflowHandlers, WorkflowApprovers, WorkflowAdmins},
+			},
+			{
+				Name:    "Cancel",
+				From:    []string{WaitingApprove.String(), Processing.String()},
+				To:      Cancelled.String(),
+				Allowed: []string{WorkflowRequester},
+			},
+		},
+	}
+
+	if qi.Approvers == "" {
+		wf.Initial = Processing.String()
+	}
+
+	return wf
+}
+
+// GetWorkflow returns queue workflow or the default one if queue does not define it
+func (qi *QueueInfo) GetWorkflow() *Workflow {
+	if qi.Workflow != nil {
+		return qi.Workflow
+	}
+	return DefaultWorkflow(qi)
+}
+
+func (wf *Workflow) GetState(name string) *WorkflowState {
+	for i := range wf.States {
+		if wf.States[i].Name == name {
+			return &wf.States[i]
+		}
+	}
+	return nil
+}
+
+// GetTransitions returns transitions available from the given state
+func (wf *Workflow) GetTransitions(from string) []*WorkflowTransition {
+	transitions := make([]*WorkflowTransition, 0)
+	for i := range wf.Transitions {
+		if containsString(wf.Transitions[i].From, from) {
+			transitions = append(transitions, &wf.Transitions[i])
+		}
+	}
+	return transitions
+}
+
+// GetTransition returns transition from one state to another, or nil if there is no such
+func (wf *Workflow) GetTransition(from string, to string) *WorkflowTransition {
+	for _, t := range wf.GetTransitions(from) {
+		if t.To == to {
+			return t
+		}
+	}
+	return nil
+}
+
+func (wf *Workflow) Validate() error {
+	if len(wf.States) == 0 {
+		return errors.New("Workflow should have at least one state")
+	}
+
+	names := make(map[string]bool, len(wf.States))
+	for _, s := range wf.States {
+		if strings.TrimSpace(s.Name) == "" {
+			return errors.New("Workflow state name should not be empty")
+		}
+		if names[s.Name] {
+			return fmt.Errorf("Workflow state %v is defined more than once", s.Name)
+		}
+		if s.Status < WaitingApprove || s.Status > Cancelled {
+			return fmt.Errorf("Workflow state %v has unknown status %v", s.Name, int(s.Status))
+		}
+		names[s.Name] = true
+	}
+
+	if !names[wf.Initial] {
+		return fmt.Errorf("Workflow initial state %v is not defined", wf.Initial)
+	}
+
+	for _, t := range wf.Transitions {
+		if !names[t.To] {
+			return fmt.Errorf("Workflow transition %v leads to unknown state %v", t.Name, t.To)
+		}
+		if len(t.From) == 0 {
+			return fmt.Errorf("Workflow transition %v should have at least one source state", t.Name)
+		}
+		for _, from := range t.From {
+			if !names[from] {
+				return fmt.Errorf("Workflow transition %v starts from unknown state %v", t.Name, from)
+			}
+			if from == t.To {
+				return fmt.Errorf("Workflow transition %v leads to the same state %v", t.Name, from)
+			}
+		}
+		if len(t.Allowed) == 0 {
+			return fmt.Errorf("Workflow transition %v is not allowed to anyone", t.Name)
+		}
+	}
+
+	return nil
+}
+
+// CurrentState returns name of the workflow state of the request, requests
+// created before workflows were introduced are in the state named after status
+func (r *RequestDetails) CurrentState() string {
+	if r.State != "" {
+		return r.State
+	}
+	return r.Status.String()
+}
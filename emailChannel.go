@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"text/template"
+
+	assist_db "assist/db"
+)
+
+// subjects and bodies of emails are defined in this file as "<kind>.subject" and
+// "<kind>.body" templates, "default" ones are used for kinds without templates
+const emailTemplatesPath = "templates/email.tmpl"
+
+// emailChannel sends notifications by email, either right away or collected
+// into hourly or daily digest depending on the user choice
+type emailChannel struct {
+	db        assist_db.Store
+	mailer    *Mailer
+	appURL    string
+	templates *template.Template
+	dev       bool
+}
+
+// emailNotification is passed to email templates
+type emailNotification struct {
+	assist_db.Notification
+	// absolute link to the page of the squad, event or request, empty when APP_URL is not set
+	URL string
+}
+
+type emailDigest struct {
+	Digest        string
+	Notifications []*emailNotification
+	URL           string
+}
+
+func newEmailChannel(db assist_db.Store, mailer *Mailer, appURL string, dev bool) (*emailChannel, error) {
+	tmpl, err := template.ParseFiles(emailTemplatesPath)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse email templates: %w", err)
+	}
+
+	return &emailChannel{
+		db:        db,
+		mailer:    mailer,
+		appURL:    strings.TrimSuffix(appURL, "/"),
+		templates: tmpl,
+		dev:       dev,
+	}, nil
+}
+
+func (c *emailChannel) Name() string {
+	return "email"
+}
+
+func (c *emailChannel) Deliver(ctx context.Context, userId string, n *assist_db.Notification) error {
+	digest, err := c.db.GetEmailDigest(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	if digest != assist_db.DigestImmediate {
+		item := &assist_db.DigestItem{Digest: digest, Notification: *n}
+		item.UserId = userId
+		return c.db.CreateDigestItem(ctx, item)
+	}
+
+	email, err := c.userEmail(ctx, userId)
+	if err != nil || email == "" {
+		return err
+	}
+
+	kind := n.Kind
+	if c.templates.Lookup(kind+".subject") == nil {
+		kind = "default"
+	}
+	subject, body, err := c.render(kind, c.emailNotification(n))
+	if err != nil {
+		return err
+	}
+
+	return c.mailer.send([]string{email}, subject, body)
+}
+
+// sendDigests sends every user one email with all notifications collected for the digest
+func (c *emailChannel) sendDigests(ctx context.Context, digest string) error {
+	items, err := c.db.GetDigestItems(ctx, digest)
+	if err != nil {
+		return err
+	}
+
+	// items are ordered by user, so notifications of every user go one after another
+	var lastErr error
+	sent := 0
+	for from := 0; from < len(items); {
+		to := from
+		for to < len(items) && items[to].UserId == items[from].UserId {
+			to++
+		}
+
+		if err = c.sendDigest(ctx, digest, items[from:to]); err != nil {
+			log.Println(err.Error())
+			lastErr = err
+		} else {
+			sent++
+		}
+		from = to
+	}
+
+	if c.dev && sent > 0 {
+		log.Printf("Sent %v %v digests", sent, digest)
+	}
+
+	return lastErr
+}
+
+func (c *emailChannel) sendDigest(ctx context.Context, digest string, items []*assist_db.DigestItemRecord) error {
+	userId := items[0].UserId
+
+	email, err := c.userEmail(ctx, userId)
+	if err != nil {
+		return fmt.Errorf("Failed to send %v digest to user %v: %w", digest, userId, err)
+	}
+
+	// notifications of users without email are dropped, they are still in the inbox
+	if email != "" {
+		data := &emailDigest{Digest: digest, URL: c.appURL}
+		for _, item := range items {
+			data.Notifications = append(data.Notifications, c.emailNotification(&item.Notification))
+		}
+
+		subject, body, err := c.render("digest", data)
+		if err != nil {
+			return fmt.Errorf("Failed to send %v digest to user %v: %w", digest, userId, err)
+		}
+		if err = c.mailer.send([]string{email}, subject, body); err != nil {
+			return err
+		}
+	}
+
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+	return c.db.DeleteDigestItems(ctx, ids)
+}
+
+func (c *emailChannel) userEmail(ctx context.Context, userId string) (string, error) {
+	userInfo, err := c.db.GetUserInfo(ctx, userId)
+	if err != nil {
+		return "", err
+	}
+	return userInfo.Email, nil
+}
+
+func (c *emailChannel) emailNotification(n *assist_db.Notification) *emailNotification {
+	en := &emailNotification{Notification: *n}
+	if c.appURL != "" && n.Link != nil {
+		switch n.Link.Type {
+		case assist_db.LinkSquad:
+			en.URL = c.appURL + "/squads/" + url.PathEscape(n.Link.ID)
+		case assist_db.LinkEvent:
+			en.URL = c.appURL + "/events"
+		case assist_db.LinkRequest:
+			en.URL = c.appURL + "/requests"
+		}
+	}
+	return en
+}
+
+// render executes subject and body templates with given name prefix, subject is
+// collapsed to a single line
+func (c *emailChannel) render(name string, data interface{}) (string, string, error) {
+	var subject, body bytes.Buffer
+	if err := c.templates.ExecuteTemplate(&subject, name+".subject", data); err != nil {
+		return "", "", fmt.Errorf("Failed to render %v email subject: %w", name, err)
+	}
+	if err := c.templates.ExecuteTemplate(&body, name+".body", data); err != nil {
+		return "", "", fmt.Errorf("Failed to render %v email body: %w", name, err)
+	}
+
+	return strings.Join(strings.Fields(subject.String()), " "), strings.TrimSpace(body.String()) + "\n", nil
+}
@@ -14,16 +14,26 @@ require (
 	github.com/gorilla/csrf v1.7.0
 	github.com/gorilla/handlers v1.5.1
 	github.com/gorilla/mux v1.8.0
+	github.com/lib/pq v1.9.0
+	github.com/mattn/go-sqlite3 v1.14.6
 	github.com/minio/blake2b-simd v0.0.0-20160723061019-3f5f724cb5b1 // indirect
 	github.com/monochromegane/go-gitignore v0.0.0-20200626010858-205db1a8cc00 // indirect
 	github.com/patrickmn/go-cache v2.1.0+incompatible
 	github.com/russross/blackfriday v1.6.0 // indirect
 	github.com/russross/blackfriday/v2 v2.1.0
 	github.com/spf13/cobra v1.1.3 // indirect
+	golang.org/x/crypto v0.0.0-20211117183948-ae814b36b871
+	golang.org/x/oauth2 v0.0.0-20201208152858-08078c50e5b5
 	golang.org/x/perf v0.0.0-20210220033136-40a54f11e909 // indirect
-	golang.org/x/text v0.3.5 // indirect
 	golang.org/x/tools v0.1.0 // indirect
 	google.golang.org/api v0.37.0
 	google.golang.org/appengine v1.6.7
 	google.golang.org/grpc v1.34.0
 )
+
+// keep x/ dependencies at versions used by grpc & firebase, x/crypto is only needed for bcrypt
+replace (
+	golang.org/x/net => golang.org/x/net v0.0.0-20201209123823-ac852fbbde11
+	golang.org/x/sys => golang.org/x/sys v0.0.0-20210119212857-b64e53b001e4
+	golang.org/x/text => golang.org/x/text v0.3.5
+)
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	assist_db "assist/db"
+)
+
+// cronSchedule is parsed cron expression of 5 fields: minute, hour, day of
+// month, month and day of week. Every field is *, number, range a-b, list of
+// them separated by commas, optionally with step (*/5, 10-40/10).
+type cronSchedule struct {
+	expr    string
+	minute  uint64
+	hour    uint64
+	dom     uint64
+	month   uint64
+	dow     uint64
+	domStar bool
+	dowStar bool
+}
+
+func parseCronField(field string, min int, max int) (bits uint64, star bool, err error) {
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return 0, false, fmt.Errorf("Wrong step in %v", part)
+			}
+			part = part[:i]
+		}
+
+		from, to := min, max
+		switch {
+		case part == "*":
+			star = star || step == 1
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			from, err = strconv.Atoi(bounds[0])
+			if err == nil {
+				to, err = strconv.Atoi(bounds[1])
+			}
+			if err != nil {
+				return 0, false, fmt.Errorf("Wrong range %v", part)
+			}
+		default:
+			from, err = strconv.Atoi(part)
+			if err != nil {
+				return 0, false, fmt.Errorf("Wrong value %v", part)
+			}
+			to = from
+		}
+
+		if from < min || to > max || from > to {
+			return 0, false, fmt.Errorf("Value %v is out of range %v-%v", part, min, max)
+		}
+		for v := from; v <= to; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, star, nil
+}
+
+func parseCron(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("Cron expression '%v' should have 5 fields", expr)
+	}
+
+	s := &cronSchedule{expr: expr}
+	var err error
+	if s.minute, _, err = parseCronField(fields[0], 0, 59); err == nil {
+		if s.hour, _, err = parseCronField(fields[1], 0, 23); err == nil {
+			if s.dom, s.domStar, err = parseCronField(fields[2], 1, 31); err == nil {
+				if s.month, _, err = parseCronField(fields[3], 1, 12); err == nil {
+					s.dow, s.dowStar, err = parseCronField(fields[4], 0, 7)
+				}
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse cron expression '%v': %w", expr, err)
+	}
+
+	// both 0 and 7 are Sunday
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+
+	return s, nil
+}
+
+// dayMatches follows cron rule: if both day of month and day of week are
+// restricted, day matching either of them is fine
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// next returns the first time after t matching the schedule, in location of t
+func (s *cronSchedule) next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	for limit := t.AddDate(5, 0, 0); t.Before(limit); {
+		year, month, day := t.Date()
+		switch {
+		case s.month&(1<<uint(month)) == 0:
+			t = time.Date(year, month+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			t = time.Date(year, month, day+1, 0, 0, 0, 0, loc)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(year, month, day, t.Hour()+1, 0, 0, 0, loc)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// Job is registered background task
+type Job struct {
+	name     string
+	schedule *cronSchedule
+	// job is cancelled after timeout, until then other instances could not run it
+	timeout time.Duration
+	run     func(ctx context.Context) error
+}
+
+// JobInfo is job schedule together with its shared status
+type JobInfo struct {
+	assist_db.JobStatus
+	Schedule string     `json:"schedule"`
+	NextRun  *time.Time `json:"nextRun,omitempty"`
+}
+
+// JobRunner runs registered jobs on their schedules. Status of the jobs is
+// kept in the database, it serves as a lock so every scheduled run is done
+// by one instance of the server only.
+type JobRunner struct {
+	db       assist_db.Store
+	instance string
+	// current time, replaced in tests
+	now func() time.Time
+	// schedules are in local time (TZ)
+	location *time.Location
+	started  time.Time
+	mx       sync.RWMutex
+	jobs     []*Job
+	// jobs being run by this instance, they are not started again till they finish
+	running   map[string]bool
+	runningMx sync.Mutex
+	wg        sync.WaitGroup
+}
+
+func newJobRunner(db assist_db.Store) *JobRunner {
+	hostname, _ := os.Hostname()
+	return &JobRunner{
+		db:       db,
+		instance: fmt.Sprintf("%v-%v-%v", hostname, os.Getpid(), time.Now().UnixNano()),
+		now:      time.Now,
+		location: time.Local,
+		started:  time.Now(),
+		running:  make(map[string]bool),
+	}
+}
+
+func (jr *JobRunner) register(name string, schedule string, timeout time.Duration, run func(ctx context.Context) error) error {
+	s, err := parseCron(schedule)
+	if err != nil {
+		return fmt.Errorf("Failed to register job %v: %w", name, err)
+	}
+
+	jr.mx.Lock()
+	defer jr.mx.Unlock()
+
+	if jr.getJob(name) != nil {
+		return fmt.Errorf("Job %v is already registered", name)
+	}
+	jr.jobs = append(jr.jobs, &Job{name, s, timeout, run})
+
+	return nil
+}
+
+func (jr *JobRunner) findJob(name string) *Job {
+	jr.mx.RLock()
+	defer jr.mx.RUnlock()
+
+	return jr.getJob(name)
+}
+
+// getJob should be called under lock
+func (jr *JobRunner) getJob(name string) *Job {
+	for _, j := range jr.jobs {
+		if j.name == name {
+			return j
+		}
+	}
+	return nil
+}
+
+// getStatuses returns shared status of jobs, jobs which have never run get empty one
+func (jr *JobRunner) getStatuses(ctx context.Context) (map[string]*assist_db.JobStatus, error) {
+	statuses, err := jr.db.GetJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]*assist_db.JobStatus, len(statuses))
+	for _, s := range statuses {
+		res[s.Name] = s
+	}
+	for _, j := range jr.jobs {
+		if res[j.name] == nil {
+			res[j.name] = &assist_db.JobStatus{Name: j.name}
+		}
+	}
+
+	return res, nil
+}
+
+// nextRun returns time of the next scheduled run after the last one, so a
+// run missed while server was down is done once it starts; jobs which have
+// never run are scheduled after the start of the runner
+func (jr *JobRunner) nextRun(j *Job, s *assist_db.JobStatus) time.Time {
+	last := jr.started
+	if s.LastStart != nil {
+		last = *s.LastStart
+	}
+	return j.schedule.next(last.In(jr.location))
+}
+
+func (jr *JobRunner) getJobs(ctx context.Context) ([]*JobInfo, error) {
+	jr.mx.RLock()
+	defer jr.mx.RUnlock()
+
+	statuses, err := jr.getStatuses(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get jobs: %w", err)
+	}
+
+	jobs := make([]*JobInfo, 0, len(jr.jobs))
+	for _, j := range jr.jobs {
+		s := statuses[j.name]
+		next := jr.nextRun(j, s)
+		jobs = append(jobs, &JobInfo{JobStatus: *s, Schedule: j.schedule.expr, NextRun: &next})
+	}
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].Name < jobs[k].Name })
+
+	return jobs, nil
+}
+
+// startRunning marks the job running, returns false if it is running already
+func (jr *JobRunner) startRunning(name string) bool {
+	jr.runningMx.Lock()
+	defer jr.runningMx.Unlock()
+
+	if jr.running[name] {
+		return false
+	}
+	jr.running[name] = true
+	return true
+}
+
+func (jr *JobRunner) stopRunning(name string) {
+	jr.runningMx.Lock()
+	defer jr.runningMx.Unlock()
+
+	delete(jr.running, name)
+}
+
+// runJob runs the job unless it is locked by another instance or has been
+// started after due time, returns false if the job has not run
+func (jr *JobRunner) runJob(ctx context.Context, j *Job, due time.Time) (ran bool, err error) {
+	now := jr.now()
+	started, err := jr.db.StartJob(ctx, j.name, jr.instance, due, now, now.Add(j.timeout))
+	if err != nil || !started {
+		return false, err
+	}
+
+	jobCtx, cancel := context.WithTimeout(ctx, j.timeout)
+	defer cancel()
+
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("Job %v panicked: %v", j.name, r)
+			}
+		}()
+		err = j.run(jobCtx)
+	}()
+
+	jobErr := ""
+	if err != nil {
+		jobErr = err.Error()
+		log.Printf("Job %v failed: %v", j.name, err)
+	}
+	if ferr := jr.db.FinishJob(ctx, j.name, jr.instance, jr.now(), jobErr); ferr != nil {
+		log.Println(ferr.Error())
+	}
+
+	return true, err
+}
+
+// runDue starts jobs which are due at the moment without waiting for them,
+// jobs still running since the previous check are skipped
+func (jr *JobRunner) runDue(ctx context.Context) error {
+	jr.mx.RLock()
+	defer jr.mx.RUnlock()
+
+	statuses, err := jr.getStatuses(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to get jobs: %w", err)
+	}
+
+	now := jr.now()
+	for _, j := range jr.jobs {
+		due := jr.nextRun(j, statuses[j.name])
+		if due.IsZero() || due.After(now) {
+			continue
+		}
+		if !jr.startRunning(j.name) {
+			continue
+		}
+
+		jr.wg.Add(1)
+		go func(j *Job, due time.Time) {
+			defer jr.wg.Done()
+			defer jr.stopRunning(j.name)
+			ok, err := jr.runJob(ctx, j, due)
+			if err != nil && !ok {
+				log.Printf("Failed to run job %v: %v", j.name, err)
+			}
+		}(j, due)
+	}
+
+	return nil
+}
+
+// wait blocks till jobs started by runDue finish
+func (jr *JobRunner) wait() {
+	jr.wg.Wait()
+}
+
+// trigger runs the job right now, returns false if the job is running already
+func (jr *JobRunner) trigger(ctx context.Context, name string) (bool, error) {
+	j := jr.findJob(name)
+	if j == nil {
+		return false, fmt.Errorf("Job %v is not registered", name)
+	}
+
+	if !jr.startRunning(name) {
+		return false, nil
+	}
+	defer jr.stopRunning(name)
+
+	return jr.runJob(ctx, j, jr.now())
+}
+
+// run checks jobs every interval until context is done, then waits for running jobs
+func (jr *JobRunner) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := jr.runDue(ctx); err != nil {
+			log.Println(err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			jr.wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// Mailer sends emails via SMTP server configured with SMTP_ADDR (host:port),
+// SMTP_FROM and optional SMTP_USER/SMTP_PASSWORD. Without SMTP_ADDR emails
+// are only logged, which is enough to run the app on a single box.
+type Mailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+	dev  bool
+}
+
+func initMailer(dev bool) *Mailer {
+	m := &Mailer{
+		addr: os.Getenv("SMTP_ADDR"),
+		from: os.Getenv("SMTP_FROM"),
+		dev:  dev,
+	}
+
+	if m.from == "" {
+		m.from = "assist@localhost"
+	}
+
+	if user := os.Getenv("SMTP_USER"); user != "" && m.addr != "" {
+		host, _, err := net.SplitHostPort(m.addr)
+		if err != nil {
+			log.Fatalf("Invalid SMTP_ADDR %v: %v", m.addr, err)
+		}
+		m.auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)
+	}
+
+	return m
+}
+
+func (m *Mailer) send(to []string, subject string, body string) error {
+	if m.addr == "" {
+		log.Printf("SMTP_ADDR is not set, email to %v is not sent:\n%v\n%v", to, subject, body)
+		return nil
+	}
+
+	if m.dev {
+		log.Printf("Sending email '%v' to %v", subject, to)
+	}
+
+	msg := "From: " + m.from + "\r\n" +
+		"To: " + strings.Join(to, ", ") + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + body
+
+	err := smtp.SendMail(m.addr, m.auth, m.from, to, []byte(msg))
+	if err != nil {
+		return fmt.Errorf("Failed to send email to %v: %w", to, err)
+	}
+
+	return nil
+}
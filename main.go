@@ -6,6 +6,8 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"context"
 
@@ -25,40 +27,146 @@ func initApp(dev bool) (*App, error) {
 	log.SetOutput(os.Stderr)
 	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
 
-	ctx := context.Background()
-
-	// init fireapp
-	fireapp, err := firebase.NewApp(ctx, nil)
-	if err != nil {
-		log.Fatalf("firebase.NewApp: %v", err)
+	// firebase is initialized only when some part of the app is configured to use it
+	getFireapp := func() *firebase.App {
+		if app.fireapp == nil {
+			fireapp, err := firebase.NewApp(context.Background(), nil)
+			if err != nil {
+				log.Fatalf("firebase.NewApp: %v", err)
+			}
+			app.fireapp = fireapp
+		}
+		return app.fireapp
 	}
 
-	// init firestore
-	app.db, err = assist_db.NewFirestoreDB(fireapp, app.dev)
-	if err != nil {
-		log.Fatalf("Failed to init database: %v", err)
+	var err error
+
+	// init storage, firestore unless other driver is configured
+	switch driver := os.Getenv("DB_DRIVER"); driver {
+	case "", "firestore":
+		app.db, err = assist_db.NewFirestoreDB(getFireapp(), app.dev)
+		if err != nil {
+			log.Fatalf("Failed to init database: %v", err)
+		}
+	case "memory":
+		log.Println("Using in-memory storage, all data will be lost on exit")
+		app.db = assist_db.NewMemoryDB(app.dev)
+	case "postgres", "sqlite3":
+		app.db, err = assist_db.NewSQLDB(driver, os.Getenv("DB_DSN"), app.dev)
+		if err != nil {
+			log.Fatalf("Failed to init database: %v", err)
+		}
+	default:
+		log.Fatalf("Unknown DB_DRIVER %v", driver)
 	}
 
-	// init firebase auth
-	su := initSessionUtil(fireapp, app.db, dev)
+	app.mailer = initMailer(dev)
+
+	// init auth providers, comma separated list in AUTH_PROVIDERS, firebase by default
+	su := initSessionUtil(app.db, dev)
+	providers := os.Getenv("AUTH_PROVIDERS")
+	if providers == "" {
+		providers = "firebase"
+	}
+	for _, name := range strings.Split(providers, ",") {
+		switch name = strings.TrimSpace(name); name {
+		case "firebase":
+			p, err := newFirebaseAuthProvider(su, getFireapp())
+			if err != nil {
+				log.Fatalf("Failed to init firebase auth: %v", err)
+			}
+			su.addProvider(p)
+		case "local":
+			su.addProvider(newLocalAuthProvider(su, app.db, app.mailer))
+		case "oidc":
+			p, err := newOIDCAuthProvider(su)
+			if err != nil {
+				log.Fatalf("Failed to init OIDC auth: %v", err)
+			}
+			su.addProvider(p)
+		default:
+			log.Fatalf("Unknown auth provider %v", name)
+		}
+	}
 	app.sm = su
 	app.sd = su
+	app.calendarSecret = su.secret
 
-	app.ntfs, err = InitNotifications(fireapp, dev)
+	app.ntfs, err = InitNotifications(app.fireapp, app.db, app.mailer, dev)
 	if err != nil {
 		log.Fatalf("Failed to init notifications: %v", err)
 	}
 
+	app.hooks = newWebhooks(app.db, dev)
+
+	app.jobs = newJobRunner(app.db)
+	if err = app.registerJobs(); err != nil {
+		log.Fatalf("Failed to init jobs: %v", err)
+	}
+
 	return &app, nil
 }
 
+// registerJobs registers background jobs, schedules are cron expressions in local time
+func (app *App) registerJobs() error {
+	reminders := newReminderScheduler(app.db, app.ntfs)
+
+	jobs := []struct {
+		name     string
+		schedule string
+		timeout  time.Duration
+		run      func(ctx context.Context) error
+	}{
+		// archive events which took place
+		{"archiveEvents", "0 3 * * *", 30 * time.Minute, app.db.ArchiveOldEvents},
+		// remind participants about upcoming events
+		{"sendReminders", "* * * * *", 5 * time.Minute, func(ctx context.Context) error {
+			_, err := reminders.sendReminders(ctx)
+			return err
+		}},
+		// fix events counters which drifted from actual participants
+		{"recountEventCounters", "30 3 * * *", 30 * time.Minute, func(ctx context.Context) error {
+			n, err := app.db.RecountEventCounters(ctx)
+			if n > 0 {
+				log.Printf("Fixed counters of %v events", n)
+			}
+			return err
+		}},
+		// check requests queues SLA
+		{"escalateRequests", "*/5 * * * *", 5 * time.Minute, app.escalateOverdueRequests},
+		// keep occurrences of recurring events generated ahead
+		{"generateEventSeries", "0 * * * *", 30 * time.Minute, app.generateEventSeries},
+		// drop notifications older than retention period
+		{"deleteOldNotifications", "15 4 * * *", 30 * time.Minute, app.ntfs.deleteOldNotifications},
+		// send email digests of notifications
+		{"sendHourlyDigests", "0 * * * *", 30 * time.Minute, app.ntfs.sendDigests(assist_db.DigestHourly)},
+		{"sendDailyDigests", "0 8 * * *", 30 * time.Minute, app.ntfs.sendDigests(assist_db.DigestDaily)},
+		// retry webhook deliveries which failed
+		{"deliverWebhooks", "* * * * *", 5 * time.Minute, app.hooks.deliverDue},
+	}
+
+	for _, j := range jobs {
+		if err := app.jobs.register(j.name, j.schedule, j.timeout, j.run); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 type App struct {
 	logWriter io.Writer
-	db        *assist_db.FirestoreDB
+	db        assist_db.Store
+	fireapp   *firebase.App
+	mailer    *Mailer
 	ntfs      *Notifications
+	hooks     *Webhooks
 	sd        SessionDataGetter
 	sm        SessionMiddleware
 	dev       bool
+	// key of calendar feeds and check-in tokens
+	calendarSecret []byte
+	jobs           *JobRunner
 }
 
 func main() {
@@ -82,6 +190,9 @@ func main() {
 
 	app.registerHandlers()
 
+	// run background jobs
+	go app.jobs.run(context.Background(), time.Minute)
+
 	log.Printf("Listening on localhost: %s", port)
 	if err := http.ListenAndServe(":"+port, nil); err != nil {
 		log.Fatal(err)
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	assist_db "assist/db"
+
+	gorilla_context "github.com/gorilla/context"
+	"github.com/gorilla/mux"
+)
+
+// calendar feeds are subscribed by calendar apps which do not have session
+// cookie, so they are authenticated by a secret token in the URL
+func (app *App) calendarToken(feed string) string {
+	h := hmac.New(sha256.New, app.calendarSecret)
+	h.Write([]byte("calendar/" + feed))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// feed is identified by kind and ids, e.g. users/{userId} or squads/{squadId}/{userId}
+func (app *App) calendarFeedPath(kind string, ids ...string) string {
+	escaped := make([]string, len(ids))
+	for i, id := range ids {
+		escaped[i] = url.PathEscape(id)
+	}
+	feed := kind + "/" + strings.Join(ids, "/")
+	return "/methods/calendar/" + kind + "/" + strings.Join(escaped, "/") + "/" + app.calendarToken(feed) + ".ics"
+}
+
+// returns false and writes error if token from the URL is not valid for the feed
+func (app *App) checkCalendarToken(w http.ResponseWriter, r *http.Request, kind string, ids ...string) bool {
+	token := mux.Vars(r)["token"]
+	feed := kind + "/" + strings.Join(ids, "/")
+	if !hmac.Equal([]byte(token), []byte(app.calendarToken(feed))) {
+		err := fmt.Errorf("Invalid calendar token for %v", feed)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return false
+	}
+
+	gorilla_context.Set(r, "AuthChecked", true)
+	return true
+}
+
+// icsWriter writes iCalendar (RFC 5545) content lines
+type icsWriter struct {
+	bytes.Buffer
+}
+
+// removes control characters, they could end content line and start another property
+func icsStripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// escapes TEXT value
+func icsText(s string) string {
+	return icsStripControl(strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\r", `\n`, "\n", `\n`).Replace(s))
+}
+
+// quotes parameter value, quoted value could contain ; , and : but neither
+// double quotes nor control characters (RFC 5545 3.2)
+func icsParam(s string) string {
+	return `"` + strings.ReplaceAll(icsStripControl(s), `"`, "'") + `"`
+}
+
+// writes content line folded to 75 octets
+func (iw *icsWriter) line(s string) {
+	for len(s) > 75 {
+		// do not split UTF-8 sequences
+		n := 75
+		for n > 0 && s[n]&0xC0 == 0x80 {
+			n--
+		}
+		iw.WriteString(s[:n] + "\r\n ")
+		s = s[n:]
+	}
+	iw.WriteString(s + "\r\n")
+}
+
+// times of events are kept as strings in HH:MM format and have no time zone,
+// so they are written as floating local time
+func parseEventTime(day time.Time, hhmm string) (time.Time, bool) {
+	t, err := time.Parse("15:04", hhmm)
+	if err != nil {
+		return day, false
+	}
+	return day.Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute), true
+}
+
+func participantPartStat(status assist_db.ParticipantStatusType) string {
+	switch status {
+	case assist_db.Applied:
+		return "TENTATIVE"
+	case assist_db.Going, assist_db.Attended, assist_db.EventOwner:
+		return "ACCEPTED"
+	case assist_db.NotGoing, assist_db.NoShow:
+		return "DECLINED"
+	}
+	return "NEEDS-ACTION"
+}
+
+// attendee is optional, it is used to show participant status in the user feed
+func (iw *icsWriter) event(id string, e *assist_db.EventInfo, host string, stamp time.Time, attendee *assist_db.UserInfo) {
+	day := dayOf(*e.Date)
+
+	iw.line("BEGIN:VEVENT")
+	iw.line("UID:" + id + "@" + host)
+	iw.line("DTSTAMP:" + stamp.UTC().Format("20060102T150405Z"))
+	if from, ok := parseEventTime(day, e.TimeFrom); ok {
+		iw.line("DTSTART:" + from.Format("20060102T150405"))
+		if to, ok := parseEventTime(day, e.TimeTo); ok {
+			if to.Before(from) {
+				to = to.AddDate(0, 0, 1)
+			}
+			iw.line("DTEND:" + to.Format("20060102T150405"))
+		}
+	} else {
+		iw.line("DTSTART;VALUE=DATE:" + day.Format("20060102"))
+		iw.line("DTEND;VALUE=DATE:" + day.AddDate(0, 0, 1).Format("20060102"))
+	}
+
+	summary := strings.SplitN(strings.TrimSpace(e.Text), "\n", 2)[0]
+	iw.line("SUMMARY:" + icsText(e.SquadId+": "+strings.TrimSpace(summary)))
+	description := e.Text
+	if l := e.Location; l != nil {
+		// online-only events show meeting link as location, so calendar apps make it clickable
+		if place := l.Place(); place != "" {
+			iw.line("LOCATION:" + icsText(place))
+		} else if l.MeetingURL != "" {
+			iw.line("LOCATION:" + icsText(l.MeetingURL))
+		}
+		if l.Lat != nil && l.Lon != nil {
+			iw.line("GEO:" + strconv.FormatFloat(*l.Lat, 'f', -1, 64) + ";" + strconv.FormatFloat(*l.Lon, 'f', -1, 64))
+		}
+		if l.MeetingURL != "" {
+			iw.line("URL:" + icsStripControl(l.MeetingURL))
+			description += "\n\nJoin online: " + l.MeetingURL
+		}
+	}
+	iw.line("DESCRIPTION:" + icsText(description))
+	iw.line("CATEGORIES:" + icsText(e.SquadId))
+	if e.Status == assist_db.Applied {
+		iw.line("STATUS:TENTATIVE")
+	} else {
+		iw.line("STATUS:CONFIRMED")
+	}
+	if attendee != nil && attendee.Email != "" {
+		iw.line("ATTENDEE;CN=" + icsParam(attendee.DisplayName) + ";PARTSTAT=" + participantPartStat(e.Status) + ":mailto:" + icsStripControl(attendee.Email))
+	}
+	iw.line("END:VEVENT")
+}
+
+func writeCalendar(w http.ResponseWriter, name string, body func(iw *icsWriter)) error {
+	iw := &icsWriter{}
+	iw.line("BEGIN:VCALENDAR")
+	iw.line("VERSION:2.0")
+	iw.line("PRODID:-//Assist//Events//EN")
+	iw.line("CALSCALE:GREGORIAN")
+	iw.line("X-WR-CALNAME:" + icsText(name))
+	body(iw)
+	iw.line("END:VCALENDAR")
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write(iw.Bytes())
+	return err
+}
+
+// methodGetUserCalendar returns events user participates in or owns
+func (app *App) methodGetUserCalendar(w http.ResponseWriter, r *http.Request) error {
+	params := mux.Vars(r)
+	ctx := r.Context()
+
+	userId := params["userId"]
+	if !app.checkCalendarToken(w, r, "users", userId) {
+		return fmt.Errorf("Invalid calendar token for user %v", userId)
+	}
+
+	userInfo, err := app.db.GetUserInfo(ctx, userId)
+	if err != nil {
+		err = fmt.Errorf("Failed to get user %v: %w", userId, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	squads, err := app.db.GetUserSquads(ctx, userId, "")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	events := make([]*assist_db.EventCountersRecord, 0)
+	if len(squads) > 0 {
+		events, err = app.db.GetEvents(ctx, squads, userId)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+	}
+
+	now := time.Now()
+	return writeCalendar(w, "Assist: "+userInfo.DisplayName, func(iw *icsWriter) {
+		for _, e := range events {
+			if e.Status != assist_db.NotGoing || e.OwnerId == userId {
+				iw.event(e.ID, &e.EventInfo, r.Host, now, userInfo)
+			}
+		}
+	})
+}
+
+// methodGetSquadCalendar returns all current events of the squad, token is
+// issued to the member and stops working once the user leaves the squad
+func (app *App) methodGetSquadCalendar(w http.ResponseWriter, r *http.Request) error {
+	params := mux.Vars(r)
+	ctx := r.Context()
+
+	squadId := params["squadId"]
+	userId := params["userId"]
+	if !app.checkCalendarToken(w, r, "squads", squadId, userId) {
+		return fmt.Errorf("Invalid calendar token for squad %v", squadId)
+	}
+
+	status, err := app.db.GetSquadMemberStatus(ctx, userId, squadId)
+	if err != nil || status == assist_db.PendingApprove {
+		err = fmt.Errorf("User %v is not a member of squad %v", userId, squadId)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	events, err := app.db.GetEvents(ctx, []string{squadId}, "")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	now := time.Now()
+	return writeCalendar(w, "Assist: "+squadId, func(iw *icsWriter) {
+		for _, e := range events {
+			e.Status = assist_db.NotGoing
+			iw.event(e.ID, &e.EventInfo, r.Host, now, nil)
+		}
+	})
+}
+
+// methodGetCalendarFeeds returns paths of the feeds available to the user
+func (app *App) methodGetCalendarFeeds(w http.ResponseWriter, r *http.Request) error {
+	params := mux.Vars(r)
+	ctx := r.Context()
+
+	userId, authLevel := app.checkAuthorization(r, params["userId"], "", myself)
+	if authLevel == 0 {
+		err := fmt.Errorf("Current user is not authorized to get calendar feeds of user " + userId)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	squads, err := app.db.GetUserSquadsMap(ctx, userId, "", false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	// squad feed is available to members only, not to the ones pending approve
+	feeds := struct {
+		User   string            `json:"user"`
+		Squads map[string]string `json:"squads"`
+	}{app.calendarFeedPath("users", userId), make(map[string]string, len(squads))}
+	for squadId, s := range squads {
+		if squadId != assist_db.ALL_USERS_SQUAD && s.Status != assist_db.PendingApprove {
+			feeds.Squads[squadId] = app.calendarFeedPath("squads", squadId, userId)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(feeds)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	return nil
+}
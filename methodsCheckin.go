@@ -0,0 +1,223 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	assist_db "assist/db"
+
+	"github.com/gorilla/mux"
+)
+
+// check-in token is shown to the participant as QR code and scanned by squad
+// admin at the door, it contains participant id and is signed for the event
+func (app *App) checkinToken(eventId string, userId string) string {
+	h := hmac.New(sha256.New, app.calendarSecret)
+	h.Write([]byte("checkin/" + eventId + "/" + userId))
+	return base64.RawURLEncoding.EncodeToString([]byte(userId)) + "." + base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// returns id of the participant if the token is valid for the event
+func (app *App) parseCheckinToken(eventId string, token string) (string, bool) {
+	i := strings.Index(token, ".")
+	if i < 0 {
+		return "", false
+	}
+
+	userId, err := base64.RawURLEncoding.DecodeString(token[:i])
+	if err != nil {
+		return "", false
+	}
+
+	if !hmac.Equal([]byte(token), []byte(app.checkinToken(eventId, string(userId)))) {
+		return "", false
+	}
+
+	return string(userId), true
+}
+
+func (app *App) methodGetCheckinToken(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	eventId := mux.Vars(r)["eventId"]
+
+	userId, authLevel := app.checkAuthorization(r, "me", "", myself)
+	if authLevel == 0 {
+		err := fmt.Errorf("Current user is not authorized to get check-in token")
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	status, err := app.db.GetParticipantStatus(ctx, userId, eventId)
+	if err != nil {
+		err = fmt.Errorf("Failed to get user %v status in event %v: %w", userId, eventId, err)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return err
+	}
+	if status != assist_db.Going && status != assist_db.Attended {
+		err = fmt.Errorf("Only participants going to the event get check-in token, user %v is %v", userId, status)
+		http.Error(w, err.Error(), http.StatusConflict)
+		return err
+	}
+
+	token := app.checkinToken(eventId, userId)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(struct {
+		Token  string                          `json:"token"`
+		Path   string                          `json:"path"`
+		Status assist_db.ParticipantStatusType `json:"status"`
+	}{token, "/events/" + url.PathEscape(eventId) + "/participants?checkin=" + token, status})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	return nil
+}
+
+func (app *App) methodCheckinParticipant(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	eventId := mux.Vars(r)["eventId"]
+
+	var data struct {
+		Token string `json:"token"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	eventInfo, err := app.db.GetEvent(ctx, eventId)
+	if err != nil {
+		err = fmt.Errorf("Failed to get event %v: %w", eventId, err)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	_, authLevel := app.checkAuthorization(r, "me", eventInfo.SquadId, squadAdmin|squadOwner)
+	if authLevel == 0 {
+		err = fmt.Errorf("Current user is not authorized to check in participants of event %v", eventId)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	if eventInfo.Archived {
+		err = fmt.Errorf("Event %v is archived", eventId)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	userId, ok := app.parseCheckinToken(eventId, strings.TrimSpace(data.Token))
+	if !ok {
+		err = fmt.Errorf("Check-in token is not valid for event %v", eventId)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	status, err := app.db.GetParticipantStatus(ctx, userId, eventId)
+	if err != nil {
+		err = fmt.Errorf("Failed to get user %v status in event %v: %w", userId, eventId, err)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return err
+	}
+
+	alreadyCheckedIn := status == assist_db.Attended
+	if !alreadyCheckedIn {
+		if status != assist_db.Going {
+			err = fmt.Errorf("User %v is %v, only participants going to the event could check in", userId, status)
+			http.Error(w, err.Error(), http.StatusConflict)
+			return err
+		}
+
+		_, _, err = app.db.SetParticipantStatus(ctx, userId, eventId, assist_db.Attended)
+		if err != nil {
+			err = fmt.Errorf("Failed to check in user %v: %w", userId, err)
+			log.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+	}
+
+	displayName := ""
+	if userInfo, err := app.db.GetUserInfo(ctx, userId); err == nil {
+		displayName = userInfo.DisplayName
+	} else {
+		log.Printf("Failed to get user %v info: %v", userId, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(struct {
+		UserId           string                          `json:"userId"`
+		DisplayName      string                          `json:"displayName"`
+		Status           assist_db.ParticipantStatusType `json:"status"`
+		AlreadyCheckedIn bool                            `json:"alreadyCheckedIn"`
+	}{userId, displayName, assist_db.Attended, alreadyCheckedIn})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	return nil
+}
+
+// methodMarkNoShows sets NoShow status to everyone who is still Going once the event has taken place
+func (app *App) methodMarkNoShows(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	eventId := mux.Vars(r)["eventId"]
+
+	eventInfo, err := app.db.GetEvent(ctx, eventId)
+	if err != nil {
+		err = fmt.Errorf("Failed to get event %v: %w", eventId, err)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	_, authLevel := app.checkAuthorization(r, "me", eventInfo.SquadId, squadAdmin|squadOwner)
+	if authLevel == 0 {
+		err = fmt.Errorf("Current user is not authorized to mark no-shows of event %v", eventId)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	if dayOf(time.Now()).Before(dayOf(*eventInfo.Date)) {
+		err = fmt.Errorf("Event %v has not taken place yet", eventId)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	marked, err := app.db.MarkNoShows(ctx, eventId)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(struct {
+		Marked int `json:"marked"`
+	}{marked})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	return nil
+}
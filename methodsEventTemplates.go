@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	assist_db "assist/db"
+
+	"github.com/gorilla/mux"
+)
+
+// normalizeEventTemplate trims template fields and validates them
+func normalizeEventTemplate(t *assist_db.EventTemplate) error {
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" {
+		return fmt.Errorf("Event template should have name")
+	}
+	if t.Capacity < 0 {
+		return fmt.Errorf("Event capacity should not be negative")
+	}
+	for _, s := range []string{t.TimeFrom, t.TimeTo} {
+		if _, err := time.Parse("15:04", s); s != "" && err != nil {
+			return fmt.Errorf("Event time should be in HH:MM format, got %v", s)
+		}
+	}
+	if err := validateReminders(t.Reminders); err != nil {
+		return err
+	}
+
+	location, err := normalizeLocation(t.Location)
+	if err != nil {
+		return err
+	}
+	t.Location = location
+
+	tags := make([]string, 0, len(t.Tags))
+	seen := make(map[string]bool)
+	for _, tag := range t.Tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	t.Tags = tags
+
+	return nil
+}
+
+// applyEventTemplate fills event details which are not provided in the request with template defaults
+func applyEventTemplate(event *assist_db.EventInfo, t *assist_db.EventTemplate, useLocation bool) {
+	if event.Text == "" {
+		event.Text = t.Text
+	}
+	if event.TimeFrom == "" {
+		event.TimeFrom = t.TimeFrom
+	}
+	if event.TimeTo == "" {
+		event.TimeTo = t.TimeTo
+	}
+	if event.Capacity == 0 {
+		event.Capacity = t.Capacity
+	}
+	if event.Reminders == nil && t.Reminders != nil {
+		event.Reminders = append([]int{}, t.Reminders...)
+	}
+	if useLocation && event.Location.IsEmpty() {
+		event.Location = t.Location.Copy()
+	}
+}
+
+func (app *App) methodCreateEventTemplate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	squadId := mux.Vars(r)["squadId"]
+
+	_, authLevel := app.checkAuthorization(r, "", squadId, squadAdmin|squadOwner)
+	if authLevel == 0 {
+		err := fmt.Errorf("Current user is not authorized to add event template to squad " + squadId)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	var template assist_db.EventTemplate
+	err := json.NewDecoder(r.Body).Decode(&template)
+	if err != nil {
+		err = fmt.Errorf("Failed to decode event template from the HTTP request: %w", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	if err = normalizeEventTemplate(&template); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	id, err := app.db.CreateEventTemplate(ctx, squadId, &template)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(struct {
+		ID string `json:"id"`
+	}{id})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	return nil
+}
+
+func (app *App) methodGetEventTemplates(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	squadId := mux.Vars(r)["squadId"]
+
+	_, authLevel := app.checkAuthorization(r, "", squadId, squadAdmin|squadOwner|squadMember)
+	if authLevel == 0 {
+		err := fmt.Errorf("Current user is not authorized to get squad " + squadId + " event templates")
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	templates, err := app.db.GetEventTemplates(ctx, squadId)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(templates)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	return nil
+}
+
+func (app *App) methodGetEventTemplate(w http.ResponseWriter, r *http.Request) error {
+	params := mux.Vars(r)
+	ctx := r.Context()
+
+	squadId := params["squadId"]
+	templateId := params["templateId"]
+
+	_, authLevel := app.checkAuthorization(r, "", squadId, squadAdmin|squadOwner|squadMember)
+	if authLevel == 0 {
+		err := fmt.Errorf("Current user is not authorized to get squad " + squadId + " event templates")
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	template, err := app.db.GetEventTemplate(ctx, squadId, templateId)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(assist_db.EventTemplateRecord{ID: templateId, EventTemplate: *template})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	return nil
+}
+
+func (app *App) methodUpdateEventTemplate(w http.ResponseWriter, r *http.Request) error {
+	params := mux.Vars(r)
+	ctx := r.Context()
+
+	squadId := params["squadId"]
+	templateId := params["templateId"]
+
+	_, authLevel := app.checkAuthorization(r, "", squadId, squadAdmin|squadOwner)
+	if authLevel == 0 {
+		err := fmt.Errorf("Current user is not authorized to update event templates of squad " + squadId)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	var template assist_db.EventTemplate
+	err := json.NewDecoder(r.Body).Decode(&template)
+	if err != nil {
+		err = fmt.Errorf("Failed to decode event template from the HTTP request: %w", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	if err = normalizeEventTemplate(&template); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	// events created from the template are not changed
+	err = app.db.UpdateEventTemplate(ctx, squadId, templateId, &template)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+func (app *App) methodDeleteEventTemplate(w http.ResponseWriter, r *http.Request) error {
+	params := mux.Vars(r)
+	ctx := r.Context()
+
+	squadId := params["squadId"]
+	templateId := params["templateId"]
+
+	_, authLevel := app.checkAuthorization(r, "", squadId, squadAdmin|squadOwner)
+	if authLevel == 0 {
+		err := fmt.Errorf("Current user is not authorized to delete event templates of squad " + squadId)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	err := app.db.DeleteEventTemplate(ctx, squadId, templateId)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	return nil
+}
@@ -25,17 +25,51 @@ func (app *App) checkAuthorizationForEvent(r *http.Request, userId string, event
 	return app.checkAuthorization(r, userId, eventInfo.SquadId, requiredLevel)
 }
 
+// promoteWaitlisted gives free places of the event to waitlisted participants and notifies them
+func (app *App) promoteWaitlisted(ctx context.Context, eventId string) {
+	promoted, err := app.db.PromoteWaitlisted(ctx, eventId)
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+	if len(promoted) == 0 {
+		return
+	}
+
+	eventInfo, err := app.db.GetEvent(ctx, eventId)
+	if err != nil {
+		log.Println("Failed to get event " + eventId + ", will not be able to notify promoted participants")
+		return
+	}
+	go func() {
+		app.ntfs.createNotification(assist_db.KindParticipation, eventInfo.SquadId, promoted, "Place Available", "You are moved from the waitlist to participants of event '"+eventInfo.Text+"'", eventLink(eventId))
+	}()
+}
+
 func (app *App) methodCreateEvent(w http.ResponseWriter, r *http.Request) error {
 
 	ctx := r.Context()
 
-	var event assist_db.EventInfo
-	err := json.NewDecoder(r.Body).Decode(&event)
+	// event with recurrence rule starts a series
+	var data struct {
+		assist_db.EventInfo
+		RRule   string   `json:"rrule"`
+		ExDates []string `json:"exDates"`
+		// saved venue of the squad used as event location
+		VenueId string `json:"venueId"`
+		// event template providing details not set in the request
+		TemplateId string `json:"templateId"`
+		// members having the tag are registered with the status, going by default
+		InviteTag    string                           `json:"inviteTag"`
+		InviteStatus *assist_db.ParticipantStatusType `json:"inviteStatus"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&data)
 	if err != nil {
 		err = fmt.Errorf("Failed to decode event data from the HTTP request: %w", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return err
 	}
+	event := data.EventInfo
 
 	userId, authLevel := app.checkAuthorization(r, "me", event.SquadId, squadAdmin|squadOwner)
 	if authLevel == 0 {
@@ -45,29 +79,138 @@ func (app *App) methodCreateEvent(w http.ResponseWriter, r *http.Request) error
 		return err
 	}
 
-	event.OwnerId = userId
+	// members having template tags are registered to the new event
+	var templateTags []string
+	if data.TemplateId != "" {
+		template, err := app.db.GetEventTemplate(ctx, event.SquadId, data.TemplateId)
+		if err != nil {
+			err = fmt.Errorf("Failed to get event template %v: %w", data.TemplateId, err)
+			log.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return err
+		}
+		applyEventTemplate(&event, template, data.VenueId == "")
+		templateTags = template.Tags
+	}
+
+	inviteStatus := assist_db.Going
+	if data.InviteStatus != nil {
+		inviteStatus = *data.InviteStatus
+	}
+	data.InviteTag = strings.TrimSpace(data.InviteTag)
+	if data.InviteTag != "" && !validInviteStatus(inviteStatus) {
+		err = fmt.Errorf("Members could be invited as going or applied, got %v", inviteStatus)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	if event.Capacity < 0 {
+		err = fmt.Errorf("Event capacity should not be negative")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	if event.Reminders == nil {
+		event.Reminders = append([]int{}, defaultReminders...)
+	}
+	if err = validateReminders(event.Reminders); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
 
-	id, err := app.db.CreateEvent(ctx, &event)
+	event.Location, err = app.eventLocation(ctx, event.SquadId, data.VenueId, event.Location)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return err
 	}
 
+	event.OwnerId = userId
+	event.SeriesId = ""
+	event.SeriesOverride = false
+
+	var id, seriesId string
+	text := "New event '" + event.Text + "' created" + locationSuffix(event.Location)
+	if data.RRule != "" {
+		if event.Text == "" || event.Date == nil || event.SquadId == "" {
+			err = fmt.Errorf("Failed to create event series, not enough details provided: %+v", event)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return err
+		}
+
+		var ids []string
+		seriesId, ids, err = app.createEventSeries(w, r, &event, data.RRule, data.ExDates)
+		if err != nil {
+			return err
+		}
+		if len(ids) > 0 {
+			id = ids[0]
+		}
+		text = "New recurring event '" + event.Text + "' created" + locationSuffix(event.Location)
+	} else {
+		id, err = app.db.CreateEvent(ctx, &event)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+	}
+
+	failures := make([]*participantFailure, 0)
+	if len(templateTags) > 0 || data.InviteTag != "" {
+		// members of the series are registered for the first occurrence and the following ones
+		first := &event
+		if seriesId != "" {
+			event.SeriesId = seriesId
+			if id != "" {
+				if first, err = app.db.GetEvent(ctx, id); err != nil {
+					log.Println(err.Error())
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return err
+				}
+			}
+		}
+
+		invite := func(tags []string, status assist_db.ParticipantStatusType) error {
+			userIds, err := app.getTagCandidates(ctx, event.SquadId, id, tags)
+			if err != nil {
+				return err
+			}
+			_, f, err := app.inviteParticipants(ctx, id, first, userIds, status, true)
+			failures = append(failures, f...)
+			return err
+		}
+		err = invite(templateTags, assist_db.Going)
+		if err == nil && data.InviteTag != "" {
+			err = invite([]string{data.InviteTag}, inviteStatus)
+		}
+		if err != nil {
+			log.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+	}
+
 	// notify squad members about new event
+	link := eventLink(id)
+	if id == "" {
+		link = squadLink(event.SquadId)
+	}
 	go func() {
 		memberIds, err := app.db.GetSquadMemberIds(context.Background(), event.SquadId, []int{int(assist_db.Owner), int(assist_db.Admin), int(assist_db.Member)}, userId)
 		if err != nil {
 			log.Println("Failed to get list of squad " + event.SquadId + " members, will not be able to create notifications")
 		}
-		app.ntfs.createNotification(memberIds, "New Event", "New event '"+event.Text+"' created")
+		app.ntfs.createNotification(assist_db.KindNewEvent, event.SquadId, memberIds, "New Event", text, link)
+		app.hooks.fire(event.SquadId, assist_db.HookEventCreated, &eventHookData{id, seriesId, event.Text, event.Date})
 	}()
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
 	err = json.NewEncoder(w).Encode(struct {
-		ID string `json:"id"`
-	}{id})
+		ID       string                `json:"id"`
+		SeriesId string                `json:"seriesId,omitempty"`
+		Failures []*participantFailure `json:"failures,omitempty"`
+	}{id, seriesId, failures})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return err
@@ -283,6 +426,26 @@ func (app *App) methodRegisterParticipant(w http.ResponseWriter, r *http.Request
 		return err
 	}
 
+	// register for the following occurrences of the series as well
+	if r.URL.Query().Get("series") == "true" && eventInfo.SeriesId != "" {
+		err = app.setSeriesParticipants(ctx, eventInfo, userIds, status)
+		if err != nil {
+			err = fmt.Errorf("Failed to register participants for event series %v: %w", eventInfo.SeriesId, err)
+			log.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+	}
+
+	// event could have no free places for the participant
+	if len(userIds) == 1 {
+		if st, err := app.db.GetParticipantStatus(ctx, userIds[0], eventId); err == nil {
+			status = st
+		}
+	}
+
+	go app.hooks.fire(eventInfo.SquadId, assist_db.HookParticipantRegistered, &participantsHookData{eventId, userIds, status.String()})
+
 	if status == assist_db.Applied {
 		// notify squad admins that there is new event participant pending approve
 		go func() {
@@ -291,7 +454,7 @@ func (app *App) methodRegisterParticipant(w http.ResponseWriter, r *http.Request
 				log.Println("Failed to get list of squad " + eventInfo.SquadId + " admins, will not be able to create notifications")
 			}
 			sd := app.sd.getCurrentUserData(r)
-			app.ntfs.createNotification(memberIds, "New Participant", sd.DisplayName+" applied for event '"+eventInfo.Text+"'")
+			app.ntfs.createNotification(assist_db.KindParticipation, eventInfo.SquadId, memberIds, "New Participant", sd.DisplayName+" applied for event '"+eventInfo.Text+"'", eventLink(eventId))
 		}()
 	}
 
@@ -335,15 +498,28 @@ func (app *App) methodUpdateParticipant(w http.ResponseWriter, r *http.Request)
 		return err
 	}
 
-	err = app.db.SetParticipantStatus(ctx, userId, eventId, *data.Status)
+	// participant is waitlisted if event is full
+	oldStatus, status, err := app.db.SetParticipantStatus(ctx, userId, eventId, *data.Status)
 
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return err
 	}
 
+	// place is freed when participant stops taking it, e.g. is marked no-show
+	if oldStatus.TakesPlace() && !status.TakesPlace() {
+		app.promoteWaitlisted(ctx, eventId)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(struct {
+		Status assist_db.ParticipantStatusType `json:"status"`
+	}{status})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
 
 	return nil
 }
@@ -369,6 +545,21 @@ func (app *App) methodRemoveParticipant(w http.ResponseWriter, r *http.Request)
 	err := app.db.DeleteParticipant(ctx, userId, eventId)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
+	} else {
+		app.promoteWaitlisted(ctx, eventId)
+	}
+
+	if r.URL.Query().Get("series") == "true" {
+		eventInfo, err := app.db.GetEvent(ctx, eventId)
+		if err == nil && eventInfo.SeriesId != "" {
+			err = app.removeSeriesParticipant(ctx, eventInfo, userId)
+		}
+		if err != nil {
+			err = fmt.Errorf("Failed to remove participant from event series: %w", err)
+			log.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -377,21 +568,192 @@ func (app *App) methodRemoveParticipant(w http.ResponseWriter, r *http.Request)
 	return nil
 }
 
-func (app *App) methodDeleteEvent(w http.ResponseWriter, r *http.Request) error {
+// eventChange is a field of the event changed by update, as shown to participants
+type eventChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
 
+func eventTimeSpan(e *assist_db.EventInfo) string {
+	switch {
+	case e.TimeFrom == "" && e.TimeTo == "":
+		return "not set"
+	case e.TimeTo == "":
+		return e.TimeFrom
+	default:
+		return e.TimeFrom + " - " + e.TimeTo
+	}
+}
+
+// eventChanges compares event before and after update, event date is compared by day
+func eventChanges(old *assist_db.EventInfo, event *assist_db.EventInfo) []eventChange {
+	changes := make([]eventChange, 0)
+	if dayOf(*old.Date) != dayOf(*event.Date) {
+		changes = append(changes, eventChange{"date", old.Date.UTC().Format("Mon, Jan 2"), event.Date.UTC().Format("Mon, Jan 2")})
+	}
+	if old.TimeFrom != event.TimeFrom || old.TimeTo != event.TimeTo {
+		changes = append(changes, eventChange{"time", eventTimeSpan(old), eventTimeSpan(event)})
+	}
+	if !old.Location.Equal(event.Location) {
+		changes = append(changes, eventChange{"location", old.Location.String(), event.Location.String()})
+	}
+	if old.Text != event.Text {
+		changes = append(changes, eventChange{"text", old.Text, event.Text})
+	}
+	return changes
+}
+
+// methodUpdateEvent changes date, time, location or text of the event, fields
+// missing in the request are kept; participants who are going or applied get
+// notification listing the changes
+func (app *App) methodUpdateEvent(w http.ResponseWriter, r *http.Request) error {
 	params := mux.Vars(r)
 	ctx := r.Context()
 
 	eventId := params["eventId"]
 
-	// authorization check
-	sd := app.sd.getCurrentUserData(r)
-	if sd.Status != db.Admin {
-		eventInfo, err := app.db.GetEvent(r.Context(), eventId)
+	eventInfo, err := app.db.GetEvent(ctx, eventId)
+	if err != nil {
+		err = fmt.Errorf("Failed to get event %v: %w", eventId, err)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return err
+	}
+
+	_, authLevel := app.checkAuthorization(r, "me", eventInfo.SquadId, squadAdmin|squadOwner)
+	if authLevel == 0 {
+		err := fmt.Errorf("Current user is not authorized to update event " + eventId)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	if eventInfo.Archived {
+		err = fmt.Errorf("Event %v is archived and could not be changed", eventId)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	var data struct {
+		Date     *time.Time `json:"date"`
+		TimeFrom *string    `json:"timeFrom"`
+		TimeTo   *string    `json:"timeTo"`
+		Text     *string    `json:"text"`
+		// empty location removes it
+		Location *assist_db.EventLocation `json:"location"`
+		VenueId  string                   `json:"venueId"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		err = fmt.Errorf("Failed to decode event data from the HTTP request: %w", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	event := *eventInfo
+	date := *eventInfo.Date
+	event.Date = &date
+	if data.Date != nil {
+		event.Date = data.Date
+	}
+	if data.TimeFrom != nil {
+		event.TimeFrom = *data.TimeFrom
+	}
+	if data.TimeTo != nil {
+		event.TimeTo = *data.TimeTo
+	}
+	if data.Text != nil {
+		event.Text = strings.TrimSpace(*data.Text)
+	}
+	if data.Location != nil || data.VenueId != "" {
+		event.Location, err = app.eventLocation(ctx, event.SquadId, data.VenueId, data.Location)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return err
 		}
+	}
+
+	if event.Text == "" {
+		err = fmt.Errorf("Event should have text")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+	for _, t := range []string{event.TimeFrom, event.TimeTo} {
+		if _, err := time.Parse("15:04", t); t != "" && err != nil {
+			err = fmt.Errorf("Event time should be in HH:MM format, got %v", t)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return err
+		}
+	}
+
+	changes := eventChanges(eventInfo, &event)
+	if len(changes) > 0 {
+		// occurrence edited separately is not changed with its series any more
+		event.SeriesOverride = event.SeriesId != ""
+		if err = app.db.UpdateEvent(ctx, eventId, &event); err != nil {
+			err = fmt.Errorf("Failed to update event %v: %w", eventId, err)
+			log.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		// occurrence moved to another day should not be generated again
+		if event.SeriesId != "" && dayOf(*event.Date) != dayOf(*eventInfo.Date) {
+			if err = app.excludeSeriesEvent(ctx, eventInfo); err != nil {
+				err = fmt.Errorf("Failed to exclude event %v from series: %w", eventId, err)
+				log.Println(err.Error())
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return err
+			}
+		}
+
+		text := make([]string, 0, len(changes))
+		for _, c := range changes {
+			text = append(text, c.Field+": "+c.Old+" -> "+c.New)
+		}
+		go func() {
+			for _, status := range []assist_db.ParticipantStatusType{assist_db.Going, assist_db.Applied} {
+				ids, err := app.db.GetParticipantIds(context.Background(), eventId, status)
+				if err != nil {
+					log.Printf("Failed to get event %v participants, will not be able to notify them: %v", eventId, err)
+					continue
+				}
+				app.ntfs.createNotification(assist_db.KindEventChange, eventInfo.SquadId, ids, "Event Changed", "Event '"+eventInfo.Text+"' has changed, "+strings.Join(text, "; "), eventLink(eventId))
+			}
+		}()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(struct {
+		assist_db.EventInfo
+		Changes []eventChange `json:"changes"`
+	}{event, changes})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	return nil
+}
+
+func (app *App) methodDeleteEvent(w http.ResponseWriter, r *http.Request) error {
+
+	params := mux.Vars(r)
+	ctx := r.Context()
+
+	eventId := params["eventId"]
+
+	eventInfo, err := app.db.GetEvent(r.Context(), eventId)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	// authorization check
+	sd := app.sd.getCurrentUserData(r)
+	if sd.Status != db.Admin {
 		userId := app.sd.getCurrentUserID(r)
 
 		if eventInfo.OwnerId != userId {
@@ -403,7 +765,18 @@ func (app *App) methodDeleteEvent(w http.ResponseWriter, r *http.Request) error
 	}
 	gorilla_context.Set(r, "AuthChecked", true)
 
-	err := app.db.DeleteEvent(ctx, eventId)
+	// deleted occurrence should not be generated again
+	if eventInfo.SeriesId != "" {
+		err = app.excludeSeriesEvent(ctx, eventInfo)
+		if err != nil {
+			err = fmt.Errorf("Failed to exclude event %v from series: %w", eventId, err)
+			log.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+	}
+
+	err = app.db.DeleteEvent(ctx, eventId)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return err
@@ -462,18 +835,3 @@ func (app *App) methodGetCandidates(w http.ResponseWriter, r *http.Request) (err
 
 	return err
 }
-
-func (app *App) methodArchiveEvents(w http.ResponseWriter, r *http.Request) (err error) {
-	err = app.db.ArchiveOldEvents(r.Context())
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return err
-	}
-
-	gorilla_context.Set(r, "AuthChecked", true)
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-
-	return nil
-}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	assist_db "assist/db"
+
+	"github.com/gorilla/mux"
+)
+
+// participantFailure is reported for every member who failed to be registered in bulk
+type participantFailure struct {
+	UserId string `json:"userId"`
+	Error  string `json:"error"`
+}
+
+// statuses squad admins could register members with in bulk
+func validInviteStatus(status assist_db.ParticipantStatusType) bool {
+	return status == assist_db.Going || status == assist_db.Applied
+}
+
+// getTagCandidates returns ids of squad members (pending approval excluded) having
+// any of the tags and not registered for the event yet
+func (app *App) getTagCandidates(ctx context.Context, squadId string, eventId string, tags []string) ([]string, error) {
+	if eventId == "" {
+		// series having no occurrences yet, nobody is registered
+		return app.getTagMembers(ctx, squadId, tags)
+	}
+
+	seen := make(map[string]bool)
+	userIds := make([]string, 0)
+	for _, tag := range tags {
+		filter := map[string]string{"Tag": tag}
+		from := ""
+		for {
+			candidates, err := app.db.GetCandidates(ctx, squadId, eventId, from, &filter)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to get squad %v members with tag %v: %w", squadId, tag, err)
+			}
+			if len(candidates) == 0 {
+				break
+			}
+			for _, c := range candidates {
+				if c.Status != assist_db.PendingApprove && !seen[c.ID] {
+					seen[c.ID] = true
+					userIds = append(userIds, c.ID)
+				}
+			}
+			from = candidates[len(candidates)-1].ID
+		}
+	}
+
+	return userIds, nil
+}
+
+// getTagMembers returns ids of squad members (pending approval excluded) having any of the tags
+func (app *App) getTagMembers(ctx context.Context, squadId string, tags []string) ([]string, error) {
+	memberIds, err := app.db.GetSquadMemberIds(ctx, squadId, []int{int(assist_db.Owner), int(assist_db.Admin), int(assist_db.Member)}, "")
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get squad %v members: %w", squadId, err)
+	}
+	members := make(map[string]bool, len(memberIds))
+	for _, id := range memberIds {
+		members[id] = true
+	}
+
+	userIds := make([]string, 0)
+	for _, tag := range tags {
+		ids, err := app.db.GetSquadMemberIdsByTag(ctx, squadId, tag)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get squad %v members with tag %v: %w", squadId, tag, err)
+		}
+		for _, id := range ids {
+			if members[id] {
+				// every member is returned once
+				members[id] = false
+				userIds = append(userIds, id)
+			}
+		}
+	}
+
+	return userIds, nil
+}
+
+// inviteParticipants registers users for the event with the given status, and
+// for the following occurrences if series is set; members failed to be
+// registered are returned instead of failing the whole list
+func (app *App) inviteParticipants(ctx context.Context, eventId string, event *assist_db.EventInfo, userIds []string, status assist_db.ParticipantStatusType, series bool) (registered []string, failures []*participantFailure, err error) {
+	failures = make([]*participantFailure, 0)
+	registered = userIds
+	if len(userIds) == 0 {
+		return registered, failures, nil
+	}
+
+	if eventId != "" {
+		err = app.db.RegisterParticipants(ctx, userIds, eventId, event, status)
+		var regErrs assist_db.RegistrationErrors
+		if errors.As(err, &regErrs) {
+			failed := make(map[string]bool, len(regErrs))
+			for _, pe := range regErrs {
+				failed[pe.UserId] = true
+				failures = append(failures, &participantFailure{pe.UserId, pe.Err.Error()})
+			}
+			registered = make([]string, 0, len(userIds))
+			for _, userId := range userIds {
+				if !failed[userId] {
+					registered = append(registered, userId)
+				}
+			}
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("Failed to register participants for event %v: %w", eventId, err)
+		}
+	}
+
+	if series && event.SeriesId != "" && len(registered) > 0 {
+		err = app.setSeriesParticipants(ctx, event, registered, status)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to register participants for event series %v: %w", event.SeriesId, err)
+		}
+	}
+
+	return registered, failures, nil
+}
+
+// methodInviteParticipants registers all squad members having the tag, who
+// are not registered yet, for the event
+func (app *App) methodInviteParticipants(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	eventId := mux.Vars(r)["eventId"]
+
+	var data struct {
+		Tag    string                          `json:"tag"`
+		Status assist_db.ParticipantStatusType `json:"status"`
+		// register for the following occurrences of the series as well
+		Series bool `json:"series"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		err = fmt.Errorf("Failed to decode invitation from the HTTP request: %w", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	eventInfo, err := app.db.GetEvent(ctx, eventId)
+	if err != nil {
+		err = fmt.Errorf("Failed to get event %v: %w", eventId, err)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	_, authLevel := app.checkAuthorization(r, "me", eventInfo.SquadId, squadAdmin|squadOwner)
+	if authLevel == 0 {
+		err = fmt.Errorf("Current user is not authorized to invite participants to event %v", eventId)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	data.Tag = strings.TrimSpace(data.Tag)
+	if data.Tag == "" {
+		err = fmt.Errorf("Tag of members to invite is not provided")
+	} else if !validInviteStatus(data.Status) {
+		err = fmt.Errorf("Members could be invited as going or applied, got %v", data.Status)
+	} else if eventInfo.Archived {
+		err = fmt.Errorf("Event %v is archived", eventId)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	userIds, err := app.getTagCandidates(ctx, eventInfo.SquadId, eventId, []string{data.Tag})
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	registered, failures, err := app.inviteParticipants(ctx, eventId, eventInfo, userIds, data.Status, data.Series)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	// let invited members know
+	go func() {
+		app.ntfs.createNotification(assist_db.KindParticipation, eventInfo.SquadId, registered, "Event Invitation", "You are registered for event '"+eventInfo.Text+"' on "+eventInfo.Date.Format("Mon, Jan 2")+locationSuffix(eventInfo.Location), eventLink(eventId))
+		if len(registered) > 0 {
+			app.hooks.fire(eventInfo.SquadId, assist_db.HookParticipantRegistered, &participantsHookData{eventId, registered, data.Status.String()})
+		}
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(struct {
+		Registered []string              `json:"registered"`
+		Failures   []*participantFailure `json:"failures"`
+	}{registered, failures})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	return nil
+}
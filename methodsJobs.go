@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func (app *App) methodGetJobs(w http.ResponseWriter, r *http.Request) error {
+	_, authLevel := app.checkAuthorization(r, "", "", systemAdmin)
+	if authLevel == 0 {
+		err := fmt.Errorf("Current user is not authorized to get background jobs")
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	jobs, err := app.jobs.getJobs(r.Context())
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(jobs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	return nil
+}
+
+// methodRunJob runs the job right away and responds once it finishes
+func (app *App) methodRunJob(w http.ResponseWriter, r *http.Request) error {
+	name := mux.Vars(r)["name"]
+
+	_, authLevel := app.checkAuthorization(r, "", "", systemAdmin)
+	if authLevel == 0 {
+		err := fmt.Errorf("Current user is not authorized to run background job %v", name)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	if app.jobs.findJob(name) == nil {
+		err := fmt.Errorf("Job %v not found", name)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return err
+	}
+
+	// job should not be cancelled and left locked if client disconnects
+	ran, err := app.jobs.trigger(context.Background(), name)
+	if err != nil {
+		err = fmt.Errorf("Failed to run job %v: %w", name, err)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+	if !ran {
+		err = fmt.Errorf("Job %v is already running", name)
+		http.Error(w, err.Error(), http.StatusConflict)
+		return err
+	}
+
+	jobs, err := app.jobs.getJobs(r.Context())
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	for _, j := range jobs {
+		if j.Name == name {
+			err = json.NewEncoder(w).Encode(j)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	assist_db "assist/db"
+
+	"github.com/gorilla/mux"
+)
+
+// attendanceStats counts participation outcomes, rate is share of attended
+// among participations marked as either attended or no-show
+type attendanceStats struct {
+	Registered int      `json:"registered"`
+	Attended   int      `json:"attended"`
+	NoShow     int      `json:"noShow"`
+	Rate       *float64 `json:"rate"`
+}
+
+func (s *attendanceStats) add(status assist_db.ParticipantStatusType) {
+	switch status {
+	case assist_db.Going:
+		s.Registered++
+	case assist_db.Attended:
+		s.Registered++
+		s.Attended++
+	case assist_db.NoShow:
+		s.Registered++
+		s.NoShow++
+	}
+}
+
+func (s *attendanceStats) computeRate() {
+	if marked := s.Attended + s.NoShow; marked > 0 {
+		rate := float64(s.Attended) / float64(marked)
+		s.Rate = &rate
+	}
+}
+
+func (s *attendanceStats) csvValues() []string {
+	rate := ""
+	if s.Rate != nil {
+		rate = strconv.FormatFloat(*s.Rate, 'f', 2, 64)
+	}
+	return []string{strconv.Itoa(s.Registered), strconv.Itoa(s.Attended), strconv.Itoa(s.NoShow), rate}
+}
+
+type memberAttendance struct {
+	UserId      string   `json:"userId"`
+	DisplayName string   `json:"displayName"`
+	Replicant   bool     `json:"replicant"`
+	Tags        []string `json:"tags"`
+	attendanceStats
+	LastNoShow *time.Time `json:"lastNoShow,omitempty"`
+}
+
+type tagAttendance struct {
+	Tag     string `json:"tag"`
+	Members int    `json:"members"`
+	attendanceStats
+}
+
+type monthAttendance struct {
+	Month  string `json:"month"`
+	Events int    `json:"events"`
+	attendanceStats
+}
+
+type AttendanceReport struct {
+	From           time.Time           `json:"from"`
+	To             time.Time           `json:"to"`
+	Events         int                 `json:"events"`
+	Members        []*memberAttendance `json:"members"`
+	Tags           []*tagAttendance    `json:"tags"`
+	Months         []*monthAttendance  `json:"months"`
+	ChronicNoShows []*memberAttendance `json:"chronicNoShows"`
+}
+
+// buildAttendanceReport aggregates attendance, members having at least
+// minNoShows no-shows and attendance rate not above maxRate are chronic no-shows
+func buildAttendanceReport(records []*assist_db.AttendanceRecord, minNoShows int, maxRate float64) *AttendanceReport {
+	report := &AttendanceReport{
+		Members:        make([]*memberAttendance, 0),
+		Tags:           make([]*tagAttendance, 0),
+		Months:         make([]*monthAttendance, 0),
+		ChronicNoShows: make([]*memberAttendance, 0),
+	}
+
+	members := make(map[string]*memberAttendance)
+	tags := make(map[string]*tagAttendance)
+	tagMembers := make(map[string]map[string]bool)
+	months := make(map[string]*monthAttendance)
+	monthEvents := make(map[string]map[string]bool)
+	events := make(map[string]bool)
+
+	for _, r := range records {
+		events[r.EventId] = true
+
+		m, ok := members[r.UserId]
+		if !ok {
+			m = &memberAttendance{UserId: r.UserId, DisplayName: r.DisplayName, Replicant: r.Replicant, Tags: r.Tags}
+			members[r.UserId] = m
+			report.Members = append(report.Members, m)
+		}
+		m.add(r.Status)
+		if r.Status == assist_db.NoShow {
+			date := r.Date
+			m.LastNoShow = &date
+		}
+
+		for _, tag := range r.Tags {
+			t, ok := tags[tag]
+			if !ok {
+				t = &tagAttendance{Tag: tag}
+				tags[tag] = t
+				tagMembers[tag] = make(map[string]bool)
+				report.Tags = append(report.Tags, t)
+			}
+			t.add(r.Status)
+			tagMembers[tag][r.UserId] = true
+		}
+
+		month := r.Date.Format("2006-01")
+		mo, ok := months[month]
+		if !ok {
+			mo = &monthAttendance{Month: month}
+			months[month] = mo
+			monthEvents[month] = make(map[string]bool)
+			report.Months = append(report.Months, mo)
+		}
+		mo.add(r.Status)
+		monthEvents[month][r.EventId] = true
+	}
+
+	report.Events = len(events)
+	for _, m := range report.Members {
+		m.computeRate()
+		if m.NoShow >= minNoShows && m.Rate != nil && *m.Rate <= maxRate {
+			report.ChronicNoShows = append(report.ChronicNoShows, m)
+		}
+	}
+	for _, t := range report.Tags {
+		t.computeRate()
+		t.Members = len(tagMembers[t.Tag])
+	}
+	for _, mo := range report.Months {
+		mo.computeRate()
+		mo.Events = len(monthEvents[mo.Month])
+	}
+
+	sort.Slice(report.Members, func(i, j int) bool { return report.Members[i].DisplayName < report.Members[j].DisplayName })
+	sort.Slice(report.Tags, func(i, j int) bool { return report.Tags[i].Tag < report.Tags[j].Tag })
+	sort.Slice(report.Months, func(i, j int) bool { return report.Months[i].Month < report.Months[j].Month })
+	sort.SliceStable(report.ChronicNoShows, func(i, j int) bool { return report.ChronicNoShows[i].NoShow > report.ChronicNoShows[j].NoShow })
+
+	return report
+}
+
+// tables of the attendance report which could be exported to CSV
+var attendanceTables = map[string]bool{"members": true, "chronicNoShows": true, "tags": true, "months": true}
+
+// prevents spreadsheet from interpreting user text as formula
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func writeAttendanceCSV(w *csv.Writer, report *AttendanceReport, table string) error {
+	var err error
+	write := func(record []string) {
+		if err == nil {
+			err = w.Write(record)
+		}
+	}
+
+	stats := []string{"registered", "attended", "noShow", "rate"}
+	writeMembers := func(members []*memberAttendance) {
+		write(append([]string{"userId", "displayName", "tags"}, append(stats, "lastNoShow")...))
+		for _, m := range members {
+			lastNoShow := ""
+			if m.LastNoShow != nil {
+				lastNoShow = m.LastNoShow.Format(assist_db.FieldDateLayout)
+			}
+			write(append([]string{m.UserId, csvText(m.DisplayName), csvText(strings.Join(m.Tags, " "))}, append(m.csvValues(), lastNoShow)...))
+		}
+	}
+
+	switch table {
+	case "members":
+		writeMembers(report.Members)
+	case "chronicNoShows":
+		writeMembers(report.ChronicNoShows)
+	case "tags":
+		write(append([]string{"tag", "members"}, stats...))
+		for _, t := range report.Tags {
+			write(append([]string{csvText(t.Tag), strconv.Itoa(t.Members)}, t.csvValues()...))
+		}
+	case "months":
+		write(append([]string{"month", "events"}, stats...))
+		for _, mo := range report.Months {
+			write(append([]string{mo.Month, strconv.Itoa(mo.Events)}, mo.csvValues()...))
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+func (app *App) methodGetAttendanceReport(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	squadId := mux.Vars(r)["squadId"]
+
+	_, authLevel := app.checkAuthorization(r, "", squadId, squadAdmin|squadOwner)
+	if authLevel == 0 {
+		err := fmt.Errorf("Current user is not authorized to get squad %v attendance", squadId)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	// period defaults to the year till today, dates are inclusive
+	v := r.URL.Query()
+	to := dayOf(time.Now())
+	var from time.Time
+	minNoShows := 3
+	maxRate := 0.5
+	var err error
+	if s := v.Get("to"); s != "" {
+		to, err = time.Parse(assist_db.FieldDateLayout, s)
+	}
+	if s := v.Get("from"); s != "" && err == nil {
+		from, err = time.Parse(assist_db.FieldDateLayout, s)
+	} else {
+		from = to.AddDate(-1, 0, 0)
+	}
+	if s := v.Get("minNoShows"); s != "" && err == nil {
+		minNoShows, err = strconv.Atoi(s)
+	}
+	if s := v.Get("maxRate"); s != "" && err == nil {
+		maxRate, err = strconv.ParseFloat(s, 64)
+	}
+	if err != nil {
+		err = fmt.Errorf("Failed to parse report parameters: %w", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+	if to.Before(from) {
+		err = fmt.Errorf("Report period should not end before it starts")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	records, err := app.db.GetSquadAttendance(ctx, squadId, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	report := buildAttendanceReport(records, minNoShows, maxRate)
+	report.From = from
+	report.To = to
+
+	if v.Get("format") == "csv" {
+		table := v.Get("table")
+		if table == "" {
+			table = "members"
+		}
+		if !attendanceTables[table] {
+			err = fmt.Errorf("Unknown attendance table %v", table)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return err
+		}
+
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="attendance-`+table+`.csv"`)
+		w.WriteHeader(http.StatusOK)
+		return writeAttendanceCSV(csv.NewWriter(w), report, table)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(report)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	return nil
+}
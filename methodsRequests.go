@@ -6,13 +6,15 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
-	"assist/db"
 	assist_db "assist/db"
 
 	gorilla_context "github.com/gorilla/context"
 	"github.com/gorilla/mux"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func (app *App) methodCreateQueue(w http.ResponseWriter, r *http.Request) error {
@@ -40,6 +42,26 @@ func (app *App) methodCreateQueue(w http.ResponseWriter, r *http.Request) error
 
 	qr.SquadId = squadId
 
+	if qr.Workflow != nil {
+		if err = qr.Workflow.Validate(); err != nil {
+			err = fmt.Errorf("Invalid queue workflow: %w", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return err
+		}
+	}
+
+	if err = qr.ValidateFields(); err != nil {
+		err = fmt.Errorf("Invalid queue fields: %w", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	if qr.SLA != nil && (qr.SLA.ApproveMinutes < 0 || qr.SLA.ProcessMinutes < 0) {
+		err = fmt.Errorf("Invalid queue SLA %+v", *qr.SLA)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
 	err = app.db.CreateRequestsQueue(ctx, qr.ID, &qr.QueueInfo)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -120,6 +142,40 @@ func (app *App) methodGetSquadQueues(w http.ResponseWriter, r *http.Request) err
 	return err
 }
 
+// returns queue details including fields requester should fill
+func (app *App) methodGetQueue(w http.ResponseWriter, r *http.Request) error {
+	params := mux.Vars(r)
+	ctx := r.Context()
+
+	queueId := params["queueId"]
+
+	queue, err := app.db.GetRequestQueue(ctx, queueId)
+	if err != nil {
+		err = fmt.Errorf("Failed to get queue %v details: %w", queueId, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	_, authLevel := app.checkAuthorization(r, "", queue.SquadId, squadMember|squadAdmin|squadOwner)
+
+	if authLevel == 0 {
+		err := fmt.Errorf("Current user is not authorized to get queue " + queueId)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(queue)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	return err
+}
+
 func (app *App) methodGetUserQueuesAndRequests(w http.ResponseWriter, r *http.Request) error {
 	params := mux.Vars(r)
 	ctx := r.Context()
@@ -159,6 +215,15 @@ func (app *App) methodGetUserQueuesAndRequests(w http.ResponseWriter, r *http.Re
 		return err
 	}
 
+	actor := app.newWorkflowActor(r)
+	for _, requests := range [][]assist_db.RequestRecord{userRequests, requestsToApprove, requestsToHandle} {
+		if err = app.fillRequestRecords(ctx, actor, requests); err != nil {
+			log.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	err = json.NewEncoder(w).Encode(struct {
@@ -206,13 +271,21 @@ func (app *App) methodCreateRequest(w http.ResponseWriter, r *http.Request) erro
 		return err
 	}
 
+	request.Fields, err = queue.ParseFields(request.Fields)
+	if err != nil {
+		err = fmt.Errorf("Invalid request fields: %w", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
 	request.UserId = userId
 	userData, err := app.db.GetUserData(ctx, userId)
 	request.UserName = userData.DisplayName
 
-	if queue.Approvers == "" {
-		request.Status = db.Processing
-	}
+	wf := queue.GetWorkflow()
+	state := wf.GetState(wf.Initial)
+	request.State = state.Name
+	request.Status = state.Status
 
 	requestId, err := app.db.CreateRequest(ctx, &request)
 	if err != nil {
@@ -220,31 +293,26 @@ func (app *App) methodCreateRequest(w http.ResponseWriter, r *http.Request) erro
 		return err
 	}
 
-	// notify squad members about new event
+	// notify those who should take care of the new request
 	go func() {
-		var memberIds []string
-		var notification string
-		var err error
-		if request.Status == db.Processing {
-			notification = "New request in queue " + request.QueueId + " waiting to be processed"
-			memberIds, err = app.db.GetSquadMemberIdsByTag(context.Background(), queue.SquadId, queue.Handlers)
-		} else {
-			notification = "New request in queue " + request.QueueId + " waiting to be approved"
-			memberIds, err = app.db.GetSquadMemberIdsByTag(context.Background(), queue.SquadId, queue.Approvers)
-		}
-
-		if err != nil {
-			log.Println("Failed to get list of squad " + queue.SquadId + " members, will not be able to create notifications")
-		}
-		app.ntfs.createNotification(memberIds, "Request "+request.QueueId, notification)
+		app.notifyRequestState(queue, requestId, &request, state, userId, "New request in queue "+request.QueueId+": "+state.Name)
+		app.hooks.fire(queue.SquadId, assist_db.HookRequestCreated, newRequestHookData(requestId, &request))
 	}()
 
+	// actions available to the requester right away
+	records := []assist_db.RequestRecord{{RequestId: requestId, RequestDetails: request}}
+	if err = app.fillRequestRecords(ctx, app.newWorkflowActor(r), records); err != nil {
+		log.Println(err.Error())
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	err = json.NewEncoder(w).Encode(struct {
-		RequestId string `json:"requestId"`
-		Status    int    `json:"status"`
-	}{requestId, int(request.Status)})
+		RequestId string                          `json:"requestId"`
+		Status    int                             `json:"status"`
+		State     string                          `json:"state"`
+		Actions   []*assist_db.WorkflowTransition `json:"actions"`
+	}{requestId, int(request.Status), request.State, records[0].Actions})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return err
@@ -258,9 +326,10 @@ func (app *App) methodGetRequests(w http.ResponseWriter, r *http.Request) (err e
 
 	v := r.URL.Query()
 	from := v.Get("from")
-	var timeFrom time.Time
+	var timeFrom *time.Time
 	if from != "" {
-		timeFrom, err = time.Parse(time.RFC3339, from)
+		t, err := time.Parse(time.RFC3339, from)
+		timeFrom = &t
 		if err != nil {
 			err = fmt.Errorf("Failed to convert from to a time struct: %w", err)
 			log.Println(err.Error())
@@ -279,7 +348,30 @@ func (app *App) methodGetRequests(w http.ResponseWriter, r *http.Request) (err e
 		return err
 	}
 
-	var requests interface{}
+	// requests might be filtered by queue and values of its fields (field.<name>=<value>)
+	var filter *assist_db.RequestsFilter
+	if queueId := v.Get("queue"); queueId != "" {
+		queue, err := app.db.GetRequestQueue(ctx, queueId)
+		if err != nil {
+			err = fmt.Errorf("Failed to get queue %v details: %w", queueId, err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return err
+		}
+
+		filter = &assist_db.RequestsFilter{QueueId: queueId, Fields: make(map[string]interface{})}
+		for key := range v {
+			if !strings.HasPrefix(key, "field.") {
+				continue
+			}
+			name := strings.TrimPrefix(key, "field.")
+			if filter.Fields[name], err = queue.ParseFilter(name, v.Get(key)); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return err
+			}
+		}
+	}
+
+	var requests []assist_db.RequestRecord
 	if status == "WaitingApprove" || status == "Processing" {
 		userData, err := app.db.GetUserData(ctx, userId)
 		if err != nil {
@@ -293,13 +385,13 @@ func (app *App) methodGetRequests(w http.ResponseWriter, r *http.Request) (err e
 			return err
 		}
 
-		requests, err = app.db.GetRequestsByTag(ctx, userData.UserTags, squadsAdmin, assist_db.RequestStatusFromString(status), &timeFrom)
+		requests, err = app.db.GetRequestsByTag(ctx, userData.UserTags, squadsAdmin, assist_db.RequestStatusFromString(status), timeFrom, filter)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return err
 		}
 	} else if status == "User" {
-		requests, err = app.db.GetUserRequests(ctx, userId, &timeFrom)
+		requests, err = app.db.GetUserRequests(ctx, userId, timeFrom, filter)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return err
@@ -311,6 +403,12 @@ func (app *App) methodGetRequests(w http.ResponseWriter, r *http.Request) (err e
 		return err
 	}
 
+	if err = app.fillRequestRecords(ctx, app.newWorkflowActor(r), requests); err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	err = json.NewEncoder(w).Encode(requests)
@@ -329,12 +427,15 @@ func (app *App) methodSetRequestStatus(w http.ResponseWriter, r *http.Request) e
 
 	requestId := params["requestId"]
 
+	// target state might be given either by name or, for the default workflow, by status
 	var requestDetails struct {
-		Status assist_db.RequestStatusType `json:"status"`
+		State   string                       `json:"state"`
+		Status  *assist_db.RequestStatusType `json:"status"`
+		Comment string                       `json:"comment"`
 	}
 	err := json.NewDecoder(r.Body).Decode(&requestDetails)
-	if err != nil {
-		err = fmt.Errorf("Failed to decode request details from the HTTP request: %w", err)
+	if err != nil || (requestDetails.State == "" && requestDetails.Status == nil) {
+		err = fmt.Errorf("Failed to decode request details from the HTTP request: %v", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return err
 	}
@@ -355,54 +456,35 @@ func (app *App) methodSetRequestStatus(w http.ResponseWriter, r *http.Request) e
 		return err
 	}
 
-	ud := app.sd.getCurrentUserData(r)
-	userId := ud.UID
+	wf := queue.GetWorkflow()
+	actor := app.newWorkflowActor(r)
 
-	// check authorization and get
-	var memberIds []string
-	var notification string
-	authorized := false
-	switch requestDetails.Status {
-	case assist_db.Cancelled:
-		authorized = request.UserId == userId
-	case assist_db.Declined, assist_db.Processing:
-		if queue.Approvers != "" && ud.HasTag(queue.SquadId+"/"+queue.Approvers) {
-			authorized = true
-		} else {
-			// check if user is admin
-			status, err := app.db.GetSquadMemberStatus(ctx, userId, queue.SquadId)
-			if err == nil && status == assist_db.Admin {
-				authorized = true
+	// find transition to the target state
+	var transition *assist_db.WorkflowTransition
+	if requestDetails.State != "" {
+		transition = wf.GetTransition(request.CurrentState(), requestDetails.State)
+	} else {
+		for _, t := range wf.GetTransitions(request.CurrentState()) {
+			if wf.GetState(t.To).Status == *requestDetails.Status && actor.isAllowed(ctx, queue, request, t) {
+				transition = t
+				break
 			}
 		}
+	}
 
-		if authorized && requestDetails.Status == assist_db.Processing {
-			notification = "New request in queue " + request.QueueId + " waiting to be processed"
-			memberIds, err = app.db.GetSquadMemberIdsByTag(context.Background(), queue.SquadId, queue.Handlers)
-			if err != nil {
-				log.Println("Failed to get list of squad " + queue.SquadId + " members, will not be able to create notifications")
-			}
-		}
-	case assist_db.Completed:
-		if ud.HasTag(queue.Handlers) {
-			authorized = true
-		} else if ud.HasTag(queue.Approvers) {
-			authorized = true
-		} else {
-			// check if user is admin
-			status, err := app.db.GetSquadMemberStatus(ctx, userId, queue.SquadId)
-			if err == nil && status >= assist_db.Admin {
-				authorized = true
-			}
-		}
-		if authorized {
-			notification = "Request '" + request.QueueId + " : " + request.Details + "' completed"
-			memberIds = []string{request.UserId}
+	if transition == nil {
+		target := requestDetails.State
+		if target == "" {
+			target = requestDetails.Status.String()
 		}
+		err := fmt.Errorf("Request %v can not be moved from %v to %v", requestId, request.CurrentState(), target)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
 	}
 
-	if !authorized {
-		err := fmt.Errorf("Current user %v is not authorized to mark request %v as %v", ud.UID, requestId, requestDetails.Status.String())
+	if !actor.isAllowed(ctx, queue, request, transition) {
+		err := fmt.Errorf("Current user %v is not authorized to %v request %v", actor.userId, transition.Name, requestId)
 		log.Println(err.Error())
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return err
@@ -410,8 +492,17 @@ func (app *App) methodSetRequestStatus(w http.ResponseWriter, r *http.Request) e
 
 	gorilla_context.Set(r, "AuthChecked", true)
 
-	err = app.db.SetRequestStatus(ctx, requestId, requestDetails.Status)
+	state := wf.GetState(transition.To)
+	err = app.db.SetRequestState(ctx, requestId, request.CurrentState(), state.Name, state.Status, &assist_db.RequestHistoryEntry{
+		UserId:   actor.userId,
+		UserName: actor.ud.DisplayName,
+		Comment:  requestDetails.Comment,
+	})
 	if err != nil {
+		if status.Code(err) == codes.FailedPrecondition {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return err
+		}
 		err = fmt.Errorf("Failed to set request "+requestId+" status: %w", err)
 		log.Println(err.Error())
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -419,14 +510,585 @@ func (app *App) methodSetRequestStatus(w http.ResponseWriter, r *http.Request) e
 	}
 
 	// send notifications
-	if notification != "" {
+	go func() {
+		app.notifyRequestState(queue, requestId, request, state, actor.userId, "Request '"+request.QueueId+" : "+request.Details+"' is "+state.Name)
+		request.State, request.Status = state.Name, state.Status
+		app.hooks.fire(queue.SquadId, assist_db.HookRequestStatus, newRequestHookData(requestId, request))
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(struct {
+		Status int    `json:"status"`
+		State  string `json:"state"`
+	}{int(state.Status), state.Name})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	return nil
+}
+
+// gets request and its queue, and checks that current user is involved in
+// processing the request, writes HTTP error if something is wrong
+func (app *App) getRequestForInvolvedUser(w http.ResponseWriter, r *http.Request, requestId string, action string) (*assist_db.RequestDetails, *assist_db.QueueInfo, *workflowActor, error) {
+	ctx := r.Context()
+
+	request, err := app.db.GetRequest(ctx, requestId)
+	if err != nil {
+		err = fmt.Errorf("Failed to get request details: %w", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, nil, nil, err
+	}
+
+	queue, err := app.db.GetRequestQueue(ctx, request.QueueId)
+	if err != nil {
+		err = fmt.Errorf("Failed to get request queue details: %w", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, nil, nil, err
+	}
+
+	actor := app.newWorkflowActor(r)
+	if !actor.isInvolved(ctx, queue, request) {
+		err := fmt.Errorf("Current user %v is not authorized to %v request %v", actor.userId, action, requestId)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return nil, nil, nil, err
+	}
+
+	gorilla_context.Set(r, "AuthChecked", true)
+
+	return request, queue, actor, nil
+}
+
+func (app *App) methodGetRequestHistory(w http.ResponseWriter, r *http.Request) error {
+	params := mux.Vars(r)
+	ctx := r.Context()
+
+	requestId := params["requestId"]
+
+	// history is available to everyone involved in processing the request
+	_, _, _, err := app.getRequestForInvolvedUser(w, r, requestId, "get history of")
+	if err != nil {
+		return err
+	}
+
+	history, err := app.db.GetRequestHistory(ctx, requestId)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(history)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	return nil
+}
+
+func (app *App) methodCreateRequestComment(w http.ResponseWriter, r *http.Request) error {
+	params := mux.Vars(r)
+	ctx := r.Context()
+
+	requestId := params["requestId"]
+
+	var comment assist_db.RequestComment
+	err := json.NewDecoder(r.Body).Decode(&comment)
+	if err != nil || strings.TrimSpace(comment.Text) == "" {
+		err = fmt.Errorf("Failed to decode comment from the HTTP request: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	request, queue, actor, err := app.getRequestForInvolvedUser(w, r, requestId, "comment")
+	if err != nil {
+		return err
+	}
+
+	comment.UserId = actor.userId
+	comment.UserName = actor.ud.DisplayName
+
+	commentId, err := app.db.CreateRequestComment(ctx, requestId, &comment)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	// notify other participants
+	go func() {
+		ctx := context.Background()
+
+		memberIds := make([]string, 0)
+		seen := map[string]bool{actor.userId: true}
+		add := func(ids []string) {
+			for _, id := range ids {
+				if !seen[id] {
+					seen[id] = true
+					memberIds = append(memberIds, id)
+				}
+			}
+		}
+
+		for _, role := range []string{assist_db.WorkflowRequester, assist_db.WorkflowApprovers, assist_db.WorkflowHandlers} {
+			ids, err := app.getWorkflowRoleMemberIds(ctx, queue, request, role)
+			if err != nil {
+				log.Printf("Failed to get squad %v members with role %v, will not be able to create notifications: %v", queue.SquadId, role, err)
+				continue
+			}
+			add(ids)
+		}
+
+		comments, err := app.db.GetRequestComments(ctx, requestId)
+		if err != nil {
+			log.Printf("Failed to get request %v comments, will not be able to notify commenters: %v", requestId, err)
+		}
+		for _, c := range comments {
+			add([]string{c.UserId})
+		}
+
+		if len(memberIds) > 0 {
+			app.ntfs.createNotification(assist_db.KindRequestUpdate, queue.SquadId, memberIds, "Request "+request.QueueId, comment.UserName+" commented request '"+request.Details+"': "+comment.Text, requestLink(requestId))
+		}
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(struct {
+		ID string `json:"id"`
+	}{commentId})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	return nil
+}
+
+func (app *App) methodGetRequestComments(w http.ResponseWriter, r *http.Request) error {
+	params := mux.Vars(r)
+	ctx := r.Context()
+
+	requestId := params["requestId"]
+
+	_, _, _, err := app.getRequestForInvolvedUser(w, r, requestId, "get comments of")
+	if err != nil {
+		return err
+	}
+
+	comments, err := app.db.GetRequestComments(ctx, requestId)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(comments)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	return nil
+}
+
+func (app *App) methodDeleteRequestComment(w http.ResponseWriter, r *http.Request) error {
+	params := mux.Vars(r)
+	ctx := r.Context()
+
+	requestId := params["requestId"]
+	commentId := params["commentId"]
+
+	_, queue, actor, err := app.getRequestForInvolvedUser(w, r, requestId, "delete comments of")
+	if err != nil {
+		return err
+	}
+
+	comment, err := app.db.GetRequestComment(ctx, requestId, commentId)
+	if err != nil {
+		err = fmt.Errorf("Failed to get comment details: %w", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	// comment might be deleted by its author or squad admin
+	if comment.UserId != actor.userId && !actor.isSquadAdmin(ctx, queue.SquadId) {
+		err := fmt.Errorf("Current user %v is not authorized to delete comment %v", actor.userId, commentId)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	err = app.db.DeleteRequestComment(ctx, requestId, commentId)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+// requests might be claimed by handlers and approvers, or assigned by approvers and squad admins
+func (app *App) methodSetRequestAssignee(w http.ResponseWriter, r *http.Request) error {
+	params := mux.Vars(r)
+	ctx := r.Context()
+
+	requestId := params["requestId"]
+
+	var assignee struct {
+		AssigneeId string `json:"assigneeId"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&assignee)
+	if err != nil || assignee.AssigneeId == "" {
+		err = fmt.Errorf("Failed to decode assignee from the HTTP request: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	request, queue, actor, err := app.getRequestForInvolvedUser(w, r, requestId, "assign")
+	if err != nil {
+		return err
+	}
+
+	if request.Status != assist_db.WaitingApprove && request.Status != assist_db.Processing {
+		err = fmt.Errorf("Request %v is already %v", requestId, request.CurrentState())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	canAssign := actor.hasRole(ctx, queue, request, assist_db.WorkflowApprovers) || actor.hasRole(ctx, queue, request, assist_db.WorkflowAdmins)
+
+	var assigneeName string
+	if assignee.AssigneeId == "me" || assignee.AssigneeId == actor.userId {
+		// claim request
+		if !canAssign && !actor.hasRole(ctx, queue, request, assist_db.WorkflowHandlers) {
+			err = fmt.Errorf("Current user %v is not authorized to claim request %v", actor.userId, requestId)
+			log.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return err
+		}
+		assignee.AssigneeId = actor.userId
+		assigneeName = actor.ud.DisplayName
+	} else {
+		if !canAssign {
+			err = fmt.Errorf("Current user %v is not authorized to assign request %v", actor.userId, requestId)
+			log.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return err
+		}
+
+		status, err := app.db.GetSquadMemberStatus(ctx, assignee.AssigneeId, queue.SquadId)
+		if err != nil || status < assist_db.Member {
+			err = fmt.Errorf("User %v is not a member of squad %v", assignee.AssigneeId, queue.SquadId)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return err
+		}
+
+		userData, err := app.db.GetUserData(ctx, assignee.AssigneeId)
+		if err != nil {
+			err = fmt.Errorf("Failed to get user %v details: %w", assignee.AssigneeId, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+		assigneeName = userData.DisplayName
+	}
+
+	// claimed request might be reassigned only by approvers and admins
+	from := request.AssigneeId
+	if !canAssign {
+		from = ""
+	}
+
+	err = app.db.SetRequestAssignee(ctx, requestId, from, assignee.AssigneeId, assigneeName)
+	if err != nil {
+		if status.Code(err) == codes.FailedPrecondition {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			log.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return err
+	}
+
+	if assignee.AssigneeId != actor.userId {
 		go func() {
-			app.ntfs.createNotification(memberIds, "Request "+request.QueueId, notification)
+			app.ntfs.createNotification(assist_db.KindRequestHandle, queue.SquadId, []string{assignee.AssigneeId}, "Request "+request.QueueId, "Request '"+request.QueueId+" : "+request.Details+"' is assigned to you", requestLink(requestId))
 		}()
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(struct {
+		AssigneeId   string `json:"assigneeId"`
+		AssigneeName string `json:"assigneeName"`
+	}{assignee.AssigneeId, assigneeName})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	return nil
+}
+
+func (app *App) methodDeleteRequestAssignee(w http.ResponseWriter, r *http.Request) error {
+	params := mux.Vars(r)
+	ctx := r.Context()
+
+	requestId := params["requestId"]
+
+	request, queue, actor, err := app.getRequestForInvolvedUser(w, r, requestId, "unassign")
+	if err != nil {
+		return err
+	}
+
+	if request.AssigneeId != actor.userId &&
+		!actor.hasRole(ctx, queue, request, assist_db.WorkflowApprovers) && !actor.hasRole(ctx, queue, request, assist_db.WorkflowAdmins) {
+		err = fmt.Errorf("Current user %v is not authorized to unassign request %v", actor.userId, requestId)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	err = app.db.SetRequestAssignee(ctx, requestId, request.AssigneeId, "", "")
+	if err != nil {
+		if status.Code(err) == codes.FailedPrecondition {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			log.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+// notifies squad admins about requests staying in their status longer than queue SLA allows
+func (app *App) escalateOverdueRequests(ctx context.Context) error {
+	queues, err := app.db.GetQueuesWithSLA(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to get queues with SLA: %w", err)
+	}
+
+	for _, queue := range queues {
+		for _, status := range []assist_db.RequestStatusType{assist_db.WaitingApprove, assist_db.Processing} {
+			limit := queue.SLA.Limit(status)
+			if limit == 0 {
+				continue
+			}
+
+			// requests are marked escalated, so every iteration gets the next portion
+			for {
+				requests, err := app.db.GetOverdueRequests(ctx, queue.ID, status, time.Now().Add(-limit))
+				if err != nil {
+					return fmt.Errorf("Failed to get overdue requests in queue %v: %w", queue.ID, err)
+				}
+
+				for i := range requests {
+					request := &requests[i]
+
+					adminIds, err := app.getWorkflowRoleMemberIds(ctx, &queue.QueueInfo, &request.RequestDetails, assist_db.WorkflowAdmins)
+					if err != nil {
+						return fmt.Errorf("Failed to get squad %v admins: %w", queue.SquadId, err)
+					}
+
+					if err = app.db.SetRequestEscalated(ctx, request.RequestId); err != nil {
+						return err
+					}
+
+					app.ntfs.createNotification(assist_db.KindRequestUpdate, queue.SquadId, adminIds, "Request "+request.QueueId,
+						fmt.Sprintf("Request '%v : %v' is %v for more than %v", request.QueueId, request.Details, request.CurrentState(), limit), requestLink(request.RequestId))
+				}
+
+				if len(requests) == 0 {
+					break
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// workflowActor tells which workflow roles current user has
+type workflowActor struct {
+	app    *App
+	userId string
+	ud     *assist_db.UserData
+	// squad admin status cache
+	admin map[string]bool
+}
+
+func (app *App) newWorkflowActor(r *http.Request) *workflowActor {
+	return &workflowActor{
+		app:    app,
+		userId: app.sd.getCurrentUserID(r),
+		ud:     app.sd.getCurrentUserData(r),
+		admin:  make(map[string]bool),
+	}
+}
+
+func (a *workflowActor) isSquadAdmin(ctx context.Context, squadId string) bool {
+	if admin, ok := a.admin[squadId]; ok {
+		return admin
+	}
+
+	status, err := a.app.db.GetSquadMemberStatus(ctx, a.userId, squadId)
+	admin := err == nil && status >= assist_db.Admin
+	a.admin[squadId] = admin
+
+	return admin
+}
+
+func (a *workflowActor) hasRole(ctx context.Context, queue *assist_db.QueueInfo, request *assist_db.RequestDetails, role string) bool {
+	switch role {
+	case assist_db.WorkflowRequester:
+		return request.UserId == a.userId
+	case assist_db.WorkflowApprovers:
+		return queue.Approvers != "" && a.ud.HasTag(queue.SquadId+"/"+queue.Approvers)
+	case assist_db.WorkflowHandlers:
+		return queue.Handlers != "" && a.ud.HasTag(queue.SquadId+"/"+queue.Handlers)
+	case assist_db.WorkflowAdmins:
+		return a.isSquadAdmin(ctx, queue.SquadId)
+	case assist_db.WorkflowAssignee:
+		return request.AssigneeId != "" && request.AssigneeId == a.userId
+	default:
+		return a.ud.HasTag(queue.SquadId + "/" + role)
+	}
+}
+
+// returns true if user is requester, approver, handler or squad admin, or is
+// allowed to perform any transition in the queue workflow
+func (a *workflowActor) isInvolved(ctx context.Context, queue *assist_db.QueueInfo, request *assist_db.RequestDetails) bool {
+	for _, role := range []string{assist_db.WorkflowRequester, assist_db.WorkflowApprovers, assist_db.WorkflowHandlers, assist_db.WorkflowAdmins} {
+		if a.hasRole(ctx, queue, request, role) {
+			return true
+		}
+	}
+
+	wf := queue.GetWorkflow()
+	for i := range wf.Transitions {
+		if a.isAllowed(ctx, queue, request, &wf.Transitions[i]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (a *workflowActor) isAllowed(ctx context.Context, queue *assist_db.QueueInfo, request *assist_db.RequestDetails, t *assist_db.WorkflowTransition) bool {
+	for _, role := range t.Allowed {
+		if a.hasRole(ctx, queue, request, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// fills transitions current user might perform and SLA flag of every request
+func (app *App) fillRequestRecords(ctx context.Context, actor *workflowActor, requests []assist_db.RequestRecord) error {
+	queues := make(map[string]*assist_db.QueueInfo)
+	now := time.Now()
+
+	for i := range requests {
+		request := &requests[i]
+
+		queue, ok := queues[request.QueueId]
+		if !ok {
+			var err error
+			queue, err = app.db.GetRequestQueue(ctx, request.QueueId)
+			if err != nil {
+				return fmt.Errorf("Failed to get request queue details: %w", err)
+			}
+			queues[request.QueueId] = queue
+		}
+
+		for _, t := range queue.GetWorkflow().GetTransitions(request.CurrentState()) {
+			if actor.isAllowed(ctx, queue, &request.RequestDetails, t) {
+				request.Actions = append(request.Actions, t)
+			}
+		}
+		request.Overdue = request.IsOverdue(queue.SLA, now)
+	}
 
 	return nil
 }
+
+// returns ids of squad members having workflow role or tag
+func (app *App) getWorkflowRoleMemberIds(ctx context.Context, queue *assist_db.QueueInfo, request *assist_db.RequestDetails, role string) ([]string, error) {
+	switch role {
+	case assist_db.WorkflowRequester:
+		return []string{request.UserId}, nil
+	case assist_db.WorkflowApprovers:
+		if queue.Approvers == "" {
+			return nil, nil
+		}
+		return app.db.GetSquadMemberIdsByTag(ctx, queue.SquadId, queue.Approvers)
+	case assist_db.WorkflowHandlers:
+		if queue.Handlers == "" {
+			return nil, nil
+		}
+		return app.db.GetSquadMemberIdsByTag(ctx, queue.SquadId, queue.Handlers)
+	case assist_db.WorkflowAdmins:
+		return app.db.GetSquadMemberIds(ctx, queue.SquadId, []int{int(assist_db.Owner), int(assist_db.Admin)}, "")
+	case assist_db.WorkflowAssignee:
+		if request.AssigneeId == "" {
+			return nil, nil
+		}
+		return []string{request.AssigneeId}, nil
+	default:
+		return app.db.GetSquadMemberIdsByTag(ctx, queue.SquadId, role)
+	}
+}
+
+// requestStateKind returns kind of notification about request getting into the state
+func requestStateKind(state *assist_db.WorkflowState) string {
+	switch state.Status {
+	case assist_db.WaitingApprove:
+		return assist_db.KindRequestApprove
+	case assist_db.Processing:
+		return assist_db.KindRequestHandle
+	default:
+		return assist_db.KindRequestCompleted
+	}
+}
+
+// notifies roles configured for the state the request got into, except the user who moved it there
+func (app *App) notifyRequestState(queue *assist_db.QueueInfo, requestId string, request *assist_db.RequestDetails, state *assist_db.WorkflowState, actorId string, notification string) {
+	ctx := context.Background()
+
+	memberIds := make([]string, 0)
+	seen := map[string]bool{actorId: true}
+	for _, role := range state.Notify {
+		ids, err := app.getWorkflowRoleMemberIds(ctx, queue, request, role)
+		if err != nil {
+			log.Printf("Failed to get squad %v members with role %v, will not be able to create notifications: %v", queue.SquadId, role, err)
+			continue
+		}
+		for _, id := range ids {
+			if !seen[id] {
+				seen[id] = true
+				memberIds = append(memberIds, id)
+			}
+		}
+	}
+
+	if len(memberIds) > 0 {
+		app.ntfs.createNotification(requestStateKind(state), queue.SquadId, memberIds, "Request "+request.QueueId, notification, requestLink(requestId))
+	}
+}
@@ -0,0 +1,558 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	assist_db "assist/db"
+
+	"github.com/gorilla/mux"
+)
+
+// occurrences of event series are generated this far ahead
+const seriesHorizonDays = 8 * 7
+
+func dayOf(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// validateSeries checks recurrence rule and exception dates of the series
+func validateSeries(series *assist_db.EventSeries) (*assist_db.RRule, error) {
+	rule, err := assist_db.ParseRRule(series.RRule)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range series.ExDates {
+		if _, err := time.Parse(assist_db.FieldDateLayout, d); err != nil {
+			return nil, fmt.Errorf("Exception date %v should be in YYYY-MM-DD format", d)
+		}
+	}
+
+	return rule, nil
+}
+
+// generateSeriesEvents creates occurrences of the series from today up to
+// the horizon which do not exist yet and registers series participants
+// there; returns ids of created events
+func (app *App) generateSeriesEvents(ctx context.Context, seriesId string, series *assist_db.EventSeries) ([]string, error) {
+	rule, err := assist_db.ParseRRule(series.RRule)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse series %v recurrence rule: %w", seriesId, err)
+	}
+
+	events, err := app.db.GetSeriesEvents(ctx, seriesId)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[time.Time]bool, len(events))
+	for _, e := range events {
+		existing[dayOf(*e.Date)] = true
+	}
+
+	today := dayOf(time.Now())
+	to := today.AddDate(0, 0, seriesHorizonDays)
+
+	ids := make([]string, 0)
+	for _, d := range rule.Occurrences(*series.Start, series.ExDates, today, to) {
+		if existing[d] {
+			continue
+		}
+
+		date := d
+		event := &assist_db.EventInfo{
+			Date:      &date,
+			TimeFrom:  series.TimeFrom,
+			TimeTo:    series.TimeTo,
+			Text:      series.Text,
+			SquadId:   series.SquadId,
+			OwnerId:   series.OwnerId,
+			SeriesId:  seriesId,
+			Capacity:  series.Capacity,
+			Reminders: series.Reminders,
+			Location:  series.Location,
+		}
+		id, err := app.db.CreateEvent(ctx, event)
+		if err != nil {
+			return ids, fmt.Errorf("Failed to create occurrence of series %v: %w", seriesId, err)
+		}
+		ids = append(ids, id)
+
+		// members could have left the squad since they registered for the series
+		for userId, status := range series.Participants {
+			if err := app.db.RegisterParticipants(ctx, []string{userId}, id, event, status); err != nil {
+				log.Printf("Failed to register series %v participant: %v", seriesId, err)
+			}
+		}
+	}
+
+	series.GeneratedUntil = &to
+	if err = app.db.UpdateEventSeries(ctx, seriesId, series); err != nil {
+		return ids, err
+	}
+
+	return ids, nil
+}
+
+// generateEventSeries extends all active series up to the horizon
+func (app *App) generateEventSeries(ctx context.Context) error {
+	series, err := app.db.GetActiveEventSeries(ctx)
+	if err != nil {
+		return err
+	}
+
+	today := dayOf(time.Now())
+	for _, s := range series {
+		if s.GeneratedUntil != nil && s.GeneratedUntil.After(today.AddDate(0, 0, seriesHorizonDays-1)) {
+			continue
+		}
+		if _, err := app.generateSeriesEvents(ctx, s.ID, &s.EventSeries); err != nil {
+			log.Printf("Failed to generate events of series %v: %v", s.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// createEventSeries is used by methodCreateEvent when event has recurrence rule
+func (app *App) createEventSeries(w http.ResponseWriter, r *http.Request, event *assist_db.EventInfo, rrule string, exDates []string) (seriesId string, eventIds []string, err error) {
+	ctx := r.Context()
+
+	start := dayOf(*event.Date)
+	series := &assist_db.EventSeries{
+		SquadId:   event.SquadId,
+		OwnerId:   event.OwnerId,
+		Text:      event.Text,
+		TimeFrom:  event.TimeFrom,
+		TimeTo:    event.TimeTo,
+		Start:     &start,
+		RRule:     rrule,
+		ExDates:   exDates,
+		Capacity:  event.Capacity,
+		Reminders: event.Reminders,
+		Location:  event.Location,
+	}
+
+	if _, err = validateSeries(series); err != nil {
+		err = fmt.Errorf("Invalid event series: %w", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", nil, err
+	}
+
+	seriesId, err = app.db.CreateEventSeries(ctx, series)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return "", nil, err
+	}
+
+	eventIds, err = app.generateSeriesEvents(ctx, seriesId, series)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return "", nil, err
+	}
+
+	return seriesId, eventIds, nil
+}
+
+// getSeriesForAdmin returns series if current user is allowed to manage it
+func (app *App) getSeriesForAdmin(w http.ResponseWriter, r *http.Request, seriesId string) (*assist_db.EventSeries, error) {
+	series, err := app.db.GetEventSeries(r.Context(), seriesId)
+	if err != nil {
+		err = fmt.Errorf("Failed to get event series %v: %w", seriesId, err)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, err
+	}
+
+	_, authLevel := app.checkAuthorization(r, "me", series.SquadId, squadAdmin|squadOwner)
+	if authLevel == 0 {
+		err = fmt.Errorf("Current user is not authorized to manage event series " + seriesId)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return nil, err
+	}
+
+	return series, nil
+}
+
+func (app *App) methodGetEventSeries(w http.ResponseWriter, r *http.Request) error {
+	params := mux.Vars(r)
+	ctx := r.Context()
+
+	seriesId := params["seriesId"]
+
+	series, err := app.getSeriesForAdmin(w, r, seriesId)
+	if err != nil {
+		return err
+	}
+
+	events, err := app.db.GetSeriesEvents(ctx, seriesId)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(struct {
+		Series *assist_db.EventSeries   `json:"series"`
+		Events []*assist_db.EventRecord `json:"events"`
+	}{series, events})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	return nil
+}
+
+// methodUpdateEventSeries changes the whole series, future occurrences which
+// were not edited separately follow the new details and recurrence rule
+func (app *App) methodUpdateEventSeries(w http.ResponseWriter, r *http.Request) error {
+	params := mux.Vars(r)
+	ctx := r.Context()
+
+	seriesId := params["seriesId"]
+
+	series, err := app.getSeriesForAdmin(w, r, seriesId)
+	if err != nil {
+		return err
+	}
+
+	if series.Cancelled {
+		err = fmt.Errorf("Event series %v is cancelled", seriesId)
+		http.Error(w, err.Error(), http.StatusConflict)
+		return err
+	}
+
+	var data struct {
+		Text      string                   `json:"text"`
+		TimeFrom  string                   `json:"timeFrom"`
+		TimeTo    string                   `json:"timeTo"`
+		Start     *time.Time               `json:"start"`
+		RRule     string                   `json:"rrule"`
+		ExDates   []string                 `json:"exDates"`
+		Capacity  int                      `json:"capacity"`
+		Reminders []int                    `json:"reminders"`
+		Location  *assist_db.EventLocation `json:"location"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		err = fmt.Errorf("Failed to decode event series from the HTTP request: %w", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	if data.Text == "" {
+		err = fmt.Errorf("Event series should have text")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	if data.Capacity < 0 {
+		err = fmt.Errorf("Event capacity should not be negative")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	if err = validateReminders(data.Reminders); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	location, err := normalizeLocation(data.Location)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	series.Text = data.Text
+	series.TimeFrom = data.TimeFrom
+	series.TimeTo = data.TimeTo
+	series.RRule = data.RRule
+	series.ExDates = data.ExDates
+	series.Capacity = data.Capacity
+	series.Reminders = data.Reminders
+	series.Location = location
+	if data.Start != nil {
+		start := dayOf(*data.Start)
+		series.Start = &start
+	}
+
+	rule, err := validateSeries(series)
+	if err != nil {
+		err = fmt.Errorf("Invalid event series: %w", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	events, err := app.db.GetSeriesEvents(ctx, seriesId)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	today := dayOf(time.Now())
+	days := make(map[time.Time]bool)
+	for _, d := range rule.Occurrences(*series.Start, series.ExDates, today, today.AddDate(0, 0, seriesHorizonDays)) {
+		days[d] = true
+	}
+
+	for _, e := range events {
+		if e.SeriesOverride || dayOf(*e.Date).Before(today) {
+			continue
+		}
+
+		if !days[dayOf(*e.Date)] {
+			err = app.db.DeleteEvent(ctx, e.ID)
+		} else {
+			e.Text = series.Text
+			e.TimeFrom = series.TimeFrom
+			e.TimeTo = series.TimeTo
+			e.Capacity = series.Capacity
+			e.Reminders = series.Reminders
+			e.Location = series.Location
+			err = app.db.UpdateEvent(ctx, e.ID, &e.EventInfo)
+			if err == nil {
+				app.promoteWaitlisted(ctx, e.ID)
+			}
+		}
+		if err != nil {
+			err = fmt.Errorf("Failed to update occurrence %v of event series %v: %w", e.ID, seriesId, err)
+			log.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+	}
+
+	// create occurrences which appeared due to the new rule
+	if _, err = app.generateSeriesEvents(ctx, seriesId, series); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+// methodDeleteEventSeries cancels the series and deletes its future occurrences
+func (app *App) methodDeleteEventSeries(w http.ResponseWriter, r *http.Request) error {
+	params := mux.Vars(r)
+	ctx := r.Context()
+
+	seriesId := params["seriesId"]
+
+	series, err := app.getSeriesForAdmin(w, r, seriesId)
+	if err != nil {
+		return err
+	}
+
+	series.Cancelled = true
+	if err = app.db.UpdateEventSeries(ctx, seriesId, series); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	events, err := app.db.GetSeriesEvents(ctx, seriesId)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	today := dayOf(time.Now())
+	for _, e := range events {
+		if dayOf(*e.Date).Before(today) {
+			continue
+		}
+		if err = app.db.DeleteEvent(ctx, e.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+	}
+
+	go func() {
+		memberIds, err := app.db.GetSquadMemberIds(context.Background(), series.SquadId, []int{int(assist_db.Owner), int(assist_db.Admin), int(assist_db.Member)}, app.sd.getCurrentUserID(r))
+		if err != nil {
+			log.Println("Failed to get list of squad " + series.SquadId + " members, will not be able to create notifications")
+		}
+		app.ntfs.createNotification(assist_db.KindEventChange, series.SquadId, memberIds, "Event Cancelled", "Recurring event '"+series.Text+"' was cancelled", squadLink(series.SquadId))
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+// methodUpdateSeriesEvent changes single occurrence, it is not touched by
+// further changes of the whole series
+func (app *App) methodUpdateSeriesEvent(w http.ResponseWriter, r *http.Request) error {
+	params := mux.Vars(r)
+	ctx := r.Context()
+
+	seriesId := params["seriesId"]
+	eventId := params["eventId"]
+
+	series, err := app.getSeriesForAdmin(w, r, seriesId)
+	if err != nil {
+		return err
+	}
+
+	eventInfo, err := app.db.GetEvent(ctx, eventId)
+	if err != nil || eventInfo.SeriesId != seriesId {
+		err = fmt.Errorf("Event %v is not an occurrence of series %v", eventId, seriesId)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	var data assist_db.EventInfo
+	err = json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		err = fmt.Errorf("Failed to decode event data from the HTTP request: %w", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	if data.Text == "" {
+		err = fmt.Errorf("Event should have text")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	if data.Capacity < 0 {
+		err = fmt.Errorf("Event capacity should not be negative")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	if err = validateReminders(data.Reminders); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	location, err := normalizeLocation(data.Location)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	oldDay := dayOf(*eventInfo.Date)
+
+	event := *eventInfo
+	event.Text = data.Text
+	event.TimeFrom = data.TimeFrom
+	event.TimeTo = data.TimeTo
+	event.Date = data.Date
+	event.Capacity = data.Capacity
+	event.Reminders = data.Reminders
+	event.Location = location
+	event.SeriesOverride = true
+	if err = app.db.UpdateEvent(ctx, eventId, &event); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+	app.promoteWaitlisted(ctx, eventId)
+
+	// occurrence moved to another day should not be generated again
+	if dayOf(*event.Date) != oldDay && series.ExcludeDay(oldDay) {
+		if err = app.db.UpdateEventSeries(ctx, seriesId, series); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+// excludeSeriesEvent adds day of deleted occurrence to exception dates of its series
+func (app *App) excludeSeriesEvent(ctx context.Context, event *assist_db.EventInfo) error {
+	series, err := app.db.GetEventSeries(ctx, event.SeriesId)
+	if err != nil {
+		return err
+	}
+
+	if !series.ExcludeDay(*event.Date) {
+		return nil
+	}
+
+	return app.db.UpdateEventSeries(ctx, event.SeriesId, series)
+}
+
+// setSeriesParticipants registers users for the whole series: for the
+// occurrences after the given event and for the ones generated later
+func (app *App) setSeriesParticipants(ctx context.Context, event *assist_db.EventInfo, userIds []string, status assist_db.ParticipantStatusType) error {
+	series, err := app.db.GetEventSeries(ctx, event.SeriesId)
+	if err != nil {
+		return err
+	}
+
+	if series.Participants == nil {
+		series.Participants = make(map[string]assist_db.ParticipantStatusType)
+	}
+	for _, userId := range userIds {
+		series.Participants[userId] = status
+	}
+	if err = app.db.UpdateEventSeries(ctx, event.SeriesId, series); err != nil {
+		return err
+	}
+
+	events, err := app.db.GetSeriesEvents(ctx, event.SeriesId)
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		if !e.Date.After(*event.Date) {
+			continue
+		}
+		// users already registered for some occurrences keep their status there
+		if err := app.db.RegisterParticipants(ctx, userIds, e.ID, &e.EventInfo, status); err != nil {
+			log.Println(err.Error())
+		}
+	}
+
+	return nil
+}
+
+// removeSeriesParticipant removes user from the series and its occurrences after the given event
+func (app *App) removeSeriesParticipant(ctx context.Context, event *assist_db.EventInfo, userId string) error {
+	series, err := app.db.GetEventSeries(ctx, event.SeriesId)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := series.Participants[userId]; ok {
+		delete(series.Participants, userId)
+		if err = app.db.UpdateEventSeries(ctx, event.SeriesId, series); err != nil {
+			return err
+		}
+	}
+
+	events, err := app.db.GetSeriesEvents(ctx, event.SeriesId)
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		if !e.Date.After(*event.Date) {
+			continue
+		}
+		if _, err := app.db.GetParticipantStatus(ctx, userId, e.ID); err != nil {
+			continue
+		}
+		if err := app.db.DeleteParticipant(ctx, userId, e.ID); err != nil {
+			return err
+		}
+		app.promoteWaitlisted(ctx, e.ID)
+	}
+
+	return nil
+}
@@ -345,13 +345,21 @@ func (app *App) methodAddMemberToSquad(w http.ResponseWriter, r *http.Request) e
 		return err
 	}
 
+	go func() {
+		data := &memberHookData{UserId: userId, Status: memberStatus.String()}
+		if userData, err := app.db.GetUserData(context.Background(), userId); err == nil {
+			data.DisplayName = userData.DisplayName
+		}
+		app.hooks.fire(squadId, assist_db.HookMemberJoined, data)
+	}()
+
 	if memberStatus == assist_db.PendingApprove {
 		go func() {
 			squadAdmins, err := app.db.GetSquadMemberIds(context.Background(), squadId, []int{int(assist_db.Admin), int(assist_db.Owner)}, "")
 			if err != nil {
 				log.Println("Failed to get list of squad "+squadId+" admins, will not be able to create notifications: %v", err)
 			}
-			app.ntfs.createNotification(squadAdmins, "Approve New Member", "User "+app.sd.getCurrentUserData(r).DisplayName+" wants to join "+squadId)
+			app.ntfs.createNotification(assist_db.KindMembership, squadId, squadAdmins, "Approve New Member", "User "+app.sd.getCurrentUserData(r).DisplayName+" wants to join "+squadId, squadLink(squadId))
 		}()
 	}
 
@@ -448,6 +456,10 @@ func (app *App) methodUpdateSquadMember(w http.ResponseWriter, r *http.Request)
 		return err
 	}
 
+	if data.Status != nil {
+		go app.hooks.fire(squadId, assist_db.HookMemberStatus, &memberHookData{UserId: userId, Status: data.Status.String()})
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
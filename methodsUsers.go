@@ -4,9 +4,11 @@ import (
 	"assist/db"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"sync"
+	"time"
 
 	gorilla_context "github.com/gorilla/context"
 	"github.com/gorilla/mux"
@@ -136,7 +138,7 @@ func (app *App) methodGetHome(w http.ResponseWriter, r *http.Request) error {
 	// user requests
 	wg.Add(1)
 	go func() {
-		userRequests, errs[6] = app.db.GetUserRequests(ctx, userId, nil)
+		userRequests, errs[6] = app.db.GetUserRequests(ctx, userId, nil, nil)
 		wg.Done()
 	}()
 
@@ -185,16 +187,28 @@ func (app *App) methodSubscribeToNotifications(w http.ResponseWriter, r *http.Re
 	}
 
 	var token struct {
-		Token string `json:"token"`
+		Token  string `json:"token"`
+		Device string `json:"device"`
 	}
 
 	err := json.NewDecoder(r.Body).Decode(&token)
+	if err == nil && token.Token == "" {
+		err = fmt.Errorf("Token is not provided")
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return err
 	}
+	if token.Device == "" {
+		token.Device = r.UserAgent()
+	}
 
-	app.ntfs.SetUserToken(userId, token.Token)
+	err = app.ntfs.SetUserToken(r.Context(), userId, token.Token, token.Device)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -215,7 +229,21 @@ func (app *App) methodUnsubscribeFromNotifications(w http.ResponseWriter, r *htt
 		return err
 	}
 
-	app.ntfs.DeleteUserToken(userId)
+	// token of the device is optional, without it user is unsubscribed on all devices
+	var token struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&token); err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	err := app.ntfs.DeleteUserToken(r.Context(), userId, token.Token)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -236,12 +264,31 @@ func (app *App) methodGetNotifications(w http.ResponseWriter, r *http.Request) e
 		return err
 	}
 
-	notifications := app.ntfs.GetNotifications(userId)
+	// notifications are returned newest first, page by page
+	v := r.URL.Query()
+	var before *time.Time
+	if from := v.Get("from"); from != "" {
+		t, err := time.Parse(time.RFC3339Nano, from)
+		if err != nil {
+			err = fmt.Errorf("Failed to convert from to a time struct: %w", err)
+			log.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return err
+		}
+		before = &t
+	}
+
+	notifications, err := app.ntfs.GetNotifications(r.Context(), userId, before, v.Get("unread") == "true")
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
-	err := json.NewEncoder(w).Encode(notifications)
+	err = json.NewEncoder(w).Encode(notifications)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return err
@@ -250,7 +297,7 @@ func (app *App) methodGetNotifications(w http.ResponseWriter, r *http.Request) e
 	return nil
 }
 
-func (app *App) methodMarkNotificationsDelivered(w http.ResponseWriter, r *http.Request) error {
+func (app *App) methodMarkNotificationsRead(w http.ResponseWriter, r *http.Request) error {
 	params := mux.Vars(r)
 
 	userId := params["userId"]
@@ -263,7 +310,215 @@ func (app *App) methodMarkNotificationsDelivered(w http.ResponseWriter, r *http.
 		return err
 	}
 
-	app.ntfs.MarkNotificationsDelivered(userId)
+	err := app.ntfs.MarkNotificationsRead(r.Context(), userId)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+func (app *App) methodMarkNotificationRead(w http.ResponseWriter, r *http.Request) error {
+	params := mux.Vars(r)
+
+	userId := params["userId"]
+	notificationId := params["notificationId"]
+	userId, ok := app.checkAuthorizationUser(r, userId)
+	if !ok {
+		// operation is not authorized, return error
+		err := fmt.Errorf("Current user is not authorized to read user %v notifications", userId)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	err := app.ntfs.MarkNotificationRead(r.Context(), userId, notificationId)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+// how often notifications could be sent by email
+var emailDigests = map[string]bool{db.DigestImmediate: true, db.DigestHourly: true, db.DigestDaily: true}
+
+type NotificationPrefs struct {
+	EmailDigest string `json:"emailDigest"`
+	// preferences per squad and notification kind, read only here
+	Kinds []*db.NotificationPref `json:"kinds"`
+}
+
+func (app *App) methodGetNotificationPrefs(w http.ResponseWriter, r *http.Request) error {
+	params := mux.Vars(r)
+
+	userId := params["userId"]
+	userId, ok := app.checkAuthorizationUser(r, userId)
+	if !ok {
+		// operation is not authorized, return error
+		err := fmt.Errorf("Current user is not authorized to get user %v notification preferences", userId)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	digest, err := app.db.GetEmailDigest(r.Context(), userId)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	kinds, err := app.db.GetNotificationPrefs(r.Context(), userId)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(&NotificationPrefs{EmailDigest: digest, Kinds: kinds})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	return nil
+}
+
+func (app *App) methodSetNotificationPrefs(w http.ResponseWriter, r *http.Request) error {
+	params := mux.Vars(r)
+
+	userId := params["userId"]
+	userId, ok := app.checkAuthorizationUser(r, userId)
+	if !ok {
+		// operation is not authorized, return error
+		err := fmt.Errorf("Current user is not authorized to set user %v notification preferences", userId)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	var prefs NotificationPrefs
+	err := json.NewDecoder(r.Body).Decode(&prefs)
+	if err != nil {
+		err = fmt.Errorf("Failed to decode notification preferences from the HTTP request: %w", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	if !emailDigests[prefs.EmailDigest] {
+		err = fmt.Errorf("Unknown email digest %v, should be immediate, hourly or daily", prefs.EmailDigest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	err = app.db.SetEmailDigest(r.Context(), userId, prefs.EmailDigest)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+func (app *App) methodSetNotificationPref(w http.ResponseWriter, r *http.Request) error {
+	params := mux.Vars(r)
+	ctx := r.Context()
+
+	userId := params["userId"]
+	squadId := params["squadId"]
+	kind := params["kind"]
+	userId, ok := app.checkAuthorizationUser(r, userId)
+	if !ok {
+		// operation is not authorized, return error
+		err := fmt.Errorf("Current user is not authorized to set user %v notification preferences", userId)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	pref := db.NotificationPref{}
+	err := json.NewDecoder(r.Body).Decode(&pref)
+	if err != nil {
+		err = fmt.Errorf("Failed to decode notification preference from the HTTP request: %w", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+	pref.SquadId = squadId
+	pref.Kind = kind
+	if pref.Channels == nil {
+		pref.Channels = []string{}
+	}
+
+	if !db.PrefKinds[kind] {
+		err = fmt.Errorf("Notifications of kind %v could not be configured", kind)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+	for _, c := range pref.Channels {
+		if !notificationChannels[c] {
+			err = fmt.Errorf("Unknown notification channel %v, should be push or email", c)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return err
+		}
+	}
+
+	if _, err = app.db.GetSquadMemberStatus(ctx, userId, squadId); err != nil {
+		err = fmt.Errorf("User %v is not a member of squad %v: %w", userId, squadId, err)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return err
+	}
+
+	err = app.db.SetNotificationPref(ctx, userId, &pref)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+// methodDeleteNotificationPref resets preference, so notifications are delivered via all channels
+func (app *App) methodDeleteNotificationPref(w http.ResponseWriter, r *http.Request) error {
+	params := mux.Vars(r)
+
+	userId := params["userId"]
+	userId, ok := app.checkAuthorizationUser(r, userId)
+	if !ok {
+		// operation is not authorized, return error
+		err := fmt.Errorf("Current user is not authorized to delete user %v notification preferences", userId)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	err := app.db.DeleteNotificationPref(r.Context(), userId, params["squadId"], params["kind"])
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	assist_db "assist/db"
+
+	"github.com/gorilla/mux"
+)
+
+// normalizeLocation trims location fields and validates them, empty location is returned as nil
+func normalizeLocation(l *assist_db.EventLocation) (*assist_db.EventLocation, error) {
+	if l == nil {
+		return nil, nil
+	}
+
+	l = l.Copy()
+	l.Venue = strings.TrimSpace(l.Venue)
+	l.Address = strings.TrimSpace(l.Address)
+	l.MeetingURL = strings.TrimSpace(l.MeetingURL)
+	if l.IsEmpty() {
+		return nil, nil
+	}
+
+	if (l.Lat == nil) != (l.Lon == nil) {
+		return nil, fmt.Errorf("Location should have both latitude and longitude or none of them")
+	}
+	if l.Lat != nil && (*l.Lat < -90 || *l.Lat > 90 || *l.Lon < -180 || *l.Lon > 180) {
+		return nil, fmt.Errorf("Location coordinates %v,%v are out of range", *l.Lat, *l.Lon)
+	}
+	if l.MeetingURL != "" {
+		u, err := url.Parse(l.MeetingURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("Meeting URL should be http or https link, got %v", l.MeetingURL)
+		}
+	}
+
+	return l, nil
+}
+
+// locationSuffix is appended to notifications about the event
+func locationSuffix(l *assist_db.EventLocation) string {
+	if l.IsEmpty() {
+		return ""
+	}
+	return " at " + l.String()
+}
+
+// eventLocation returns location of the event, either copied from the venue
+// saved in the squad or the one provided in the request
+func (app *App) eventLocation(ctx context.Context, squadId string, venueId string, location *assist_db.EventLocation) (*assist_db.EventLocation, error) {
+	if venueId != "" {
+		venue, err := app.db.GetVenue(ctx, squadId, venueId)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get venue %v of squad %v: %w", venueId, squadId, err)
+		}
+		return venue, nil
+	}
+
+	return normalizeLocation(location)
+}
+
+func (app *App) methodCreateVenue(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	squadId := mux.Vars(r)["squadId"]
+
+	_, authLevel := app.checkAuthorization(r, "", squadId, squadAdmin|squadOwner)
+	if authLevel == 0 {
+		err := fmt.Errorf("Current user is not authorized to add venue to squad " + squadId)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	var data assist_db.EventLocation
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		err = fmt.Errorf("Failed to decode venue from the HTTP request: %w", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	venue, err := normalizeLocation(&data)
+	if err == nil && (venue == nil || venue.Venue == "") {
+		err = fmt.Errorf("Venue should have name")
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	id, err := app.db.CreateVenue(ctx, squadId, venue)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(struct {
+		ID string `json:"id"`
+	}{id})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	return nil
+}
+
+func (app *App) methodGetVenues(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	squadId := mux.Vars(r)["squadId"]
+
+	_, authLevel := app.checkAuthorization(r, "", squadId, squadAdmin|squadOwner|squadMember)
+	if authLevel == 0 {
+		err := fmt.Errorf("Current user is not authorized to get squad " + squadId + " venues")
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	venues, err := app.db.GetVenues(ctx, squadId)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(venues)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	return nil
+}
+
+func (app *App) methodUpdateVenue(w http.ResponseWriter, r *http.Request) error {
+	params := mux.Vars(r)
+	ctx := r.Context()
+
+	squadId := params["squadId"]
+	venueId := params["venueId"]
+
+	_, authLevel := app.checkAuthorization(r, "", squadId, squadAdmin|squadOwner)
+	if authLevel == 0 {
+		err := fmt.Errorf("Current user is not authorized to update venues of squad " + squadId)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	var data assist_db.EventLocation
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		err = fmt.Errorf("Failed to decode venue from the HTTP request: %w", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	venue, err := normalizeLocation(&data)
+	if err == nil && (venue == nil || venue.Venue == "") {
+		err = fmt.Errorf("Venue should have name")
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	// events keep copy of the location, so they are not changed
+	err = app.db.UpdateVenue(ctx, squadId, venueId, venue)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+func (app *App) methodDeleteVenue(w http.ResponseWriter, r *http.Request) error {
+	params := mux.Vars(r)
+	ctx := r.Context()
+
+	squadId := params["squadId"]
+	venueId := params["venueId"]
+
+	_, authLevel := app.checkAuthorization(r, "", squadId, squadAdmin|squadOwner)
+	if authLevel == 0 {
+		err := fmt.Errorf("Current user is not authorized to delete venues of squad " + squadId)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	err := app.db.DeleteVenue(ctx, squadId, venueId)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	return nil
+}
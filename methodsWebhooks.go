@@ -0,0 +1,193 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	assist_db "assist/db"
+
+	"github.com/gorilla/mux"
+)
+
+// amount of the latest deliveries shown in the webhook delivery log
+const webhookDeliveriesLimit = 100
+
+func (app *App) methodCreateWebhook(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	squadId := mux.Vars(r)["squadId"]
+
+	_, authLevel := app.checkAuthorization(r, "", squadId, squadAdmin|squadOwner)
+	if authLevel == 0 {
+		err := fmt.Errorf("Current user is not authorized to add webhook to squad " + squadId)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	var data struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		err = fmt.Errorf("Failed to decode webhook from the HTTP request: %w", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	data.URL = strings.TrimSpace(data.URL)
+	if u, e := url.Parse(data.URL); e != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		err = fmt.Errorf("Webhook URL should be http or https link, got %v", data.URL)
+	} else if e = app.hooks.checkHost(ctx, u.Hostname()); e != nil {
+		err = e
+	} else if len(data.Events) == 0 {
+		err = fmt.Errorf("Webhook should be subscribed to at least one event")
+	}
+	for _, event := range data.Events {
+		if !assist_db.HookEvents[event] {
+			err = fmt.Errorf("Unknown webhook event %v", event)
+		}
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	// secret is shown only once, receiver uses it to check signatures of payloads
+	secret := make([]byte, 32)
+	if _, err = rand.Read(secret); err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	hook := &assist_db.Webhook{URL: data.URL, Events: data.Events, Secret: hex.EncodeToString(secret), Created: time.Now()}
+	id, err := app.db.CreateWebhook(ctx, squadId, hook)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(struct {
+		ID     string `json:"id"`
+		Secret string `json:"secret"`
+	}{id, hook.Secret})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	return nil
+}
+
+func (app *App) methodGetWebhooks(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	squadId := mux.Vars(r)["squadId"]
+
+	_, authLevel := app.checkAuthorization(r, "", squadId, squadAdmin|squadOwner)
+	if authLevel == 0 {
+		err := fmt.Errorf("Current user is not authorized to get squad " + squadId + " webhooks")
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	hooks, err := app.db.GetWebhooks(ctx, squadId)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+	for _, h := range hooks {
+		h.Secret = ""
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(hooks)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	return nil
+}
+
+func (app *App) methodDeleteWebhook(w http.ResponseWriter, r *http.Request) error {
+	params := mux.Vars(r)
+	ctx := r.Context()
+
+	squadId := params["squadId"]
+	webhookId := params["webhookId"]
+
+	_, authLevel := app.checkAuthorization(r, "", squadId, squadAdmin|squadOwner)
+	if authLevel == 0 {
+		err := fmt.Errorf("Current user is not authorized to delete webhooks of squad " + squadId)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	err := app.db.DeleteWebhook(ctx, squadId, webhookId)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+func (app *App) methodGetWebhookDeliveries(w http.ResponseWriter, r *http.Request) error {
+	params := mux.Vars(r)
+	ctx := r.Context()
+
+	squadId := params["squadId"]
+	webhookId := params["webhookId"]
+
+	_, authLevel := app.checkAuthorization(r, "", squadId, squadAdmin|squadOwner)
+	if authLevel == 0 {
+		err := fmt.Errorf("Current user is not authorized to get webhooks of squad " + squadId)
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return err
+	}
+
+	// webhook should belong to the squad
+	_, err := app.db.GetWebhook(ctx, squadId, webhookId)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return err
+	}
+
+	deliveries, err := app.db.GetWebhookDeliveries(ctx, webhookId, webhookDeliveriesLimit)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(deliveries)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	return nil
+}
@@ -2,20 +2,29 @@ package main
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/textproto"
+	"net/url"
 	"os"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	assist_db "assist/db"
 
 	firebase "firebase.google.com/go"
-	"firebase.google.com/go/auth"
 	"github.com/gorilla/mux"
 )
 
@@ -25,7 +34,8 @@ var (
 	testSquadId     = "Super Huge Squad"
 	replicantsCount = 1000
 	maxThreadsCount = 8
-	adb             *assist_db.FirestoreDB
+	adb             assist_db.Store
+	app             *App
 	su              *SessionTestUtil
 	ctx             = context.Background()
 	router          = mux.NewRouter()
@@ -33,6 +43,7 @@ var (
 
 // Run flags
 var recreate = flag.Bool("recreate", false, "Set this flag to purge the database and create everything from scratch")
+var store = flag.String("store", "memory", "Storage backend to run tests against (memory, sqlite or firestore), memory and sqlite are always created from scratch")
 
 // Fake session objects
 type SessionTestUtil struct {
@@ -52,7 +63,7 @@ func (stu *SessionTestUtil) getCurrentUserID(r *http.Request) string {
 	return testUserId
 }
 
-func (stu *SessionTestUtil) getCurrentUserRecord(r *http.Request) (*auth.UserRecord, error) {
+func (stu *SessionTestUtil) getCurrentUserRecord(r *http.Request) (*AuthUserRecord, error) {
 
 	return nil, nil
 }
@@ -62,37 +73,60 @@ func TestInit(t *testing.T) {
 
 	t.Run("Init test app ", func(t *testing.T) {
 
-		assist_db.SetTestPrefix("testMethods_")
-		ctx := context.Background()
+		if *store == "memory" {
+			adb = assist_db.NewMemoryDB(false)
+			*recreate = true
+		} else if *store == "sqlite" {
+			var err error
+			adb, err = assist_db.NewSQLDB("sqlite3", ":memory:", false)
+			if err != nil {
+				log.Fatalf("Failed to init sqlite DB: %v", err)
+			}
+			*recreate = true
+		} else {
+			assist_db.SetTestPrefix("testMethods_")
+			ctx := context.Background()
 
-		// init fireapp
-		fireapp, err := firebase.NewApp(ctx, nil)
-		if err != nil {
-			log.Fatalf("firebase.NewApp: %v", err)
-		}
+			// init fireapp
+			fireapp, err := firebase.NewApp(ctx, nil)
+			if err != nil {
+				log.Fatalf("firebase.NewApp: %v", err)
+			}
 
-		// init firestore
-		adb, err = assist_db.NewFirestoreDB(fireapp, false)
-		if err != nil {
-			log.Fatalf("Failed to init database: %v", err)
+			// init firestore
+			adb, err = assist_db.NewFirestoreDB(fireapp, false)
+			if err != nil {
+				log.Fatalf("Failed to init database: %v", err)
+			}
 		}
 
 		// init session interfaces
 		su := &SessionTestUtil{}
 
-		app := App{
+		ntfs, err := InitNotifications(nil, adb, nil, false)
+		if err != nil {
+			log.Fatalf("Failed to init notifications: %v", err)
+		}
+
+		// test receivers listen on loopback
+		hooks := newWebhooks(adb, false)
+		hooks.allowPrivate = true
+
+		app = &App{
 			logWriter: os.Stderr,
 			db:        adb,
 			sd:        su,
+			ntfs:      ntfs,
+			hooks:     hooks,
 			dev:       false, // set to true if want logs
 		}
 
 		app.registerMethodHandlers(router)
 	})
 
-	if *recreate {
+	if fdb, ok := adb.(*assist_db.FirestoreDB); ok && *recreate {
 		t.Run("Clean test DB", func(t *testing.T) {
-			err := adb.DeleteCollectionRecurse(ctx, adb.Squads)
+			err := fdb.DeleteCollectionRecurse(ctx, fdb.Squads)
 			if err != nil {
 				t.Fatalf("Failed to clean test data: %v", err)
 			}
@@ -100,11 +134,14 @@ func TestInit(t *testing.T) {
 		})
 
 		t.Run("Create ALL_USERS squad", func(t *testing.T) {
-			_, err := adb.Squads.Doc(assist_db.ALL_USERS_SQUAD).Set(ctx, &struct{ Description string }{"Special squad with all users"})
+			_, err := fdb.Squads.Doc(assist_db.ALL_USERS_SQUAD).Set(ctx, &struct{ Description string }{"Special squad with all users"})
 			if err != nil {
 				t.Fatalf("Failed to touch ALL_SQUADS doc: %v", err)
 			}
 		})
+	}
+
+	if *recreate {
 
 		t.Run("Create test user", func(t *testing.T) {
 			userInfo := &assist_db.UserInfo{
@@ -154,7 +191,7 @@ func TestInit(t *testing.T) {
 						router.ServeHTTP(rr, req)
 
 						if rr.Result().StatusCode != 200 {
-							t.Fatalf("Failed to create squad: %v", rr.Result())
+							t.Errorf("Failed to create replicant: %v", rr.Result())
 						}
 					}
 				}()
@@ -171,6 +208,1988 @@ func TestInit(t *testing.T) {
 	}
 }
 
+func TestLocalAuth(t *testing.T) {
+	os.Setenv("SESSION_SECRET", "local-auth-test")
+	sessions := initSessionUtil(assist_db.NewMemoryDB(false), false)
+	local := newLocalAuthProvider(sessions, sessions.db, initMailer(false))
+	sessions.addProvider(local)
+
+	r := mux.NewRouter()
+	sessions.registerAuthHandlers(r)
+
+	post := func(path string, form string) *http.Response {
+		req, _ := http.NewRequest("POST", path, strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr.Result()
+	}
+
+	checkSession := func(res *http.Response) string {
+		req, _ := http.NewRequest("GET", "/home", nil)
+		for _, c := range res.Cookies() {
+			req.AddCookie(c)
+		}
+		token, err := sessions.getSessionToken(req)
+		if err != nil {
+			t.Fatalf("Session cookie is not valid: %v", err)
+		}
+		return token.UserId
+	}
+
+	var userId string
+	t.Run("Sign up", func(t *testing.T) {
+		res := post("/auth/local/signup", "email=Local@Mail.com&password=short&displayName=Local")
+		if res.StatusCode != http.StatusBadRequest {
+			t.Fatalf("Short password should be rejected, got %v", res.Status)
+		}
+
+		res = post("/auth/local/signup", "email=Local@Mail.com&password=password1&displayName=Local")
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("Failed to sign up: %v", res.Status)
+		}
+		if len(res.Cookies()) != 0 {
+			t.Fatalf("Session should not be started before email is verified")
+		}
+
+		c, err := sessions.db.GetCredentials(ctx, "local@mail.com")
+		if err != nil {
+			t.Fatalf("Failed to get credentials: %v", err)
+		}
+		userId = c.UserId
+
+		// duplicate email gets the same response to not disclose registered users
+		res = post("/auth/local/signup", "email=local@mail.com&password=password2&displayName=Other")
+		if res.StatusCode != http.StatusOK || len(res.Cookies()) != 0 {
+			t.Fatalf("Duplicate email should get the same response as new one, got %v", res.Status)
+		}
+		c, err = sessions.db.GetCredentials(ctx, "local@mail.com")
+		if err != nil || c.UserId != userId {
+			t.Fatalf("Existing credentials should be kept, got %v, %v", c, err)
+		}
+	})
+
+	t.Run("Login unverified", func(t *testing.T) {
+		res := post("/auth/local/login", "email=local@mail.com&password=wrong-password")
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("Wrong password should be rejected, got %v", res.Status)
+		}
+
+		res = post("/auth/local/login", "email=local@mail.com&password=password1")
+		if res.StatusCode != http.StatusForbidden {
+			t.Fatalf("Unverified email should be rejected, got %v", res.Status)
+		}
+	})
+
+	t.Run("Verify email", func(t *testing.T) {
+		token, err := sessions.sign(verifyEmailPurpose, &verifyEmailToken{"local@mail.com"}, verifyEmailExpiresIn)
+		if err != nil {
+			t.Fatalf("Failed to sign token: %v", err)
+		}
+
+		// session cookie can not be used as verification token
+		if err = sessions.verify(sessionCookie, token, &sessionToken{}); err == nil {
+			t.Fatalf("Token should be rejected for other purpose")
+		}
+
+		req, _ := http.NewRequest("GET", "/auth/local/verify?token="+token, nil)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if rr.Result().StatusCode != http.StatusFound {
+			t.Fatalf("Failed to verify email: %v", rr.Result().Status)
+		}
+
+		u, err := local.getUserRecord(ctx, userId)
+		if err != nil {
+			t.Fatalf("Failed to get user record: %v", err)
+		}
+		if !u.EmailVerified || u.DisplayName != "Local" {
+			t.Fatalf("Unexpected user record %+v", u)
+		}
+	})
+
+	t.Run("Login", func(t *testing.T) {
+		res := post("/auth/local/login", "email=LOCAL@mail.com&password=password1")
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("Failed to login: %v", res.Status)
+		}
+		if id := checkSession(res); id != userId {
+			t.Fatalf("Logged in as %v, expected %v", id, userId)
+		}
+	})
+}
+
+func TestRequestWorkflow(t *testing.T) {
+	queueId := "Two Level Approve"
+
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Create queue", func(t *testing.T) {
+		rr := do("POST", "/squads/"+testSquadId+"/queues", `{"id": "`+queueId+`", "workflow": {"initial": "Level2"}}`)
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("Queue with invalid workflow should be rejected, got %v", rr.Code)
+		}
+
+		rr = do("POST", "/squads/"+testSquadId+"/queues", `{"id": "`+queueId+`", "workflow": {
+			"initial": "Level1",
+			"states": [
+				{"name": "Level1", "status": 0},
+				{"name": "Level2", "status": 0},
+				{"name": "Processing", "status": 1},
+				{"name": "Completed", "status": 2}
+			],
+			"transitions": [
+				{"name": "Approve", "from": ["Level1"], "to": "Level2", "allowed": ["@admins"]},
+				{"name": "Approve", "from": ["Level2"], "to": "Processing", "allowed": ["finance"]},
+				{"name": "Complete", "from": ["Processing"], "to": "Completed", "allowed": ["@requester"]}
+			]}}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Failed to create queue: %v", rr.Body.String())
+		}
+	})
+
+	var requestId string
+	t.Run("Move request", func(t *testing.T) {
+		rr := do("POST", "/requests", `{"queueId": "`+queueId+`", "details": "New laptop"}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Failed to create request: %v", rr.Body.String())
+		}
+		res := struct {
+			RequestId string `json:"requestId"`
+			State     string `json:"state"`
+		}{}
+		json.NewDecoder(rr.Body).Decode(&res)
+		if res.State != "Level1" {
+			t.Fatalf("Request should start in Level1 state, got %v", res.State)
+		}
+		requestId = res.RequestId
+
+		if rr = do("PUT", "/requests/"+requestId, `{"state": "Processing"}`); rr.Code != http.StatusBadRequest {
+			t.Fatalf("Request should not skip approve level, got %v", rr.Code)
+		}
+		if rr = do("PUT", "/requests/"+requestId, `{"state": "Level2"}`); rr.Code != http.StatusOK {
+			t.Fatalf("Squad admin failed to approve request: %v", rr.Body.String())
+		}
+		// test user does not have finance tag
+		if rr = do("PUT", "/requests/"+requestId, `{"state": "Processing"}`); rr.Code != http.StatusUnauthorized {
+			t.Fatalf("Only finance should be able to approve request on second level, got %v", rr.Code)
+		}
+
+		request, err := adb.GetRequest(ctx, requestId)
+		if err != nil {
+			t.Fatalf("Failed to get request: %v", err)
+		}
+		if request.State != "Level2" || request.Status != assist_db.WaitingApprove {
+			t.Fatalf("Unexpected request state %v (%v)", request.State, request.Status)
+		}
+	})
+
+	t.Run("Get history", func(t *testing.T) {
+		rr := do("GET", "/requests/"+requestId+"/history", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Failed to get request history: %v", rr.Body.String())
+		}
+
+		var history []*assist_db.RequestHistoryEntry
+		json.NewDecoder(rr.Body).Decode(&history)
+		if len(history) != 2 || history[1].OldState != "Level1" || history[1].NewState != "Level2" || history[1].UserId != testUserId {
+			t.Fatalf("Unexpected request history %+v", history)
+		}
+	})
+
+	t.Run("Comments", func(t *testing.T) {
+		if rr := do("POST", "/requests/"+requestId+"/comments", `{"text": " "}`); rr.Code != http.StatusBadRequest {
+			t.Fatalf("Empty comment should be rejected, got %v", rr.Code)
+		}
+
+		rr := do("POST", "/requests/"+requestId+"/comments", `{"text": "Which model?"}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Failed to create comment: %v", rr.Body.String())
+		}
+		res := struct {
+			ID string `json:"id"`
+		}{}
+		json.NewDecoder(rr.Body).Decode(&res)
+
+		rr = do("GET", "/requests/"+requestId+"/comments", "")
+		var comments []*assist_db.RequestCommentRecord
+		json.NewDecoder(rr.Body).Decode(&comments)
+		if len(comments) != 1 || comments[0].ID != res.ID || comments[0].Text != "Which model?" || comments[0].UserId != testUserId {
+			t.Fatalf("Unexpected comments %+v", comments)
+		}
+
+		if rr = do("DELETE", "/requests/"+requestId+"/comments/"+res.ID, ""); rr.Code != http.StatusOK {
+			t.Fatalf("Failed to delete comment: %v", rr.Body.String())
+		}
+		if rr = do("DELETE", "/requests/"+requestId+"/comments/"+res.ID, ""); rr.Code != http.StatusBadRequest {
+			t.Fatalf("Deleted comment should not be found, got %v", rr.Code)
+		}
+	})
+
+	t.Run("Assignee", func(t *testing.T) {
+		if rr := do("PUT", "/requests/"+requestId+"/assignee", `{"assigneeId": "me"}`); rr.Code != http.StatusOK {
+			t.Fatalf("Squad admin failed to claim request: %v", rr.Body.String())
+		}
+		if rr := do("PUT", "/requests/"+requestId+"/assignee", `{"assigneeId": "NOT_A_MEMBER"}`); rr.Code != http.StatusBadRequest {
+			t.Fatalf("Request should not be assigned to non member, got %v", rr.Code)
+		}
+
+		request, err := adb.GetRequest(ctx, requestId)
+		if err != nil {
+			t.Fatalf("Failed to get request: %v", err)
+		}
+		if request.AssigneeId != testUserId {
+			t.Fatalf("Request should be assigned to %v, got %v", testUserId, request.AssigneeId)
+		}
+
+		if rr := do("DELETE", "/requests/"+requestId+"/assignee", ""); rr.Code != http.StatusOK {
+			t.Fatalf("Failed to unclaim request: %v", rr.Body.String())
+		}
+		request, _ = adb.GetRequest(ctx, requestId)
+		if request.AssigneeId != "" {
+			t.Fatalf("Request should not be assigned, got %v", request.AssigneeId)
+		}
+
+		if err = app.escalateOverdueRequests(ctx); err != nil {
+			t.Fatalf("Failed to escalate overdue requests: %v", err)
+		}
+	})
+}
+
+func TestRequestFields(t *testing.T) {
+	queueId := "Laptops"
+
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do("POST", "/squads/"+testSquadId+"/queues", `{"id": "`+queueId+`", "fields": [{"name": "os", "type": "select"}]}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("Queue with invalid fields should be rejected, got %v", rr.Code)
+	}
+
+	rr := do("POST", "/squads/"+testSquadId+"/queues", `{"id": "`+queueId+`", "fields": [
+		{"name": "model", "type": "text", "required": true},
+		{"name": "os", "type": "select", "options": ["Linux", "Windows"]}
+	]}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Failed to create queue: %v", rr.Body.String())
+	}
+
+	if rr = do("GET", "/queues/"+queueId, ""); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"model"`) {
+		t.Fatalf("Failed to get queue fields: %v", rr.Body.String())
+	}
+
+	if rr = do("POST", "/requests", `{"queueId": "`+queueId+`", "details": "New laptop", "fields": {"os": "Linux"}}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("Request without required field should be rejected, got %v", rr.Code)
+	}
+	for _, os := range []string{"Linux", "Windows"} {
+		if rr = do("POST", "/requests", `{"queueId": "`+queueId+`", "details": "New laptop", "fields": {"model": "X1", "os": "`+os+`"}}`); rr.Code != http.StatusOK {
+			t.Fatalf("Failed to create request: %v", rr.Body.String())
+		}
+	}
+
+	rr = do("GET", "/requests?status=Processing&queue="+queueId+"&field.os=Windows", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Failed to get requests: %v", rr.Body.String())
+	}
+	var requests []assist_db.RequestRecord
+	json.NewDecoder(rr.Body).Decode(&requests)
+	if len(requests) != 1 || requests[0].Fields["os"] != "Windows" {
+		t.Fatalf("Expected one request with Windows, got %+v", requests)
+	}
+
+	if rr = do("GET", "/requests?status=User&queue="+queueId+"&field.color=Black", ""); rr.Code != http.StatusBadRequest {
+		t.Fatalf("Filter by unknown field should be rejected, got %v", rr.Code)
+	}
+}
+
+func TestEventSeries(t *testing.T) {
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	today := time.Now().UTC().Format(assist_db.FieldDateLayout)
+	if rr := do("POST", "/events", `{"squadId": "`+testSquadId+`", "text": "Training", "date": "`+today+`T00:00:00Z", "rrule": "FREQ=YEARLY"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("Series with unsupported rule should be rejected, got %v", rr.Code)
+	}
+
+	rr := do("POST", "/events", `{"squadId": "`+testSquadId+`", "text": "Training", "timeFrom": "19:00", "date": "`+today+`T00:00:00Z", "rrule": "FREQ=WEEKLY"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Failed to create event series: %v", rr.Body.String())
+	}
+	res := struct {
+		ID       string `json:"id"`
+		SeriesId string `json:"seriesId"`
+	}{}
+	json.NewDecoder(rr.Body).Decode(&res)
+
+	events, err := adb.GetSeriesEvents(ctx, res.SeriesId)
+	if err != nil {
+		t.Fatalf("Failed to get series events: %v", err)
+	}
+	if len(events) != seriesHorizonDays/7 || events[0].ID != res.ID || events[1].TimeFrom != "19:00" {
+		t.Fatalf("Unexpected series events %+v", events)
+	}
+
+	if rr = do("POST", "/events/"+events[1].ID+"/participants/me?series=true", ""); rr.Code != http.StatusOK {
+		t.Fatalf("Failed to register for series: %v", rr.Body.String())
+	}
+	for i, e := range events {
+		_, err := adb.GetParticipantStatus(ctx, testUserId, e.ID)
+		if (i == 0) != (err != nil) {
+			t.Fatalf("User should be registered for occurrences after the first one only, occurrence %v: %v", i, err)
+		}
+	}
+
+	if rr = do("PUT", "/series/"+res.SeriesId+"/events/"+events[1].ID, `{"text": "Training (gym)"}`); rr.Code != http.StatusOK {
+		t.Fatalf("Failed to update occurrence: %v", rr.Body.String())
+	}
+	if rr = do("DELETE", "/events/"+events[3].ID, ""); rr.Code != http.StatusOK {
+		t.Fatalf("Failed to delete occurrence: %v", rr.Body.String())
+	}
+
+	if rr = do("PUT", "/series/"+res.SeriesId, `{"text": "Evening training", "timeFrom": "20:00", "rrule": "FREQ=WEEKLY;INTERVAL=2"}`); rr.Code != http.StatusOK {
+		t.Fatalf("Failed to update series: %v", rr.Body.String())
+	}
+	series, err := adb.GetEventSeries(ctx, res.SeriesId)
+	if err != nil {
+		t.Fatalf("Failed to get series: %v", err)
+	}
+	if series.Text != "Evening training" || series.Participants[testUserId] != assist_db.Going {
+		t.Fatalf("Unexpected series %+v", series)
+	}
+
+	// every second week plus the occurrence edited separately
+	events, _ = adb.GetSeriesEvents(ctx, res.SeriesId)
+	if len(events) != seriesHorizonDays/14+1 {
+		t.Fatalf("Unexpected series events %+v", events)
+	}
+	for _, e := range events {
+		if e.SeriesOverride && e.Text != "Training (gym)" || !e.SeriesOverride && (e.Text != "Evening training" || e.TimeFrom != "20:00") {
+			t.Fatalf("Unexpected occurrence %+v", e)
+		}
+	}
+
+	if rr = do("DELETE", "/series/"+res.SeriesId, ""); rr.Code != http.StatusOK {
+		t.Fatalf("Failed to cancel series: %v", rr.Body.String())
+	}
+	if events, _ = adb.GetSeriesEvents(ctx, res.SeriesId); len(events) != 0 {
+		t.Fatalf("Cancelled series should not have occurrences, got %+v", events)
+	}
+}
+
+func TestCalendarFeeds(t *testing.T) {
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format(assist_db.FieldDateLayout)
+	rr := do("POST", "/events", `{"squadId": "`+testSquadId+`", "text": "Party, bring snacks; or not", "timeFrom": "19:30", "timeTo": "01:00", "date": "`+tomorrow+`T00:00:00Z"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Failed to create event: %v", rr.Body.String())
+	}
+
+	rr = do("GET", "/users/me/calendars", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Failed to get calendar feeds: %v", rr.Body.String())
+	}
+	feeds := struct {
+		User   string            `json:"user"`
+		Squads map[string]string `json:"squads"`
+	}{}
+	json.NewDecoder(rr.Body).Decode(&feeds)
+	if feeds.Squads[testSquadId] == "" {
+		t.Fatalf("Squad feed should be available, got %+v", feeds)
+	}
+
+	day := strings.ReplaceAll(tomorrow, "-", "")
+	for _, feed := range []string{feeds.User, feeds.Squads[testSquadId]} {
+		rr = do("GET", strings.TrimPrefix(feed, "/methods"), "")
+		if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "text/calendar; charset=utf-8" {
+			t.Fatalf("Failed to get calendar feed %v: %v", feed, rr.Body.String())
+		}
+		ics := rr.Body.String()
+		for _, s := range []string{"BEGIN:VCALENDAR\r\n", "SUMMARY:" + testSquadId + ": Party\\, bring snacks\\; or not\r\n", "DTSTART:" + day + "T193000\r\n", "END:VCALENDAR\r\n"} {
+			if !strings.Contains(ics, s) {
+				t.Fatalf("Feed %v should contain %q, got %v", feed, s, ics)
+			}
+		}
+		// event ends after midnight
+		if strings.Contains(ics, "DTEND:"+day+"T010000") {
+			t.Fatalf("Event should end next day, got %v", ics)
+		}
+	}
+
+	if rr = do("GET", strings.TrimPrefix(feeds.User, "/methods"), ""); !strings.Contains(rr.Body.String(), "PARTSTAT=ACCEPTED:mailto:test@mail.com") {
+		t.Fatalf("User feed should contain owner status, got %v", rr.Body.String())
+	}
+	if rr = do("GET", "/calendar/users/"+testUserId+"/wrong.ics", ""); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("Feed with wrong token should be rejected, got %v", rr.Code)
+	}
+	// user provided values can not add properties or events to the feed
+	date := time.Date(2031, time.June, 1, 0, 0, 0, 0, time.UTC)
+	iw := &icsWriter{}
+	iw.event("EVENT", &assist_db.EventInfo{SquadId: testSquadId, Text: "Party\r\nBEGIN:VEVENT\rSUMMARY:Fake", Date: &date, Status: assist_db.Going}, "host", date,
+		&assist_db.UserInfo{DisplayName: "Evil\r\nBEGIN:VEVENT\r\nSUMMARY:Fake;\"x\",:", Email: "evil@mail.com\r\nBEGIN:VEVENT"})
+	ics := strings.ReplaceAll(iw.String(), "\r\n ", "") // unfold lines
+	if !strings.HasPrefix(ics, "BEGIN:VEVENT\r\n") || strings.Contains(ics, "\r\nBEGIN:VEVENT") || strings.Count(ics, "\r\nSUMMARY:") != 1 {
+		t.Fatalf("Feed should contain one event, got %v", ics)
+	}
+	if !strings.Contains(ics, "ATTENDEE;CN=\"EvilBEGIN:VEVENTSUMMARY:Fake;'x',:\";PARTSTAT=ACCEPTED:mailto:evil@mail.comBEGIN:VEVENT\r\n") {
+		t.Fatalf("Attendee name should be quoted and stripped of control characters, got %v", ics)
+	}
+
+	// squad feed token is valid only while user is a member of the squad
+	if rr = do("GET", strings.TrimPrefix(app.calendarFeedPath("squads", testSquadId, "NOT_A_MEMBER"), "/methods"), ""); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("Squad feed of non member should be rejected, got %v", rr.Code)
+	}
+}
+
+func TestEventLocation(t *testing.T) {
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	createEvent := func(body string) string {
+		t.Helper()
+		rr := do("POST", "/events", `{"squadId": "`+testSquadId+`", "text": "Party somewhere", "date": "2031-08-01T00:00:00Z", `+body+`}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Failed to create event: %v", rr.Body.String())
+		}
+		res := struct {
+			ID string `json:"id"`
+		}{}
+		json.NewDecoder(rr.Body).Decode(&res)
+		return res.ID
+	}
+	getLocation := func(eventId string) *assist_db.EventLocation {
+		t.Helper()
+		rr := do("GET", "/events/"+eventId, "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Failed to get event: %v", rr.Body.String())
+		}
+		res := struct {
+			Event assist_db.EventInfo `json:"event"`
+		}{}
+		json.NewDecoder(rr.Body).Decode(&res)
+		return res.Event.Location
+	}
+
+	for _, body := range []string{`{"address": "1 Main St"}`, `{"venue": "Hall", "lat": 55.75}`, `{"venue": "Hall", "lat": 95, "lon": 37.62}`, `{"venue": "Hall", "meetingUrl": "ftp://example.com"}`} {
+		if rr := do("POST", "/squads/"+testSquadId+"/venues", body); rr.Code != http.StatusBadRequest {
+			t.Fatalf("Venue %v should be rejected, got %v", body, rr.Code)
+		}
+	}
+
+	rr := do("POST", "/squads/"+testSquadId+"/venues", `{"venue": " Main Hall ", "address": "1 Main St", "lat": 55.75, "lon": 37.62}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Failed to create venue: %v", rr.Body.String())
+	}
+	res := struct {
+		ID string `json:"id"`
+	}{}
+	json.NewDecoder(rr.Body).Decode(&res)
+	venueId := res.ID
+
+	rr = do("GET", "/squads/"+testSquadId+"/venues", "")
+	venues := []*assist_db.VenueRecord{}
+	json.NewDecoder(rr.Body).Decode(&venues)
+	if rr.Code != http.StatusOK || len(venues) != 1 || venues[0].ID != venueId || venues[0].Venue != "Main Hall" || *venues[0].Lon != 37.62 {
+		t.Fatalf("Wrong venues %v", rr.Body.String())
+	}
+
+	if rr = do("POST", "/events", `{"squadId": "`+testSquadId+`", "text": "Party", "date": "2031-08-01T00:00:00Z", "venueId": "unknown"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("Event with unknown venue should be rejected, got %v", rr.Code)
+	}
+	atVenue := createEvent(`"venueId": "` + venueId + `"`)
+	online := createEvent(`"location": {"meetingUrl": "https://meet.example.com/abc"}`)
+
+	// event keeps copy of the venue
+	if rr = do("PUT", "/squads/"+testSquadId+"/venues/"+venueId, `{"venue": "Old Hall"}`); rr.Code != http.StatusOK {
+		t.Fatalf("Failed to update venue: %v", rr.Body.String())
+	}
+	if rr = do("PUT", "/squads/"+testSquadId+"/venues/unknown", `{"venue": "Old Hall"}`); rr.Code != http.StatusNotFound {
+		t.Fatalf("Update of unknown venue should return 404, got %v", rr.Code)
+	}
+	if l := getLocation(atVenue); l == nil || l.Venue != "Main Hall" || l.Address != "1 Main St" || *l.Lat != 55.75 {
+		t.Fatalf("Wrong event location %+v", l)
+	}
+
+	rr = do("GET", "/users/me/calendars", "")
+	feeds := struct {
+		Squads map[string]string `json:"squads"`
+	}{}
+	json.NewDecoder(rr.Body).Decode(&feeds)
+	ics := do("GET", strings.TrimPrefix(feeds.Squads[testSquadId], "/methods"), "").Body.String()
+	for _, s := range []string{"LOCATION:Main Hall\\, 1 Main St\r\n", "GEO:55.75;37.62\r\n", "LOCATION:https://meet.example.com/abc\r\n", "URL:https://meet.example.com/abc\r\n"} {
+		if !strings.Contains(ics, s) {
+			t.Fatalf("Squad feed should contain %q, got %v", s, ics)
+		}
+	}
+
+	rr = do("PATCH", "/events/"+online, `{"venueId": "`+venueId+`"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Failed to update event: %v", rr.Body.String())
+	}
+	updated := struct {
+		Changes []eventChange `json:"changes"`
+	}{}
+	json.NewDecoder(rr.Body).Decode(&updated)
+	if len(updated.Changes) != 1 || updated.Changes[0] != (eventChange{"location", "online https://meet.example.com/abc", "Old Hall"}) {
+		t.Fatalf("Wrong changes %+v", updated.Changes)
+	}
+
+	if rr = do("PATCH", "/events/"+online, `{"location": {}}`); rr.Code != http.StatusOK || getLocation(online) != nil {
+		t.Fatalf("Empty location should remove it: %v", rr.Body.String())
+	}
+
+	for _, eventId := range []string{atVenue, online} {
+		if rr = do("DELETE", "/events/"+eventId, ""); rr.Code != http.StatusOK {
+			t.Fatalf("Failed to delete event: %v", rr.Body.String())
+		}
+	}
+	if rr = do("DELETE", "/squads/"+testSquadId+"/venues/"+venueId, ""); rr.Code != http.StatusOK {
+		t.Fatalf("Failed to delete venue: %v", rr.Body.String())
+	}
+	rr = do("GET", "/squads/"+testSquadId+"/venues", "")
+	if strings.TrimSpace(rr.Body.String()) != "[]" {
+		t.Fatalf("Venue should be deleted, got %v", rr.Body.String())
+	}
+}
+
+func TestEventTemplates(t *testing.T) {
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	createEvent := func(body string) (string, string) {
+		t.Helper()
+		rr := do("POST", "/events", `{"squadId": "`+testSquadId+`", `+body+`}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Failed to create event: %v", rr.Body.String())
+		}
+		res := struct {
+			ID       string `json:"id"`
+			SeriesId string `json:"seriesId"`
+		}{}
+		json.NewDecoder(rr.Body).Decode(&res)
+		return res.ID, res.SeriesId
+	}
+
+	// replicants with the tag are registered to events created from the template
+	replicants, err := adb.GetSquadMembers(ctx, testSquadId, nil, &map[string]string{"Keys": "Rep"})
+	if err != nil || len(replicants) < 3 {
+		t.Fatalf("Failed to get replicants: %v", err)
+	}
+	if err = adb.CreateTag(ctx, testSquadId, &assist_db.Tag{Name: "regulars"}); err != nil {
+		t.Fatalf("Failed to create tag: %v", err)
+	}
+	defer adb.DeleteTag(ctx, testSquadId, "regulars")
+	for _, r := range replicants[:2] {
+		if _, err = adb.SetSquadMemberTag(ctx, r.ID, testSquadId, "regulars", ""); err != nil {
+			t.Fatalf("Failed to set member tag: %v", err)
+		}
+		defer adb.DeleteSquadMemberTag(ctx, r.ID, testSquadId, "regulars", "")
+	}
+
+	for _, body := range []string{`{"name": " "}`, `{"name": "Training", "capacity": -1}`, `{"name": "Training", "timeFrom": "7pm"}`, `{"name": "Training", "reminders": [0]}`, `{"name": "Training", "location": {"meetingUrl": "meet"}}`} {
+		if rr := do("POST", "/squads/"+testSquadId+"/eventTemplates", body); rr.Code != http.StatusBadRequest {
+			t.Fatalf("Event template %v should be rejected, got %v", body, rr.Code)
+		}
+	}
+
+	rr := do("POST", "/squads/"+testSquadId+"/eventTemplates", `{"name": "Training", "text": "Weekly training", "timeFrom": "19:00", "timeTo": "21:00", "capacity": 10, "reminders": [60], "location": {"venue": "Gym"}, "tags": ["regulars", " regulars", ""]}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Failed to create event template: %v", rr.Body.String())
+	}
+	res := struct {
+		ID string `json:"id"`
+	}{}
+	json.NewDecoder(rr.Body).Decode(&res)
+	templateId := res.ID
+
+	rr = do("GET", "/squads/"+testSquadId+"/eventTemplates/"+templateId, "")
+	template := assist_db.EventTemplateRecord{}
+	json.NewDecoder(rr.Body).Decode(&template)
+	if rr.Code != http.StatusOK || template.ID != templateId || template.Capacity != 10 || len(template.Tags) != 1 || template.Location.Venue != "Gym" {
+		t.Fatalf("Wrong event template %v", rr.Body.String())
+	}
+	if rr = do("GET", "/squads/"+testSquadId+"/eventTemplates/unknown", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("Unknown event template should return 404, got %v", rr.Code)
+	}
+	if rr = do("PUT", "/squads/"+testSquadId+"/eventTemplates/unknown", `{"name": "Training"}`); rr.Code != http.StatusNotFound {
+		t.Fatalf("Update of unknown event template should return 404, got %v", rr.Code)
+	}
+
+	if rr = do("POST", "/events", `{"squadId": "`+testSquadId+`", "date": "2031-09-01T00:00:00Z", "templateId": "unknown"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("Event with unknown template should be rejected, got %v", rr.Code)
+	}
+
+	// request overrides template details
+	eventId, _ := createEvent(`"date": "2031-09-01T00:00:00Z", "templateId": "` + templateId + `", "timeTo": "22:00", "capacity": 1`)
+	event, err := adb.GetEvent(ctx, eventId)
+	if err != nil || event.Text != "Weekly training" || event.TimeFrom != "19:00" || event.TimeTo != "22:00" || event.Capacity != 1 || len(event.Reminders) != 1 || event.Reminders[0] != 60 || event.Location.Venue != "Gym" {
+		t.Fatalf("Wrong event created from template %+v (%v)", event, err)
+	}
+	statuses := make(map[assist_db.ParticipantStatusType]int)
+	for _, r := range replicants[:3] {
+		status, err := adb.GetParticipantStatus(ctx, r.ID, eventId)
+		if err == nil {
+			statuses[status]++
+		}
+	}
+	if statuses[assist_db.Going] != 1 || statuses[assist_db.Waitlisted] != 1 || len(statuses) != 2 {
+		t.Fatalf("Tag members should be registered up to capacity, got %v", statuses)
+	}
+
+	// tag members are registered to every occurrence of the series
+	if rr = do("PUT", "/squads/"+testSquadId+"/eventTemplates/"+templateId, `{"name": "Training", "text": "Training", "tags": ["regulars"]}`); rr.Code != http.StatusOK {
+		t.Fatalf("Failed to update event template: %v", rr.Body.String())
+	}
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format(assist_db.FieldDateLayout)
+	firstId, seriesId := createEvent(`"date": "` + tomorrow + `T00:00:00Z", "rrule": "FREQ=WEEKLY", "templateId": "` + templateId + `", "location": {"venue": "Park"}`)
+	series, err := adb.GetEventSeries(ctx, seriesId)
+	if err != nil || series.Text != "Training" || series.Location.Venue != "Park" || len(series.Participants) != 2 || series.Participants[replicants[0].ID] != assist_db.Going {
+		t.Fatalf("Wrong series created from template %+v (%v)", series, err)
+	}
+	if status, err := adb.GetParticipantStatus(ctx, replicants[1].ID, firstId); err != nil || status != assist_db.Going {
+		t.Fatalf("Tag member should be going to the first occurrence, got %v (%v)", status, err)
+	}
+
+	if rr = do("DELETE", "/series/"+seriesId, ""); rr.Code != http.StatusOK {
+		t.Fatalf("Failed to delete event series: %v", rr.Body.String())
+	}
+	if rr = do("DELETE", "/events/"+eventId, ""); rr.Code != http.StatusOK {
+		t.Fatalf("Failed to delete event: %v", rr.Body.String())
+	}
+	if rr = do("DELETE", "/squads/"+testSquadId+"/eventTemplates/"+templateId, ""); rr.Code != http.StatusOK {
+		t.Fatalf("Failed to delete event template: %v", rr.Body.String())
+	}
+	rr = do("GET", "/squads/"+testSquadId+"/eventTemplates", "")
+	if strings.TrimSpace(rr.Body.String()) != "[]" {
+		t.Fatalf("Event template should be deleted, got %v", rr.Body.String())
+	}
+}
+
+func TestInviteParticipants(t *testing.T) {
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	statusOf := func(userId string, eventId string) assist_db.ParticipantStatusType {
+		t.Helper()
+		status, err := adb.GetParticipantStatus(ctx, userId, eventId)
+		if err != nil {
+			t.Fatalf("Failed to get participant status: %v", err)
+		}
+		return status
+	}
+
+	replicants, err := adb.GetSquadMembers(ctx, testSquadId, nil, &map[string]string{"Keys": "Rep"})
+	if err != nil || len(replicants) < 4 {
+		t.Fatalf("Failed to get replicants: %v", err)
+	}
+	if err = adb.CreateTag(ctx, testSquadId, &assist_db.Tag{Name: "team", Values: map[string]int64{"red": 0, "blue": 0}}); err != nil {
+		t.Fatalf("Failed to create tag: %v", err)
+	}
+	defer adb.DeleteTag(ctx, testSquadId, "team")
+	for i, r := range replicants[:4] {
+		team := "red"
+		if i == 3 {
+			team = "blue"
+		}
+		if _, err = adb.SetSquadMemberTag(ctx, r.ID, testSquadId, "team", team); err != nil {
+			t.Fatalf("Failed to set member tag: %v", err)
+		}
+		defer adb.DeleteSquadMemberTag(ctx, r.ID, testSquadId, "team", team)
+	}
+
+	if rr := do("POST", "/events", `{"squadId": "`+testSquadId+`", "text": "Red team match", "date": "2031-10-01T00:00:00Z", "inviteTag": "team/red", "inviteStatus": 5}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("Invitation with wrong status should be rejected, got %v", rr.Code)
+	}
+	rr := do("POST", "/events", fmt.Sprintf(`{"squadId": "%v", "text": "Red team match", "date": "2031-10-01T00:00:00Z", "inviteTag": "team/red", "inviteStatus": %d}`, testSquadId, assist_db.Applied))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Failed to create event: %v", rr.Body.String())
+	}
+	res := struct {
+		ID       string                `json:"id"`
+		Failures []*participantFailure `json:"failures"`
+	}{}
+	json.NewDecoder(rr.Body).Decode(&res)
+	eventId := res.ID
+	defer do("DELETE", "/events/"+eventId, "")
+	if len(res.Failures) != 0 {
+		t.Fatalf("Unexpected failures %+v", res.Failures)
+	}
+	for i, r := range replicants[:3] {
+		if status := statusOf(r.ID, eventId); status != assist_db.Applied {
+			t.Fatalf("Replicant %v should be applied, got %v", i, status)
+		}
+	}
+	if _, err = adb.GetParticipantStatus(ctx, replicants[3].ID, eventId); err == nil {
+		t.Fatalf("Member without the tag should not be registered")
+	}
+
+	rr = do("POST", "/events", `{"squadId": "`+testSquadId+`", "text": "Blue team match", "date": "2031-10-02T00:00:00Z"}`)
+	json.NewDecoder(rr.Body).Decode(&res)
+	eventId = res.ID
+	defer do("DELETE", "/events/"+eventId, "")
+
+	for _, body := range []string{`{"tag": " "}`, `{"tag": "team/red", "status": 3}`} {
+		if rr = do("POST", "/events/"+eventId+"/participants", body); rr.Code != http.StatusBadRequest {
+			t.Fatalf("Invitation %v should be rejected, got %v", body, rr.Code)
+		}
+	}
+
+	// members already registered keep their status
+	if err = adb.RegisterParticipants(ctx, []string{replicants[0].ID}, eventId, &assist_db.EventInfo{SquadId: testSquadId}, assist_db.NotGoing); err != nil {
+		t.Fatalf("Failed to register participant: %v", err)
+	}
+	rr = do("POST", "/events/"+eventId+"/participants", fmt.Sprintf(`{"tag": "team/red", "status": %d}`, assist_db.Going))
+	invited := struct {
+		Registered []string              `json:"registered"`
+		Failures   []*participantFailure `json:"failures"`
+	}{}
+	json.NewDecoder(rr.Body).Decode(&invited)
+	if rr.Code != http.StatusOK || len(invited.Registered) != 2 || len(invited.Failures) != 0 {
+		t.Fatalf("Wrong invitation result %v", rr.Body.String())
+	}
+	if statusOf(replicants[0].ID, eventId) != assist_db.NotGoing || statusOf(replicants[1].ID, eventId) != assist_db.Going {
+		t.Fatalf("Wrong participant statuses after invitation")
+	}
+
+	// failures are reported per member, the others are registered anyway
+	event, err := adb.GetEvent(ctx, eventId)
+	if err != nil {
+		t.Fatalf("Failed to get event: %v", err)
+	}
+	registered, failures, err := app.inviteParticipants(ctx, eventId, event, []string{replicants[3].ID, "UNKNOWN", replicants[1].ID}, assist_db.Going, false)
+	if err != nil || len(registered) != 1 || registered[0] != replicants[3].ID || len(failures) != 2 || failures[0].UserId != "UNKNOWN" || failures[1].UserId != replicants[1].ID {
+		t.Fatalf("Wrong invitation result %v %+v (%v)", registered, failures, err)
+	}
+}
+
+func TestEventCapacity(t *testing.T) {
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format(assist_db.FieldDateLayout)
+	if rr := do("POST", "/events", `{"squadId": "`+testSquadId+`", "text": "Small party", "capacity": -1, "date": "`+tomorrow+`T00:00:00Z"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("Event with negative capacity should be rejected, got %v", rr.Code)
+	}
+	rr := do("POST", "/events", `{"squadId": "`+testSquadId+`", "text": "Small party", "capacity": 1, "date": "`+tomorrow+`T00:00:00Z"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Failed to create event: %v", rr.Body.String())
+	}
+	res := struct {
+		ID string `json:"id"`
+	}{}
+	json.NewDecoder(rr.Body).Decode(&res)
+
+	filter := map[string]string{"Keys": "Rep"}
+	members, err := adb.GetSquadMembers(ctx, testSquadId, nil, &filter)
+	if err != nil || len(members) < 2 {
+		t.Fatalf("Failed to get replicants: %v", err)
+	}
+
+	for i, expected := range []assist_db.ParticipantStatusType{assist_db.Going, assist_db.Waitlisted} {
+		rr = do("POST", "/events/"+res.ID+"/participants/"+members[i].ID, "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Failed to register participant: %v", rr.Body.String())
+		}
+		status := struct {
+			Status assist_db.ParticipantStatusType `json:"status"`
+		}{}
+		json.NewDecoder(rr.Body).Decode(&status)
+		if status.Status != expected {
+			t.Fatalf("Participant %v should get status %v, got %v", i, expected, status.Status)
+		}
+	}
+
+	if rr = do("DELETE", "/events/"+res.ID+"/participants/"+members[0].ID, ""); rr.Code != http.StatusOK {
+		t.Fatalf("Failed to remove participant: %v", rr.Body.String())
+	}
+	if status, err := adb.GetParticipantStatus(ctx, members[1].ID, res.ID); err != nil || status != assist_db.Going {
+		t.Fatalf("Waitlisted participant should be promoted, got %v (%v)", status, err)
+	}
+
+	// no-show frees the place for the waitlist, participant changing mind is waitlisted
+	if rr = do("POST", "/events/"+res.ID+"/participants/"+members[0].ID, ""); rr.Code != http.StatusOK {
+		t.Fatalf("Failed to register participant: %v", rr.Body.String())
+	}
+	if rr = do("PATCH", "/events/"+res.ID+"/participants/"+members[1].ID, fmt.Sprintf(`{"status": %d}`, assist_db.NoShow)); rr.Code != http.StatusOK {
+		t.Fatalf("Failed to change participant status: %v", rr.Body.String())
+	}
+	if status, err := adb.GetParticipantStatus(ctx, members[0].ID, res.ID); err != nil || status != assist_db.Going {
+		t.Fatalf("Waitlisted participant should be promoted after no-show, got %v (%v)", status, err)
+	}
+	rr = do("PATCH", "/events/"+res.ID+"/participants/"+members[1].ID, fmt.Sprintf(`{"status": %d}`, assist_db.Going))
+	changed := struct {
+		Status assist_db.ParticipantStatusType `json:"status"`
+	}{}
+	json.NewDecoder(rr.Body).Decode(&changed)
+	if rr.Code != http.StatusOK || changed.Status != assist_db.Waitlisted {
+		t.Fatalf("Participant going to full event should be waitlisted, got %v %v", rr.Code, changed.Status)
+	}
+
+	if rr = do("DELETE", "/events/"+res.ID, ""); rr.Code != http.StatusOK {
+		t.Fatalf("Failed to delete event: %v", rr.Body.String())
+	}
+}
+
+func TestEventReminders(t *testing.T) {
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// far enough not to get reminders of events created by other tests
+	date := time.Now().UTC().AddDate(1, 0, 0).Format(assist_db.FieldDateLayout)
+	if rr := do("POST", "/events", `{"squadId": "`+testSquadId+`", "text": "Reminded party", "reminders": [0], "date": "`+date+`T00:00:00Z"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("Event with invalid reminder should be rejected, got %v", rr.Code)
+	}
+	rr := do("POST", "/events", `{"squadId": "`+testSquadId+`", "text": "Reminded party", "timeFrom": "10:00", "reminders": [60, 1440], "date": "`+date+`T00:00:00Z"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Failed to create event: %v", rr.Body.String())
+	}
+	res := struct {
+		ID string `json:"id"`
+	}{}
+	json.NewDecoder(rr.Body).Decode(&res)
+
+	if rr = do("POST", "/events/"+res.ID+"/participants/"+testUserId, ""); rr.Code != http.StatusOK {
+		t.Fatalf("Failed to register participant: %v", rr.Body.String())
+	}
+
+	start, _ := time.Parse("2006-01-02 15:04", date+" 10:00")
+	var now time.Time
+	rs := newReminderScheduler(adb, app.ntfs)
+	rs.now = func() time.Time { return now }
+	rs.location = time.UTC
+
+	notifications := app.ntfs.GetNotificationsCount(testUserId)
+	for _, step := range []struct {
+		now  time.Time
+		sent int
+	}{
+		{start.Add(-25 * time.Hour), 0},
+		{start.Add(-23 * time.Hour), 1},
+		{start.Add(-22 * time.Hour), 0},
+		{start.Add(-30 * time.Minute), 1},
+		{start.Add(-10 * time.Minute), 0},
+		{start.Add(time.Minute), 0},
+	} {
+		now = step.now
+		sent, err := rs.sendReminders(ctx)
+		if err != nil {
+			t.Fatalf("Failed to send reminders: %v", err)
+		}
+		if sent != step.sent {
+			t.Fatalf("Expected %v reminders sent at %v, got %v", step.sent, now, sent)
+		}
+	}
+	if n := app.ntfs.GetNotificationsCount(testUserId); n != notifications+2 {
+		t.Fatalf("Expected 2 reminders to be received, got %v", n-notifications)
+	}
+
+	// scheduler started again does not repeat sent reminders
+	rs = newReminderScheduler(adb, app.ntfs)
+	rs.now = func() time.Time { return start.Add(-30 * time.Minute) }
+	rs.location = time.UTC
+	if sent, err := rs.sendReminders(ctx); err != nil || sent != 0 {
+		t.Fatalf("Reminders should not be sent again after restart, sent %v (%v)", sent, err)
+	}
+
+	if rr = do("DELETE", "/events/"+res.ID, ""); rr.Code != http.StatusOK {
+		t.Fatalf("Failed to delete event: %v", rr.Body.String())
+	}
+}
+
+func TestUpdateEvent(t *testing.T) {
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do("POST", "/events", `{"squadId": "`+testSquadId+`", "text": "Party to move", "date": "2031-07-01T00:00:00Z", "timeFrom": "18:00"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Failed to create event: %v", rr.Body.String())
+	}
+	res := struct {
+		ID string `json:"id"`
+	}{}
+	json.NewDecoder(rr.Body).Decode(&res)
+	eventId := res.ID
+
+	if rr = do("POST", "/events/"+eventId+"/participants/"+testUserId, ""); rr.Code != http.StatusOK {
+		t.Fatalf("Failed to register participant: %v", rr.Body.String())
+	}
+
+	for _, body := range []string{`{"text": " "}`, `{"timeFrom": "6pm"}`, `garbage`} {
+		if rr = do("PATCH", "/events/"+eventId, body); rr.Code != http.StatusBadRequest {
+			t.Fatalf("Update %v should be rejected, got %v", body, rr.Code)
+		}
+	}
+	if rr = do("PATCH", "/events/unknown", `{"text": "Party"}`); rr.Code != http.StatusNotFound {
+		t.Fatalf("Update of unknown event should return 404, got %v", rr.Code)
+	}
+
+	notifications := app.ntfs.GetNotificationsCount(testUserId)
+
+	rr = do("PATCH", "/events/"+eventId, `{"date": "2031-07-02T00:00:00Z", "timeFrom": "19:00", "timeTo": "21:00"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Failed to update event: %v", rr.Body.String())
+	}
+	updated := struct {
+		assist_db.EventInfo
+		Changes []eventChange `json:"changes"`
+	}{}
+	json.NewDecoder(rr.Body).Decode(&updated)
+	if updated.Text != "Party to move" || updated.Date.Format(assist_db.FieldDateLayout) != "2031-07-02" || len(updated.Changes) != 2 ||
+		updated.Changes[0] != (eventChange{"date", "Tue, Jul 1", "Wed, Jul 2"}) || updated.Changes[1] != (eventChange{"time", "18:00", "19:00 - 21:00"}) {
+		t.Fatalf("Wrong updated event %+v", updated)
+	}
+
+	// participants are notified in background
+	var changed *assist_db.NotificationRecord
+	for i := 0; i < 100 && changed == nil; i++ {
+		if app.ntfs.GetNotificationsCount(testUserId) > notifications {
+			ns, err := app.ntfs.GetNotifications(ctx, testUserId, nil, true)
+			if err != nil {
+				t.Fatalf("Failed to get notifications: %v", err)
+			}
+			for _, n := range ns {
+				if n.Title == "Event Changed" && strings.Contains(n.Text, "Party to move") {
+					changed = n
+					break
+				}
+			}
+		}
+		if changed == nil {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if changed == nil {
+		t.Fatalf("Participant going to the event should be notified")
+	}
+	if !strings.Contains(changed.Text, "date: Tue, Jul 1 -> Wed, Jul 2") || !strings.Contains(changed.Text, "time: 18:00 -> 19:00 - 21:00") {
+		t.Fatalf("Wrong notification %+v", changed)
+	}
+
+	// participant copy of the event is updated as well
+	events, err := adb.GetUserEvents(ctx, testUserId, 100)
+	if err != nil {
+		t.Fatalf("Failed to get user events: %v", err)
+	}
+	found := false
+	for _, e := range events {
+		if e.Text == "Party to move" {
+			found = true
+			if e.Date.Format(assist_db.FieldDateLayout) != "2031-07-02" || e.TimeFrom != "19:00" || e.TimeTo != "21:00" {
+				t.Fatalf("Participant copy of the event is not updated: %+v", e)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("Event is not among user events")
+	}
+
+	// nothing changed, nobody is notified
+	notifications = app.ntfs.GetNotificationsCount(testUserId)
+	rr = do("PATCH", "/events/"+eventId, `{"text": "Party to move"}`)
+	if rr.Code != http.StatusOK || app.ntfs.GetNotificationsCount(testUserId) != notifications {
+		t.Fatalf("Update without changes should not notify participants: %v", rr.Body.String())
+	}
+
+	if rr = do("DELETE", "/events/"+eventId, ""); rr.Code != http.StatusOK {
+		t.Fatalf("Failed to delete event: %v", rr.Body.String())
+	}
+}
+
+func TestEventCheckin(t *testing.T) {
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	createEvent := func(date time.Time) string {
+		t.Helper()
+		rr := do("POST", "/events", `{"squadId": "`+testSquadId+`", "text": "Party with check-in", "date": "`+date.Format(assist_db.FieldDateLayout)+`T00:00:00Z"}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Failed to create event: %v", rr.Body.String())
+		}
+		res := struct {
+			ID string `json:"id"`
+		}{}
+		json.NewDecoder(rr.Body).Decode(&res)
+		return res.ID
+	}
+	today := createEvent(time.Now().UTC())
+	tomorrow := createEvent(time.Now().UTC().AddDate(0, 0, 1))
+
+	filter := map[string]string{"Keys": "Rep"}
+	members, err := adb.GetSquadMembers(ctx, testSquadId, nil, &filter)
+	if err != nil || len(members) < 1 {
+		t.Fatalf("Failed to get replicants: %v", err)
+	}
+
+	if rr := do("GET", "/events/"+today+"/checkin", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("User not participating in the event should not get check-in token, got %v", rr.Code)
+	}
+
+	tokens := make(map[string]string)
+	for _, eventId := range []string{today, tomorrow} {
+		for _, userId := range []string{testUserId, members[0].ID} {
+			if rr := do("POST", "/events/"+eventId+"/participants/"+userId, ""); rr.Code != http.StatusOK {
+				t.Fatalf("Failed to register participant: %v", rr.Body.String())
+			}
+		}
+
+		rr := do("GET", "/events/"+eventId+"/checkin", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Failed to get check-in token: %v", rr.Body.String())
+		}
+		res := struct {
+			Token string `json:"token"`
+			Path  string `json:"path"`
+		}{}
+		json.NewDecoder(rr.Body).Decode(&res)
+		if res.Token == "" || !strings.HasSuffix(res.Path, "?checkin="+res.Token) {
+			t.Fatalf("Wrong check-in token %+v", res)
+		}
+		tokens[eventId] = res.Token
+	}
+
+	for _, token := range []string{"", "garbage", tokens[tomorrow], tokens[today] + "x"} {
+		if rr := do("POST", "/events/"+today+"/checkin", `{"token": "`+token+`"}`); rr.Code != http.StatusBadRequest {
+			t.Fatalf("Token '%v' should be rejected, got %v", token, rr.Code)
+		}
+	}
+
+	for _, already := range []bool{false, true} {
+		rr := do("POST", "/events/"+today+"/checkin", `{"token": "`+tokens[today]+`"}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Failed to check in: %v", rr.Body.String())
+		}
+		res := struct {
+			UserId           string                          `json:"userId"`
+			Status           assist_db.ParticipantStatusType `json:"status"`
+			AlreadyCheckedIn bool                            `json:"alreadyCheckedIn"`
+		}{}
+		json.NewDecoder(rr.Body).Decode(&res)
+		if res.UserId != testUserId || res.Status != assist_db.Attended || res.AlreadyCheckedIn != already {
+			t.Fatalf("Wrong check-in result %+v", res)
+		}
+	}
+
+	if rr := do("POST", "/events/"+tomorrow+"/noShows", ""); rr.Code != http.StatusBadRequest {
+		t.Fatalf("No-shows should not be marked before the event, got %v", rr.Code)
+	}
+	rr := do("POST", "/events/"+today+"/noShows", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Failed to mark no-shows: %v", rr.Body.String())
+	}
+	res := struct {
+		Marked int `json:"marked"`
+	}{}
+	json.NewDecoder(rr.Body).Decode(&res)
+	if res.Marked != 1 {
+		t.Fatalf("Expected 1 no-show, got %v", res.Marked)
+	}
+	for userId, expected := range map[string]assist_db.ParticipantStatusType{testUserId: assist_db.Attended, members[0].ID: assist_db.NoShow} {
+		if status, err := adb.GetParticipantStatus(ctx, userId, today); err != nil || status != expected {
+			t.Fatalf("User %v should be %v, got %v (%v)", userId, expected, status, err)
+		}
+	}
+
+	for _, eventId := range []string{today, tomorrow} {
+		if rr = do("DELETE", "/events/"+eventId, ""); rr.Code != http.StatusOK {
+			t.Fatalf("Failed to delete event: %v", rr.Body.String())
+		}
+	}
+}
+
+func TestBuildAttendanceReport(t *testing.T) {
+	march := time.Date(2031, time.March, 1, 0, 0, 0, 0, time.UTC)
+	april := time.Date(2031, time.April, 1, 0, 0, 0, 0, time.UTC)
+	records := []*assist_db.AttendanceRecord{}
+	for i, statuses := range [][]assist_db.ParticipantStatusType{
+		{assist_db.Attended, assist_db.NoShow, assist_db.Going},
+		{assist_db.Attended, assist_db.NoShow, assist_db.NotGoing},
+		{assist_db.NoShow, assist_db.NoShow, assist_db.Attended},
+	} {
+		date := march.AddDate(0, 0, i*7)
+		if i == 2 {
+			date = april
+		}
+		for j, status := range statuses {
+			records = append(records, &assist_db.AttendanceRecord{
+				EventId:     fmt.Sprintf("event %v", i),
+				Date:        date,
+				UserId:      fmt.Sprintf("user %v", j),
+				DisplayName: fmt.Sprintf("User %v", j),
+				Tags:        []string{"everyone", fmt.Sprintf("group/%v", j%2)},
+				Status:      status,
+			})
+		}
+	}
+
+	report := buildAttendanceReport(records, 3, 0.5)
+	if report.Events != 3 || len(report.Members) != 3 || len(report.Tags) != 3 || len(report.Months) != 2 {
+		t.Fatalf("Wrong report %+v", report)
+	}
+
+	m := report.Members[1]
+	if m.UserId != "user 1" || m.Registered != 3 || m.Attended != 0 || m.NoShow != 3 || *m.Rate != 0 || !m.LastNoShow.Equal(april) {
+		t.Fatalf("Wrong member attendance %+v", m)
+	}
+	if m = report.Members[2]; m.Registered != 2 || m.Attended != 1 || m.NoShow != 0 || *m.Rate != 1 {
+		t.Fatalf("Wrong member attendance %+v", m)
+	}
+	if len(report.ChronicNoShows) != 1 || report.ChronicNoShows[0].UserId != "user 1" {
+		t.Fatalf("Only user 1 should be chronic no-show, got %+v", report.ChronicNoShows)
+	}
+
+	if tag := report.Tags[1]; tag.Tag != "group/0" || tag.Members != 2 || tag.Attended != 3 || tag.NoShow != 1 || *tag.Rate != 0.75 {
+		t.Fatalf("Wrong tag attendance %+v", tag)
+	}
+	if month := report.Months[0]; month.Month != "2031-03" || month.Events != 2 || month.Registered != 5 || month.Attended != 2 || month.NoShow != 2 {
+		t.Fatalf("Wrong month attendance %+v", month)
+	}
+}
+
+func TestAttendanceReport(t *testing.T) {
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	filter := map[string]string{"Keys": "Rep"}
+	members, err := adb.GetSquadMembers(ctx, testSquadId, nil, &filter)
+	if err != nil || len(members) < 2 {
+		t.Fatalf("Failed to get replicants: %v", err)
+	}
+
+	// replicant 0 attends every event, replicant 1 never shows up
+	for _, date := range []string{"2020-03-02", "2020-03-16", "2020-04-06"} {
+		rr := do("POST", "/events", `{"squadId": "`+testSquadId+`", "text": "Past party", "date": "`+date+`T00:00:00Z"}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Failed to create event: %v", rr.Body.String())
+		}
+		res := struct {
+			ID string `json:"id"`
+		}{}
+		json.NewDecoder(rr.Body).Decode(&res)
+
+		if rr = do("POST", "/events/"+res.ID+"/participants/"+members[0].ID+","+members[1].ID, ""); rr.Code != http.StatusOK {
+			t.Fatalf("Failed to register participants: %v", rr.Body.String())
+		}
+		if rr = do("PATCH", "/events/"+res.ID+"/participants/"+members[0].ID, fmt.Sprintf(`{"status": %d}`, assist_db.Attended)); rr.Code != http.StatusOK {
+			t.Fatalf("Failed to set participant status: %v", rr.Body.String())
+		}
+		if rr = do("POST", "/events/"+res.ID+"/noShows", ""); rr.Code != http.StatusOK {
+			t.Fatalf("Failed to mark no-shows: %v", rr.Body.String())
+		}
+	}
+	if err = adb.ArchiveOldEvents(ctx); err != nil {
+		t.Fatalf("Failed to archive events: %v", err)
+	}
+
+	if rr := do("GET", "/squads/"+testSquadId+"/reports/attendance?from=2020-03-10&to=2020-03-01", ""); rr.Code != http.StatusBadRequest {
+		t.Fatalf("Report period ending before start should be rejected, got %v", rr.Code)
+	}
+
+	rr := do("GET", "/squads/"+testSquadId+"/reports/attendance?from=2020-03-01&to=2020-04-30", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Failed to get attendance report: %v", rr.Body.String())
+	}
+	report := AttendanceReport{}
+	json.NewDecoder(rr.Body).Decode(&report)
+	if report.Events != 3 || len(report.Members) != 2 || len(report.Months) != 2 {
+		t.Fatalf("Wrong attendance report %+v", report)
+	}
+	if len(report.ChronicNoShows) != 1 || report.ChronicNoShows[0].UserId != members[1].ID || report.ChronicNoShows[0].NoShow != 3 {
+		t.Fatalf("Replicant 1 should be chronic no-show, got %+v", report.ChronicNoShows)
+	}
+
+	rr = do("GET", "/squads/"+testSquadId+"/reports/attendance?from=2020-03-01&to=2020-03-31&format=csv&table=members", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Failed to export attendance report: %v", rr.Body.String())
+	}
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "userId,displayName,tags,registered,attended,noShow,rate") || !strings.Contains(rr.Body.String(), members[0].ID+","+members[0].DisplayName+",,2,2,0,1.00,") {
+		t.Fatalf("Wrong CSV export:\n%v", rr.Body.String())
+	}
+
+	if rr = do("GET", "/squads/"+testSquadId+"/reports/attendance?format=csv&table=unknown", ""); rr.Code != http.StatusBadRequest {
+		t.Fatalf("Unknown table should be rejected, got %v", rr.Code)
+	}
+
+	// user text should not be interpreted as formula by spreadsheets
+	var b strings.Builder
+	err = writeAttendanceCSV(csv.NewWriter(&b), &AttendanceReport{
+		Members: []*memberAttendance{{UserId: "u1", DisplayName: "=HYPERLINK(\"x\")", Tags: []string{"+tag"}}},
+	}, "members")
+	if err != nil || !strings.Contains(b.String(), "u1,\"'=HYPERLINK(\"\"x\"\")\",'+tag,0,0,0,,") {
+		t.Fatalf("Formula should be escaped, got %v, %v", b.String(), err)
+	}
+}
+
+func TestCronSchedule(t *testing.T) {
+	from := time.Date(2031, time.May, 6, 10, 17, 30, 0, time.UTC) // Tuesday
+	for _, test := range []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2031, time.May, 6, 10, 18, 0, 0, time.UTC)},
+		{"*/5 * * * *", time.Date(2031, time.May, 6, 10, 20, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2031, time.May, 7, 3, 0, 0, 0, time.UTC)},
+		{"15,45 9-17 * * 1-5", time.Date(2031, time.May, 6, 10, 45, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2031, time.June, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2031, time.May, 11, 12, 0, 0, 0, time.UTC)},
+		{"0 12 31 * 0", time.Date(2031, time.May, 11, 12, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2032, time.February, 29, 0, 0, 0, 0, time.UTC)},
+	} {
+		s, err := parseCron(test.expr)
+		if err != nil {
+			t.Fatalf("Failed to parse '%v': %v", test.expr, err)
+		}
+		if next := s.next(from); !next.Equal(test.next) {
+			t.Fatalf("Next run of '%v' should be %v, got %v", test.expr, test.next, next)
+		}
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Fatalf("Cron expression '%v' should be rejected", expr)
+		}
+	}
+}
+
+func TestJobRunner(t *testing.T) {
+	start := time.Date(2031, time.May, 6, 10, 0, 30, 0, time.UTC)
+	now := start
+	runs := 0
+	failing := false
+
+	// two instances of the server sharing the database
+	runners := make([]*JobRunner, 2)
+	for i := range runners {
+		jr := newJobRunner(adb)
+		jr.instance = fmt.Sprintf("instance %v", i)
+		jr.now = func() time.Time { return now }
+		jr.location = time.UTC
+		jr.started = start
+		err := jr.register("testJob", "*/10 * * * *", time.Minute, func(ctx context.Context) error {
+			runs++
+			if failing {
+				return fmt.Errorf("Test failure")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Failed to register job: %v", err)
+		}
+		runners[i] = jr
+	}
+	if err := runners[0].register("testJob", "* * * * *", time.Minute, nil); err == nil {
+		t.Fatalf("Job should not be registered twice")
+	}
+
+	runDue := func(jr *JobRunner, at time.Time, expected int) {
+		t.Helper()
+		now = at
+		if err := jr.runDue(ctx); err != nil {
+			t.Fatalf("Failed to run jobs: %v", err)
+		}
+		jr.wait()
+		if runs != expected {
+			t.Fatalf("Job should have run %v times by %v, but run %v times", expected, at, runs)
+		}
+	}
+
+	runDue(runners[0], start.Add(5*time.Minute), 0)
+	runDue(runners[0], start.Add(10*time.Minute), 1)
+	runDue(runners[1], start.Add(11*time.Minute), 1)
+	runDue(runners[1], start.Add(20*time.Minute), 2)
+	runDue(runners[0], start.Add(21*time.Minute), 2)
+
+	// job locked by one instance does not run on another
+	if ok, err := adb.StartJob(ctx, "testJob", "instance 0", now, now, now.Add(time.Hour)); err != nil || !ok {
+		t.Fatalf("Failed to lock job: %v", err)
+	}
+	runDue(runners[1], start.Add(30*time.Minute), 2)
+	if ok, err := runners[1].trigger(ctx, "testJob"); err != nil || ok {
+		t.Fatalf("Locked job should not be triggered (%v)", err)
+	}
+	if err := adb.FinishJob(ctx, "testJob", "instance 0", now, ""); err != nil {
+		t.Fatalf("Failed to unlock job: %v", err)
+	}
+
+	// missed run is done once
+	runDue(runners[1], start.Add(31*time.Minute), 3)
+	runDue(runners[0], start.Add(32*time.Minute), 3)
+
+	failing = true
+	if ok, err := runners[0].trigger(ctx, "testJob"); err == nil || !ok {
+		t.Fatalf("Triggered job should run and fail (%v)", err)
+	}
+	jobs, err := runners[1].getJobs(ctx)
+	if err != nil || len(jobs) != 1 {
+		t.Fatalf("Failed to get jobs: %v", err)
+	}
+	if jobs[0].LastError != "Test failure" || jobs[0].LockedBy != "" || !jobs[0].LastStart.Equal(now) {
+		t.Fatalf("Wrong job status %+v", jobs[0])
+	}
+
+	// job still running since the previous check is not started again, even
+	// if its lock has expired
+	started := make(chan bool)
+	release := make(chan bool)
+	slowRuns := 0
+	jr := newJobRunner(adb)
+	jr.now = func() time.Time { return now }
+	jr.location = time.UTC
+	jr.started = start
+	if err := jr.register("slowJob", "* * * * *", time.Minute, func(ctx context.Context) error {
+		slowRuns++
+		started <- true
+		<-release
+		return nil
+	}); err != nil {
+		t.Fatalf("Failed to register job: %v", err)
+	}
+
+	now = start.Add(40 * time.Minute)
+	if err := jr.runDue(ctx); err != nil {
+		t.Fatalf("Failed to run jobs: %v", err)
+	}
+	<-started
+	now = start.Add(50 * time.Minute)
+	if err := jr.runDue(ctx); err != nil {
+		t.Fatalf("Failed to run jobs: %v", err)
+	}
+	if ok, err := jr.trigger(ctx, "slowJob"); err != nil || ok {
+		t.Fatalf("Running job should not be triggered (%v)", err)
+	}
+	close(release)
+	jr.wait()
+	if slowRuns != 1 {
+		t.Fatalf("Running job should not be started again, but run %v times", slowRuns)
+	}
+}
+
+func TestNotificationsInbox(t *testing.T) {
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	get := func(query string) []*assist_db.NotificationRecord {
+		t.Helper()
+		rr := do("GET", "/users/me/notifications"+query, "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Failed to get notifications: %v", rr.Body.String())
+		}
+		ns := []*assist_db.NotificationRecord{}
+		json.NewDecoder(rr.Body).Decode(&ns)
+		return ns
+	}
+
+	if rr := do("PUT", "/users/me/notifications", ""); rr.Code != http.StatusOK || app.ntfs.GetNotificationsCount(testUserId) != 0 {
+		t.Fatalf("Failed to mark notifications read: %v", rr.Body.String())
+	}
+
+	app.ntfs.createNotification(assist_db.KindParticipation, testSquadId, []string{testUserId}, "First", "First notification", eventLink("EVENT_1"))
+	app.ntfs.createNotification(assist_db.KindMembership, testSquadId, []string{testUserId}, "Second", "Second notification", squadLink(testSquadId))
+	if n := app.ntfs.GetNotificationsCount(testUserId); n != 2 {
+		t.Fatalf("Expected 2 unread notifications, got %v", n)
+	}
+
+	ns := get("?unread=true")
+	if len(ns) != 2 || ns[0].Title != "Second" || ns[0].Read || ns[0].Link.Type != assist_db.LinkSquad || ns[0].Link.ID != testSquadId || ns[1].Link.Type != assist_db.LinkEvent {
+		t.Fatalf("Wrong unread notifications %+v", ns)
+	}
+
+	if rr := do("PUT", "/users/me/notifications/"+ns[0].ID, ""); rr.Code != http.StatusOK {
+		t.Fatalf("Failed to mark notification read: %v", rr.Body.String())
+	}
+	if rr := do("PUT", "/users/me/notifications/unknown", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("Unknown notification should return 404, got %v", rr.Code)
+	}
+	if ns = get("?unread=true"); len(ns) != 1 || ns[0].Title != "First" {
+		t.Fatalf("Wrong unread notifications after marking one read %+v", ns)
+	}
+	if ns = get(""); len(ns) < 2 || ns[0].Title != "Second" || !ns[0].Read {
+		t.Fatalf("Read notifications should be kept %+v", ns)
+	}
+
+	// notifications are returned page by page
+	for i := 0; i < 12; i++ {
+		app.ntfs.createNotification(assist_db.KindRequestUpdate, testSquadId, []string{testUserId}, "Bulk", fmt.Sprintf("Notification %v", i), nil)
+	}
+	page := get("")
+	if len(page) != 10 || page[0].Text != "Notification 11" || page[0].Link != nil {
+		t.Fatalf("Wrong first page of notifications %+v", page)
+	}
+	next := get("?from=" + url.QueryEscape(page[9].Time.Format(time.RFC3339Nano)))
+	if len(next) < 4 || next[0].Text != "Notification 1" || next[2].Title != "Second" {
+		t.Fatalf("Wrong second page of notifications %+v", next)
+	}
+	if rr := do("GET", "/users/me/notifications?from=yesterday", ""); rr.Code != http.StatusBadRequest {
+		t.Fatalf("Wrong page start should be rejected, got %v", rr.Code)
+	}
+
+	if rr := do("PUT", "/users/me/notifications", ""); rr.Code != http.StatusOK || app.ntfs.GetNotificationsCount(testUserId) != 0 {
+		t.Fatalf("All notifications should be marked read: %v", rr.Body.String())
+	}
+}
+
+// recordingChannel remembers users notifications were delivered to
+type recordingChannel struct {
+	name    string
+	userIds []string
+}
+
+func (c *recordingChannel) Name() string {
+	return c.name
+}
+
+func (c *recordingChannel) Deliver(ctx context.Context, userId string, n *assist_db.Notification) error {
+	c.userIds = append(c.userIds, userId)
+	return nil
+}
+
+func TestNotificationPrefs(t *testing.T) {
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	prefsPath := "/users/me/notificationPrefs/" + testSquadId + "/"
+
+	// own instance, so notifications sent in background by other tests do not reach the channels
+	push := &recordingChannel{name: "push"}
+	email := &recordingChannel{name: "email"}
+	ntfs := &Notifications{db: adb, channels: []NotificationChannel{push, email}}
+
+	if rr := do("PUT", prefsPath+assist_db.KindEventReminder, `{"muted": true}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("Reminders should not be configurable, got %v", rr.Code)
+	}
+	if rr := do("PUT", prefsPath+assist_db.KindNewEvent, `{"channels": ["sms"]}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("Unknown channel should be rejected, got %v", rr.Code)
+	}
+	if rr := do("PUT", "/users/me/notificationPrefs/Unknown Squad/"+assist_db.KindNewEvent, `{"muted": true}`); rr.Code != http.StatusNotFound {
+		t.Fatalf("Preferences should be set only for squads of the user, got %v", rr.Code)
+	}
+
+	// muted notifications are not even put to the inbox
+	if rr := do("PUT", prefsPath+assist_db.KindNewEvent, `{"muted": true}`); rr.Code != http.StatusOK {
+		t.Fatalf("Failed to mute notifications: %v", rr.Body.String())
+	}
+	count := ntfs.GetNotificationsCount(testUserId)
+	ntfs.createNotification(assist_db.KindNewEvent, testSquadId, []string{testUserId}, "New Event", "Muted event", nil)
+	if ntfs.GetNotificationsCount(testUserId) != count || len(push.userIds) != 0 || len(email.userIds) != 0 {
+		t.Fatalf("Muted notification should not be delivered")
+	}
+	ntfs.createNotification(assist_db.KindNewEvent, "Another Squad", []string{testUserId}, "New Event", "Event in another squad", nil)
+	if ntfs.GetNotificationsCount(testUserId) != count+1 || len(push.userIds) != 1 || len(email.userIds) != 1 {
+		t.Fatalf("Notifications from other squads should be delivered")
+	}
+
+	// only chosen channels are used
+	if rr := do("PUT", prefsPath+assist_db.KindEventChange, `{"channels": ["email"]}`); rr.Code != http.StatusOK {
+		t.Fatalf("Failed to choose channels: %v", rr.Body.String())
+	}
+	ntfs.createNotification(assist_db.KindEventChange, testSquadId, []string{testUserId}, "Event Changed", "Event changed", nil)
+	if ntfs.GetNotificationsCount(testUserId) != count+2 || len(push.userIds) != 1 || len(email.userIds) != 2 {
+		t.Fatalf("Notification should be delivered only by email, push %v email %v", push.userIds, email.userIds)
+	}
+
+	rr := do("GET", "/users/me/notificationPrefs", "")
+	prefs := NotificationPrefs{}
+	json.NewDecoder(rr.Body).Decode(&prefs)
+	if rr.Code != http.StatusOK || len(prefs.Kinds) != 2 || prefs.Kinds[0].Kind != assist_db.KindEventChange || !prefs.Kinds[0].HasChannel("email") ||
+		prefs.Kinds[1].Kind != assist_db.KindNewEvent || !prefs.Kinds[1].Muted || prefs.Kinds[1].SquadId != testSquadId {
+		t.Fatalf("Wrong notification preferences %+v", prefs.Kinds)
+	}
+
+	// reset preferences get notifications back to all channels
+	for _, kind := range []string{assist_db.KindNewEvent, assist_db.KindEventChange} {
+		if rr = do("DELETE", prefsPath+kind, ""); rr.Code != http.StatusOK {
+			t.Fatalf("Failed to reset preference: %v", rr.Body.String())
+		}
+	}
+	ntfs.createNotification(assist_db.KindNewEvent, testSquadId, []string{testUserId}, "New Event", "Event after reset", nil)
+	if ntfs.GetNotificationsCount(testUserId) != count+3 || len(push.userIds) != 2 || len(email.userIds) != 3 {
+		t.Fatalf("Notification should be delivered via all channels after reset")
+	}
+}
+
+// fakePushSender remembers tokens messages were sent to and reports some of them as unregistered
+type fakePushSender struct {
+	tokens       []string
+	unregistered map[string]bool
+}
+
+func (s *fakePushSender) send(ctx context.Context, tokens []string, data map[string]string) ([]string, error) {
+	unregistered := make([]string, 0)
+	for _, token := range tokens {
+		s.tokens = append(s.tokens, token)
+		if s.unregistered[token] {
+			unregistered = append(unregistered, token)
+		}
+	}
+	return unregistered, nil
+}
+
+func TestPushTokens(t *testing.T) {
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("User-Agent", "Test Browser")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	tokens := func() map[string]string {
+		devices, err := app.db.GetPushTokens(ctx, testUserId)
+		if err != nil {
+			t.Fatalf("Failed to get push tokens: %v", err)
+		}
+		m := make(map[string]string)
+		for _, d := range devices {
+			m[d.Token] = d.Device
+		}
+		return m
+	}
+
+	sender := &fakePushSender{unregistered: map[string]bool{"TOKEN_OLD": true}}
+	channels := app.ntfs.channels
+	app.ntfs.channels = []NotificationChannel{&pushChannel{app.ntfs, sender}}
+	defer func() { app.ntfs.channels = channels }()
+
+	if rr := do("POST", "/users/me/notifications", `{}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("Empty token should be rejected, got %v", rr.Code)
+	}
+	for _, body := range []string{`{"token": "TOKEN_LAPTOP", "device": "Laptop"}`, `{"token": "TOKEN_PHONE"}`, `{"token": "TOKEN_OLD", "device": "Old phone"}`} {
+		if rr := do("POST", "/users/me/notifications", body); rr.Code != http.StatusOK {
+			t.Fatalf("Failed to subscribe to notifications: %v", rr.Body.String())
+		}
+	}
+	if m := tokens(); len(m) != 3 || m["TOKEN_LAPTOP"] != "Laptop" || m["TOKEN_PHONE"] != "Test Browser" {
+		t.Fatalf("Wrong push tokens %+v", m)
+	}
+
+	// notification goes to all devices, unregistered ones are pruned
+	app.ntfs.createNotification(assist_db.KindMembership, testSquadId, []string{testUserId}, "Push", "Push to all devices", nil)
+	if len(sender.tokens) != 3 {
+		t.Fatalf("Notification should be sent to all devices, sent to %v", sender.tokens)
+	}
+	if m := tokens(); len(m) != 2 || m["TOKEN_OLD"] != "" {
+		t.Fatalf("Unregistered token should be pruned %+v", m)
+	}
+
+	if rr := do("DELETE", "/users/me/notifications", `{"token": "TOKEN_PHONE"}`); rr.Code != http.StatusOK {
+		t.Fatalf("Failed to unsubscribe device: %v", rr.Body.String())
+	}
+	if m := tokens(); len(m) != 1 || m["TOKEN_LAPTOP"] != "Laptop" {
+		t.Fatalf("Only one device should be unsubscribed %+v", m)
+	}
+	if rr := do("DELETE", "/users/me/notifications", ""); rr.Code != http.StatusOK {
+		t.Fatalf("Failed to unsubscribe all devices: %v", rr.Body.String())
+	}
+	if m := tokens(); len(m) != 0 {
+		t.Fatalf("All devices should be unsubscribed %+v", m)
+	}
+}
+
+// smtpSink is a minimal SMTP server keeping messages it receives
+type smtpSink struct {
+	listener net.Listener
+	mx       sync.Mutex
+	messages []string
+}
+
+func newSMTPSink(t *testing.T) *smtpSink {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start SMTP sink: %v", err)
+	}
+
+	s := &smtpSink{listener: l}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *smtpSink) serve(conn net.Conn) {
+	defer conn.Close()
+
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost SMTP sink")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+		case "DATA":
+			tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mx.Lock()
+			s.messages = append(s.messages, string(data))
+			s.mx.Unlock()
+			tp.PrintfLine("250 OK")
+		case "QUIT":
+			tp.PrintfLine("221 Bye")
+			return
+		default:
+			tp.PrintfLine("250 OK")
+		}
+	}
+}
+
+// take returns messages received so far and forgets them
+func (s *smtpSink) take() []string {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	messages := s.messages
+	s.messages = nil
+	return messages
+}
+
+func TestEmailNotifications(t *testing.T) {
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	contains := func(msg string, parts ...string) bool {
+		for _, p := range parts {
+			if !strings.Contains(msg, p) {
+				return false
+			}
+		}
+		return true
+	}
+
+	sink := newSMTPSink(t)
+	defer sink.listener.Close()
+
+	email, err := newEmailChannel(adb, &Mailer{addr: sink.listener.Addr().String(), from: "assist@localhost"}, "https://assist.test/", false)
+	if err != nil {
+		t.Fatalf("Failed to init email channel: %v", err)
+	}
+	// own instance, so notifications sent in background by other tests are not mailed
+	ntfs := &Notifications{db: adb}
+	ntfs.addChannel(email)
+
+	rr := do("GET", "/users/me/notificationPrefs", "")
+	prefs := NotificationPrefs{}
+	json.NewDecoder(rr.Body).Decode(&prefs)
+	if rr.Code != http.StatusOK || prefs.EmailDigest != assist_db.DigestImmediate {
+		t.Fatalf("Emails should be sent right away by default, got %+v", prefs)
+	}
+
+	// subject and body are rendered with template of the notification kind
+	ntfs.createNotification(assist_db.KindNewEvent, testSquadId, []string{testUserId}, "New Event", "New event 'Party' on Fri", eventLink("EVENT_1"))
+	msgs := sink.take()
+	if len(msgs) != 1 || !contains(msgs[0], "To: test@mail.com", "Subject: Assist: new event", "New event 'Party' on Fri", "Apply for participation: https://assist.test/events") {
+		t.Fatalf("Wrong new event email %+v", msgs)
+	}
+
+	ntfs.createNotification("unknownKind", testSquadId, []string{testUserId}, "Something", "Something happened", squadLink(testSquadId))
+	msgs = sink.take()
+	if len(msgs) != 1 || !contains(msgs[0], "Subject: Assist: Something", "Something happened", "https://assist.test/squads/Super%20Huge%20Squad") {
+		t.Fatalf("Notification of unknown kind should use default template %+v", msgs)
+	}
+
+	// notifications are collected for digest
+	if rr = do("PUT", "/users/me/notificationPrefs", `{"emailDigest": "weekly"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("Unknown digest should be rejected, got %v", rr.Code)
+	}
+	if rr = do("PUT", "/users/me/notificationPrefs", `{"emailDigest": "hourly"}`); rr.Code != http.StatusOK {
+		t.Fatalf("Failed to set email digest: %v", rr.Body.String())
+	}
+	defer do("PUT", "/users/me/notificationPrefs", `{"emailDigest": "immediate"}`)
+
+	ntfs.createNotification(assist_db.KindEventReminder, testSquadId, []string{testUserId}, "Event Reminder", "Event 'Party' starts tomorrow", eventLink("EVENT_1"))
+	ntfs.createNotification(assist_db.KindRequestApprove, testSquadId, []string{testUserId}, "Request Queue", "New request in queue Queue", requestLink("REQUEST_1"))
+	if msgs = sink.take(); len(msgs) != 0 {
+		t.Fatalf("Notifications should wait for digest %+v", msgs)
+	}
+
+	if err = ntfs.sendDigests(assist_db.DigestDaily)(ctx); err != nil || len(sink.take()) != 0 {
+		t.Fatalf("Daily digest should be empty (%v)", err)
+	}
+	if err = ntfs.sendDigests(assist_db.DigestHourly)(ctx); err != nil {
+		t.Fatalf("Failed to send digests: %v", err)
+	}
+	msgs = sink.take()
+	if len(msgs) != 1 || !contains(msgs[0], "To: test@mail.com", "Subject: Assist: 2 new notifications", "Event 'Party' starts tomorrow", "New request in queue Queue", "https://assist.test/requests") ||
+		strings.Index(msgs[0], "Event 'Party'") > strings.Index(msgs[0], "New request") {
+		t.Fatalf("Wrong digest email %+v", msgs)
+	}
+
+	if err = ntfs.sendDigests(assist_db.DigestHourly)(ctx); err != nil || len(sink.take()) != 0 {
+		t.Fatalf("Sent notifications should not be sent again (%v)", err)
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	// separate squad, so activity of other tests does not reach the webhook
+	squadId := "Webhooks Squad"
+	if err := adb.CreateSquad(ctx, squadId, testUserId); err != nil {
+		log.Printf("Squad %v is already created: %v", squadId, err)
+	}
+	hooksPath := "/squads/" + url.PathEscape(squadId) + "/webhooks"
+
+	// receiver responds with the code set by the test and keeps requests
+	var mx sync.Mutex
+	code := http.StatusInternalServerError
+	received := make([]*http.Request, 0)
+	bodies := make([][]byte, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mx.Lock()
+		defer mx.Unlock()
+		received = append(received, r)
+		bodies = append(bodies, body)
+		w.WriteHeader(code)
+	}))
+	defer server.Close()
+	setCode := func(c int) {
+		mx.Lock()
+		code = c
+		mx.Unlock()
+	}
+	deliveries := func(webhookId string) []*assist_db.WebhookDeliveryRecord {
+		rr := do("GET", hooksPath+"/"+webhookId+"/deliveries", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Failed to get webhook deliveries: %v", rr.Body.String())
+		}
+		d := []*assist_db.WebhookDeliveryRecord{}
+		json.NewDecoder(rr.Body).Decode(&d)
+		return d
+	}
+
+	if rr := do("POST", hooksPath, `{"url": "ftp://example.com", "events": ["member.status"]}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("Non http URL should be rejected, got %v", rr.Code)
+	}
+	if rr := do("POST", hooksPath, `{"url": "`+server.URL+`", "events": ["member.left"]}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("Unknown event should be rejected, got %v", rr.Code)
+	}
+
+	// receivers in internal networks are rejected when registered and when connected
+	strict := newWebhooks(adb, false)
+	for _, host := range []string{"127.0.0.1", "localhost", "10.1.2.3", "192.168.0.1", "169.254.169.254", "::1"} {
+		if err := strict.checkHost(ctx, host); err == nil {
+			t.Fatalf("Webhook host %v should be rejected", host)
+		}
+	}
+	if err := strict.checkHost(ctx, "8.8.8.8"); err != nil {
+		t.Fatalf("Public webhook host should be allowed: %v", err)
+	}
+	if res, err := strict.client.Post(server.URL, "application/json", strings.NewReader("{}")); err == nil {
+		res.Body.Close()
+		t.Fatalf("Webhook client should not connect to loopback address")
+	}
+	rr := do("POST", hooksPath, `{"url": "`+server.URL+`", "events": ["member.status", "request.created"]}`)
+	created := struct {
+		ID     string `json:"id"`
+		Secret string `json:"secret"`
+	}{}
+	json.NewDecoder(rr.Body).Decode(&created)
+	if rr.Code != http.StatusOK || created.ID == "" || created.Secret == "" {
+		t.Fatalf("Failed to create webhook: %v", rr.Body.String())
+	}
+
+	rr = do("GET", hooksPath, "")
+	hooks := []*assist_db.WebhookRecord{}
+	json.NewDecoder(rr.Body).Decode(&hooks)
+	if rr.Code != http.StatusOK || len(hooks) != 1 || hooks[0].ID != created.ID || hooks[0].Secret != "" || !hooks[0].HasEvent(assist_db.HookRequestCreated) {
+		t.Fatalf("Wrong webhooks %+v", hooks)
+	}
+
+	// payload is signed, failed delivery is scheduled for retry
+	app.hooks.fire(squadId, assist_db.HookEventCreated, &eventHookData{Text: "Not subscribed"})
+	app.hooks.fire(squadId, assist_db.HookMemberStatus, &memberHookData{UserId: testUserId, Status: assist_db.Member.String()})
+	if len(received) != 1 || received[0].Header.Get("X-Assist-Event") != assist_db.HookMemberStatus {
+		t.Fatalf("Expected one delivery of member status, got %v", len(received))
+	}
+	mac := hmac.New(sha256.New, []byte(created.Secret))
+	mac.Write(bodies[0])
+	if received[0].Header.Get("X-Assist-Signature") != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+		t.Fatalf("Wrong payload signature %v", received[0].Header.Get("X-Assist-Signature"))
+	}
+	payload := struct {
+		Event   string         `json:"event"`
+		SquadId string         `json:"squadId"`
+		Data    memberHookData `json:"data"`
+	}{}
+	if err := json.Unmarshal(bodies[0], &payload); err != nil || payload.Event != assist_db.HookMemberStatus || payload.SquadId != squadId ||
+		payload.Data.UserId != testUserId || payload.Data.Status != "Member" {
+		t.Fatalf("Wrong payload %v (%v)", string(bodies[0]), err)
+	}
+
+	entries := deliveries(created.ID)
+	if len(entries) != 1 || entries[0].Status != assist_db.DeliveryPending || entries[0].Attempts != 1 || entries[0].ResponseCode != http.StatusInternalServerError ||
+		entries[0].NextAttempt.Before(time.Now().Add(50*time.Second)) || entries[0].ID != received[0].Header.Get("X-Assist-Delivery") {
+		t.Fatalf("Wrong delivery log %+v", entries)
+	}
+
+	// delivery is retried once it is due
+	if err := app.hooks.deliverDue(ctx); err != nil || len(received) != 1 {
+		t.Fatalf("Delivery should not be retried before next attempt (%v)", err)
+	}
+	delivery := entries[0].WebhookDelivery
+	delivery.NextAttempt = time.Now().Add(-time.Second)
+	if err := adb.UpdateWebhookDelivery(ctx, entries[0].ID, &delivery); err != nil {
+		t.Fatalf("Failed to update delivery: %v", err)
+	}
+	setCode(http.StatusNoContent)
+	if err := app.hooks.deliverDue(ctx); err != nil || len(received) != 2 || string(bodies[1]) != string(bodies[0]) {
+		t.Fatalf("Delivery should be retried with the same payload (%v)", err)
+	}
+	if entries = deliveries(created.ID); entries[0].Status != assist_db.DeliveryDelivered || entries[0].Attempts != 2 || entries[0].Error != "" {
+		t.Fatalf("Delivery should be delivered %+v", entries[0])
+	}
+
+	// delivery fails after the last attempt
+	setCode(http.StatusBadGateway)
+	app.hooks.fire(squadId, assist_db.HookRequestCreated, &requestHookData{RequestId: "REQUEST"})
+	entries = deliveries(created.ID)
+	delivery = entries[0].WebhookDelivery
+	delivery.Attempts = len(webhookBackoff)
+	delivery.NextAttempt = time.Now().Add(-time.Second)
+	if err := adb.UpdateWebhookDelivery(ctx, entries[0].ID, &delivery); err != nil {
+		t.Fatalf("Failed to update delivery: %v", err)
+	}
+	if err := app.hooks.deliverDue(ctx); err != nil {
+		t.Fatalf("Failed to deliver webhooks: %v", err)
+	}
+	if entries = deliveries(created.ID); len(entries) != 2 || entries[0].Event != assist_db.HookRequestCreated || entries[0].Status != assist_db.DeliveryFailed || entries[0].ResponseCode != http.StatusBadGateway {
+		t.Fatalf("Delivery should fail after the last attempt %+v", entries[0])
+	}
+
+	// delivery which webhook can not be found fails
+	orphanId, err := adb.CreateWebhookDelivery(ctx, &assist_db.WebhookDelivery{WebhookId: created.ID, SquadId: testSquadId, Event: assist_db.HookMemberStatus,
+		Payload: "{}", Status: assist_db.DeliveryPending, Created: time.Now(), NextAttempt: time.Now().Add(-time.Minute)})
+	if err != nil {
+		t.Fatalf("Failed to create delivery: %v", err)
+	}
+	if err := app.hooks.deliverDue(ctx); err != nil {
+		t.Fatalf("Failed to deliver webhooks: %v", err)
+	}
+	if entries = deliveries(created.ID); entries[0].ID != orphanId || entries[0].Status != assist_db.DeliveryFailed || entries[0].Attempts != 0 {
+		t.Fatalf("Delivery of unknown webhook should fail %+v", entries[0])
+	}
+
+	if rr = do("GET", hooksPath+"/UNKNOWN/deliveries", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("Deliveries of unknown webhook should not be found, got %v", rr.Code)
+	}
+	if rr = do("DELETE", hooksPath+"/"+created.ID, ""); rr.Code != http.StatusOK {
+		t.Fatalf("Failed to delete webhook: %v", rr.Body.String())
+	}
+	rr = do("GET", hooksPath, "")
+	json.NewDecoder(rr.Body).Decode(&hooks)
+	if len(hooks) != 0 {
+		t.Fatalf("Webhook should be deleted %+v", hooks)
+	}
+}
+
+func TestJobsMethods(t *testing.T) {
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	app.jobs = newJobRunner(adb)
+	if err := app.registerJobs(); err != nil {
+		t.Fatalf("Failed to register jobs: %v", err)
+	}
+
+	rr := do("GET", "/jobs", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Failed to get jobs: %v", rr.Body.String())
+	}
+	jobs := []JobInfo{}
+	json.NewDecoder(rr.Body).Decode(&jobs)
+	if len(jobs) != 9 {
+		t.Fatalf("Expected 9 jobs, got %+v", jobs)
+	}
+
+	if rr = do("POST", "/jobs/unknownJob", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("Unknown job should not be found, got %v", rr.Code)
+	}
+
+	rr = do("POST", "/jobs/recountEventCounters", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Failed to run job: %v", rr.Body.String())
+	}
+	job := JobInfo{}
+	json.NewDecoder(rr.Body).Decode(&job)
+	if job.Name != "recountEventCounters" || job.LastFinish == nil || job.LastError != "" {
+		t.Fatalf("Wrong status of the job: %+v", job)
+	}
+}
+
 // Benchmarking home screen and particular components
 func BenchmarkMethodGetHome(b *testing.B) {
 	for i := 0; i < b.N; i++ {
@@ -3,40 +3,62 @@ package main
 import (
 	"context"
 	"log"
-	"strconv"
+	"os"
 	"time"
 
+	assist_db "assist/db"
+
 	firebase "firebase.google.com/go"
 	"firebase.google.com/go/messaging"
-	"github.com/patrickmn/go-cache"
 )
 
-type Notification struct {
-	Time  time.Time `json:"time"`
-	Title string    `json:"title"`
-	Text  string    `json:"text"`
+// notifications are kept in the inbox of the user for this period
+const notificationsRetention = 90 * 24 * time.Hour
+
+// NotificationChannel delivers notification put to the user inbox outside of the app
+type NotificationChannel interface {
+	Name() string
+	Deliver(ctx context.Context, userId string, n *assist_db.Notification) error
 }
 
 type Notifications struct {
-	notificationsCache *cache.Cache
-	dev                bool
-	messaging          *messaging.Client
-	userTokens         *cache.Cache
+	db       assist_db.Store
+	dev      bool
+	channels []NotificationChannel
+	email    *emailChannel
 }
 
-func InitNotifications(fireapp *firebase.App, dev bool) (*Notifications, error) {
+func InitNotifications(fireapp *firebase.App, db assist_db.Store, mailer *Mailer, dev bool) (*Notifications, error) {
 
 	ctx := context.Background()
 
-	nc := cache.New(24*time.Hour, 10*time.Minute)
-	ut := cache.New(24*time.Hour, 10*time.Minute)
+	// push notifications are delivered with FCM, which is available only with firebase
+	var client *messaging.Client
+	if fireapp != nil {
+		var err error
+		client, err = fireapp.Messaging(ctx)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		log.Println("Firebase is not configured, push notifications are disabled")
+	}
 
-	messaging, err := fireapp.Messaging(ctx)
-	if err != nil {
-		return nil, err
+	ntfs := Notifications{db: db, dev: dev}
+	if client != nil {
+		ntfs.addChannel(&pushChannel{&ntfs, &fcmSender{client}})
 	}
 
-	ntfs := Notifications{notificationsCache: nc, dev: dev, messaging: messaging, userTokens: ut}
+	// emails are sent only when SMTP server is configured, otherwise they would be just logged
+	if mailer != nil && mailer.addr != "" {
+		email, err := newEmailChannel(db, mailer, os.Getenv("APP_URL"), dev)
+		if err != nil {
+			return nil, err
+		}
+		ntfs.addChannel(email)
+	} else {
+		log.Println("SMTP_ADDR is not set, email notifications are disabled")
+	}
 
 	return &ntfs, nil
 
@@ -50,107 +72,153 @@ func (ntfs *Notifications) logDev(format string, v ...interface{}) {
 	}
 }
 
-func (ntfs *Notifications) createNotification(userIds []string, title string, notification string) {
-
-	if ntfs.dev {
-		log.Printf("createNotification %v: %v %+v\n", title, notification, userIds)
+func (ntfs *Notifications) addChannel(ch NotificationChannel) {
+	ntfs.channels = append(ntfs.channels, ch)
+	if email, ok := ch.(*emailChannel); ok {
+		ntfs.email = email
 	}
+}
 
-	n := Notification{
-		time.Now(),
-		title,
-		notification,
-	}
+func squadLink(squadId string) *assist_db.NotificationLink {
+	return &assist_db.NotificationLink{Type: assist_db.LinkSquad, ID: squadId}
+}
 
-	for _, userId := range userIds {
-		var notifications []Notification
-		ns, found := ntfs.notificationsCache.Get(userId)
-		if !found {
-			notifications = make([]Notification, 0)
-		} else {
-			notifications = ns.([]Notification)
-		}
-		notifications = append(notifications, n)
-		ntfs.notificationsCache.Set(userId, notifications, cache.DefaultExpiration)
+func eventLink(eventId string) *assist_db.NotificationLink {
+	return &assist_db.NotificationLink{Type: assist_db.LinkEvent, ID: eventId}
+}
 
-		ntfs.sendMessage(userId, n, len(notifications))
-	}
+func requestLink(requestId string) *assist_db.NotificationLink {
+	return &assist_db.NotificationLink{Type: assist_db.LinkRequest, ID: requestId}
 }
 
-func (ntfs *Notifications) sendMessage(userId string, n Notification, count int) {
+// names of channels users could choose in notification preferences
+var notificationChannels = map[string]bool{"push": true, "email": true}
 
-	token := ntfs.GetUserToken(userId)
+// createNotification puts notification to the inbox of every user and delivers it via
+// channels chosen in their preferences for the kind of notifications from the squad, link
+// points to the squad, event or request the notification is about
+func (ntfs *Notifications) createNotification(kind string, squadId string, userIds []string, title string, notification string, link *assist_db.NotificationLink) {
 
-	if token != "" {
-		ctx := context.Background()
+	if ntfs.dev {
+		log.Printf("createNotification %v: %v %+v\n", title, notification, userIds)
+	}
 
-		message := &messaging.Message{
-			Data: map[string]string{
-				"count": strconv.Itoa(count),
-				"title": n.Title,
-				"text":  n.Text,
-				"time":  n.Time.Format("2006.01.02 15:04:05"),
-			},
-			Token: token,
-		}
+	ctx := context.Background()
 
-		response, err := ntfs.messaging.Send(ctx, message)
+	// users without preferences get notifications via all channels
+	var prefs map[string]*assist_db.NotificationPref
+	if squadId != "" && assist_db.PrefKinds[kind] && len(userIds) > 0 {
+		var err error
+		prefs, err = ntfs.db.GetUsersNotificationPrefs(ctx, userIds, squadId, kind)
 		if err != nil {
-			log.Println(err)
+			log.Printf("Failed to get notification preferences, notification '%v' is sent to everyone: %v", title, err)
 		}
 
-		if ntfs.dev {
-			log.Printf("sendMessage(userId=%v, token=%v, title=%v) %+v\n", userId, token, n.Title, response)
+		recipients := make([]string, 0, len(userIds))
+		for _, userId := range userIds {
+			if p := prefs[userId]; p == nil || !p.Muted {
+				recipients = append(recipients, userId)
+			}
 		}
+		userIds = recipients
 	}
-}
 
-func (ntfs *Notifications) GetNotificationsCount(userId string) int {
-	ns, found := ntfs.notificationsCache.Get(userId)
-	if ntfs.dev {
-		log.Printf("GetNotificationsCount %v %v %+v\n", userId, found, ns)
+	if len(userIds) == 0 {
+		return
 	}
-	if !found {
-		return 0
-	} else {
-		return len(ns.([]Notification))
+
+	n := assist_db.Notification{
+		Kind:  kind,
+		Time:  time.Now(),
+		Title: title,
+		Text:  notification,
+		Link:  link,
+	}
+
+	if err := ntfs.db.CreateNotification(ctx, userIds, &n); err != nil {
+		log.Println(err.Error())
+		return
+	}
+
+	for _, userId := range userIds {
+		for _, ch := range ntfs.channels {
+			if p := prefs[userId]; p != nil && !p.HasChannel(ch.Name()) {
+				continue
+			}
+			if err := ch.Deliver(ctx, userId, &n); err != nil {
+				log.Printf("Failed to deliver notification '%v' to user %v via %v: %v", title, userId, ch.Name(), err)
+			}
+		}
 	}
 }
 
-func (ntfs *Notifications) GetNotifications(userId string) []Notification {
-	ns, found := ntfs.notificationsCache.Get(userId)
-	if ntfs.dev {
-		log.Printf("GetNotifications %v %v %+v\n", userId, found, ns)
+// GetNotificationsCount returns number of unread notifications of the user
+func (ntfs *Notifications) GetNotificationsCount(userId string) int {
+	count, err := ntfs.db.GetUnreadNotificationsCount(context.Background(), userId)
+	if err != nil {
+		log.Println(err.Error())
 	}
-	if found {
-		return ns.([]Notification)
+	if ntfs.dev {
+		log.Printf("GetNotificationsCount %v %v\n", userId, count)
 	}
-	return nil
+	return count
+}
+
+// GetNotifications returns page of the user notifications, newest first
+func (ntfs *Notifications) GetNotifications(ctx context.Context, userId string, before *time.Time, unreadOnly bool) ([]*assist_db.NotificationRecord, error) {
+	return ntfs.db.GetNotifications(ctx, userId, before, unreadOnly)
 }
 
-func (ntfs *Notifications) MarkNotificationsDelivered(userId string) {
-	ntfs.notificationsCache.Delete(userId)
+func (ntfs *Notifications) MarkNotificationRead(ctx context.Context, userId string, notificationId string) error {
+	return ntfs.db.MarkNotificationRead(ctx, userId, notificationId)
 }
 
-func (ntfs *Notifications) GetUserToken(userId string) string {
+func (ntfs *Notifications) MarkNotificationsRead(ctx context.Context, userId string) error {
+	return ntfs.db.MarkNotificationsRead(ctx, userId)
+}
 
-	v, found := ntfs.userTokens.Get(userId)
-	if found {
-		return v.(string)
+// sendDigests returns job sending by email notifications collected for the digest
+func (ntfs *Notifications) sendDigests(digest string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if ntfs.email == nil {
+			return nil
+		}
+		return ntfs.email.sendDigests(ctx, digest)
 	}
-	return ""
 }
 
-func (ntfs *Notifications) SetUserToken(userId string, token string) {
+// deleteOldNotifications removes notifications which are older than retention period
+func (ntfs *Notifications) deleteOldNotifications(ctx context.Context) error {
+	_, err := ntfs.db.DeleteNotificationsBefore(ctx, time.Now().Add(-notificationsRetention))
+	return err
+}
+
+// SetUserToken saves FCM token of the user device, it is called every time the
+// device is used, so last seen time is kept up to date
+func (ntfs *Notifications) SetUserToken(ctx context.Context, userId string, token string, device string) error {
 	if ntfs.dev {
-		log.Printf("SetUserToken(%v, %v)\n", userId, token)
+		log.Printf("SetUserToken(%v, %v, %v)\n", userId, token, device)
 	}
-	ntfs.userTokens.Set(userId, token, cache.DefaultExpiration)
+	return ntfs.db.SavePushToken(ctx, &assist_db.PushToken{Token: token, UserId: userId, Device: device, LastSeen: time.Now()})
 }
 
-func (ntfs *Notifications) DeleteUserToken(userId string) {
+// DeleteUserToken deletes FCM token of the user device, or all of them if token is empty
+func (ntfs *Notifications) DeleteUserToken(ctx context.Context, userId string, token string) error {
 	if ntfs.dev {
-		log.Printf("DeleteUserToken(%v)\n", userId)
+		log.Printf("DeleteUserToken(%v, %v)\n", userId, token)
 	}
-	ntfs.userTokens.Delete(userId)
+
+	tokens := []string{token}
+	if token == "" {
+		devices, err := ntfs.db.GetPushTokens(ctx, userId)
+		if err != nil {
+			return err
+		}
+		tokens = make([]string, len(devices))
+		for i, d := range devices {
+			tokens[i] = d.Token
+		}
+	}
+
+	return ntfs.db.DeletePushTokens(ctx, userId, tokens)
 }
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+
+	assist_db "assist/db"
+
+	"firebase.google.com/go/messaging"
+)
+
+// FCM accepts up to this number of tokens in one multicast message
+const fcmMulticastLimit = 500
+
+// pushSender sends data message to devices and reports tokens which are not
+// registered anymore, so they could be pruned
+type pushSender interface {
+	send(ctx context.Context, tokens []string, data map[string]string) (unregistered []string, err error)
+}
+
+type fcmSender struct {
+	client *messaging.Client
+}
+
+func (s *fcmSender) send(ctx context.Context, tokens []string, data map[string]string) ([]string, error) {
+	unregistered := make([]string, 0)
+	var lastErr error
+
+	for from := 0; from < len(tokens); from += fcmMulticastLimit {
+		to := from + fcmMulticastLimit
+		if to > len(tokens) {
+			to = len(tokens)
+		}
+
+		response, err := s.client.SendMulticast(ctx, &messaging.MulticastMessage{Tokens: tokens[from:to], Data: data})
+		if err != nil {
+			return unregistered, err
+		}
+		for i, r := range response.Responses {
+			if r.Success {
+				continue
+			}
+			if messaging.IsRegistrationTokenNotRegistered(r.Error) {
+				unregistered = append(unregistered, tokens[from+i])
+			} else {
+				lastErr = r.Error
+			}
+		}
+	}
+
+	return unregistered, lastErr
+}
+
+// pushChannel sends notifications to all devices of the user with FCM
+type pushChannel struct {
+	ntfs   *Notifications
+	sender pushSender
+}
+
+func (c *pushChannel) Name() string {
+	return "push"
+}
+
+func (c *pushChannel) Deliver(ctx context.Context, userId string, n *assist_db.Notification) error {
+	devices, err := c.ntfs.db.GetPushTokens(ctx, userId)
+	if err != nil || len(devices) == 0 {
+		return err
+	}
+
+	tokens := make([]string, len(devices))
+	for i, d := range devices {
+		tokens[i] = d.Token
+	}
+
+	data := map[string]string{
+		"count": strconv.Itoa(c.ntfs.GetNotificationsCount(userId)),
+		"title": n.Title,
+		"text":  n.Text,
+		"time":  n.Time.Format("2006.01.02 15:04:05"),
+	}
+	if n.Link != nil {
+		data["linkType"] = n.Link.Type
+		data["linkId"] = n.Link.ID
+	}
+
+	unregistered, err := c.sender.send(ctx, tokens, data)
+	if c.ntfs.dev {
+		log.Printf("Sent push notification '%v' to %v devices of user %v, %v are not registered: %v", n.Title, len(tokens), userId, len(unregistered), err)
+	}
+
+	// tokens of devices where the app was uninstalled or notifications were revoked
+	if len(unregistered) > 0 {
+		if err := c.ntfs.db.DeletePushTokens(ctx, userId, unregistered); err != nil {
+			log.Println(err.Error())
+		}
+	}
+
+	return err
+}
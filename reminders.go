@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	assist_db "assist/db"
+)
+
+// reminders of events created without them, minutes before the event start
+var defaultReminders = []int{24 * 60, 60}
+
+// reminders could be sent up to a week before the event
+const maxReminderMinutes = 7 * 24 * 60
+
+func validateReminders(reminders []int) error {
+	for _, m := range reminders {
+		if m <= 0 || m > maxReminderMinutes {
+			return fmt.Errorf("Reminder should be from 1 minute to %v days before the event, got %v minutes", maxReminderMinutes/24/60, m)
+		}
+	}
+	return nil
+}
+
+// ReminderScheduler sends reminders about upcoming events to their Going
+// participants. Sent reminders are marked in the database, so they are not
+// sent again after restart or by another instance of the server.
+type ReminderScheduler struct {
+	db   assist_db.Store
+	ntfs *Notifications
+	// current time, replaced in tests
+	now func() time.Time
+	// event times have no time zone, they are treated as local time (TZ)
+	location *time.Location
+}
+
+func newReminderScheduler(db assist_db.Store, ntfs *Notifications) *ReminderScheduler {
+	return &ReminderScheduler{
+		db:       db,
+		ntfs:     ntfs,
+		now:      time.Now,
+		location: time.Local,
+	}
+}
+
+// eventStart returns time the event starts at, events without time start at the beginning of the day
+func (rs *ReminderScheduler) eventStart(e *assist_db.EventInfo) (start time.Time, hasTime bool) {
+	year, month, day := e.Date.UTC().Date()
+	t, err := time.Parse("15:04", e.TimeFrom)
+	if err != nil {
+		return time.Date(year, month, day, 0, 0, 0, 0, rs.location), false
+	}
+	return time.Date(year, month, day, t.Hour(), t.Minute(), 0, 0, rs.location), true
+}
+
+// sendReminders sends reminders which are due, only the latest due reminder
+// of an event is sent, so reminders missed while server was down do not come
+// all together; returns amount of sent reminders
+func (rs *ReminderScheduler) sendReminders(ctx context.Context) (int, error) {
+	now := rs.now()
+
+	// event dates are days in UTC, take a day more on both sides to cover time zones
+	from := dayOf(now).AddDate(0, 0, -1)
+	to := dayOf(now).AddDate(0, 0, maxReminderMinutes/24/60+2)
+	events, err := rs.db.GetUpcomingEvents(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, e := range events {
+		start, hasTime := rs.eventStart(&e.EventInfo)
+		if !start.After(now) {
+			continue
+		}
+
+		var remindAt time.Time
+		for _, m := range e.Reminders {
+			at := start.Add(-time.Duration(m) * time.Minute)
+			if !at.After(now) && at.After(remindAt) {
+				remindAt = at
+			}
+		}
+		if remindAt.IsZero() {
+			continue
+		}
+
+		ok, err := rs.db.MarkEventReminderSent(ctx, e.ID, remindAt)
+		if err != nil {
+			return sent, err
+		}
+		if !ok {
+			continue
+		}
+
+		userIds, err := rs.db.GetParticipantIds(ctx, e.ID, assist_db.Going)
+		if err != nil {
+			return sent, err
+		}
+
+		when := start.Format("Mon, Jan 2")
+		if hasTime {
+			when += " at " + start.Format("15:04")
+		}
+		rs.ntfs.createNotification(assist_db.KindEventReminder, e.SquadId, userIds, "Event Reminder", "Event '"+e.Text+"' starts "+when+locationSuffix(e.Location), eventLink(e.ID))
+		sent++
+	}
+
+	return sent, nil
+}
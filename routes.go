@@ -84,8 +84,7 @@ func (app *App) registerHandlers() {
 	r.Use(app.sm.authMiddleware)
 
 	// auth handlers
-	r.Methods("POST").Path("/sessionLogin").Handler(appHandler(app.sm.sessionLogin))
-	r.Methods("POST").Path("/sessionLogout").Handler(appHandler(app.sm.sessionLogout))
+	app.sm.registerAuthHandlers(r)
 
 	// tab handlers
 	r.Methods("GET").Path("/home").Handler(appHandler(app.homeHandler))
@@ -138,40 +137,95 @@ func (app *App) registerMethodHandlers(rm *mux.Router) {
 	rm.Methods("DELETE").Path("/squads/{squadId}/members/{userId}/tags/{tagName}").Handler(appHandler(app.methodDeleteMemberTag))
 	rm.Methods("DELETE").Path("/squads/{squadId}/members/{userId}/tags/{tagName}/{tagValue}").Handler(appHandler(app.methodDeleteMemberTag))
 
+	// squad reports
+	rm.Methods("GET").Path("/squads/{squadId}/reports/attendance").Handler(appHandler(app.methodGetAttendanceReport))
+
 	// squad notes
 	rm.Methods("PUT").Path("/squads/{squadId}/notes/{noteId}").Handler(appHandler(app.methodUpdateNote))
 	rm.Methods("POST").Path("/squads/{squadId}/notes").Handler(appHandler(app.methodCreateNote))
 	rm.Methods("GET").Path("/squads/{squadId}/notes").Handler(appHandler(app.methodGetNotes))
 	rm.Methods("DELETE").Path("/squads/{squadId}/notes/{noteId}").Handler(appHandler(app.methodDeleteNote))
 
+	// squad venues
+	rm.Methods("POST").Path("/squads/{squadId}/venues").Handler(appHandler(app.methodCreateVenue))
+	rm.Methods("GET").Path("/squads/{squadId}/venues").Handler(appHandler(app.methodGetVenues))
+	rm.Methods("PUT").Path("/squads/{squadId}/venues/{venueId}").Handler(appHandler(app.methodUpdateVenue))
+	rm.Methods("DELETE").Path("/squads/{squadId}/venues/{venueId}").Handler(appHandler(app.methodDeleteVenue))
+
+	// squad event templates
+	rm.Methods("POST").Path("/squads/{squadId}/eventTemplates").Handler(appHandler(app.methodCreateEventTemplate))
+	rm.Methods("GET").Path("/squads/{squadId}/eventTemplates").Handler(appHandler(app.methodGetEventTemplates))
+	rm.Methods("GET").Path("/squads/{squadId}/eventTemplates/{templateId}").Handler(appHandler(app.methodGetEventTemplate))
+	rm.Methods("PUT").Path("/squads/{squadId}/eventTemplates/{templateId}").Handler(appHandler(app.methodUpdateEventTemplate))
+	rm.Methods("DELETE").Path("/squads/{squadId}/eventTemplates/{templateId}").Handler(appHandler(app.methodDeleteEventTemplate))
+
+	// squad webhooks
+	rm.Methods("POST").Path("/squads/{squadId}/webhooks").Handler(appHandler(app.methodCreateWebhook))
+	rm.Methods("GET").Path("/squads/{squadId}/webhooks").Handler(appHandler(app.methodGetWebhooks))
+	rm.Methods("DELETE").Path("/squads/{squadId}/webhooks/{webhookId}").Handler(appHandler(app.methodDeleteWebhook))
+	rm.Methods("GET").Path("/squads/{squadId}/webhooks/{webhookId}/deliveries").Handler(appHandler(app.methodGetWebhookDeliveries))
+
 	// events
 	rm.Methods("POST").Path("/events").Handler(appHandler(app.methodCreateEvent))
 	rm.Methods("DELETE").Path("/events/{eventId}").Handler(appHandler(app.methodDeleteEvent))
+	rm.Methods("PATCH").Path("/events/{eventId}").Handler(appHandler(app.methodUpdateEvent))
 	rm.Methods("GET").Path("/users/{userId}/events").Handler(appHandler(app.methodGetEvents))
+	rm.Methods("POST").Path("/events/{eventId}/participants").Handler(appHandler(app.methodInviteParticipants))
 	rm.Methods("POST").Path("/events/{eventId}/participants/{userIds}").Handler(appHandler(app.methodRegisterParticipant))
 	rm.Methods("GET").Path("/events/{eventId}/participants").Handler(appHandler(app.methodGetParticipants))
 	rm.Methods("GET").Path("/events/{eventId}").Handler(appHandler(app.methodGetEventDetails))
 	rm.Methods("PATCH").Path("/events/{eventId}/participants/{userId}").Handler(appHandler(app.methodUpdateParticipant))
 	rm.Methods("DELETE").Path("/events/{eventId}/participants/{userId}").Handler(appHandler(app.methodRemoveParticipant))
 	rm.Methods("GET").Path("/events/{eventId}/candidates").Handler(appHandler(app.methodGetCandidates))
-	rm.Methods("GET").Path("/archive").Handler(appHandler(app.methodArchiveEvents))
+
+	// event check-in
+	rm.Methods("GET").Path("/events/{eventId}/checkin").Handler(appHandler(app.methodGetCheckinToken))
+	rm.Methods("POST").Path("/events/{eventId}/checkin").Handler(appHandler(app.methodCheckinParticipant))
+	rm.Methods("POST").Path("/events/{eventId}/noShows").Handler(appHandler(app.methodMarkNoShows))
+
+	// calendar feeds, authenticated by token instead of session
+	rm.Methods("GET").Path("/calendar/users/{userId}/{token}.ics").Handler(appHandler(app.methodGetUserCalendar))
+	rm.Methods("GET").Path("/calendar/squads/{squadId}/{userId}/{token}.ics").Handler(appHandler(app.methodGetSquadCalendar))
+	rm.Methods("GET").Path("/users/{userId}/calendars").Handler(appHandler(app.methodGetCalendarFeeds))
+
+	// recurring events
+	rm.Methods("GET").Path("/series/{seriesId}").Handler(appHandler(app.methodGetEventSeries))
+	rm.Methods("PUT").Path("/series/{seriesId}").Handler(appHandler(app.methodUpdateEventSeries))
+	rm.Methods("DELETE").Path("/series/{seriesId}").Handler(appHandler(app.methodDeleteEventSeries))
+	rm.Methods("PUT").Path("/series/{seriesId}/events/{eventId}").Handler(appHandler(app.methodUpdateSeriesEvent))
 
 	// request queues
 	rm.Methods("POST").Path("/squads/{squadId}/queues").Handler(appHandler(app.methodCreateQueue))
 	rm.Methods("GET").Path("/squads/{squadId}/queues").Handler(appHandler(app.methodGetSquadQueues))
 	rm.Methods("GET").Path("/users/{userId}/queues").Handler(appHandler(app.methodGetUserQueuesAndRequests))
+	rm.Methods("GET").Path("/queues/{queueId}").Handler(appHandler(app.methodGetQueue))
 	rm.Methods("DELETE").Path("/squads/{squadId}/queues/{queueId}").Handler(appHandler(app.methodDeleteQueue))
 
 	// requests
 	rm.Methods("POST").Path("/requests").Handler(appHandler(app.methodCreateRequest))
 	rm.Methods("PUT").Path("/requests/{requestId}").Handler(appHandler(app.methodSetRequestStatus))
+	rm.Methods("GET").Path("/requests/{requestId}/history").Handler(appHandler(app.methodGetRequestHistory))
+	rm.Methods("POST").Path("/requests/{requestId}/comments").Handler(appHandler(app.methodCreateRequestComment))
+	rm.Methods("GET").Path("/requests/{requestId}/comments").Handler(appHandler(app.methodGetRequestComments))
+	rm.Methods("DELETE").Path("/requests/{requestId}/comments/{commentId}").Handler(appHandler(app.methodDeleteRequestComment))
+	rm.Methods("PUT").Path("/requests/{requestId}/assignee").Handler(appHandler(app.methodSetRequestAssignee))
+	rm.Methods("DELETE").Path("/requests/{requestId}/assignee").Handler(appHandler(app.methodDeleteRequestAssignee))
 	rm.Methods("GET").Path("/requests").Handler(appHandler(app.methodGetRequests))
 
+	// background jobs, system admins only
+	rm.Methods("GET").Path("/jobs").Handler(appHandler(app.methodGetJobs))
+	rm.Methods("POST").Path("/jobs/{name}").Handler(appHandler(app.methodRunJob))
+
 	// notifications
 	rm.Methods("POST").Path("/users/{userId}/notifications").Handler(appHandler(app.methodSubscribeToNotifications))
 	rm.Methods("DELETE").Path("/users/{userId}/notifications").Handler(appHandler(app.methodUnsubscribeFromNotifications))
 	rm.Methods("GET").Path("/users/{userId}/notifications").Handler(appHandler(app.methodGetNotifications))
-	rm.Methods("PUT").Path("/users/{userId}/notifications").Handler(appHandler(app.methodMarkNotificationsDelivered))
+	rm.Methods("PUT").Path("/users/{userId}/notifications").Handler(appHandler(app.methodMarkNotificationsRead))
+	rm.Methods("PUT").Path("/users/{userId}/notifications/{notificationId}").Handler(appHandler(app.methodMarkNotificationRead))
+	rm.Methods("GET").Path("/users/{userId}/notificationPrefs").Handler(appHandler(app.methodGetNotificationPrefs))
+	rm.Methods("PUT").Path("/users/{userId}/notificationPrefs").Handler(appHandler(app.methodSetNotificationPrefs))
+	rm.Methods("PUT").Path("/users/{userId}/notificationPrefs/{squadId}/{kind}").Handler(appHandler(app.methodSetNotificationPref))
+	rm.Methods("DELETE").Path("/users/{userId}/notificationPrefs/{squadId}/{kind}").Handler(appHandler(app.methodDeleteNotificationPref))
 
 	rm.Use(app.assertAuthWasChecked)
 }
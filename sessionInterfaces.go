@@ -2,19 +2,41 @@ package main
 
 import (
 	assist_db "assist/db"
+	"context"
 	"net/http"
 
-	"firebase.google.com/go/auth"
+	"github.com/gorilla/mux"
 )
 
 type SessionDataGetter interface {
 	getCurrentUserData(r *http.Request) *assist_db.UserData
 	getCurrentUserID(r *http.Request) string
-	getCurrentUserRecord(r *http.Request) (*auth.UserRecord, error)
+	getCurrentUserRecord(r *http.Request) (*AuthUserRecord, error)
 }
 
 type SessionMiddleware interface {
 	authMiddleware(next http.Handler) http.Handler
-	sessionLogin(w http.ResponseWriter, r *http.Request) error
+	registerAuthHandlers(r *mux.Router)
 	sessionLogout(w http.ResponseWriter, r *http.Request) error
+	authProviders() []string
+}
+
+// AuthProvider verifies who the user is, the session itself is kept by
+// SessionUtil in a signed cookie once the provider has authenticated the user
+type AuthProvider interface {
+	name() string
+	// registers login handlers of the provider, they are not protected by session check
+	registerHandlers(r *mux.Router)
+	// returns user details as known to the provider
+	getUserRecord(ctx context.Context, userId string) (*AuthUserRecord, error)
+}
+
+// AuthUserRecord is provider independent user record
+type AuthUserRecord struct {
+	UID           string
+	DisplayName   string
+	Email         string
+	PhoneNumber   string
+	EmailVerified bool
+	Provider      string
 }
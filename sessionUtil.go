@@ -1,24 +1,50 @@
 package main
 
 import (
-	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 
 	assist_db "assist/db"
 
-	firebase "firebase.google.com/go"
-	"firebase.google.com/go/auth"
 	gorilla_context "github.com/gorilla/context"
+	"github.com/gorilla/mux"
 )
 
+const sessionCookie = "session"
+
+// Set session expiration to 14 days.
+const sessionExpiresIn = time.Hour * 24 * 14
+
 type SessionUtil struct {
-	authClient *auth.Client
-	db         *assist_db.FirestoreDB
-	dev        bool
+	db        assist_db.Store
+	dev       bool
+	secret    []byte
+	providers map[string]AuthProvider
+	order     []string
+}
+
+// content of the session cookie
+type sessionToken struct {
+	UserId   string `json:"uid"`
+	Provider string `json:"prv"`
+}
+
+// all signed values carry purpose, so that e.g. email verification token
+// could not be used as a session cookie
+type signedValue struct {
+	Purpose string          `json:"p"`
+	Expires int64           `json:"e"`
+	Value   json.RawMessage `json:"v"`
 }
 
 func TimeTrack(name string, start time.Time) {
@@ -27,142 +53,206 @@ func TimeTrack(name string, start time.Time) {
 	log.Println(fmt.Sprintf("%s took %s", name, elapsed))
 }
 
-// init firebase auth
-func initSessionUtil(fireapp *firebase.App, db *assist_db.FirestoreDB, dev bool) *SessionUtil {
-	ctx := context.Background()
+// init session util, auth providers are added by the caller
+func initSessionUtil(db assist_db.Store, dev bool) *SessionUtil {
+	secret := []byte(os.Getenv("SESSION_SECRET"))
+	if len(secret) == 0 {
+		if !dev {
+			log.Fatalf("SESSION_SECRET is not set")
+		}
+		log.Println("SESSION_SECRET is not set, using random one - sessions will not survive restart")
+		secret = []byte(randomToken(32))
+	}
 
-	authClient, err := fireapp.Auth(ctx)
-	if err != nil {
-		log.Fatalf("firebase.Auth: %v", err)
+	return &SessionUtil{
+		db:        db,
+		dev:       dev,
+		secret:    secret,
+		providers: make(map[string]AuthProvider),
 	}
-	mdlwr := SessionUtil{
-		authClient, db, dev}
+}
 
-	return &mdlwr
+func (su *SessionUtil) addProvider(p AuthProvider) {
+	su.providers[p.name()] = p
+	su.order = append(su.order, p.name())
 }
 
-func (su *SessionUtil) sessionLogin(w http.ResponseWriter, r *http.Request) error {
-	ctx := r.Context()
+func (su *SessionUtil) authProviders() []string {
+	return su.order
+}
 
-	// Get the tokens sent by the client
-	idToken := r.FormValue("idToken")
+func (su *SessionUtil) registerAuthHandlers(r *mux.Router) {
+	r.Methods("POST").Path("/sessionLogout").Handler(appHandler(su.sessionLogout))
 
-	// Decode the IDToken
-	decoded, err := su.authClient.VerifyIDToken(ctx, idToken)
-	if err != nil {
-		str := err.Error()
-		http.Error(w, str, http.StatusUnauthorized)
-		return err
+	for _, name := range su.order {
+		su.providers[name].registerHandlers(r)
 	}
-	time_now := time.Now().Unix()
-	claimed_auth_time := int64(decoded.Claims["auth_time"].(float64))
-	// Return error if the sign-in is older than 5 minutes.
-	if time_now-claimed_auth_time > 5*60 {
-		err = errors.New(fmt.Sprintf("Recent sign-in required, claimed_auth_time=%v, time_now=%v", time_now, claimed_auth_time))
-		http.Error(w, err.Error(), http.StatusUnauthorized)
-		return err
+}
+
+// returns random URL safe string built from n random bytes
+func randomToken(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		log.Panicf("Failed to generate random token: %v", err)
 	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
 
-	// Set session expiration to 14 days.
-	expiresIn := time.Hour * 24 * 14
+func (su *SessionUtil) mac(s string) string {
+	h := hmac.New(sha256.New, su.secret)
+	h.Write([]byte(s))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
 
-	// Create the session cookie. This will also verify the ID token in the process.
-	// The session cookie will have the same claims as the ID token.
-	// To only allow session cookie setting on recent sign-in, auth_time in ID token
-	// can be checked to ensure user was recently signed in before creating a session cookie.
-	cookie, err := su.authClient.SessionCookie(ctx, idToken, expiresIn)
+// returns value serialized to JSON and signed with the session secret
+func (su *SessionUtil) sign(purpose string, v interface{}, expiresIn time.Duration) (string, error) {
+	value, err := json.Marshal(v)
 	if err != nil {
-		err = errors.New("Failed to create a session cookie: " + err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return err
+		return "", err
 	}
 
-	// Set cookie policy for session cookie.
-	http.SetCookie(w, &http.Cookie{
-		Name:     "firebaseSession",
-		Value:    cookie,
-		MaxAge:   int(expiresIn.Seconds()),
-		HttpOnly: true,
-		SameSite: http.SameSiteStrictMode,
-		Secure:   !su.dev,
+	data, err := json.Marshal(&signedValue{
+		Purpose: purpose,
+		Expires: time.Now().Add(expiresIn).Unix(),
+		Value:   value,
 	})
-	w.Write([]byte(`{"status": "success"}`))
+	if err != nil {
+		return "", err
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + su.mac(payload), nil
+}
 
-	// Check if user exists in DB, add record otherwise
-	userId := decoded.UID
-	userRecord, err := su.authClient.GetUser(ctx, userId)
+// checks signature, purpose and expiration of the token and unmarshals its value into v
+func (su *SessionUtil) verify(purpose string, token string, v interface{}) error {
+	s := strings.Split(token, ".")
+	if len(s) != 2 || !hmac.Equal([]byte(s[1]), []byte(su.mac(s[0]))) {
+		return errors.New("Invalid signature")
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(s[0])
 	if err != nil {
-		return fmt.Errorf("Failed to get user record: %w", err)
+		return err
 	}
 
-	err = su.db.UpdateUserInfoFromFirebase(ctx, userRecord)
+	sv := &signedValue{}
+	if err = json.Unmarshal(data, sv); err != nil {
+		return err
+	}
+	if sv.Purpose != purpose {
+		return fmt.Errorf("Token is issued for %v, not %v", sv.Purpose, purpose)
+	}
+	if time.Now().Unix() > sv.Expires {
+		return errors.New("Token expired")
+	}
+
+	return json.Unmarshal(sv.Value, v)
+}
+
+// stores user info reported by the provider and sets session cookie
+func (su *SessionUtil) startSession(w http.ResponseWriter, r *http.Request, provider string, u *AuthUserRecord) error {
+	err := su.db.UpdateUserInfoFromAuth(r.Context(), u.UID, &assist_db.UserInfo{
+		DisplayName: u.DisplayName,
+		Email:       u.Email,
+		PhoneNumber: u.PhoneNumber,
+	})
 	if err != nil {
 		return fmt.Errorf("Failed to update user info: %w", err)
 	}
 
-	http.Redirect(w, r, "/home", http.StatusFound)
+	cookie, err := su.sign(sessionCookie, &sessionToken{
+		UserId:   u.UID,
+		Provider: provider,
+	}, sessionExpiresIn)
+	if err != nil {
+		return fmt.Errorf("Failed to create a session cookie: %w", err)
+	}
+
+	// Lax is required, user is redirected back to the app by OIDC provider
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    cookie,
+		Path:     "/",
+		MaxAge:   int(sessionExpiresIn.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   !su.dev,
+	})
+
 	return nil
 }
 
 func (su *SessionUtil) sessionLogout(w http.ResponseWriter, r *http.Request) error {
 	http.SetCookie(w, &http.Cookie{
-		Name:     "firebaseSession",
+		Name:     sessionCookie,
 		Value:    "",
-		SameSite: http.SameSiteStrictMode,
-		MaxAge:   0,
+		Path:     "/",
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   -1,
 	})
 
 	http.Redirect(w, r, "/login", http.StatusFound)
 	return nil
 }
 
+func (su *SessionUtil) getSessionToken(r *http.Request) (*sessionToken, error) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil, err
+	}
+
+	token := &sessionToken{}
+	if err = su.verify(sessionCookie, cookie.Value, token); err != nil {
+		return nil, err
+	}
+
+	if _, ok := su.providers[token.Provider]; !ok {
+		return nil, fmt.Errorf("Auth provider %v is not enabled", token.Provider)
+	}
+
+	return token, nil
+}
+
 func (su *SessionUtil) isSessionValid(w http.ResponseWriter, r *http.Request) bool {
 	if su.dev {
 		defer TimeTrack("isSessionValid "+r.URL.Path, time.Now())
 	}
+
+	// login handlers of auth providers and calendar feeds which check own token
+	if strings.HasPrefix(r.URL.Path, "/auth/") || strings.HasPrefix(r.URL.Path, "/methods/calendar/") {
+		return true
+	}
+
 	switch r.URL.Path {
 	case "/sessionLogin":
 	case "/sessionLogout":
 	case "/login":
-	case "/methods/archive":
 	default:
-		// Get the ID token sent by the client
-		cookie, err := r.Cookie("firebaseSession")
+		token, err := su.getSessionToken(r)
 		if err != nil {
 			if r.URL.Path != "/about" {
-				log.Print("Session cookie is unavailable. Force user to login.")
+				log.Printf("Session cookie is unavailable or invalid (%v). Force user to login.", err)
 				http.Redirect(w, r, "/login", http.StatusFound)
 				return false
 			}
 		} else {
-
-			// Check refoked token is quite expensive, do not do it for now
-			//decoded, err := su.authClient.VerifySessionCookieAndCheckRevoked(r.Context(), cookie.Value)
-			decoded, err := su.authClient.VerifySessionCookie(r.Context(), cookie.Value)
+			gorilla_context.Set(r, "SessionToken", token)
+			sd, err := su.db.GetUserData(r.Context(), token.UserId)
 			if err != nil {
 				if r.URL.Path != "/about" {
-					log.Print("Session cookie is invalid. Force user to login.")
-					http.Redirect(w, r, "/login", http.StatusFound)
+					err = errors.New("Failed to get user details: " + err.Error())
+					http.Error(w, err.Error(), http.StatusInternalServerError)
 					return false
 				}
 			} else {
-				gorilla_context.Set(r, "SessionToken", decoded)
-				sd, err := su.db.GetUserData(r.Context(), decoded.UID)
-				if err != nil {
-					if r.URL.Path != "/about" {
-						err = errors.New("Failed to get user details: " + err.Error())
-						http.Error(w, err.Error(), http.StatusInternalServerError)
+				gorilla_context.Set(r, "SessionData", sd)
+				if sd.Status == assist_db.PendingApprove {
+					if r.URL.Path != "/userinfo" {
+						log.Print("User is pending approve. Redirect to /userinfo")
+						http.Redirect(w, r, "/userinfo", http.StatusFound)
 						return false
 					}
-				} else {
-					gorilla_context.Set(r, "SessionData", sd)
-					if sd.Status == assist_db.PendingApprove {
-						if r.URL.Path != "/userinfo" {
-							log.Print("User is pending approve. Redirect to /userinfo")
-							http.Redirect(w, r, "/userinfo", http.StatusFound)
-							return false
-						}
-					}
 				}
 			}
 		}
@@ -182,10 +272,10 @@ func (su *SessionUtil) authMiddleware(next http.Handler) http.Handler {
 }
 
 func (su *SessionUtil) getCurrentUserID(r *http.Request) string {
-	sessionToken := gorilla_context.Get(r, "SessionToken")
+	token := gorilla_context.Get(r, "SessionToken")
 
-	if sessionToken != nil {
-		return sessionToken.(*auth.Token).UID
+	if token != nil {
+		return token.(*sessionToken).UserId
 	} else {
 		return ""
 	}
@@ -197,8 +287,32 @@ func (su *SessionUtil) getCurrentUserData(r *http.Request) *assist_db.UserData {
 	return sessionData.(*assist_db.UserData)
 }
 
-func (su *SessionUtil) getCurrentUserRecord(r *http.Request) (*auth.UserRecord, error) {
-	u, err := su.authClient.GetUser(r.Context(), su.getCurrentUserID(r))
+func (su *SessionUtil) getCurrentUserRecord(r *http.Request) (*AuthUserRecord, error) {
+	v := gorilla_context.Get(r, "SessionToken")
+	if v == nil {
+		return nil, errors.New("User is not logged in")
+	}
 
-	return u, err
+	token := v.(*sessionToken)
+	u, err := su.providers[token.Provider].getUserRecord(r.Context(), token.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	u.Provider = token.Provider
+	return u, nil
+}
+
+// returns URL of the app as seen by the client, used to build links sent by email
+// and redirect URLs
+func (su *SessionUtil) baseURL(r *http.Request) string {
+	if url := os.Getenv("APP_URL"); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+
+	scheme := "https"
+	if su.dev {
+		scheme = "http"
+	}
+	return scheme + "://" + r.Host
 }
@@ -47,6 +47,10 @@ const globalMixin = {
 					return "Attended";
 				case 4:
 					return "No-show";
+				case 5:
+					return "Owner";
+				case 6:
+					return "Waitlisted";
 			};
 		},
 		getStatusText : function(status) {
@@ -108,7 +112,7 @@ const notificationsToast = createApp( {
 		// Load notifications
 		axios({
 			method: 'GET',
-			url: `/methods/users/me/notifications`,
+			url: `/methods/users/me/notifications?unread=true`,
 		})
 		.then( res => {
 			this.notifications = res.data;
@@ -119,6 +123,18 @@ const notificationsToast = createApp( {
 			var t = new Date(time);
 			return t.toLocaleString(undefined);
 		},
+		// link to the page of the squad, event or request notification is about
+		linkUrl : function(n) {
+			var link = n.link || (n.linkType ? {type: n.linkType, id: n.linkId} : null);
+			if(link == null)
+				return null;
+			switch(link.type) {
+				case 'squad': return '/squads/' + encodeURIComponent(link.id);
+				case 'event': return '/events';
+				case 'request': return '/requests';
+			}
+			return null;
+		},
 		addNotification : function(n) {
 			if(this.notifications != null)
 				this.notifications.push(n);
@@ -148,11 +164,16 @@ const navbar = createApp( {
 		return {
 			notificationsCount: notificationsCount,
 			notificationsEnabled: localStorage.getItem('notificationsEnabled'),
+			firebaseEnabled: firebaseEnabled,
 			messaging: {},
 		}
 	},
 	delimiters: ['[[', ']]'],
 	created:function() {
+		// push notifications are available only with firebase
+		if (!firebaseEnabled)
+			return;
+
 		// Initialize Firebase
 		firebase.initializeApp(firebaseConfig);
 		firebase.analytics();
@@ -211,9 +232,13 @@ const navbar = createApp( {
 					.catch( err => {
 						console.log('Failed to delete FCM token: ', err);
 					});
+					localStorage.removeItem('messagingTokenSent');
 					axios({
 						method: 'DELETE',
 						url: `/methods/users/me/notifications`,
+						data: {
+							token: currentToken,
+						},
 						headers: { "X-CSRF-Token": csrfToken },
 					})
 					.catch( err => {
@@ -230,7 +255,9 @@ const navbar = createApp( {
 			.then((currentToken) => {
 				if (currentToken) {
 					this.notificationsEnabled = true;
-					if (messagingToken != currentToken) {
+					// token is sent again once a day, so backend knows the device is still in use
+					var sent = JSON.parse(localStorage.getItem('messagingTokenSent') || '{}');
+					if (sent.token != currentToken || sent.userId != userId || Date.now() - sent.time > 24 * 60 * 60 * 1000) {
 						if(devMode)
 							console.log('Subscribing to firebase cloud messaging notifications with token ' + currentToken);
 						axios({
@@ -242,6 +269,7 @@ const navbar = createApp( {
 							headers: { "X-CSRF-Token": csrfToken },
 						})
 						.then( res => {
+							localStorage.setItem('messagingTokenSent', JSON.stringify({token: currentToken, userId: userId, time: Date.now()}));
 							this.catchMessages(this.messaging);	
 						});
 					} else {
@@ -8,17 +8,64 @@ const AddEventDialog = {
 			type: Object,
 			default: () => ({})
 		},
+		// editing existing event allows to change date, time and text only
+		edit: Boolean,
 	},
 	emits: ["submit-form"],
+	data() {
+		return {
+			venues: [],
+			templates: [],
+		}
+	},
+	watch: {
+		'evnt.squadId': function(squadId) {
+			this.venues = [];
+			this.templates = [];
+			if(squadId) {
+				axios.get(`/methods/squads/${encodeURIComponent(squadId)}/venues`)
+				.then(res => {
+					this.venues = res.data;
+				})
+				.catch(err => console.log("Failed to get squad venues: " + err));
+				if(!this.edit) {
+					axios.get(`/methods/squads/${encodeURIComponent(squadId)}/eventTemplates`)
+					.then(res => {
+						this.templates = res.data;
+					})
+					.catch(err => console.log("Failed to get squad event templates: " + err));
+				}
+			}
+		},
+	},
 	methods: {
 		onSubmit:function() {
 			this.$emit('submit-form', this.evnt);
 		},
+		pickVenue:function(v) {
+			// event keeps its own copy of the venue
+			this.evnt.location = {venue: v.venue, address: v.address, lat: v.lat, lon: v.lon, meetingUrl: v.meetingUrl};
+		},
+		pickTemplate:function(t) {
+			// members having template tags are registered on the server
+			this.evnt.templateId = t.id;
+			this.evnt.text = t.text;
+			this.evnt.timeFrom = t.timeFrom;
+			this.evnt.timeTo = t.timeTo;
+			this.evnt.capacity = t.capacity;
+			this.evnt.reminders = (t.reminders || []).slice();
+			if(t.location) {
+				this.pickVenue(t.location);
+			}
+		},
 	},
 	computed: {
 		descriptionNotComplete : function() {
 			return this.evnt.date == null || this.evnt.date == "" || this.evnt.text == null || this.evnt.text == "" || this.evnt.squadId == null || this.evnt.squadId == "";
-		}
+		},
+		repeatUnit : function() {
+			return {DAILY: "day(s)", WEEKLY: "week(s)", MONTHLY: "month(s)"}[this.evnt.repeat];
+		},
 	},
     template:  `
 	<div class="modal fade" :id="windowId" tabindex="-1" role="dialog">
@@ -32,6 +79,10 @@ const AddEventDialog = {
 				</div>
 				<form>
 					<div class="modal-body">
+						<div v-if="!edit && templates.length > 0" class="mb-2">
+							<small>Templates: </small>
+							<a v-for="t in templates" href="#" class="badge mr-1" :class="evnt.templateId == t.id ? 'badge-primary' : 'badge-light'" @click.prevent="pickTemplate(t)">[[t.name]]</a>
+						</div>
 						<div class="row">
 							<div class="col-5 form-group">
 								<label for="dateTitle">Date</label>
@@ -54,15 +105,73 @@ const AddEventDialog = {
 							<label for="eventText">Description</label>
 							<textarea id="eventText" class="form-control" v-model="evnt.text"></textarea>
 						</div>
-						<div class="form-group">
+						<div v-if="evnt.location" class="form-group">
+							<label for="eventVenue">Location</label>
+							<div v-if="venues.length > 0" class="mb-1">
+								<small>Saved venues: </small>
+								<a v-for="v in venues" href="#" class="badge badge-light mr-1" @click.prevent="pickVenue(v)">[[v.venue]]</a>
+							</div>
+							<input type="text" id="eventVenue" class="input-sm form-control mb-1" placeholder="Venue" v-model="evnt.location.venue">
+							<input type="text" id="eventAddress" class="input-sm form-control mb-1" placeholder="Address" v-model="evnt.location.address">
+							<input type="url" id="eventMeetingUrl" class="input-sm form-control" placeholder="Online meeting link" v-model="evnt.location.meetingUrl">
+						</div>
+						<div v-if="!edit" class="form-group">
 							<label for="evenSquad">Squad</label>
 							<select id="eventSquad" class="form-control" v-model="evnt.squadId">
 								<option v-for="squad in squads" :value="squad.id">[[squad.id]]</option>
 							</select>
 						</div>
+						<div v-if="!edit" class="form-group">
+							<label for="eventCapacity">Capacity (leave empty if unlimited)</label>
+							<input type="number" min="0" id="eventCapacity" class="input-sm form-control" v-model.number="evnt.capacity">
+						</div>
+						<div v-if="!edit" class="form-group">
+							<label>Remind participants</label>
+							<div>
+								<div class="form-check form-check-inline">
+									<input class="form-check-input" type="checkbox" id="eventReminderDay" :value="1440" v-model="evnt.reminders">
+									<label class="form-check-label" for="eventReminderDay">1 day before</label>
+								</div>
+								<div class="form-check form-check-inline">
+									<input class="form-check-input" type="checkbox" id="eventReminderHour" :value="60" v-model="evnt.reminders">
+									<label class="form-check-label" for="eventReminderHour">1 hour before</label>
+								</div>
+							</div>
+						</div>
+						<div v-if="!edit" class="form-group">
+							<label for="eventRepeat">Repeat</label>
+							<div class="row">
+								<div class="col-5">
+									<select id="eventRepeat" class="form-control" v-model="evnt.repeat">
+										<option :value="undefined">Does not repeat</option>
+										<option value="DAILY">Daily</option>
+										<option value="WEEKLY">Weekly</option>
+										<option value="MONTHLY">Monthly</option>
+									</select>
+								</div>
+								<div v-if="evnt.repeat" class="col-7 d-flex align-items-center">
+									<span class="mr-2">every</span>
+									<input type="number" min="1" class="input-sm form-control w-25 mr-2" v-model.number="evnt.repeatInterval" placeholder="1">
+									<span>[[repeatUnit]]</span>
+								</div>
+							</div>
+						</div>
+						<div v-if="!edit && evnt.repeat" class="form-group">
+							<label for="eventRepeatUntil">Ends (leave empty to repeat until cancelled)</label>
+							<div class="row">
+								<div class="col-6">
+									<input type="date" id="eventRepeatUntil" class="input-sm form-control" v-model="evnt.repeatUntil" :disabled="evnt.repeatCount > 0">
+								</div>
+								<div class="col-6 d-flex align-items-center">
+									<span class="mr-2">or after</span>
+									<input type="number" min="1" class="input-sm form-control w-50 mr-2" v-model.number="evnt.repeatCount" :disabled="evnt.repeatUntil != null && evnt.repeatUntil != ''">
+									<span>times</span>
+								</div>
+							</div>
+						</div>
 					</div>
 					<div class="modal-footer">
-						<button type="submit" class="btn btn-primary" v-on:click="onSubmit()" data-dismiss="modal" :disabled="descriptionNotComplete">Add</button>
+						<button type="submit" class="btn btn-primary" v-on:click="onSubmit()" data-dismiss="modal" :disabled="descriptionNotComplete">[[edit ? "Save" : "Add"]]</button>
 					</div>
 				</form>
 			</div>
@@ -23,12 +23,18 @@ const app = createApp( {
 			prevKeys:"",
 			loadingMore:false,
 			noMoreCandidates:false,
+			checkinToken:"",
+			checkin_message:"",
 		};
 	},
 	created:function() {
 		let uri = window.location.search.substring(1); 
 		let params = new URLSearchParams(uri);
 		this.filter.status = params.get("status");
+		// page is opened by scanning check-in code of the participant
+		if(params.get("checkin")) {
+			this.checkIn(params.get("checkin"));
+		}
 
 		axios.all([
 			axios.get(`/methods/events/${eventId}`),
@@ -51,7 +57,67 @@ const app = createApp( {
 		});
 	},
 	methods: {
-
+		checkIn:function(token) {
+			// scanned code might be the whole link
+			let i = token.indexOf("checkin=");
+			if(i >= 0) {
+				token = decodeURIComponent(token.substring(i + "checkin=".length));
+			}
+			axios({
+				method: 'POST',
+				url: `/methods/events/${eventId}/checkin`,
+				data: { token: token, },
+				headers: { "X-CSRF-Token": csrfToken },
+			})
+			.then( res => {
+				this.error_message = "";
+				this.checkinToken = "";
+				this.checkin_message = res.data.alreadyCheckedIn ? `${res.data.displayName} has already checked in` : `${res.data.displayName} checked in`;
+				let p = this.eventParticipants.find(p => p.id == res.data.userId);
+				if(p) {
+					p.status = res.data.status;
+				}
+			})
+			.catch(err => {
+				this.checkin_message = "";
+				this.error_message = "Check-in failed: " + this.getAxiosErrorMessage(err);
+			});
+		},
+		inviteByTag:function(tag) {
+			if(confirm(`Please confirm you want to register everyone with tag ${tag} for '${this.evnt.text}'`)) {
+				axios({
+					method: 'POST',
+					url: `/methods/events/${eventId}/participants`,
+					data: { tag: tag, status: 2, },
+					headers: { "X-CSRF-Token": csrfToken },
+				})
+				.then( res => {
+					this.checkin_message = `${res.data.registered.length} members registered`;
+					this.error_message = res.data.failures.map(f => `${f.userId}: ${f.error}`).join("; ");
+					this.onFilterChange({target: {id: "selectTag"}});
+				})
+				.catch(err => {
+					this.error_message = "Failed to invite members: " + this.getAxiosErrorMessage(err);
+				});
+			}
+		},
+		markNoShows:function() {
+			if(confirm(`Please confirm you want to mark everyone who is still going to '${this.evnt.text}' as no-show`)) {
+				axios({
+					method: 'POST',
+					url: `/methods/events/${eventId}/noShows`,
+					headers: { "X-CSRF-Token": csrfToken },
+				})
+				.then( res => {
+					this.error_message = "";
+					this.checkin_message = `${res.data.marked} participants marked as no-show`;
+					this.eventParticipants.forEach(p => { if(p.status == 2) p.status = 4; });
+				})
+				.catch(err => {
+					this.error_message = "Failed to mark no-shows: " + this.getAxiosErrorMessage(err);
+				});
+			}
+		},
 		changeStatus:function(member, index) {
 			this.participantToChange = member;
 			this.participantToChange.index = index;
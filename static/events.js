@@ -10,7 +10,8 @@ const app = createApp( {
 			loading:true,
 			showArchived:false,
 			userIsAdmin: userIsAdmin,
-			newEvnt:{},
+			newEvnt:{reminders: [1440, 60], location: {}},
+			editEvnt:{location: {}},
 			squads:{},
 			events:[],
 			archivedEvents:null,
@@ -18,6 +19,8 @@ const app = createApp( {
 			moreRecordsAvailable:false,
 			moreArchivedRecordsAvailable:false,
 			getting_more:false,
+			calendarFeeds:null,
+			showCalendarFeeds:false,
 		}
 	},
 	created:function() {
@@ -37,9 +40,34 @@ const app = createApp( {
 		});
 	},
 	methods: {
+		getRRule:function(e) {
+			if(!e.repeat)
+				return undefined;
+			let rrule = "FREQ=" + e.repeat;
+			if(e.repeatInterval > 1)
+				rrule += ";INTERVAL=" + e.repeatInterval;
+			if(e.repeatCount > 0)
+				rrule += ";COUNT=" + e.repeatCount;
+			else if(e.repeatUntil)
+				rrule += ";UNTIL=" + e.repeatUntil.replaceAll("-", "");
+			return rrule;
+		},
+		reloadEvents:function() {
+			axios.get(`/methods/users/me/events`)
+			.then(res => {
+				this.events = res.data == null || res.data == "" ? [] : res.data.map(x => {x.date = new Date(x.date); return x});
+			})
+			.catch(err => {
+				this.error_message = "Failed to retrieve events: " + this.getAxiosErrorMessage(err);
+			});
+		},
 		addEvent:function(e) {
 			e = Object.assign({}, e);
 			e.date = new Date(e.date);
+			e.rrule = this.getRRule(e);
+			if(!e.capacity) {
+				delete e.capacity;
+			}
 			axios({
 				method: 'POST',
 				url: '/methods/events',
@@ -47,16 +75,54 @@ const app = createApp( {
 				headers: { "X-CSRF-Token": csrfToken },
 			})
 			.then( res => {
+				this.error_message = "";
+				this.newEvnt = {reminders: [1440, 60], location: {}};
+				if(res.data.seriesId) {
+					// occurrences of recurring event are created on the server
+					this.reloadEvents();
+					return;
+				}
 				e.id = res.data.id;
 				e.ownerId = currentUserId;
-				this.error_message = "";
 				this.events.push(e);
-				this.newEvnt = {};
 			})
 			.catch(err => {
 				this.error_message = "Error while adding new squad: " + this.getAxiosErrorMessage(err);
 			});
 		},
+		editEvent(e, i) {
+			this.editEvnt = {
+				index: i,
+				id: e.id,
+				squadId: e.squadId,
+				date: e.date.toISOString().substring(0, 10),
+				timeFrom: e.timeFrom,
+				timeTo: e.timeTo,
+				text: e.text,
+				location: Object.assign({}, e.location),
+			};
+			$('#editEventModal').modal('show');
+		},
+		updateEvent:function(e) {
+			axios({
+				method: 'PATCH',
+				url: '/methods/events/' + e.id,
+				data: {date: new Date(e.date), timeFrom: e.timeFrom, timeTo: e.timeTo, text: e.text, location: e.location},
+				headers: { "X-CSRF-Token": csrfToken },
+			})
+			.then( res => {
+				this.error_message = "";
+				let evnt = this.events[e.index];
+				evnt.date = new Date(res.data.date);
+				evnt.timeFrom = res.data.timeFrom;
+				evnt.timeTo = res.data.timeTo;
+				evnt.text = res.data.text;
+				evnt.location = res.data.location;
+			})
+			.catch(err => {
+				this.error_message = "Error while updating event " + e.id + ": " + this.getAxiosErrorMessage(err);
+			});
+		},
 		showAttendies(e, i) {
 			let url = `/events/` + e.id + `/participants`;
 			if (i != 0) {
@@ -65,7 +131,20 @@ const app = createApp( {
 			window.location.href = url;
 		},
 		deleteEvent(e, i) {
-			if(confirm(`Please confirm you really want to delete event ${e.text}, it will be impossible to rollback this operation.`)) {
+			if(e.seriesId && confirm(`Event ${e.text} is recurring. Press OK to cancel the whole series with all future occurrences, or Cancel to delete just this one.`)) {
+				axios({
+					method: 'DELETE',
+					url: '/methods/series/' + e.seriesId,
+					headers: { "X-CSRF-Token": csrfToken },
+				})
+				.then( res => {
+					this.error_message = "";
+					this.reloadEvents();
+				})
+				.catch(err => {
+					this.error_message = "Error while cancelling recurring event " + e.seriesId + ": " + this.getAxiosErrorMessage(err);
+				});
+			} else if(confirm(`Please confirm you really want to delete event ${e.text}, it will be impossible to rollback this operation.`)) {
 				axios({
 					method: 'DELETE',
 					url: '/methods/events/' + e.id,
@@ -83,13 +162,18 @@ const app = createApp( {
 		registerForEvent(e, i) {
 			if( !e.changingStatus ) {
 				e.changingStatus = true;
+				let series = e.seriesId != null && confirm(`Event ${e.text} is recurring. Do you want to register for all following occurrences as well?`);
 				axios({
 					method: 'POST',
-					url: `/methods/events/${e.id}/participants/me`,
+					url: `/methods/events/${e.id}/participants/me` + (series ? `?series=true` : ``),
 					headers: { "X-CSRF-Token": csrfToken },
 				})
 				.then( res => {
 					this.error_message = "";
+					if(series) {
+						this.reloadEvents();
+						return;
+					}
 					e.status = res.data.status;
 					let st = this.getEventStatusText(res.data.status).toLowerCase();
 					e[st] = 0 + e[st];
@@ -106,13 +190,23 @@ const app = createApp( {
 			if( !e.changingStatus ) {
 				if(confirm(`Please confirm you really want to decline event ${e.text}.`)) {
 					e.changingStatus = true;
+					let series = e.seriesId != null && confirm(`Do you want to decline all following occurrences of ${e.text} as well?`);
 					axios({
 						method: 'DELETE',
-						url: `/methods/events/${e.id}/participants/me`,
+						url: `/methods/events/${e.id}/participants/me` + (series ? `?series=true` : ``),
 						headers: { "X-CSRF-Token": csrfToken },
 					})
 					.then( res => {
 						this.error_message = "";
+						if(series) {
+							this.reloadEvents();
+							return;
+						}
+						if(e.capacity) {
+							// somebody could be promoted from the waitlist
+							this.reloadEvents();
+							return;
+						}
 						let st = this.getEventStatusText(e.status).toLowerCase();
 						e[st]--;
 						this.events[i].status = 0;
@@ -125,6 +219,38 @@ const app = createApp( {
 				}
 			}
 		},
+		toggleCalendarFeeds() {
+			this.showCalendarFeeds = !this.showCalendarFeeds;
+			if(this.calendarFeeds == null) {
+				axios.get(`/methods/users/me/calendars`)
+				.then(res => {
+					this.calendarFeeds = res.data;
+				})
+				.catch(err => {
+					this.error_message = "Failed to retrieve calendar feeds: " + this.getAxiosErrorMessage(err);
+				});
+			}
+		},
+		toggleCheckin(e) {
+			if(e.checkinQR) {
+				e.checkinQR = null;
+				return;
+			}
+			axios.get(`/methods/events/${e.id}/checkin`)
+			.then(res => {
+				// admin scanning the code opens event participants page which checks the participant in
+				let qr = qrcode(0, 'M');
+				qr.addData(window.location.origin + res.data.path);
+				qr.make();
+				e.checkinQR = qr.createDataURL(5);
+			})
+			.catch(err => {
+				this.error_message = "Failed to get check-in code: " + this.getAxiosErrorMessage(err);
+			});
+		},
+		getFeedUrl(path) {
+			return window.location.origin + path;
+		},
 		getParticipantsByStatus(e, i) {
 			return e[this.getEventStatusText(i).toLowerCase()];
 		},
@@ -4,16 +4,71 @@ const app = createApp( {
 		return {
 			loading:true,
 			error_message:"",
+			info_message:"",
 			fire_ui:[],
+			signup:false,
+			displayName:"",
+			email:"",
+			password:"",
 		};
 	},
 	created:function() {
-		// Initialize the FirebaseUI Widget using Firebase.
-		fire_ui = new firebaseui.auth.AuthUI(firebase.auth());
-		fire_ui.start('#firebaseui-auth-container', this.getUiConfig());
+		if (authProviders.firebase) {
+			// Initialize the FirebaseUI Widget using Firebase.
+			fire_ui = new firebaseui.auth.AuthUI(firebase.auth());
+			fire_ui.start('#firebaseui-auth-container', this.getUiConfig());
+		}
 		this.loading = false;
 	},
 	methods: {
+		postForm : function(url, params) {
+			return axios({
+				method: 'POST',
+				url: url,
+				data: params,
+				headers: { 
+					'Content-Type': 'application/x-www-form-urlencoded',
+					'X-CSRF-Token': csrfToken, },
+			})
+		},
+		submitLocal:function() {
+			this.loading = true;
+			this.error_message = "";
+			const params = new URLSearchParams();
+			params.append('email', this.email);
+			params.append('password', this.password);
+			if (this.signup)
+				params.append('displayName', this.displayName);
+
+			this.postForm(this.signup ? '/auth/local/signup' : '/auth/local/login', params)
+				.then(() => {
+					if (this.signup) {
+						this.loading = false;
+						this.signup = false;
+						this.info_message = "Please follow the link sent to " + this.email + " to verify your email, then sign in";
+					} else {
+						window.location.assign('/home');
+					}
+				}, error => {
+					this.loading = false;
+					this.error_message = "Failed to login - " + this.getAxiosErrorMessage(error);
+				});
+		},
+		resendVerification:function() {
+			this.error_message = "";
+			if (this.email.length == 0) {
+				this.error_message = "Please enter your email";
+				return;
+			}
+			const params = new URLSearchParams();
+			params.append('email', this.email);
+			this.postForm('/auth/local/verify', params)
+				.then(() => {
+					this.info_message = "Verification email is sent to " + this.email;
+				}, error => {
+					this.error_message = "Failed to send verification email - " + this.getAxiosErrorMessage(error);
+				});
+		},
 		handleSignedInUser:function(user) {
 
 			this.loading = true;
@@ -21,27 +76,18 @@ const app = createApp( {
 			user.getIdToken().then(idToken => {
 				// Session login endpoint is queried and the session cookie is set.
 				// CSRF token should be sent along with request.
-				return this.postIdTokenToSessionLogin('/sessionLogin', idToken)
+				const params = new URLSearchParams();
+				params.append('idToken', idToken);
+				return this.postForm('/sessionLogin', params)
 					.then(function() {
 						// Redirect to profile on success.
 						window.location.assign('/home');
-					}, function(error) {
+					}, error => {
+						this.loading = false;
 						this.error_message = ("Failed to login - " + error);
 					});
 			});
 		},
-		postIdTokenToSessionLogin : function(url, idToken) {
-			const params = new URLSearchParams();
-			params.append('idToken', idToken);
-			return axios({
-				method: 'POST',
-				url: url,
-				data: params,
-				headers: { 
-					'Content-Type': 'application/x-www-form-urlencoded',
-					'X-CSRF-Token': csrfToken, },
-			})
-		},
 		getUiConfig : function() {
 			return {
 				'callbacks': {
@@ -71,5 +117,5 @@ const app = createApp( {
 			};
 		},
 	},
+	mixins: [globalMixin],
 }).mount("#app");
-
@@ -8,9 +8,17 @@ const app = createApp( {
 			getting_more:false,
 			queues:{},
 			requests:{},
-			filter:{},
+			filter:{fields:{}},
+			filterFields:[],
 			moreRequestsAvailable:{},
-			newRequest:{},
+			newRequest:{fields:{}},
+			newRequestFields:[],
+			pendingAction:{},
+			history:null,
+			commentsRequest:null,
+			comments:null,
+			newComment:"",
+			currentUserId:userId,
 		};
 	},
 	computed: {
@@ -60,6 +68,63 @@ const app = createApp( {
 		},
 		setMode : function(mode) {
 			this.mode = mode;
+			if(this.filter.queue != null && this.filter.queue != "")
+				this.onFilterChange();
+		},
+		getQueueFields:function(queueId) {
+			return axios({
+				method: 'GET',
+				url: `/methods/queues/${encodeURIComponent(queueId)}`,
+			})
+			.then(res => res.data.fields || [])
+			.catch(err => {
+				this.error_message = "Failed to retrieve queue details: " + this.getAxiosErrorMessage(err);
+				return [];
+			});
+		},
+		onNewRequestQueueChange:function() {
+			this.newRequest.fields = {};
+			this.newRequestFields = [];
+			if(this.newRequest.queueId != null && this.newRequest.queueId != "") {
+				this.getQueueFields(this.newRequest.queueId).then(fields => { this.newRequestFields = fields; });
+			}
+		},
+		newRequestFieldsFilled:function() {
+			return this.newRequestFields.every(f => {
+				let v = this.newRequest.fields[f.name];
+				return !f.required || (v != null && v !== "" && !(Array.isArray(v) && v.length == 0));
+			});
+		},
+		getFilterQuery:function() {
+			let query = "";
+			if(this.filter.queue != null && this.filter.queue != "") {
+				query += `&queue=${encodeURIComponent(this.filter.queue)}`;
+				for(const [name, value] of Object.entries(this.filter.fields)) {
+					if(value != null && value !== "")
+						query += `&field.${name}=${encodeURIComponent(value)}`;
+				}
+			}
+			return query;
+		},
+		onFilterChange:function(e) {
+			if(e != null && e.target.id == "selectQueue") {
+				this.filter.fields = {};
+				this.filterFields = [];
+				if(this.filter.queue != null && this.filter.queue != "")
+					this.getQueueFields(this.filter.queue).then(fields => { this.filterFields = fields; });
+			}
+
+			axios({
+				method: 'GET',
+				url: `/methods/requests?status=${this.mode}` + this.getFilterQuery(),
+			})
+			.then(res => {
+				this.requests[this.mode] = res.data.map(x => {x.timeFrom = this.getDurationFrom(new Date(x.time)); return x;});
+				this.moreRequestsAvailable[this.mode] = res.data.length == 10;
+			})
+			.catch(err => {
+				this.error_message = "Failed to retrieve requests: " + this.getAxiosErrorMessage(err);
+			});
 		},
 		getMore:function() {
 			this.getting_more = true;
@@ -68,7 +133,7 @@ const app = createApp( {
 			var url = "requests";
 			axios({
 				method: 'GET',
-				url: `/methods/${url}?from=${lastMember.time}&status=${this.mode}`,
+				url: `/methods/${url}?from=${lastMember.time}&status=${this.mode}` + this.getFilterQuery(),
 			})
 			.then(res => {
 				let moreRequests = res.data.map(x => {x.timeFrom = this.getDurationFrom(new Date(x.time)); return x;});
@@ -83,6 +148,10 @@ const app = createApp( {
 		},
 		createRequest:function() {
 			let request = this.newRequest;
+			for(const [name, value] of Object.entries(request.fields)) {
+				if(value === "" || value == null)
+					delete request.fields[name];
+			}
 
 			if(request.queueId != null && request.queueId.length > 0) {
 				axios({
@@ -92,12 +161,15 @@ const app = createApp( {
 					headers: { "X-CSRF-Token": csrfToken },
 				})
 				.then(res => {
-					request.id = res.data.requestId;
+					request.requestId = res.data.requestId;
 					request.status = res.data.status;
+					request.state = res.data.state;
+					request.actions = res.data.actions;
 					request.timeFrom = "Just added";
 					this.requests["User"].unshift(request); 
 
-					this.newRequest = {};
+					this.newRequest = {fields:{}};
+					this.newRequestFields = [];
 				})
 				.catch(err => {
 					this.error_message = "Failed to create request: " + this.getAxiosErrorMessage(err);
@@ -129,15 +201,133 @@ const app = createApp( {
 
 			return text;
 		},
-		setRequestStatus:function(request, index, status) {
+		getRequestStateText:function(request) {
+			// states of the default workflow are named after statuses
+			if(request.state == null || request.state == "" || request.state == Object.keys(this.requestStatusesEnum)[request.status])
+				return this.getRequestStatusText(request.status);
+			return request.state;
+		},
+		getActionIcon:function(action) {
+			switch(action.name) {
+				case "Approve":
+				case "Complete":
+					return "fas fa-check-circle";
+				case "Decline":
+					return "fas fa-ban";
+				case "Cancel":
+					return "fas fa-times-circle";
+			}
+			return "fas fa-arrow-circle-right";
+		},
+		selectAction:function(request, index, action) {
+			this.pendingAction = {request:request, index:index, action:action, comment:""};
+		},
+		confirmAction:function() {
+			let a = this.pendingAction;
+			this.setRequestState(a.request, a.index, a.action.to, a.comment);
+		},
+		claimRequest:function(request) {
+			axios({
+				method: 'PUT',
+				url: `/methods/requests/${request.requestId}/assignee`,
+				data: {assigneeId:"me"},
+				headers: { "X-CSRF-Token": csrfToken },
+			})
+			.then(res => {
+				request.assigneeId = res.data.assigneeId;
+				request.assigneeName = res.data.assigneeName;
+			})
+			.catch(err => {
+				this.error_message = "Failed to claim request: " + this.getAxiosErrorMessage(err);
+			});
+		},
+		unclaimRequest:function(request) {
+			axios({
+				method: 'DELETE',
+				url: `/methods/requests/${request.requestId}/assignee`,
+				headers: { "X-CSRF-Token": csrfToken },
+			})
+			.then(res => {
+				request.assigneeId = "";
+				request.assigneeName = "";
+			})
+			.catch(err => {
+				this.error_message = "Failed to unclaim request: " + this.getAxiosErrorMessage(err);
+			});
+		},
+		getHistory:function(request) {
+			this.history = null;
+			axios({
+				method: 'GET',
+				url: `/methods/requests/${request.requestId}/history`,
+			})
+			.then( res => {
+				this.history = res.data;
+			})
+			.catch(err => {
+				this.history = [];
+				this.error_message = "Failed to retrieve request history: " + this.getAxiosErrorMessage(err);
+			});
+		},
+		getComments:function(request) {
+			this.commentsRequest = request;
+			this.comments = null;
+			this.newComment = "";
+			axios({
+				method: 'GET',
+				url: `/methods/requests/${request.requestId}/comments`,
+			})
+			.then( res => {
+				this.comments = res.data;
+			})
+			.catch(err => {
+				this.comments = [];
+				this.error_message = "Failed to retrieve request comments: " + this.getAxiosErrorMessage(err);
+			});
+		},
+		addComment:function() {
+			let comment = {text: this.newComment.trim()};
+			axios({
+				method: 'POST',
+				url: `/methods/requests/${this.commentsRequest.requestId}/comments`,
+				data: comment,
+				headers: { "X-CSRF-Token": csrfToken },
+			})
+			.then( res => {
+				comment.id = res.data.id;
+				comment.userId = userId;
+				comment.userName = "You";
+				comment.time = new Date();
+				this.comments.push(comment);
+				this.newComment = "";
+			})
+			.catch(err => {
+				this.error_message = "Failed to add comment: " + this.getAxiosErrorMessage(err);
+			});
+		},
+		deleteComment:function(index) {
+			axios({
+				method: 'DELETE',
+				url: `/methods/requests/${this.commentsRequest.requestId}/comments/${this.comments[index].id}`,
+				headers: { "X-CSRF-Token": csrfToken },
+			})
+			.then( res => {
+				this.comments.splice(index, 1);
+			})
+			.catch(err => {
+				this.error_message = "Failed to delete comment: " + this.getAxiosErrorMessage(err);
+			});
+		},
+		setRequestState:function(request, index, state, comment) {
 			axios({
 				method: 'PUT',
 				url: `/methods/requests/${request.requestId}`,
-				data: { status : status},
+				data: { state : state, comment : comment},
 				headers: { "X-CSRF-Token": csrfToken },
 			})
 			.then( res => {
-				this.getRequests()[index].status = status;
+				this.getRequests()[index].status = res.data.status;
+				this.getRequests()[index].state = res.data.state;
 				this.getRequests()[index].modified = true;
 				this.error_message = "";
 			})
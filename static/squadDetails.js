@@ -17,7 +17,16 @@ const app = createApp( {
 			noteToEdit:{},
 			noteNew:{},
 			newQueue:{},
+			newQueueSLA:{},
+			newQueueFields:[],
 			queues:[],
+			venues:[],
+			newVenue:{},
+			webhooks:[],
+			webhookEvents:["member.joined", "member.status", "event.created", "participant.registered", "request.created", "request.status"],
+			newWebhook:{events:[]},
+			newWebhookSecret:"",
+			deliveries:{},
 		};
 	},
 	created:function() {
@@ -26,12 +35,16 @@ const app = createApp( {
 			axios.get(`/methods/squads/${squadId}/notes`),
 			axios.get(`/methods/squads/${squadId}/tags`),
 			axios.get(`/methods/squads/${squadId}/queues`),
+			axios.get(`/methods/squads/${squadId}/venues`),
+			axios.get(`/methods/squads/${squadId}/webhooks`),
 		])
-		.then(axios.spread((squad,notes, tags, queues) => {
+		.then(axios.spread((squad,notes, tags, queues, venues, webhooks) => {
 			this.squad = squad.data;
 			this.notes = notes.data;
 			this.tags = tags.data;
 			this.queues = queues.data;
+			this.venues = venues.data;
+			this.webhooks = webhooks.data;
 			this.loading = false;
 		}))
 		.catch(errors => {
@@ -50,6 +63,23 @@ const app = createApp( {
 			this.newQueue.id = this.newQueue.id.trim();
 			this.newQueue.requestsWaitingApprove = 0;
 			this.newQueue.requestsProcessing = 0;
+			if(this.newQueue.workflowText != null && this.newQueue.workflowText.trim().length > 0) {
+				try {
+					this.newQueue.workflow = JSON.parse(this.newQueue.workflowText);
+				} catch (error) {
+					this.error_message = "Queue workflow is not a valid JSON: " + error;
+					return;
+				}
+			}
+			this.newQueue.fields = this.newQueueFields.map(f => {
+				let field = {name:f.name, type:f.type, required:f.required};
+				if(f.optionsText != null && (f.type == "select" || f.type == "multiselect"))
+					field.options = f.optionsText.split(",").map(o => o.trim()).filter(o => o.length > 0);
+				return field;
+			});
+			if(this.newQueueSLA.approveMinutes > 0 || this.newQueueSLA.processMinutes > 0) {
+				this.newQueue.sla = Object.assign({}, this.newQueueSLA);
+			}
 
 			axios({
 				method: 'POST',
@@ -127,6 +157,55 @@ const app = createApp( {
 				this.error_message = "Error while saving note: " + this.getAxiosErrorMessage(err);
 			});
 		},
+		addVenue:function() {
+			axios({
+				method: 'POST',
+				url: `/methods/squads/${squadId}/venues`,
+				data: this.newVenue,
+				headers: { "X-CSRF-Token": csrfToken },
+			})
+			.then( res => {
+				this.error_message = "";
+				this.newVenue.id = res.data.id;
+				this.venues.push(this.newVenue);
+				this.newVenue = {};
+			})
+			.catch(err => {
+				this.error_message = "Error while adding venue: " + this.getAxiosErrorMessage(err);
+			});
+		},
+		addWebhook:function() {
+			axios({
+				method: 'POST',
+				url: `/methods/squads/${squadId}/webhooks`,
+				data: this.newWebhook,
+				headers: { "X-CSRF-Token": csrfToken },
+			})
+			.then( res => {
+				this.error_message = "";
+				this.newWebhook.id = res.data.id;
+				this.newWebhookSecret = res.data.secret;
+				this.webhooks.push(this.newWebhook);
+				this.newWebhook = {events:[]};
+			})
+			.catch(err => {
+				this.error_message = "Error while adding webhook: " + this.getAxiosErrorMessage(err);
+			});
+		},
+		toggleDeliveries:function(webhook) {
+			if(this.deliveries[webhook.id]) {
+				delete this.deliveries[webhook.id];
+				return;
+			}
+			axios.get(`/methods/squads/${squadId}/webhooks/${webhook.id}/deliveries`)
+			.then( res => {
+				this.error_message = "";
+				this.deliveries[webhook.id] = res.data;
+			})
+			.catch(err => {
+				this.error_message = "Error while getting webhook deliveries: " + this.getAxiosErrorMessage(err);
+			});
+		},
 		deleteObject:function(obj, id, index) {
 			if(confirm(`Please confirm you really want to delete ${obj} from squad ${id}`)) {
 				index = index;
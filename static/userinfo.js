@@ -16,9 +16,11 @@ const escapeHTML = function(unsafe) {
 	});
 };
 
+const firebaseAuth = authProvider == "firebase";
+
 const editInput = function(id) {
 	var input = document.getElementById(id);
-	var user = firebase.auth().currentUser;
+	var user = firebaseAuth ? firebase.auth().currentUser : null;
 	if(input.disabled) {
 		input.disabled = false;
 	} else {
@@ -34,7 +36,8 @@ const editInput = function(id) {
 						headers: { "X-CSRF-Token": csrfToken },
 					})
 					.then( function() {
-						user.updateProfile( {displayName: name})
+						if (user != null)
+							user.updateProfile( {displayName: name})
 					}, function(error) {
 						document.getElementById(id + 'Error').textContent = error.response.data;
 					})
@@ -185,6 +188,23 @@ const sendPasswordReset = function() {
 	
 const sendVerificationEmail = function(e) {
 	e.preventDefault();
+	if (!firebaseAuth) {
+		const params = new URLSearchParams();
+		params.append('email', sessionUserInfo.email);
+		axios({
+			method: 'POST',
+			url: '/auth/local/verify',
+			data: params,
+			headers: { "X-CSRF-Token": csrfToken },
+		})
+		.then( () => {
+			document.getElementById('emailError').textContent = 'Verification email sent. Please check your inbox and follow instructions.';
+		})
+		.catch( error => {
+			document.getElementById('emailError').textContent = "Failed to send verification email: " + error;
+		});
+		return;
+	}
 	user = firebase.auth().currentUser;
 	user.sendEmailVerification().then(
 		function() {
@@ -195,39 +215,148 @@ const sendVerificationEmail = function(e) {
 	});
 };
 
-// init appVerifier
-var appVerifier;
+const setEmailDigest = function(select) {
+	document.getElementById('emailDigestError').textContent = '';
+	axios({
+		method: 'PUT',
+		url: '/methods/users/me/notificationPrefs',
+		data: { emailDigest: select.value },
+		headers: { "X-CSRF-Token": csrfToken },
+	})
+	.catch( error => {
+		document.getElementById('emailDigestError').textContent = "Failed to save email notifications setting: " + error;
+	});
+};
+
+// kinds of notifications which could be configured per squad
+const notificationKinds = {
+	newEvent: 'New events',
+	eventChange: 'Event changes',
+	requestApprove: 'Requests to approve',
+	requestHandle: 'Requests to handle',
+	requestCompleted: 'Completed requests',
+	membership: 'Membership',
+};
+
+// options of the preference, "all" means there is no preference
+const notificationPrefOptions = {
+	all: { text: 'All channels' },
+	push: { text: 'Browser', channels: ['push'] },
+	email: { text: 'Email', channels: ['email'] },
+	inbox: { text: 'Inbox only', channels: [] },
+	muted: { text: 'Muted', muted: true },
+};
+
+const notificationPrefOption = function(pref) {
+	if (pref == null)
+		return 'all';
+	if (pref.muted)
+		return 'muted';
+	var channels = pref.channels || [];
+	if (channels.length == 0)
+		return 'inbox';
+	if (channels.length == 1)
+		return channels[0];
+	return 'all';
+};
+
+const setNotificationPref = function(select) {
+	document.getElementById('notificationPrefsError').textContent = '';
+	var path = '/methods/users/me/notificationPrefs/' + encodeURIComponent(select.dataset.squad) + '/' + select.dataset.kind;
+	var option = notificationPrefOptions[select.value];
+	axios({
+		method: select.value == 'all' ? 'DELETE' : 'PUT',
+		url: path,
+		data: { muted: option.muted == true, channels: option.channels || [] },
+		headers: { "X-CSRF-Token": csrfToken },
+	})
+	.catch( error => {
+		document.getElementById('notificationPrefsError').textContent = "Failed to save notification preference: " + error;
+	});
+};
+
+const renderNotificationPrefs = function(squadIds, prefs) {
+	var head = document.getElementById('notificationPrefsHead');
+	var row = head.insertRow();
+	row.insertCell().textContent = '';
+	for (var kind in notificationKinds)
+		row.insertCell().textContent = notificationKinds[kind];
+
+	var body = document.getElementById('notificationPrefsBody');
+	squadIds.forEach(squadId => {
+		var row = body.insertRow();
+		row.insertCell().textContent = squadId;
+		for (var kind in notificationKinds) {
+			var select = document.createElement('select');
+			select.className = 'form-control form-control-sm';
+			select.dataset.squad = squadId;
+			select.dataset.kind = kind;
+			for (var value in notificationPrefOptions)
+				select.add(new Option(notificationPrefOptions[value].text, value));
+			select.value = notificationPrefOption(prefs.find(p => p.squadId == squadId && p.kind == kind));
+			select.onchange = function() { setNotificationPref(this); };
+			row.insertCell().appendChild(select);
+		}
+	});
+};
+
 window.addEventListener('load', function() {
-	appVerifier = new firebase.auth.RecaptchaVerifier( "recaptcha", { size: "invisible" });
+	axios.all([
+		axios.get('/methods/users/me/notificationPrefs'),
+		axios.get('/methods/users/me/squads'),
+	])
+	.then(axios.spread((prefs, squads) => {
+		document.getElementById('emailDigest').value = prefs.data.emailDigest;
+		renderNotificationPrefs(Object.keys(squads.data).sort(), prefs.data.kinds || []);
+	}))
+	.catch( error => {
+		document.getElementById('emailDigestError').textContent = "Failed to load notification preferences: " + error;
+	});
 });
 
-firebase.auth().onAuthStateChanged(user => {
-	if (user) {
+if (firebaseAuth) {
+	// init appVerifier
+	var appVerifier;
+	window.addEventListener('load', function() {
+		appVerifier = new firebase.auth.RecaptchaVerifier( "recaptcha", { size: "invisible" });
+	});
 
-		// init user info settings
-		var inputs = document.getElementById('userInfo').getElementsByTagName('input');
-		for (var i=0; i<inputs.length; ++i) {
-			if(inputs[i].id != "role")
-				inputs[i].value = escapeHTML(user[inputs[i].id]);
-		}
+	firebase.auth().onAuthStateChanged(user => {
+		if (user) {
 
-		// init auth providers
-		var pd = user.providerData;
-		for(var i=0; i<pd.length; ++i) {
-			try {
-				//console.log(pd[i].providerId);
-				document.getElementById(pd[i].providerId).checked = true;
-			} catch (error) {
-				console.log("Error while processing provider " + pd[i].providerId + ": " + error);
+			// init user info settings
+			var inputs = document.getElementById('userInfo').getElementsByTagName('input');
+			for (var i=0; i<inputs.length; ++i) {
+				if(inputs[i].id != "role")
+					inputs[i].value = escapeHTML(user[inputs[i].id]);
 			}
-		}
-		if(pd.length == 1) {
-			try {
-				document.getElementById(pd[0].providerId).disabled = true;
-			} catch (error) {
-				console.log("Error while disabling provider " + pd[i].providerId + ": " + error);
+
+			// init auth providers
+			var pd = user.providerData;
+			for(var i=0; i<pd.length; ++i) {
+				try {
+					//console.log(pd[i].providerId);
+					document.getElementById(pd[i].providerId).checked = true;
+				} catch (error) {
+					console.log("Error while processing provider " + pd[i].providerId + ": " + error);
+				}
+			}
+			if(pd.length == 1) {
+				try {
+					document.getElementById(pd[0].providerId).disabled = true;
+				} catch (error) {
+					console.log("Error while disabling provider " + pd[i].providerId + ": " + error);
+				}
 			}
 		}
-	}
-});
-
+	});
+} else {
+	// user info is reported by auth provider on login
+	window.addEventListener('load', function() {
+		var inputs = document.getElementById('userInfo').getElementsByTagName('input');
+		for (var i=0; i<inputs.length; ++i) {
+			if(inputs[i].id != "role")
+				inputs[i].value = sessionUserInfo[inputs[i].id];
+		}
+	});
+}
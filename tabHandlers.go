@@ -120,11 +120,13 @@ func (app *App) userinfoHandler(w http.ResponseWriter, r *http.Request) error {
 		DisplayNameNotUnique bool
 		Role                 string
 		PendingApprove       bool
+		AuthProvider         string
 	}{
 
 		EmailVerified:     u.EmailVerified,
 		ContactInfoIssues: !u.EmailVerified || len(currentUserData.DisplayName) == 0,
 		Role:              currentUserData.Status.String(),
+		AuthProvider:      u.Provider,
 	}
 
 	if currentUserData.Status == assist_db.PendingApprove {
@@ -154,8 +156,17 @@ func (app *App) userinfoHandler(w http.ResponseWriter, r *http.Request) error {
 }
 
 func (app *App) loginHandler(w http.ResponseWriter, r *http.Request) error {
+	providers := Values{}
+	for _, p := range app.sm.authProviders() {
+		providers[p] = true
+	}
+
 	return loginTmpl.Execute(w, Values{
-		"CSRFTag": csrf.TemplateField(r),
-		"Session": nil,
+		"CSRFTag":       csrf.TemplateField(r),
+		"Session":       nil,
+		"Dev":           app.dev,
+		"Firebase":      app.fireapp != nil,
+		"AuthProviders": providers,
+		"OIDCName":      oidcName(),
 	})
 }
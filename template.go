@@ -67,8 +67,8 @@ func (tmpl *appTemplate) ExecuteWithSession(app *App, w http.ResponseWriter, r *
 	values["Session"] = userData
 	values["CSRFTag"] = csrf.TemplateField(r)
 	values["Dev"] = app.dev
+	values["Firebase"] = app.fireapp != nil
 	values["NotificationsCount"] = app.ntfs.GetNotificationsCount(userData.UID)
-	values["MessagingToken"] = app.ntfs.GetUserToken(userData.UID)
 
 	if err := tmpl.Execute(w, values); err != nil {
 		log.Panicf("could not write template: %v", err)
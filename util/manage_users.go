@@ -80,7 +80,11 @@ func (app *App) updateListOfUsers(ctx context.Context) {
 		}
 		for _, u := range users {
 			if u.UserRecord.EmailVerified {
-				app.db.UpdateUserInfoFromFirebase(ctx, u.UserRecord)
+				app.db.UpdateUserInfoFromAuth(ctx, u.UID, &db.UserInfo{
+					DisplayName: u.DisplayName,
+					Email:       u.Email,
+					PhoneNumber: u.PhoneNumber,
+				})
 			}
 
 		}
@@ -121,7 +125,7 @@ func (app *App) removeCollection(ctx context.Context, collection *firestore.Coll
 		}
 
 		if err != nil {
-			log.Fatal("Error while iterating through users: %w", err)
+			log.Fatalf("Error while iterating through users: %v", err)
 		}
 
 		doc.Ref.Delete(ctx)
@@ -139,7 +143,7 @@ func (app *App) flushSquadInfoFromUsers(ctx context.Context) {
 		}
 
 		if err != nil {
-			log.Fatal("Error while iterating through users: %w", err)
+			log.Fatalf("Error while iterating through users: %v", err)
 		}
 
 		log.Printf("Removing records about squads for user %v", doc.Ref.ID)
@@ -158,7 +162,7 @@ func (app *App) addTimestampIfMissing(ctx context.Context) {
 		}
 
 		if err != nil {
-			log.Fatal("Error while iterating through squads: %w", err)
+			log.Fatalf("Error while iterating through squads: %v", err)
 		}
 
 		squadId := doc.Ref.ID
@@ -172,7 +176,7 @@ func (app *App) addTimestampIfMissing(ctx context.Context) {
 			}
 
 			if err != nil {
-				log.Fatal("Error while iterating through squad %v members: %w", squadId, err)
+				log.Fatalf("Error while iterating through squad %v members: %v", squadId, err)
 			}
 
 			memberId := docMember.Ref.ID
@@ -200,7 +204,7 @@ func (app *App) populateSquadInfoToUsers(ctx context.Context) {
 		}
 
 		if err != nil {
-			log.Fatal("Error while iterating through squads: %w", err)
+			log.Fatalf("Error while iterating through squads: %v", err)
 		}
 
 		squadId := doc.Ref.ID
@@ -210,21 +214,21 @@ func (app *App) populateSquadInfoToUsers(ctx context.Context) {
 
 		squadInfo, err := app.db.GetSquad(ctx, squadId)
 		if err != nil {
-			log.Fatal("Error while getting squads info: %w", err)
+			log.Fatalf("Error while getting squads info: %v", err)
 		}
 
 		log.Printf("Restoring records about squad %v:", doc.Ref.ID)
 
 		// later size will be recalculated while updateUserInfoInSquads, now we just need to calculate replicants
 		log.Printf("Flushing squad %v size", doc.Ref.ID)
-		app.db.FlushSquadSize(ctx, squadId)
+		err = app.db.FlushSquadSize(ctx, squadId)
 		if err != nil {
-			log.Fatal("Error while flushing squad %v size: %w", squadId, err)
+			log.Fatalf("Error while flushing squad %v size: %v", squadId, err)
 		}
 
-		squadMembers, err := app.db.GetSquadMembers(ctx, squadId, "", nil)
+		squadMembers, err := app.db.GetSquadMembers(ctx, squadId, nil, nil)
 		if err != nil {
-			log.Fatal("Error while populating squads info to users: %w", err)
+			log.Fatalf("Error while populating squads info to users: %v", err)
 		}
 		for _, member := range squadMembers {
 			if member.Replicant == false {
@@ -234,7 +238,7 @@ func (app *App) populateSquadInfoToUsers(ctx context.Context) {
 				}
 				err := app.db.AddSquadRecordToMember(ctx, member.ID, squadId, memberSquadInfo)
 				if err != nil {
-					log.Fatal("Error while populating squads info to users: %w", err)
+					log.Fatalf("Error while populating squads info to users: %v", err)
 				}
 				log.Printf("\tmember : %v", member.ID)
 			}
@@ -244,9 +248,9 @@ func (app *App) populateSquadInfoToUsers(ctx context.Context) {
 
 func (app *App) updateUsersInfoInSquads(ctx context.Context) {
 
-	allUsers, err := app.db.GetSquadMembers(ctx, db.ALL_USERS_SQUAD, "", nil)
+	allUsers, err := app.db.GetSquadMembers(ctx, db.ALL_USERS_SQUAD, nil, nil)
 	if err != nil {
-		log.Fatal("Error while getting list of users: %w", err)
+		log.Fatalf("Error while getting list of users: %v", err)
 	}
 
 	for _, user := range allUsers {
@@ -254,7 +258,7 @@ func (app *App) updateUsersInfoInSquads(ctx context.Context) {
 		log.Printf("Updating user %v details in squads:\n", user.ID)
 		userSquads, err := app.db.GetUserSquadsMap(ctx, user.ID, "", false)
 		if err != nil {
-			log.Fatal("Error while getting user %v squads: %w", user.ID, err)
+			log.Fatalf("Error while getting user %v squads: %v", user.ID, err)
 		}
 		for squadId, squad := range userSquads {
 			log.Printf("\t%v\n", squadId)
@@ -278,7 +282,7 @@ func (app *App) rebuildKeys(ctx context.Context) {
 		}
 
 		if err != nil {
-			log.Fatal("Error while iterating through squads: %w", err)
+			log.Fatalf("Error while iterating through squads: %v", err)
 		}
 
 		squadId := docSquad.Ref.ID
@@ -309,6 +313,14 @@ func (app *App) rebuildKeys(ctx context.Context) {
 	}
 }
 
+func (app *App) recountEventCounters(ctx context.Context) {
+	fixed, err := app.db.RecountEventCounters(ctx)
+	if err != nil {
+		log.Fatalf("Error while recounting event counters: %v", err)
+	}
+	log.Printf("Fixed counters of %v events", fixed)
+}
+
 func (app *App) makeDBConsistent() {
 	ctx := context.Background()
 
@@ -327,6 +339,7 @@ func printUsage() {
 	setRole <uid> <name>    - expected roles - Member, Admin or empty ("") which will set user pending approve 
 	makeDBConsistent        - flush denormalized DB entries stored per user and recreate them from squads collection
 	rebuildKeys             - rebuild keys (which are used to search) for all squad members
+	recountEventCounters    - recalculate participant counters of all events
 `)
 
 }
@@ -352,6 +365,8 @@ func main() {
 			app.makeDBConsistent()
 		case "rebuildKeys":
 			app.rebuildKeys(ctx)
+		case "recountEventCounters":
+			app.recountEventCounters(ctx)
 		case "setRole":
 			app.setRole(args[1], args[2])
 		default:
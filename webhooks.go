@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	assist_db "assist/db"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// delays between attempts to deliver webhook payload, delivery fails when all of them are used
+var webhookBackoff = []time.Duration{time.Minute, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour, 12 * time.Hour}
+
+// private (RFC 1918, RFC 4193) and carrier-grade NAT networks, webhooks should
+// not reach services which are not exposed to the internet
+var privateNetworks = func() []*net.IPNet {
+	nets := make([]*net.IPNet, 0)
+	for _, cidr := range []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7"} {
+		_, n, _ := net.ParseCIDR(cidr)
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return false
+	}
+	for _, n := range privateNetworks {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Webhooks posts signed JSON payloads about squad activity to URLs registered by
+// squad admins, payloads are kept in the delivery log till they are delivered
+type Webhooks struct {
+	db     assist_db.Store
+	client *http.Client
+	dev    bool
+	// receivers with loopback and private addresses are allowed, used in tests
+	allowPrivate bool
+}
+
+func newWebhooks(db assist_db.Store, dev bool) *Webhooks {
+	wh := &Webhooks{db: db, dev: dev}
+
+	// address is checked once more when connecting, DNS could have changed
+	// since the webhook was registered
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network string, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); !wh.allowPrivate && (ip == nil || !isPublicIP(ip)) {
+				return fmt.Errorf("Webhook address %v is not public", host)
+			}
+			return nil
+		},
+	}
+	// no proxy, otherwise address of the proxy is checked instead of the receiver
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	wh.client = &http.Client{Timeout: 10 * time.Second, Transport: transport}
+
+	return wh
+}
+
+// checkHost resolves host of the webhook URL and returns error if any of its
+// addresses is loopback, private or link-local
+func (wh *Webhooks) checkHost(ctx context.Context, host string) error {
+	if wh.allowPrivate {
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("Failed to resolve webhook host %v: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if !isPublicIP(addr.IP) {
+			return fmt.Errorf("Webhook host %v resolves to address %v which is not public", host, addr.IP)
+		}
+	}
+
+	return nil
+}
+
+// webhookPayload is JSON posted to webhooks, Data depends on the event
+type webhookPayload struct {
+	Event   string      `json:"event"`
+	SquadId string      `json:"squadId"`
+	Time    time.Time   `json:"time"`
+	Data    interface{} `json:"data"`
+}
+
+type memberHookData struct {
+	UserId      string `json:"userId"`
+	DisplayName string `json:"displayName,omitempty"`
+	Status      string `json:"status"`
+}
+
+type eventHookData struct {
+	EventId  string     `json:"eventId,omitempty"`
+	SeriesId string     `json:"seriesId,omitempty"`
+	Text     string     `json:"text"`
+	Date     *time.Time `json:"date"`
+}
+
+type participantsHookData struct {
+	EventId string   `json:"eventId"`
+	UserIds []string `json:"userIds"`
+	Status  string   `json:"status"`
+}
+
+type requestHookData struct {
+	RequestId string `json:"requestId"`
+	QueueId   string `json:"queueId"`
+	UserId    string `json:"userId"`
+	Details   string `json:"details"`
+	State     string `json:"state"`
+	Status    string `json:"status"`
+}
+
+func newRequestHookData(requestId string, request *assist_db.RequestDetails) *requestHookData {
+	return &requestHookData{requestId, request.QueueId, request.UserId, request.Details, request.State, request.Status.String()}
+}
+
+// signPayload returns value of X-Assist-Signature header, HMAC SHA256 of the body
+func signPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// fire posts payload to webhooks of the squad subscribed to the event, failed
+// deliveries are retried later by deliverDue. Delivery is created due after
+// the first retry delay, so deliverDue does not pick it while it is attempted.
+func (wh *Webhooks) fire(squadId string, event string, data interface{}) {
+	ctx := context.Background()
+
+	hooks, err := wh.db.GetWebhooks(ctx, squadId)
+	if err != nil {
+		log.Printf("Failed to get squad %v webhooks, %v will not be delivered: %v", squadId, event, err)
+		return
+	}
+
+	var payload []byte
+	for _, hook := range hooks {
+		if !hook.HasEvent(event) {
+			continue
+		}
+
+		if payload == nil {
+			payload, err = json.Marshal(&webhookPayload{event, squadId, time.Now().UTC(), data})
+			if err != nil {
+				log.Printf("Failed to marshal %v webhook payload: %v", event, err)
+				return
+			}
+		}
+
+		now := time.Now()
+		delivery := &assist_db.WebhookDelivery{
+			WebhookId:   hook.ID,
+			SquadId:     squadId,
+			Event:       event,
+			Payload:     string(payload),
+			Status:      assist_db.DeliveryPending,
+			Created:     now,
+			NextAttempt: now.Add(webhookBackoff[0]),
+		}
+		deliveryId, err := wh.db.CreateWebhookDelivery(ctx, delivery)
+		if err != nil {
+			log.Println(err.Error())
+			continue
+		}
+
+		if err = wh.attempt(ctx, deliveryId, delivery, &hook.Webhook); err != nil {
+			log.Println(err.Error())
+		}
+	}
+}
+
+// attempt posts payload of the delivery and saves the outcome, delivery which
+// is not accepted is scheduled for the next attempt or failed after the last one
+func (wh *Webhooks) attempt(ctx context.Context, deliveryId string, delivery *assist_db.WebhookDelivery, hook *assist_db.Webhook) error {
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastAttempt = &now
+	delivery.ResponseCode = 0
+	delivery.Error = ""
+
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, "POST", hook.URL, bytes.NewReader(body))
+	if err == nil {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "Assist-Webhooks")
+		req.Header.Set("X-Assist-Event", delivery.Event)
+		req.Header.Set("X-Assist-Delivery", deliveryId)
+		req.Header.Set("X-Assist-Signature", signPayload(hook.Secret, body))
+
+		var res *http.Response
+		if res, err = wh.client.Do(req); err == nil {
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+			delivery.ResponseCode = res.StatusCode
+			if res.StatusCode < 200 || res.StatusCode > 299 {
+				err = fmt.Errorf("Webhook responded with %v", res.Status)
+			}
+		}
+	}
+
+	switch {
+	case err == nil:
+		delivery.Status = assist_db.DeliveryDelivered
+	case delivery.Attempts > len(webhookBackoff):
+		delivery.Status = assist_db.DeliveryFailed
+		delivery.Error = err.Error()
+	default:
+		delivery.Status = assist_db.DeliveryPending
+		delivery.NextAttempt = now.Add(webhookBackoff[delivery.Attempts-1])
+		delivery.Error = err.Error()
+	}
+
+	if wh.dev {
+		log.Printf("Webhook %v delivery %v of %v attempt %v: %v %v", delivery.WebhookId, deliveryId, delivery.Event, delivery.Attempts, delivery.Status, delivery.Error)
+	}
+
+	return wh.db.UpdateWebhookDelivery(ctx, deliveryId, delivery)
+}
+
+// deliverDue retries deliveries which next attempt has come, failure of one
+// delivery does not stop the others
+func (wh *Webhooks) deliverDue(ctx context.Context) error {
+	deliveries, err := wh.db.GetDueWebhookDeliveries(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	hooks := make(map[string]*assist_db.Webhook)
+	for _, d := range deliveries {
+		hook, ok := hooks[d.WebhookId]
+		if !ok {
+			hook, err = wh.db.GetWebhook(ctx, d.SquadId, d.WebhookId)
+			if err != nil && status.Code(err) != codes.NotFound {
+				// delivery stays pending and is retried next time
+				log.Printf("Failed to get webhook of delivery %v: %v", d.ID, err)
+				continue
+			}
+			hooks[d.WebhookId] = hook
+		}
+
+		// webhook is deleted, delivery could not be done anymore
+		if hook == nil {
+			d.Status = assist_db.DeliveryFailed
+			d.Error = "Webhook is not found"
+			if err = wh.db.UpdateWebhookDelivery(ctx, d.ID, &d.WebhookDelivery); err != nil {
+				log.Println(err.Error())
+			}
+			continue
+		}
+
+		if err = wh.attempt(ctx, d.ID, &d.WebhookDelivery, hook); err != nil {
+			log.Println(err.Error())
+		}
+	}
+
+	return nil
+}